@@ -13,13 +13,24 @@ import (
 	"github.com/duckmesh/duckmesh/internal/api"
 	"github.com/duckmesh/duckmesh/internal/api/uistatic"
 	"github.com/duckmesh/duckmesh/internal/auth"
+	"github.com/duckmesh/duckmesh/internal/bulkload"
+	"github.com/duckmesh/duckmesh/internal/bus"
+	busmemory "github.com/duckmesh/duckmesh/internal/bus/memory"
 	buspostgres "github.com/duckmesh/duckmesh/internal/bus/postgres"
 	catalogpostgres "github.com/duckmesh/duckmesh/internal/catalog/postgres"
 	"github.com/duckmesh/duckmesh/internal/config"
+	"github.com/duckmesh/duckmesh/internal/coordinator"
 	"github.com/duckmesh/duckmesh/internal/maintenance"
 	"github.com/duckmesh/duckmesh/internal/nl2sql"
+	"github.com/duckmesh/duckmesh/internal/notify"
+	notifypostgres "github.com/duckmesh/duckmesh/internal/notify/postgres"
 	"github.com/duckmesh/duckmesh/internal/observability"
+	"github.com/duckmesh/duckmesh/internal/query"
 	duckdbengine "github.com/duckmesh/duckmesh/internal/query/duckdb"
+	"github.com/duckmesh/duckmesh/internal/queryjob"
+	"github.com/duckmesh/duckmesh/internal/storage"
+	"github.com/duckmesh/duckmesh/internal/storage/cache"
+	memorystore "github.com/duckmesh/duckmesh/internal/storage/memory"
 	s3store "github.com/duckmesh/duckmesh/internal/storage/s3"
 )
 
@@ -31,6 +42,11 @@ func main() {
 	}
 
 	logger := observability.NewLogger(cfg, os.Stdout)
+	if (cfg.Bus.Backend == config.BusBackendMemory || cfg.ObjectStore.Backend == config.ObjectStoreBackendMemory) && !cfg.Coordinator.Embedded {
+		logger.Error("memory bus and object store backends require DUCKMESH_COORDINATOR_EMBEDDED=true")
+		os.Exit(1)
+	}
+
 	catalogDB, err := catalogpostgres.Open(context.Background(), catalogpostgres.DBConfig{
 		DSN:             cfg.Catalog.DSN,
 		MaxOpenConns:    cfg.Catalog.MaxOpenConns,
@@ -45,25 +61,58 @@ func main() {
 	defer func() { _ = catalogDB.Close() }()
 
 	catalogRepo := catalogpostgres.NewRepository(catalogDB)
-	ingestBus := buspostgres.NewIngestBus(catalogDB)
-	objectStore, err := s3store.New(context.Background(), s3store.Config{
-		Endpoint:         cfg.ObjectStore.Endpoint,
-		Region:           cfg.ObjectStore.Region,
-		Bucket:           cfg.ObjectStore.Bucket,
-		AccessKeyID:      cfg.ObjectStore.AccessKeyID,
-		SecretAccessKey:  cfg.ObjectStore.SecretAccessKey,
-		UseSSL:           cfg.ObjectStore.UseSSL,
-		Prefix:           cfg.ObjectStore.Prefix,
-		AutoCreateBucket: cfg.ObjectStore.AutoCreateBucket,
-	})
-	if err != nil {
-		logger.Error("failed to initialize object store", slog.Any("error", err))
-		os.Exit(1)
+	retryPolicy := bus.RetryPolicy{
+		MaxAttempts: cfg.Bus.MaxAttempts,
+		BaseBackoff: cfg.Bus.RetryBaseBackoff,
+		MaxBackoff:  cfg.Bus.RetryMaxBackoff,
+	}
+	var notifications *notify.Hub
+	if cfg.Catalog.NotifyEnabled {
+		notifications = notify.NewHub()
+	}
+	claimPolicy := bus.ClaimPolicy{
+		Fair:          cfg.Bus.ClaimFairness == config.ClaimFairnessRoundRobin,
+		TenantWeights: cfg.Bus.TenantWeights,
+	}
+	var ingestBus bus.IngestBus = buspostgres.NewIngestBus(catalogDB).WithRetryPolicy(retryPolicy).WithClaimPolicy(claimPolicy)
+	if cfg.Bus.Backend == config.BusBackendMemory {
+		memoryBus := busmemory.NewIngestBus().WithRetryPolicy(retryPolicy).WithClaimPolicy(claimPolicy)
+		if notifications != nil {
+			memoryBus = memoryBus.WithNotifier(notifications)
+		}
+		ingestBus = memoryBus
+	}
+	var objectStore storage.ObjectStore = memorystore.New()
+	if cfg.ObjectStore.Backend == config.ObjectStoreBackendS3 {
+		objectStore, err = s3store.New(context.Background(), s3store.Config{
+			Endpoint:         cfg.ObjectStore.Endpoint,
+			Region:           cfg.ObjectStore.Region,
+			Bucket:           cfg.ObjectStore.Bucket,
+			AccessKeyID:      cfg.ObjectStore.AccessKeyID,
+			SecretAccessKey:  cfg.ObjectStore.SecretAccessKey,
+			UseSSL:           cfg.ObjectStore.UseSSL,
+			Prefix:           cfg.ObjectStore.Prefix,
+			AutoCreateBucket: cfg.ObjectStore.AutoCreateBucket,
+		})
+		if err != nil {
+			logger.Error("failed to initialize object store", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+	var objectCache *cache.Cache
+	if cfg.ObjectCache.Dir != "" {
+		objectCache, err = cache.New(objectStore, cfg.ObjectCache.Dir, int64(cfg.ObjectCache.MaxBytes))
+		if err != nil {
+			logger.Error("failed to open object cache", slog.Any("error", err))
+			os.Exit(1)
+		}
 	}
 	queryEngine := duckdbengine.NewEngine(objectStore)
+	queryEngine.Cache = objectCache
 	maintenanceService := &maintenance.Service{
 		Catalog:     catalogRepo,
 		ObjectStore: objectStore,
+		Cache:       objectCache,
 		Config: maintenance.Config{
 			CompactionInterval:      cfg.Maintenance.CompactionInterval,
 			CompactionMinInputFiles: cfg.Maintenance.CompactionMinInputFiles,
@@ -91,20 +140,50 @@ func main() {
 		}
 	}
 
+	queryJobs := &queryjob.Service{
+		Catalog:     catalogRepo,
+		Exporter:    queryEngine,
+		ObjectStore: objectStore,
+		Config: queryjob.Config{
+			Workers:      cfg.QueryJobs.Workers,
+			PollInterval: cfg.QueryJobs.PollInterval,
+			Lease:        cfg.QueryJobs.Lease,
+			Timeout:      cfg.QueryJobs.Timeout,
+		},
+		Logger: logger,
+	}
+
+	queryPolicy := query.Policy{
+		AllowedFunctions:       cfg.Query.AllowedFunctions,
+		DeniedFunctions:        cfg.Query.DeniedFunctions,
+		TenantAllowedFunctions: cfg.Query.TenantAllowedFunctions,
+		TenantDeniedFunctions:  cfg.Query.TenantDeniedFunctions,
+	}
+
 	deps := api.Dependencies{
-		Logger:          logger,
-		CatalogRepo:     catalogRepo,
-		IngestBus:       ingestBus,
-		QueryEngine:     queryEngine,
-		Maintenance:     maintenanceService,
-		QueryTranslator: translator,
-		UISchemaSamples: cfg.UI.SchemaSampleRows,
-		UI:              uistatic.Handler(),
+		Logger:                   logger,
+		CatalogRepo:              catalogRepo,
+		IngestBus:                ingestBus,
+		QueryEngine:              queryEngine,
+		QueryPolicy:              queryPolicy,
+		Maintenance:              maintenanceService,
+		QueryTranslator:          translator,
+		UISchemaSamples:          cfg.UI.SchemaSampleRows,
+		IngestStreamChunkRecords: cfg.Ingest.StreamChunkRecords,
+		IngestStreamIdleTimeout:  cfg.Ingest.StreamIdleTimeout,
+		QueryStreamWriteTimeout:  cfg.HTTP.WriteTimeout,
+		BulkLoader:               &bulkload.Service{Catalog: catalogRepo, ObjectStore: objectStore},
+		QueryJobs:                queryJobs,
+		UI:                       uistatic.Handler(),
 		Readiness: api.CombineReadinessChecks(
 			catalogRepo.HealthCheck,
 			api.CheckObjectStoreConfig(cfg),
 		),
-		DependencyTimout: time.Second,
+		DependencyTimout:    time.Second,
+		BarrierFallbackPoll: cfg.Catalog.NotifyFallbackPoll,
+	}
+	if notifications != nil {
+		deps.SnapshotNotifications = notifications
 	}
 	if cfg.Auth.Required {
 		validator, err := auth.NewStaticAPIKeyValidator(cfg.Auth.StaticKeys)
@@ -127,6 +206,64 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	if notifications != nil {
+		// Memory bus publishes wake the embedded coordinator directly; only
+		// the Postgres bus needs the ingest channel from the catalog.
+		channels := []string{notify.ChannelSnapshot}
+		if cfg.Coordinator.Embedded && cfg.Bus.Backend == config.BusBackendPostgres {
+			channels = append(channels, notify.ChannelIngest)
+		}
+		listener := &notifypostgres.Listener{
+			DSN:      cfg.Catalog.DSN,
+			Channels: channels,
+			Hub:      notifications,
+			Logger:   logger,
+		}
+		go func() { _ = listener.Run(ctx) }()
+	}
+
+	coordinatorDone := make(chan struct{})
+	if cfg.Coordinator.Embedded {
+		coordinatorService := &coordinator.Service{
+			Bus:         ingestBus,
+			Publisher:   catalogRepo,
+			Tables:      catalogRepo,
+			ObjectStore: objectStore,
+			Config: coordinator.Config{
+				ConsumerID:           cfg.Coordinator.ConsumerID,
+				ClaimLimit:           cfg.Coordinator.ClaimLimit,
+				LeaseSeconds:         cfg.Coordinator.LeaseSeconds,
+				PollInterval:         cfg.Coordinator.PollInterval,
+				CreatedBy:            cfg.Coordinator.CreatedBy,
+				HeartbeatInterval:    cfg.Coordinator.HeartbeatInterval,
+				SweepInterval:        cfg.Coordinator.SweepInterval,
+				FallbackPollInterval: cfg.Catalog.NotifyFallbackPoll,
+				TargetFileRows:       cfg.Coordinator.TargetFileRows,
+				TargetFileBytes:      int64(cfg.Coordinator.TargetFileBytes),
+				MaxBatchDelay:        cfg.Coordinator.MaxBatchDelay,
+				Workers:              cfg.Coordinator.Workers,
+			},
+			Logger: logger,
+		}
+		if notifications != nil {
+			coordinatorService.Notifications = notifications
+		}
+		go func() {
+			defer close(coordinatorDone)
+			logger.Info("embedded coordinator started", slog.String("bus_backend", cfg.Bus.Backend), slog.String("object_store_backend", cfg.ObjectStore.Backend))
+			if err := coordinatorService.Run(ctx); err != nil {
+				logger.Error("embedded coordinator failed", slog.Any("error", err))
+				stop()
+			}
+		}()
+	} else {
+		close(coordinatorDone)
+	}
+
+	if cfg.QueryJobs.Workers > 0 {
+		go func() { _ = queryJobs.Run(ctx) }()
+	}
+
 	go func() {
 		logger.Info("starting api server", slog.String("addr", cfg.HTTP.Address))
 		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -145,4 +282,5 @@ func main() {
 		_ = server.Close()
 		os.Exit(1)
 	}
+	<-coordinatorDone
 }
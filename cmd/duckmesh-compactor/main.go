@@ -11,6 +11,7 @@ import (
 	"github.com/duckmesh/duckmesh/internal/config"
 	"github.com/duckmesh/duckmesh/internal/maintenance"
 	"github.com/duckmesh/duckmesh/internal/observability"
+	"github.com/duckmesh/duckmesh/internal/storage/cache"
 	s3store "github.com/duckmesh/duckmesh/internal/storage/s3"
 )
 
@@ -22,6 +23,11 @@ func main() {
 	}
 
 	logger := observability.NewLogger(cfg, os.Stdout)
+	if cfg.ObjectStore.Backend != config.ObjectStoreBackendS3 {
+		logger.Error("memory object store backend only runs inside duckmesh-api")
+		os.Exit(1)
+	}
+
 	db, err := catalogpostgres.Open(context.Background(), catalogpostgres.DBConfig{
 		DSN:             cfg.Catalog.DSN,
 		MaxOpenConns:    cfg.Catalog.MaxOpenConns,
@@ -50,9 +56,18 @@ func main() {
 		os.Exit(1)
 	}
 
+	var objectCache *cache.Cache
+	if cfg.ObjectCache.Dir != "" {
+		objectCache, err = cache.New(store, cfg.ObjectCache.Dir, int64(cfg.ObjectCache.MaxBytes))
+		if err != nil {
+			logger.Error("failed to open object cache", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
 	svc := &maintenance.Service{
 		Catalog:     catalogpostgres.NewRepository(db),
 		ObjectStore: store,
+		Cache:       objectCache,
 		Config: maintenance.Config{
 			CompactionInterval:      cfg.Maintenance.CompactionInterval,
 			CompactionMinInputFiles: cfg.Maintenance.CompactionMinInputFiles,
@@ -7,10 +7,13 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/duckmesh/duckmesh/internal/bus"
 	buspostgres "github.com/duckmesh/duckmesh/internal/bus/postgres"
 	catalogpostgres "github.com/duckmesh/duckmesh/internal/catalog/postgres"
 	"github.com/duckmesh/duckmesh/internal/config"
 	"github.com/duckmesh/duckmesh/internal/coordinator"
+	"github.com/duckmesh/duckmesh/internal/notify"
+	notifypostgres "github.com/duckmesh/duckmesh/internal/notify/postgres"
 	"github.com/duckmesh/duckmesh/internal/observability"
 	s3store "github.com/duckmesh/duckmesh/internal/storage/s3"
 )
@@ -23,6 +26,10 @@ func main() {
 	}
 
 	logger := observability.NewLogger(cfg, os.Stdout)
+	if cfg.Bus.Backend != config.BusBackendPostgres || cfg.ObjectStore.Backend != config.ObjectStoreBackendS3 {
+		logger.Error("memory bus and object store backends only run embedded in duckmesh-api (DUCKMESH_COORDINATOR_EMBEDDED=true)")
+		os.Exit(1)
+	}
 
 	db, err := catalogpostgres.Open(context.Background(), catalogpostgres.DBConfig{
 		DSN:             cfg.Catalog.DSN,
@@ -52,16 +59,32 @@ func main() {
 		os.Exit(1)
 	}
 
+	catalogRepo := catalogpostgres.NewRepository(db)
 	svc := &coordinator.Service{
-		Bus:         buspostgres.NewIngestBus(db),
-		Publisher:   catalogpostgres.NewRepository(db),
+		Bus: buspostgres.NewIngestBus(db).WithRetryPolicy(bus.RetryPolicy{
+			MaxAttempts: cfg.Bus.MaxAttempts,
+			BaseBackoff: cfg.Bus.RetryBaseBackoff,
+			MaxBackoff:  cfg.Bus.RetryMaxBackoff,
+		}).WithClaimPolicy(bus.ClaimPolicy{
+			Fair:          cfg.Bus.ClaimFairness == config.ClaimFairnessRoundRobin,
+			TenantWeights: cfg.Bus.TenantWeights,
+		}),
+		Publisher:   catalogRepo,
+		Tables:      catalogRepo,
 		ObjectStore: store,
 		Config: coordinator.Config{
-			ConsumerID:   cfg.Coordinator.ConsumerID,
-			ClaimLimit:   cfg.Coordinator.ClaimLimit,
-			LeaseSeconds: cfg.Coordinator.LeaseSeconds,
-			PollInterval: cfg.Coordinator.PollInterval,
-			CreatedBy:    cfg.Coordinator.CreatedBy,
+			ConsumerID:           cfg.Coordinator.ConsumerID,
+			ClaimLimit:           cfg.Coordinator.ClaimLimit,
+			LeaseSeconds:         cfg.Coordinator.LeaseSeconds,
+			PollInterval:         cfg.Coordinator.PollInterval,
+			CreatedBy:            cfg.Coordinator.CreatedBy,
+			HeartbeatInterval:    cfg.Coordinator.HeartbeatInterval,
+			SweepInterval:        cfg.Coordinator.SweepInterval,
+			FallbackPollInterval: cfg.Catalog.NotifyFallbackPoll,
+			TargetFileRows:       cfg.Coordinator.TargetFileRows,
+			TargetFileBytes:      int64(cfg.Coordinator.TargetFileBytes),
+			MaxBatchDelay:        cfg.Coordinator.MaxBatchDelay,
+			Workers:              cfg.Coordinator.Workers,
 		},
 		Logger: logger,
 	}
@@ -69,6 +92,18 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	if cfg.Catalog.NotifyEnabled {
+		hub := notify.NewHub()
+		svc.Notifications = hub
+		listener := &notifypostgres.Listener{
+			DSN:      cfg.Catalog.DSN,
+			Channels: []string{notify.ChannelIngest},
+			Hub:      hub,
+			Logger:   logger,
+		}
+		go func() { _ = listener.Run(ctx) }()
+	}
+
 	logger.Info("coordinator worker started")
 	if err := svc.Run(ctx); err != nil {
 		logger.Error("coordinator worker failed", slog.Any("error", err))
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/duckmesh/duckmesh/internal/cli/duckmeshctl"
@@ -21,7 +23,9 @@ func main() {
 		Stderr:   os.Stderr,
 	}
 
-	code := duckmeshctl.Run(context.Background(), os.Args[1:], options)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	code := duckmeshctl.Run(ctx, os.Args[1:], options)
+	stop()
 	os.Exit(code)
 }
 
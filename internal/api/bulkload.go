@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/duckmesh/duckmesh/internal/bulkload"
+	"github.com/duckmesh/duckmesh/internal/catalog"
+	"github.com/duckmesh/duckmesh/internal/storage"
+)
+
+type BulkLoader interface {
+	LoadObjects(ctx context.Context, request bulkload.ObjectsRequest) (bulkload.Result, error)
+	LoadUpload(ctx context.Context, request bulkload.UploadRequest) (bulkload.Result, error)
+}
+
+type bulkLoadRequest struct {
+	Files []bulkLoadFile `json:"files"`
+}
+
+type bulkLoadFile struct {
+	Path string `json:"path"`
+}
+
+func isParquetUploadRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(mediaType) {
+	case "application/vnd.apache.parquet", "application/x-parquet", "application/octet-stream":
+		return true
+	}
+	return false
+}
+
+func handleBulkLoad(deps Dependencies, w http.ResponseWriter, r *http.Request) {
+	if deps.CatalogRepo == nil || deps.BulkLoader == nil {
+		writeError(r.Context(), w, http.StatusNotImplemented, "BULK_LOAD_NOT_CONFIGURED", "bulk load dependencies are not configured", false, nil)
+		return
+	}
+
+	tableName := strings.TrimSpace(r.PathValue("table"))
+	if tableName == "" {
+		writeError(r.Context(), w, http.StatusBadRequest, "TABLE_REQUIRED", "table path parameter is required", false, nil)
+		return
+	}
+
+	tenantID, err := tenantFromRequest(r)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusUnauthorized, "TENANT_REQUIRED", err.Error(), false, nil)
+		return
+	}
+
+	if err := requireRole(r, "ingest_writer"); err != nil {
+		writeError(r.Context(), w, http.StatusForbidden, "FORBIDDEN", err.Error(), false, nil)
+		return
+	}
+
+	var result bulkload.Result
+	if isParquetUploadRequest(r) {
+		tableDef, ok := resolveIngestTable(deps, w, r, tenantID, tableName)
+		if !ok {
+			return
+		}
+		result, err = deps.BulkLoader.LoadUpload(r.Context(), bulkload.UploadRequest{TenantID: tenantID, Table: tableDef, Body: r.Body})
+	} else {
+		var request bulkLoadRequest
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&request); err != nil {
+			writeError(r.Context(), w, http.StatusBadRequest, "INVALID_JSON", "invalid bulk load request body", false, map[string]any{"details": err.Error()})
+			return
+		}
+		if len(request.Files) == 0 {
+			writeError(r.Context(), w, http.StatusBadRequest, "FILES_REQUIRED", "at least one file is required", false, nil)
+			return
+		}
+		paths := make([]string, 0, len(request.Files))
+		for _, file := range request.Files {
+			paths = append(paths, strings.TrimSpace(file.Path))
+		}
+
+		tableDef, ok := resolveIngestTable(deps, w, r, tenantID, tableName)
+		if !ok {
+			return
+		}
+		result, err = deps.BulkLoader.LoadObjects(r.Context(), bulkload.ObjectsRequest{TenantID: tenantID, Table: tableDef, Paths: paths})
+	}
+	if err != nil {
+		writeBulkLoadError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func writeBulkLoadError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErr *bulkload.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		errContext := map[string]any{"path": validationErr.Path}
+		if validationErr.Column != "" {
+			errContext["column"] = validationErr.Column
+		}
+		writeError(r.Context(), w, http.StatusBadRequest, "BULK_LOAD_INVALID", validationErr.Error(), false, errContext)
+	case errors.Is(err, bulkload.ErrSchemaRequired):
+		writeError(r.Context(), w, http.StatusBadRequest, "SCHEMA_REQUIRED", err.Error(), false, nil)
+	case errors.Is(err, bulkload.ErrKeyedTable):
+		writeError(r.Context(), w, http.StatusBadRequest, "BULK_LOAD_UNSUPPORTED", err.Error(), false, nil)
+	case errors.Is(err, storage.ErrObjectNotFound):
+		writeError(r.Context(), w, http.StatusBadRequest, "OBJECT_NOT_FOUND", "referenced object does not exist", false, map[string]any{"details": err.Error()})
+	case errors.Is(err, catalog.ErrConflict):
+		writeError(r.Context(), w, http.StatusConflict, "BULK_LOAD_CONFLICT", "file is already registered in the catalog", false, map[string]any{"details": err.Error()})
+	default:
+		writeError(r.Context(), w, http.StatusInternalServerError, "BULK_LOAD_FAILED", "failed to publish bulk load", true, map[string]any{"details": err.Error()})
+	}
+}
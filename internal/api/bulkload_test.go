@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/duckmesh/duckmesh/internal/bulkload"
+	"github.com/duckmesh/duckmesh/internal/catalog"
+	"github.com/duckmesh/duckmesh/internal/config"
+)
+
+func TestBulkLoadEndpointPublishesReferencedObjects(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+
+	loader := &fakeBulkLoader{result: bulkload.Result{
+		SnapshotID:         12,
+		VisibilityToken:    300,
+		MaxVisibilityToken: 300,
+		Files:              []bulkload.FileResult{{Path: "tenant-1/imports/a.parquet", FileID: 5, RecordCount: 10}},
+	}}
+	h := NewHandler(cfg, Dependencies{
+		CatalogRepo: &fakeCatalogRepo{table: catalog.TableDef{TableID: 42, TenantID: "tenant-1", TableName: "events"}},
+		BulkLoader:  loader,
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/tables/events/bulk-load", strings.NewReader(`{"files":[{"path":"tenant-1/imports/a.parquet"}]}`))
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	var response bulkload.Result
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response decode error: %v", err)
+	}
+	if response.SnapshotID != 12 || response.VisibilityToken != 300 || len(response.Files) != 1 {
+		t.Fatalf("response = %+v", response)
+	}
+	if loader.objects.TenantID != "tenant-1" || loader.objects.Table.TableID != 42 || len(loader.objects.Paths) != 1 {
+		t.Fatalf("objects request = %+v", loader.objects)
+	}
+}
+
+func TestBulkLoadEndpointStreamsParquetUpload(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+
+	loader := &fakeBulkLoader{}
+	h := NewHandler(cfg, Dependencies{
+		CatalogRepo: &fakeCatalogRepo{table: catalog.TableDef{TableID: 42, TenantID: "tenant-1", TableName: "events"}},
+		BulkLoader:  loader,
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/tables/events/bulk-load", strings.NewReader("PAR1-bytes"))
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	req.Header.Set("Content-Type", "application/vnd.apache.parquet")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	if loader.uploaded != "PAR1-bytes" {
+		t.Fatalf("uploaded body = %q", loader.uploaded)
+	}
+}
+
+func TestBulkLoadEndpointMapsErrors(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{err: &bulkload.ValidationError{Path: "tenant-1/a.parquet", Column: "id", Message: "type mismatch"}, status: http.StatusBadRequest, code: "BULK_LOAD_INVALID"},
+		{err: bulkload.ErrKeyedTable, status: http.StatusBadRequest, code: "BULK_LOAD_UNSUPPORTED"},
+		{err: bulkload.ErrSchemaRequired, status: http.StatusBadRequest, code: "SCHEMA_REQUIRED"},
+		{err: fmt.Errorf("publish: %w", catalog.ErrConflict), status: http.StatusConflict, code: "BULK_LOAD_CONFLICT"},
+	}
+	for _, tc := range cases {
+		h := NewHandler(cfg, Dependencies{
+			CatalogRepo: &fakeCatalogRepo{table: catalog.TableDef{TableID: 42, TenantID: "tenant-1", TableName: "events"}},
+			BulkLoader:  &fakeBulkLoader{err: tc.err},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/v1/tables/events/bulk-load", strings.NewReader(`{"files":[{"path":"tenant-1/a.parquet"}]}`))
+		req.Header.Set("X-Tenant-ID", "tenant-1")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		if rr.Code != tc.status || !strings.Contains(rr.Body.String(), tc.code) {
+			t.Fatalf("error %v: status = %d, body = %s", tc.err, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+type fakeBulkLoader struct {
+	result   bulkload.Result
+	err      error
+	objects  bulkload.ObjectsRequest
+	uploaded string
+}
+
+func (f *fakeBulkLoader) LoadObjects(_ context.Context, request bulkload.ObjectsRequest) (bulkload.Result, error) {
+	f.objects = request
+	return f.result, f.err
+}
+
+func (f *fakeBulkLoader) LoadUpload(_ context.Context, request bulkload.UploadRequest) (bulkload.Result, error) {
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		return bulkload.Result{}, err
+	}
+	f.uploaded = string(body)
+	return f.result, f.err
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/bus"
+)
+
+const (
+	defaultFailedEventsLimit = 100
+	maxFailedEventsLimit     = 1000
+)
+
+type failedEventsActionRequest struct {
+	EventIDs []string `json:"event_ids"`
+}
+
+type failedEventResponse struct {
+	EventID        string          `json:"event_id"`
+	TableID        string          `json:"table_id"`
+	IdempotencyKey string          `json:"idempotency_key"`
+	Op             string          `json:"op"`
+	Payload        json.RawMessage `json:"payload"`
+	EventTimeMs    int64           `json:"event_time_unix_ms,omitempty"`
+	Attempts       int             `json:"attempts"`
+	LastError      string          `json:"last_error"`
+	FailedAt       *time.Time      `json:"failed_at,omitempty"`
+}
+
+func handleListFailedEvents(deps Dependencies, w http.ResponseWriter, r *http.Request) {
+	tenantID, queue, ok := deadLetterRequest(deps, w, r)
+	if !ok {
+		return
+	}
+
+	filter := bus.FailedEventFilter{TenantID: tenantID, Limit: defaultFailedEventsLimit}
+	if rawLimit := strings.TrimSpace(r.URL.Query().Get("limit")); rawLimit != "" {
+		limit, err := strconv.Atoi(rawLimit)
+		if err != nil || limit <= 0 || limit > maxFailedEventsLimit {
+			writeError(r.Context(), w, http.StatusBadRequest, "INVALID_LIMIT", "limit must be between 1 and 1000", false, map[string]any{"limit": rawLimit})
+			return
+		}
+		filter.Limit = limit
+	}
+	if tableName := strings.TrimSpace(r.URL.Query().Get("table")); tableName != "" {
+		if deps.CatalogRepo == nil {
+			writeError(r.Context(), w, http.StatusNotImplemented, "TABLES_NOT_CONFIGURED", "catalog dependency is not configured", false, nil)
+			return
+		}
+		tableDef, ok := resolveIngestTable(deps, w, r, tenantID, tableName)
+		if !ok {
+			return
+		}
+		filter.TableID = strconv.FormatInt(tableDef.TableID, 10)
+	}
+
+	failed, err := queue.ListFailed(r.Context(), filter)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusInternalServerError, "DEAD_LETTER_ERROR", "failed to list failed events", true, map[string]any{"details": err.Error()})
+		return
+	}
+
+	events := make([]failedEventResponse, 0, len(failed))
+	for _, event := range failed {
+		item := failedEventResponse{
+			EventID:        event.EventID,
+			TableID:        event.TableID,
+			IdempotencyKey: event.IdempotencyKey,
+			Op:             event.Op,
+			Payload:        json.RawMessage(event.PayloadJSON),
+			EventTimeMs:    event.EventTimeUnixMs,
+			Attempts:       event.Attempts,
+			LastError:      event.LastError,
+		}
+		if len(item.Payload) == 0 {
+			item.Payload = json.RawMessage("null")
+		}
+		if !event.FailedAt.IsZero() {
+			failedAt := event.FailedAt.UTC()
+			item.FailedAt = &failedAt
+		}
+		events = append(events, item)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"tenant_id": tenantID,
+		"count":     len(events),
+		"events":    events,
+	})
+}
+
+func handleReplayFailedEvents(deps Dependencies, w http.ResponseWriter, r *http.Request) {
+	handleFailedEventsAction(deps, w, r, "replayed", func(queue bus.DeadLetterQueue, tenantID string, eventIDs []string) (int, error) {
+		return queue.ReplayFailed(r.Context(), tenantID, eventIDs)
+	})
+}
+
+func handleDiscardFailedEvents(deps Dependencies, w http.ResponseWriter, r *http.Request) {
+	handleFailedEventsAction(deps, w, r, "discarded", func(queue bus.DeadLetterQueue, tenantID string, eventIDs []string) (int, error) {
+		return queue.DiscardFailed(r.Context(), tenantID, eventIDs)
+	})
+}
+
+func handleFailedEventsAction(deps Dependencies, w http.ResponseWriter, r *http.Request, result string, apply func(bus.DeadLetterQueue, string, []string) (int, error)) {
+	tenantID, queue, ok := deadLetterRequest(deps, w, r)
+	if !ok {
+		return
+	}
+
+	var request failedEventsActionRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeError(r.Context(), w, http.StatusBadRequest, "INVALID_JSON", "invalid request body", false, map[string]any{"details": err.Error()})
+		return
+	}
+	if len(request.EventIDs) == 0 {
+		writeError(r.Context(), w, http.StatusBadRequest, "EVENT_IDS_REQUIRED", "at least one event id is required", false, nil)
+		return
+	}
+	for _, eventID := range request.EventIDs {
+		if _, err := strconv.ParseInt(eventID, 10, 64); err != nil {
+			writeError(r.Context(), w, http.StatusBadRequest, "INVALID_EVENT_ID", "event ids must be integers", false, map[string]any{"event_id": eventID})
+			return
+		}
+	}
+
+	count, err := apply(queue, tenantID, request.EventIDs)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusInternalServerError, "DEAD_LETTER_ERROR", "failed to update failed events", true, map[string]any{"details": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"tenant_id": tenantID,
+		"requested": len(request.EventIDs),
+		result:      count,
+	})
+}
+
+func deadLetterRequest(deps Dependencies, w http.ResponseWriter, r *http.Request) (string, bus.DeadLetterQueue, bool) {
+	tenantID, err := tenantFromRequest(r)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusUnauthorized, "TENANT_REQUIRED", err.Error(), false, nil)
+		return "", nil, false
+	}
+	if err := requireRole(r, "ops_admin"); err != nil {
+		writeError(r.Context(), w, http.StatusForbidden, "FORBIDDEN", err.Error(), false, nil)
+		return "", nil, false
+	}
+	queue, ok := deps.IngestBus.(bus.DeadLetterQueue)
+	if !ok {
+		writeError(r.Context(), w, http.StatusNotImplemented, "DEAD_LETTER_NOT_CONFIGURED", "ingest bus does not keep failed events", false, nil)
+		return "", nil, false
+	}
+	return tenantID, queue, true
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/duckmesh/duckmesh/internal/auth"
+	"github.com/duckmesh/duckmesh/internal/bus"
+	busmemory "github.com/duckmesh/duckmesh/internal/bus/memory"
+	"github.com/duckmesh/duckmesh/internal/config"
+)
+
+func TestFailedEventsListReplayAndDiscard(t *testing.T) {
+	ctx := context.Background()
+	ingestBus := busmemory.NewIngestBus().WithRetryPolicy(bus.RetryPolicy{MaxAttempts: 1})
+	published, err := ingestBus.Publish(ctx, []bus.Envelope{
+		{TenantID: "t1", TableID: "7", IdempotencyKey: "k1", Op: "insert", PayloadJSON: []byte(`{"id":1}`)},
+		{TenantID: "t1", TableID: "7", IdempotencyKey: "k2", Op: "insert", PayloadJSON: []byte(`{"id":2}`)},
+	})
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	batch, err := ingestBus.ClaimBatch(ctx, "worker-1", 10, 30)
+	if err != nil {
+		t.Fatalf("ClaimBatch() error = %v", err)
+	}
+	if err := ingestBus.Nack(ctx, batch.BatchID, batch.EventIDs, "write parquet: bad value"); err != nil {
+		t.Fatalf("Nack() error = %v", err)
+	}
+	h := newDeadLetterHandler(t, ingestBus)
+
+	rr := serveDeadLetter(h, http.MethodGet, "/v1/ingest/failed", "ops", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("list status = %d, body=%s", rr.Code, rr.Body.String())
+	}
+	var listed struct {
+		Count  int                   `json:"count"`
+		Events []failedEventResponse `json:"events"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("json decode failed: %v", err)
+	}
+	if listed.Count != 2 || listed.Events[0].LastError != "write parquet: bad value" || listed.Events[0].Attempts != 1 || string(listed.Events[0].Payload) != `{"id":1}` {
+		t.Fatalf("listed = %+v", listed)
+	}
+
+	rr = serveDeadLetter(h, http.MethodPost, "/v1/ingest/failed/replay", "ops", `{"event_ids":["`+published[0].EventID+`"]}`)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"replayed":1`) {
+		t.Fatalf("replay status = %d, body=%s", rr.Code, rr.Body.String())
+	}
+	if state, _ := ingestBus.State(published[0].EventID); state != bus.StateAccepted {
+		t.Fatalf("replayed state = %q, want accepted", state)
+	}
+
+	rr = serveDeadLetter(h, http.MethodPost, "/v1/ingest/failed/discard", "ops", `{"event_ids":["`+published[1].EventID+`","`+published[0].EventID+`"]}`)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"discarded":1`) {
+		t.Fatalf("discard status = %d, body=%s", rr.Code, rr.Body.String())
+	}
+	rr = serveDeadLetter(h, http.MethodGet, "/v1/ingest/failed", "ops", "")
+	if !strings.Contains(rr.Body.String(), `"count":0`) {
+		t.Fatalf("list after discard = %s", rr.Body.String())
+	}
+}
+
+func TestFailedEventsRejectsBadRequests(t *testing.T) {
+	h := newDeadLetterHandler(t, busmemory.NewIngestBus())
+
+	if rr := serveDeadLetter(h, http.MethodGet, "/v1/ingest/failed", "reader", ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("reader status = %d, body=%s", rr.Code, rr.Body.String())
+	}
+	if rr := serveDeadLetter(h, http.MethodGet, "/v1/ingest/failed?limit=0", "ops", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("limit status = %d, body=%s", rr.Code, rr.Body.String())
+	}
+	if rr := serveDeadLetter(h, http.MethodPost, "/v1/ingest/failed/replay", "ops", `{"event_ids":[]}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("empty ids status = %d, body=%s", rr.Code, rr.Body.String())
+	}
+	if rr := serveDeadLetter(h, http.MethodPost, "/v1/ingest/failed/discard", "ops", `{"event_ids":["abc"]}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("invalid id status = %d, body=%s", rr.Code, rr.Body.String())
+	}
+
+	unsupported := newDeadLetterHandler(t, &fakeIngestBus{})
+	if rr := serveDeadLetter(unsupported, http.MethodGet, "/v1/ingest/failed", "ops", ""); rr.Code != http.StatusNotImplemented {
+		t.Fatalf("unsupported bus status = %d, body=%s", rr.Code, rr.Body.String())
+	}
+}
+
+func newDeadLetterHandler(t *testing.T, ingestBus bus.IngestBus) http.Handler {
+	t.Helper()
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{
+		"DUCKMESH_AUTH_REQUIRED": "true",
+	}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+	validator, err := auth.NewStaticAPIKeyValidator("ops:t1:ops_admin,reader:t1:query_reader")
+	if err != nil {
+		t.Fatalf("validator setup failed: %v", err)
+	}
+	return NewHandler(cfg, Dependencies{
+		AuthMiddleware: auth.Middleware(nil, validator),
+		IngestBus:      ingestBus,
+	})
+}
+
+func serveDeadLetter(h http.Handler, method, target, apiKey, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("X-API-Key", apiKey)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
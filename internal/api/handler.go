@@ -15,6 +15,7 @@ import (
 	"github.com/duckmesh/duckmesh/internal/config"
 	"github.com/duckmesh/duckmesh/internal/maintenance"
 	"github.com/duckmesh/duckmesh/internal/nl2sql"
+	"github.com/duckmesh/duckmesh/internal/notify"
 	"github.com/duckmesh/duckmesh/internal/observability"
 	"github.com/duckmesh/duckmesh/internal/query"
 )
@@ -38,10 +39,24 @@ type Dependencies struct {
 	CatalogRepo      CatalogTableLookup
 	IngestBus        bus.IngestBus
 	QueryEngine      query.Engine
+	QueryPolicy      query.Policy
 	Maintenance      MaintenanceRunner
 	QueryTranslator  nl2sql.Translator
 	UISchemaSamples  int
-	UI               http.Handler
+	// IngestStreamChunkRecords bounds how many NDJSON records are buffered
+	// before a publish; IngestStreamIdleTimeout is the per-chunk read deadline.
+	// QueryStreamWriteTimeout is the write deadline of each batch of a
+	// streamed query result.
+	IngestStreamChunkRecords int
+	IngestStreamIdleTimeout  time.Duration
+	QueryStreamWriteTimeout  time.Duration
+	BulkLoader               BulkLoader
+	QueryJobs                QueryJobRunner
+	UI                       http.Handler
+	// SnapshotNotifications wakes visibility barrier waiters on
+	// notify.ChannelSnapshot; they then only poll every BarrierFallbackPoll.
+	SnapshotNotifications notify.Subscriber
+	BarrierFallbackPoll   time.Duration
 }
 
 type MaintenanceRunner interface {
@@ -87,6 +102,9 @@ func NewHandler(cfg config.Config, deps Dependencies) http.Handler {
 	protected.HandleFunc("GET /v1/tables/{table}", func(w http.ResponseWriter, r *http.Request) {
 		handleGetTable(deps, w, r)
 	})
+	protected.HandleFunc("GET /v1/tables/{table}/stats", func(w http.ResponseWriter, r *http.Request) {
+		handleGetTableStats(deps, w, r)
+	})
 	protected.HandleFunc("PATCH /v1/tables/{table}", func(w http.ResponseWriter, r *http.Request) {
 		handlePatchTable(deps, w, r)
 	})
@@ -97,9 +115,24 @@ func NewHandler(cfg config.Config, deps Dependencies) http.Handler {
 	protected.HandleFunc("POST /v1/ingest/{table}", func(w http.ResponseWriter, r *http.Request) {
 		handleIngest(deps, w, r)
 	})
+	protected.HandleFunc("POST /v1/tables/{table}/bulk-load", func(w http.ResponseWriter, r *http.Request) {
+		handleBulkLoad(deps, w, r)
+	})
 	protected.HandleFunc("POST /v1/query", func(w http.ResponseWriter, r *http.Request) {
 		handleQuery(deps, w, r)
 	})
+	protected.HandleFunc("POST /v1/query/jobs", func(w http.ResponseWriter, r *http.Request) {
+		handleSubmitQueryJob(deps, w, r)
+	})
+	protected.HandleFunc("GET /v1/query/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handleGetQueryJob(deps, w, r)
+	})
+	protected.HandleFunc("DELETE /v1/query/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteQueryJob(deps, w, r)
+	})
+	protected.HandleFunc("GET /v1/query/jobs/{id}/result", func(w http.ResponseWriter, r *http.Request) {
+		handleGetQueryJobResult(deps, w, r)
+	})
 	protected.HandleFunc("GET /v1/ui/schema", func(w http.ResponseWriter, r *http.Request) {
 		handleUISchema(deps, w, r)
 	})
@@ -109,6 +142,15 @@ func NewHandler(cfg config.Config, deps Dependencies) http.Handler {
 	protected.HandleFunc("GET /v1/lag", func(w http.ResponseWriter, r *http.Request) {
 		handleLag(deps, w, r)
 	})
+	protected.HandleFunc("GET /v1/ingest/failed", func(w http.ResponseWriter, r *http.Request) {
+		handleListFailedEvents(deps, w, r)
+	})
+	protected.HandleFunc("POST /v1/ingest/failed/replay", func(w http.ResponseWriter, r *http.Request) {
+		handleReplayFailedEvents(deps, w, r)
+	})
+	protected.HandleFunc("POST /v1/ingest/failed/discard", func(w http.ResponseWriter, r *http.Request) {
+		handleDiscardFailedEvents(deps, w, r)
+	})
 	protected.HandleFunc("POST /v1/compaction/run", func(w http.ResponseWriter, r *http.Request) {
 		handleCompactionRun(deps, w, r)
 	})
@@ -135,13 +177,22 @@ func NewHandler(cfg config.Config, deps Dependencies) http.Handler {
 	mux.Handle("GET /v1/tables", protectedHandler)
 	mux.Handle("POST /v1/tables", protectedHandler)
 	mux.Handle("GET /v1/tables/{table}", protectedHandler)
+	mux.Handle("GET /v1/tables/{table}/stats", protectedHandler)
 	mux.Handle("PATCH /v1/tables/{table}", protectedHandler)
 	mux.Handle("DELETE /v1/tables/{table}", protectedHandler)
 	mux.Handle("POST /v1/ingest/{table}", protectedHandler)
+	mux.Handle("POST /v1/tables/{table}/bulk-load", protectedHandler)
 	mux.Handle("POST /v1/query", protectedHandler)
+	mux.Handle("POST /v1/query/jobs", protectedHandler)
+	mux.Handle("GET /v1/query/jobs/{id}", protectedHandler)
+	mux.Handle("DELETE /v1/query/jobs/{id}", protectedHandler)
+	mux.Handle("GET /v1/query/jobs/{id}/result", protectedHandler)
 	mux.Handle("GET /v1/ui/schema", protectedHandler)
 	mux.Handle("POST /v1/query/translate", protectedHandler)
 	mux.Handle("GET /v1/lag", protectedHandler)
+	mux.Handle("GET /v1/ingest/failed", protectedHandler)
+	mux.Handle("POST /v1/ingest/failed/replay", protectedHandler)
+	mux.Handle("POST /v1/ingest/failed/discard", protectedHandler)
 	mux.Handle("POST /v1/compaction/run", protectedHandler)
 	mux.Handle("POST /v1/retention/run", protectedHandler)
 	mux.Handle("POST /v1/integrity/run", protectedHandler)
@@ -170,6 +221,9 @@ func CheckCatalogDSN(cfg config.Config) ReadinessCheck {
 
 func CheckObjectStoreConfig(cfg config.Config) ReadinessCheck {
 	return func(_ context.Context) error {
+		if cfg.ObjectStore.Backend == config.ObjectStoreBackendMemory {
+			return nil
+		}
 		if cfg.ObjectStore.Endpoint == "" {
 			return errors.New("object store endpoint is not configured")
 		}
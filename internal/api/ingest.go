@@ -13,12 +13,16 @@ import (
 	"github.com/duckmesh/duckmesh/internal/bus"
 	"github.com/duckmesh/duckmesh/internal/catalog"
 	"github.com/duckmesh/duckmesh/internal/observability"
+	"github.com/duckmesh/duckmesh/internal/tableschema"
 )
 
 type ingestRequest struct {
 	Records             []ingestRecord `json:"records"`
 	WaitForVisibility   bool           `json:"wait_for_visibility"`
 	VisibilityTimeoutMs int            `json:"visibility_timeout_ms"`
+	// Partial publishes the valid records and reports invalid ones in the
+	// results instead of rejecting the whole request.
+	Partial bool `json:"partial"`
 }
 
 type ingestRecord struct {
@@ -29,11 +33,23 @@ type ingestRecord struct {
 }
 
 type ingestResponse struct {
-	AcceptedCount      int    `json:"accepted_count"`
-	DuplicateCount     int    `json:"duplicate_count"`
-	MaxVisibilityToken int64  `json:"max_visibility_token"`
-	VisibleSnapshotID  *int64 `json:"visible_snapshot_id"`
-	Status             string `json:"status"`
+	AcceptedCount      int                  `json:"accepted_count"`
+	DuplicateCount     int                  `json:"duplicate_count"`
+	RejectedCount      int                  `json:"rejected_count"`
+	MaxVisibilityToken int64                `json:"max_visibility_token"`
+	VisibleSnapshotID  *int64               `json:"visible_snapshot_id"`
+	Status             string               `json:"status"`
+	Results            []ingestRecordResult `json:"results,omitempty"`
+}
+
+type ingestRecordResult struct {
+	RecordIndex     int                     `json:"record_index"`
+	Status          string                  `json:"status"`
+	EventID         string                  `json:"event_id,omitempty"`
+	VisibilityToken int64                   `json:"visibility_token,omitempty"`
+	ErrorCode       string                  `json:"error_code,omitempty"`
+	Message         string                  `json:"message,omitempty"`
+	Violations      []tableschema.Violation `json:"violations,omitempty"`
 }
 
 func handleIngest(deps Dependencies, w http.ResponseWriter, r *http.Request) {
@@ -59,6 +75,15 @@ func handleIngest(deps Dependencies, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isNDJSONRequest(r) {
+		handleIngestStream(deps, w, r, tenantID, tableName)
+		return
+	}
+	if isCSVRequest(r) {
+		handleIngestCSV(deps, w, r, tenantID, tableName)
+		return
+	}
+
 	var request ingestRequest
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields()
@@ -71,93 +96,202 @@ func handleIngest(deps Dependencies, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tableDef, err := deps.CatalogRepo.GetTableByName(r.Context(), tenantID, tableName)
+	tableDef, ok := resolveIngestTable(deps, w, r, tenantID, tableName)
+	if !ok {
+		return
+	}
+
+	schema, err := loadIngestSchema(r.Context(), deps, tableDef.TableID)
 	if err != nil {
-		if errors.Is(err, catalog.ErrNotFound) {
-			writeError(r.Context(), w, http.StatusBadRequest, "TABLE_NOT_FOUND", "table is not registered for tenant", false, map[string]any{"table": tableName})
-			return
-		}
-		writeError(r.Context(), w, http.StatusInternalServerError, "CATALOG_ERROR", "failed to resolve table", true, map[string]any{"details": err.Error()})
+		writeError(r.Context(), w, http.StatusInternalServerError, "CATALOG_ERROR", "failed to resolve table schema", true, map[string]any{"details": err.Error()})
 		return
 	}
 
+	response := ingestResponse{Results: make([]ingestRecordResult, len(request.Records))}
 	envelopes := make([]bus.Envelope, 0, len(request.Records))
+	envelopeRecords := make([]int, 0, len(request.Records))
+	violations := make([]ingestViolation, 0)
+	violationCount := 0
 	for i, record := range request.Records {
-		if strings.TrimSpace(record.IdempotencyKey) == "" {
-			writeError(r.Context(), w, http.StatusBadRequest, "IDEMPOTENCY_KEY_REQUIRED", "idempotency_key is required", false, map[string]any{"record_index": i})
-			return
-		}
-		switch record.Op {
-		case "insert", "upsert", "delete":
-		default:
-			writeError(r.Context(), w, http.StatusBadRequest, "INVALID_OP", "op must be insert, upsert, or delete", false, map[string]any{"record_index": i})
-			return
+		response.Results[i].RecordIndex = i
+		envelope, recordErr := buildIngestEnvelope(tenantID, tableDef.TableID, record)
+		if recordErr != nil {
+			if !request.Partial {
+				recordErr.write(r, w, i, nil)
+				return
+			}
+			response.reject(i, recordErr.code, recordErr.message, nil)
+			continue
 		}
-		if record.Payload == nil {
-			writeError(r.Context(), w, http.StatusBadRequest, "PAYLOAD_REQUIRED", "payload object is required", false, map[string]any{"record_index": i})
-			return
+		if recordErrs := schema.validate(record); len(recordErrs) > 0 {
+			if request.Partial {
+				response.reject(i, "SCHEMA_VIOLATION", "record does not match the table schema", recordErrs)
+				continue
+			}
+			violationCount += len(recordErrs)
+			for _, violation := range recordViolations(i, recordErrs) {
+				if len(violations) < maxIngestViolations {
+					violations = append(violations, violation)
+				}
+			}
+			continue
 		}
+		envelopes = append(envelopes, envelope)
+		envelopeRecords = append(envelopeRecords, i)
+	}
+	if violationCount > 0 {
+		writeError(r.Context(), w, http.StatusBadRequest, "SCHEMA_VIOLATION", "records do not match the table schema", false, map[string]any{
+			"violations":      violations,
+			"violation_count": violationCount,
+		})
+		return
+	}
 
-		payloadJSON, err := json.Marshal(record.Payload)
+	publishStart := time.Now()
+	if len(envelopes) > 0 {
+		published, err := deps.IngestBus.Publish(r.Context(), envelopes)
 		if err != nil {
-			writeError(r.Context(), w, http.StatusBadRequest, "INVALID_PAYLOAD", "payload must be valid JSON object", false, map[string]any{"record_index": i, "details": err.Error()})
+			writeError(r.Context(), w, http.StatusInternalServerError, "INGEST_PUBLISH_FAILED", "failed to publish ingest events", true, map[string]any{"details": err.Error()})
 			return
 		}
+		response.addPublished(published)
+		for j, result := range published {
+			if j >= len(envelopeRecords) {
+				break
+			}
+			recordResult := &response.Results[envelopeRecords[j]]
+			recordResult.Status = "accepted"
+			if !result.Inserted {
+				recordResult.Status = "duplicate"
+			}
+			recordResult.EventID = result.EventID
+			recordResult.VisibilityToken = result.VisibilityToken
+		}
+	}
+	response.setStatus()
 
-		eventTimeMs := int64(0)
-		if record.EventTime != nil {
-			eventTimeMs = record.EventTime.UTC().UnixMilli()
+	if request.WaitForVisibility && !response.waitForVisibility(r, w, deps, tenantID, request.VisibilityTimeoutMs) {
+		return
+	}
+	observability.ObserveIngestAck(response.AcceptedCount, response.DuplicateCount, time.Since(publishStart))
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func resolveIngestTable(deps Dependencies, w http.ResponseWriter, r *http.Request, tenantID, tableName string) (catalog.TableDef, bool) {
+	tableDef, err := deps.CatalogRepo.GetTableByName(r.Context(), tenantID, tableName)
+	if err != nil {
+		if errors.Is(err, catalog.ErrNotFound) {
+			writeError(r.Context(), w, http.StatusBadRequest, "TABLE_NOT_FOUND", "table is not registered for tenant", false, map[string]any{"table": tableName})
+			return catalog.TableDef{}, false
 		}
-		envelopes = append(envelopes, bus.Envelope{
-			TenantID:        tenantID,
-			TableID:         strconv.FormatInt(tableDef.TableID, 10),
-			IdempotencyKey:  record.IdempotencyKey,
-			Op:              record.Op,
-			PayloadJSON:     payloadJSON,
-			EventTimeUnixMs: eventTimeMs,
-		})
+		writeError(r.Context(), w, http.StatusInternalServerError, "CATALOG_ERROR", "failed to resolve table", true, map[string]any{"details": err.Error()})
+		return catalog.TableDef{}, false
 	}
+	return tableDef, true
+}
 
-	publishStart := time.Now()
-	published, err := deps.IngestBus.Publish(r.Context(), envelopes)
+type ingestRecordError struct {
+	code    string
+	message string
+	details string
+}
+
+func (e *ingestRecordError) write(r *http.Request, w http.ResponseWriter, recordIndex int, extra map[string]any) {
+	errContext := map[string]any{"record_index": recordIndex}
+	if e.details != "" {
+		errContext["details"] = e.details
+	}
+	for key, value := range extra {
+		errContext[key] = value
+	}
+	writeError(r.Context(), w, http.StatusBadRequest, e.code, e.message, false, errContext)
+}
+
+func buildIngestEnvelope(tenantID string, tableID int64, record ingestRecord) (bus.Envelope, *ingestRecordError) {
+	if strings.TrimSpace(record.IdempotencyKey) == "" {
+		return bus.Envelope{}, &ingestRecordError{code: "IDEMPOTENCY_KEY_REQUIRED", message: "idempotency_key is required"}
+	}
+	switch record.Op {
+	case "insert", "upsert", "delete":
+	default:
+		return bus.Envelope{}, &ingestRecordError{code: "INVALID_OP", message: "op must be insert, upsert, or delete"}
+	}
+	if record.Payload == nil {
+		return bus.Envelope{}, &ingestRecordError{code: "PAYLOAD_REQUIRED", message: "payload object is required"}
+	}
+
+	payloadJSON, err := json.Marshal(record.Payload)
 	if err != nil {
-		writeError(r.Context(), w, http.StatusInternalServerError, "INGEST_PUBLISH_FAILED", "failed to publish ingest events", true, map[string]any{"details": err.Error()})
-		return
+		return bus.Envelope{}, &ingestRecordError{code: "INVALID_PAYLOAD", message: "payload must be valid JSON object", details: err.Error()}
 	}
 
-	response := ingestResponse{}
+	eventTimeMs := int64(0)
+	if record.EventTime != nil {
+		eventTimeMs = record.EventTime.UTC().UnixMilli()
+	}
+	return bus.Envelope{
+		TenantID:        tenantID,
+		TableID:         strconv.FormatInt(tableID, 10),
+		IdempotencyKey:  record.IdempotencyKey,
+		Op:              record.Op,
+		PayloadJSON:     payloadJSON,
+		EventTimeUnixMs: eventTimeMs,
+	}, nil
+}
+
+func (r *ingestResponse) addPublished(published []bus.PublishResult) {
 	for _, result := range published {
 		if result.Inserted {
-			response.AcceptedCount++
+			r.AcceptedCount++
 		} else {
-			response.DuplicateCount++
+			r.DuplicateCount++
 		}
-		if result.VisibilityToken > response.MaxVisibilityToken {
-			response.MaxVisibilityToken = result.VisibilityToken
+		if result.VisibilityToken > r.MaxVisibilityToken {
+			r.MaxVisibilityToken = result.VisibilityToken
 		}
 	}
-	response.Status = "accepted"
-	if response.DuplicateCount > 0 {
-		response.Status = "partial_duplicate"
+}
+
+func (r *ingestResponse) reject(recordIndex int, code, message string, violations []tableschema.Violation) {
+	r.RejectedCount++
+	r.Results[recordIndex] = ingestRecordResult{
+		RecordIndex: recordIndex,
+		Status:      "rejected",
+		ErrorCode:   code,
+		Message:     message,
+		Violations:  violations,
 	}
+}
 
-	if request.WaitForVisibility {
-		if response.MaxVisibilityToken > 0 {
-			token := response.MaxVisibilityToken
-			snapshot, err := resolveSnapshotWithBarrier(r, deps, tenantID, &token, request.VisibilityTimeoutMs)
-			if err != nil {
-				handleSnapshotResolutionError(r, w, err)
-				return
-			}
-			response.VisibleSnapshotID = &snapshot.SnapshotID
-			if response.AcceptedCount > 0 {
-				response.Status = "visible"
-			}
+func (r *ingestResponse) setStatus() {
+	r.Status = "accepted"
+	if r.DuplicateCount > 0 {
+		r.Status = "partial_duplicate"
+	}
+	if r.RejectedCount > 0 {
+		r.Status = "partial_rejected"
+		if r.AcceptedCount+r.DuplicateCount == 0 {
+			r.Status = "rejected"
 		}
 	}
-	observability.ObserveIngestAck(response.AcceptedCount, response.DuplicateCount, time.Since(publishStart))
+}
 
-	writeJSON(w, http.StatusOK, response)
+func (r *ingestResponse) waitForVisibility(req *http.Request, w http.ResponseWriter, deps Dependencies, tenantID string, timeoutMs int) bool {
+	if r.MaxVisibilityToken <= 0 {
+		return true
+	}
+	token := r.MaxVisibilityToken
+	snapshot, err := resolveSnapshotWithBarrier(req, deps, tenantID, &token, timeoutMs)
+	if err != nil {
+		handleSnapshotResolutionError(req, w, err)
+		return false
+	}
+	r.VisibleSnapshotID = &snapshot.SnapshotID
+	if r.AcceptedCount > 0 && r.RejectedCount == 0 {
+		r.Status = "visible"
+	}
+	return true
 }
 
 func tenantFromRequest(r *http.Request) (string, error) {
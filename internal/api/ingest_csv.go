@@ -0,0 +1,252 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/observability"
+	"github.com/duckmesh/duckmesh/internal/tableschema"
+)
+
+const maxCSVRowErrors = 100
+
+type csvRowError struct {
+	RecordIndex int    `json:"record_index"`
+	Line        int    `json:"line"`
+	Column      string `json:"column,omitempty"`
+	Message     string `json:"message"`
+}
+
+type ingestCSVResponse struct {
+	ingestStreamResponse
+	Errors []csvRowError `json:"errors"`
+}
+
+type csvIngestOptions struct {
+	keyColumn       string
+	op              string
+	eventTimeColumn string
+}
+
+func isCSVRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "text/csv"
+}
+
+// handleIngestCSV maps CSV header columns to payload fields, coercing cells
+// with the table's declared schema. Rows that fail to parse are reported and
+// skipped; the remaining rows are published in chunks like NDJSON streams.
+func handleIngestCSV(deps Dependencies, w http.ResponseWriter, r *http.Request, tenantID, tableName string) {
+	waitForVisibility, visibilityTimeoutMs, err := parseStreamVisibilityParams(r)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusBadRequest, "INVALID_QUERY_PARAM", err.Error(), false, nil)
+		return
+	}
+	options := csvIngestOptions{
+		keyColumn:       strings.TrimSpace(r.URL.Query().Get("key_column")),
+		op:              strings.TrimSpace(r.URL.Query().Get("op")),
+		eventTimeColumn: strings.TrimSpace(r.URL.Query().Get("event_time_column")),
+	}
+	if options.op == "" {
+		options.op = "insert"
+	}
+	switch options.op {
+	case "insert", "upsert", "delete":
+	default:
+		writeError(r.Context(), w, http.StatusBadRequest, "INVALID_OP", "op must be insert, upsert, or delete", false, nil)
+		return
+	}
+
+	tableDef, ok := resolveIngestTable(deps, w, r, tenantID, tableName)
+	if !ok {
+		return
+	}
+	schema, err := loadIngestSchema(r.Context(), deps, tableDef.TableID)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusInternalServerError, "CATALOG_ERROR", "failed to resolve table schema", true, map[string]any{"details": err.Error()})
+		return
+	}
+
+	reader := csv.NewReader(r.Body)
+	header, err := reader.Read()
+	if err != nil {
+		writeError(r.Context(), w, http.StatusBadRequest, "INVALID_CSV", "failed to read CSV header", false, map[string]any{"details": err.Error()})
+		return
+	}
+	if err := validateCSVHeader(header, options); err != nil {
+		writeError(r.Context(), w, http.StatusBadRequest, "INVALID_CSV_HEADER", err.Error(), false, nil)
+		return
+	}
+	for _, column := range header {
+		if !schema.declares(column) {
+			writeError(r.Context(), w, http.StatusBadRequest, "INVALID_CSV_HEADER", "CSV column "+column+" is not declared in the table schema", false, map[string]any{"column": column})
+			return
+		}
+	}
+
+	publisher := newIngestChunkPublisher(deps, w, r)
+	response := ingestCSVResponse{Errors: make([]csvRowError, 0)}
+	rejectedCount := 0
+	rowErr := func(rowErrors ...csvRowError) {
+		rejectedCount++
+		for _, rowError := range rowErrors {
+			if len(response.Errors) < maxCSVRowErrors {
+				response.Errors = append(response.Errors, rowError)
+			}
+		}
+	}
+
+	rowIndex := 0
+	for ; ; rowIndex++ {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				errContext := publisher.progress()
+				errContext["record_index"] = rowIndex
+				errContext["details"] = err.Error()
+				writeError(r.Context(), w, http.StatusBadRequest, "STREAM_READ_FAILED", "failed to read CSV body", false, errContext)
+				return
+			}
+			rowErr(csvRowError{RecordIndex: rowIndex, Line: parseErr.Line, Message: parseErr.Err.Error()})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		record, rowError := csvRowToRecord(header, row, schema.schema, options)
+		if rowError != nil {
+			rowError.RecordIndex, rowError.Line = rowIndex, line
+			rowErr(*rowError)
+			continue
+		}
+		if violations := schema.validate(record); len(violations) > 0 {
+			rowErrors := make([]csvRowError, 0, len(violations))
+			for _, violation := range violations {
+				rowErrors = append(rowErrors, csvRowError{RecordIndex: rowIndex, Line: line, Column: violation.Field, Message: violation.Message})
+			}
+			rowErr(rowErrors...)
+			continue
+		}
+		envelope, recordErr := buildIngestEnvelope(tenantID, tableDef.TableID, record)
+		if recordErr != nil {
+			rowErr(csvRowError{RecordIndex: rowIndex, Line: line, Message: recordErr.message})
+			continue
+		}
+		if err := publisher.add(envelope, rowIndex); err != nil {
+			publisher.writePublishError(err)
+			return
+		}
+	}
+	if err := publisher.flush(); err != nil {
+		publisher.writePublishError(err)
+		return
+	}
+	if rowIndex == 0 {
+		writeError(r.Context(), w, http.StatusBadRequest, "RECORDS_REQUIRED", "at least one CSV row is required", false, nil)
+		return
+	}
+
+	response.ingestStreamResponse = *publisher.response
+	response.RecordCount = rowIndex
+	response.RejectedCount = rejectedCount
+	response.setStatus()
+	if waitForVisibility && !response.waitForVisibility(r, w, deps, tenantID, visibilityTimeoutMs) {
+		return
+	}
+	observability.ObserveIngestAck(response.AcceptedCount, response.DuplicateCount, time.Since(publisher.start))
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func validateCSVHeader(header []string, options csvIngestOptions) error {
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	seen := make(map[string]struct{}, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return errors.New("CSV header contains an empty column name")
+		}
+		if _, ok := seen[name]; ok {
+			return errors.New("CSV header contains duplicate column " + name)
+		}
+		seen[name] = struct{}{}
+		header[i] = name
+	}
+	for _, column := range []string{options.keyColumn, options.eventTimeColumn} {
+		if column == "" {
+			continue
+		}
+		if _, ok := seen[column]; !ok {
+			return errors.New("CSV header does not contain column " + column)
+		}
+	}
+	return nil
+}
+
+func csvRowToRecord(header, row []string, schema tableschema.Schema, options csvIngestOptions) (ingestRecord, *csvRowError) {
+	record := ingestRecord{Op: options.op, Payload: make(map[string]any, len(header))}
+	for i, column := range header {
+		cell := row[i]
+		if strings.TrimSpace(cell) == "" {
+			record.Payload[column] = nil
+			continue
+		}
+		value := any(cell)
+		if field, ok := schema.Field(column); ok {
+			parsed, err := field.ParseText(cell)
+			if err != nil {
+				return ingestRecord{}, &csvRowError{Column: column, Message: err.Error()}
+			}
+			value = parsed
+		}
+		record.Payload[column] = value
+
+		if column == options.eventTimeColumn {
+			parsed, err := tableschema.Field{Type: tableschema.TypeTimestamp}.Coerce(strings.TrimSpace(cell))
+			if err != nil {
+				return ingestRecord{}, &csvRowError{Column: column, Message: err.Error()}
+			}
+			eventTime := parsed.(time.Time)
+			record.EventTime = &eventTime
+		}
+	}
+
+	if options.keyColumn != "" {
+		for i, column := range header {
+			if column == options.keyColumn {
+				record.IdempotencyKey = strings.TrimSpace(row[i])
+			}
+		}
+		if record.IdempotencyKey == "" {
+			return ingestRecord{}, &csvRowError{Column: options.keyColumn, Message: "key column is empty"}
+		}
+	} else {
+		record.IdempotencyKey = csvRowHash(header, row)
+	}
+	return record, nil
+}
+
+// csvRowHash derives an idempotency key from the header and cell values, so
+// re-uploading the same export is deduplicated by the ingest bus.
+func csvRowHash(header, row []string) string {
+	hash := sha256.New()
+	for i, column := range header {
+		_, _ = io.WriteString(hash, column)
+		_, _ = hash.Write([]byte{0x1f})
+		_, _ = io.WriteString(hash, row[i])
+		_, _ = hash.Write([]byte{0x1e})
+	}
+	return "csv-" + hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/duckmesh/duckmesh/internal/catalog"
+	"github.com/duckmesh/duckmesh/internal/config"
+)
+
+type schemaCatalogRepo struct {
+	*fakeCatalogRepo
+	schemaJSON    string
+	unknownFields string
+}
+
+func (s schemaCatalogRepo) GetCurrentTableSchema(_ context.Context, tableID int64) (catalog.TableSchemaVersion, error) {
+	return catalog.TableSchemaVersion{TableID: tableID, SchemaVersion: 1, SchemaJSON: []byte(s.schemaJSON), UnknownFields: s.unknownFields}, nil
+}
+
+func TestIngestCSVCoercesSchemaAndReportsRowErrors(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+
+	repo := schemaCatalogRepo{
+		fakeCatalogRepo: &fakeCatalogRepo{table: catalog.TableDef{TableID: 42, TenantID: "tenant-1", TableName: "events"}},
+		schemaJSON:      `{"id":"bigint","amount":"double","active":"boolean","seen_at":"timestamp"}`,
+	}
+	busStub := &fakeIngestBus{}
+	h := NewHandler(cfg, Dependencies{CatalogRepo: repo, IngestBus: busStub})
+
+	body := strings.Join([]string{
+		"id,amount,active,seen_at,note",
+		"1,10.5,true,2026-01-02T03:04:05Z,first",
+		"2,not-a-number,false,2026-01-02T03:04:06Z,second",
+		"3,7,false,2026-01-02T03:04:07Z",
+		",1,true,2026-01-02T03:04:08Z,missing key",
+		"5,,false,2026-01-02T03:04:09Z,",
+	}, "\n")
+	req := httptest.NewRequest(http.MethodPost, "/v1/ingest/events?key_column=id&op=upsert&event_time_column=seen_at", strings.NewReader(body))
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	req.Header.Set("Content-Type", "text/csv")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	var response struct {
+		AcceptedCount int           `json:"accepted_count"`
+		RecordCount   int           `json:"record_count"`
+		RejectedCount int           `json:"rejected_count"`
+		Errors        []csvRowError `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response decode error: %v", err)
+	}
+	if response.AcceptedCount != 2 || response.RecordCount != 5 || response.RejectedCount != 3 {
+		t.Fatalf("response = %s", rr.Body.String())
+	}
+	if response.Errors[0].RecordIndex != 1 || response.Errors[0].Column != "amount" || response.Errors[0].Line != 3 {
+		t.Fatalf("errors[0] = %+v", response.Errors[0])
+	}
+	if response.Errors[1].RecordIndex != 2 || response.Errors[2].Column != "id" {
+		t.Fatalf("errors = %+v", response.Errors)
+	}
+
+	if len(busStub.publishedEvents) != 2 {
+		t.Fatalf("published events = %d, want 2", len(busStub.publishedEvents))
+	}
+	first := busStub.publishedEvents[0]
+	if first.IdempotencyKey != "1" || first.Op != "upsert" || first.EventTimeUnixMs != 1767323045000 {
+		t.Fatalf("first envelope = %+v", first)
+	}
+	if string(first.PayloadJSON) != `{"active":true,"amount":10.5,"id":1,"note":"first","seen_at":"2026-01-02T03:04:05Z"}` {
+		t.Fatalf("payload = %s", first.PayloadJSON)
+	}
+	if string(busStub.publishedEvents[1].PayloadJSON) != `{"active":false,"amount":null,"id":5,"note":null,"seen_at":"2026-01-02T03:04:09Z"}` {
+		t.Fatalf("payload = %s", busStub.publishedEvents[1].PayloadJSON)
+	}
+}
+
+func TestIngestCSVDerivesRowHashKeys(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+
+	repo := &fakeCatalogRepo{table: catalog.TableDef{TableID: 42, TenantID: "tenant-1", TableName: "events"}}
+	busStub := &fakeIngestBus{}
+	h := NewHandler(cfg, Dependencies{CatalogRepo: repo, IngestBus: busStub})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/ingest/events", strings.NewReader("a,b\n1,x\n1,x\n2,y\n"))
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	req.Header.Set("Content-Type", "text/csv; charset=utf-8")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	events := busStub.publishedEvents
+	if len(events) != 3 || events[0].Op != "insert" {
+		t.Fatalf("published events = %+v", events)
+	}
+	if !strings.HasPrefix(events[0].IdempotencyKey, "csv-") || events[0].IdempotencyKey != events[1].IdempotencyKey || events[0].IdempotencyKey == events[2].IdempotencyKey {
+		t.Fatalf("idempotency keys = %q %q %q", events[0].IdempotencyKey, events[1].IdempotencyKey, events[2].IdempotencyKey)
+	}
+	if string(events[2].PayloadJSON) != `{"a":"2","b":"y"}` {
+		t.Fatalf("payload = %s", events[2].PayloadJSON)
+	}
+}
+
+func TestIngestCSVRejectsUnknownKeyColumn(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+
+	repo := &fakeCatalogRepo{table: catalog.TableDef{TableID: 42, TenantID: "tenant-1", TableName: "events"}}
+	h := NewHandler(cfg, Dependencies{CatalogRepo: repo, IngestBus: &fakeIngestBus{}})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/ingest/events?key_column=id", strings.NewReader("a,b\n1,x\n"))
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	req.Header.Set("Content-Type", "text/csv")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "INVALID_CSV_HEADER") {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/duckmesh/duckmesh/internal/catalog"
+	"github.com/duckmesh/duckmesh/internal/tableschema"
+)
+
+const maxIngestViolations = 100
+
+type tableSchemaReader interface {
+	GetCurrentTableSchema(ctx context.Context, tableID int64) (catalog.TableSchemaVersion, error)
+}
+
+// ingestSchema is the current schema version of a table as enforced at
+// ingest. An empty schema accepts any payload.
+type ingestSchema struct {
+	schema        tableschema.Schema
+	rejectUnknown bool
+}
+
+type ingestViolation struct {
+	RecordIndex int    `json:"record_index"`
+	Field       string `json:"field"`
+	Message     string `json:"message"`
+}
+
+func loadIngestSchema(ctx context.Context, deps Dependencies, tableID int64) (ingestSchema, error) {
+	reader, ok := deps.CatalogRepo.(tableSchemaReader)
+	if !ok {
+		return ingestSchema{}, nil
+	}
+	version, err := reader.GetCurrentTableSchema(ctx, tableID)
+	if err != nil {
+		if errors.Is(err, catalog.ErrNotFound) {
+			return ingestSchema{}, nil
+		}
+		return ingestSchema{}, err
+	}
+	schema, err := tableschema.Parse(version.SchemaJSON)
+	if err != nil {
+		return ingestSchema{}, err
+	}
+	return ingestSchema{schema: schema, rejectUnknown: version.UnknownFields == tableschema.UnknownFieldsReject}, nil
+}
+
+func (s ingestSchema) validate(record ingestRecord) []tableschema.Violation {
+	if s.schema.IsEmpty() {
+		return nil
+	}
+	return s.schema.Validate(record.Payload, record.Op != "delete", s.rejectUnknown)
+}
+
+func (s ingestSchema) declares(name string) bool {
+	_, ok := s.schema.Field(name)
+	return ok || s.schema.IsEmpty() || !s.rejectUnknown
+}
+
+func recordViolations(recordIndex int, violations []tableschema.Violation) []ingestViolation {
+	out := make([]ingestViolation, 0, len(violations))
+	for _, violation := range violations {
+		out = append(out, ingestViolation{RecordIndex: recordIndex, Field: violation.Field, Message: violation.Message})
+	}
+	return out
+}
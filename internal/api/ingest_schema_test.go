@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/duckmesh/duckmesh/internal/catalog"
+	"github.com/duckmesh/duckmesh/internal/config"
+)
+
+func strictSchemaRepo() schemaCatalogRepo {
+	return schemaCatalogRepo{
+		fakeCatalogRepo: &fakeCatalogRepo{table: catalog.TableDef{TableID: 42, TenantID: "tenant-1", TableName: "events"}},
+		schemaJSON:      `{"id":{"type":"bigint","required":true},"name":"varchar"}`,
+		unknownFields:   "reject",
+	}
+}
+
+func TestIngestEndpointReportsSchemaViolationsPerRecord(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+
+	busStub := &fakeIngestBus{}
+	h := NewHandler(cfg, Dependencies{CatalogRepo: strictSchemaRepo(), IngestBus: busStub})
+
+	body := `{"records":[
+		{"idempotency_key":"k1","op":"insert","payload":{"id":1,"name":"a"}},
+		{"idempotency_key":"k2","op":"insert","payload":{"name":"b"}},
+		{"idempotency_key":"k3","op":"upsert","payload":{"id":3,"name":7,"extra":true}},
+		{"idempotency_key":"k4","op":"delete","payload":{"id":4}}
+	]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/ingest/events", strings.NewReader(body))
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	var response struct {
+		ErrorCode string `json:"error_code"`
+		Context   struct {
+			Violations     []ingestViolation `json:"violations"`
+			ViolationCount int               `json:"violation_count"`
+		} `json:"context"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response decode error: %v", err)
+	}
+	want := []ingestViolation{
+		{RecordIndex: 1, Field: "id", Message: "required field is missing"},
+		{RecordIndex: 2, Field: "name", Message: "expected string, got number"},
+		{RecordIndex: 2, Field: "extra", Message: "field is not declared in the table schema"},
+	}
+	if response.ErrorCode != "SCHEMA_VIOLATION" || response.Context.ViolationCount != len(want) || len(response.Context.Violations) != len(want) {
+		t.Fatalf("response = %s", rr.Body.String())
+	}
+	for i := range want {
+		if response.Context.Violations[i] != want[i] {
+			t.Fatalf("violations[%d] = %+v, want %+v", i, response.Context.Violations[i], want[i])
+		}
+	}
+	if len(busStub.publishedEvents) != 0 {
+		t.Fatalf("published events = %d, want 0", len(busStub.publishedEvents))
+	}
+}
+
+func TestIngestStreamStopsAtSchemaViolation(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+
+	busStub := &fakeIngestBus{}
+	h := NewHandler(cfg, Dependencies{CatalogRepo: strictSchemaRepo(), IngestBus: busStub, IngestStreamChunkRecords: 1})
+
+	body := `{"idempotency_key":"k1","op":"insert","payload":{"id":1}}` + "\n" + `{"idempotency_key":"k2","op":"insert","payload":{"id":"two"}}` + "\n"
+	req := httptest.NewRequest(http.MethodPost, "/v1/ingest/events", strings.NewReader(body))
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	var response struct {
+		ErrorCode string `json:"error_code"`
+		Context   struct {
+			RecordIndex int               `json:"record_index"`
+			Violations  []ingestViolation `json:"violations"`
+		} `json:"context"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response decode error: %v", err)
+	}
+	if response.ErrorCode != "SCHEMA_VIOLATION" || response.Context.RecordIndex != 1 || len(response.Context.Violations) != 1 || response.Context.Violations[0].Field != "id" {
+		t.Fatalf("response = %s", rr.Body.String())
+	}
+	if len(busStub.publishedEvents) != 1 {
+		t.Fatalf("published events = %d, want 1", len(busStub.publishedEvents))
+	}
+}
+
+func TestIngestCSVRejectsUndeclaredColumnsAndMissingRequiredCells(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+
+	busStub := &fakeIngestBus{}
+	h := NewHandler(cfg, Dependencies{CatalogRepo: strictSchemaRepo(), IngestBus: busStub})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/ingest/events", strings.NewReader("id,name,extra\n1,a,x\n"))
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	req.Header.Set("Content-Type", "text/csv")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "INVALID_CSV_HEADER") {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/ingest/events", strings.NewReader("id,name\n1,a\n,b\n"))
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	req.Header.Set("Content-Type", "text/csv")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	var response ingestCSVResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response decode error: %v", err)
+	}
+	if response.RejectedCount != 1 || len(response.Errors) != 1 || response.Errors[0].Column != "id" || response.Errors[0].Message != "required field is null" {
+		t.Fatalf("response = %s", rr.Body.String())
+	}
+	if len(busStub.publishedEvents) != 1 {
+		t.Fatalf("published events = %d, want 1", len(busStub.publishedEvents))
+	}
+}
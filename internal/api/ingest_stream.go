@@ -0,0 +1,254 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/bus"
+	"github.com/duckmesh/duckmesh/internal/observability"
+)
+
+const (
+	defaultIngestStreamChunkRecords = 500
+	maxIngestStreamLineBytes        = 4 << 20
+)
+
+type ingestChunkResult struct {
+	ChunkIndex         int   `json:"chunk_index"`
+	FirstRecordIndex   int   `json:"first_record_index"`
+	LastRecordIndex    int   `json:"last_record_index"`
+	RecordCount        int   `json:"record_count"`
+	AcceptedCount      int   `json:"accepted_count"`
+	DuplicateCount     int   `json:"duplicate_count"`
+	MaxVisibilityToken int64 `json:"max_visibility_token"`
+}
+
+type ingestStreamResponse struct {
+	ingestResponse
+	RecordCount int                 `json:"record_count"`
+	Chunks      []ingestChunkResult `json:"chunks"`
+}
+
+func isNDJSONRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return true
+	}
+	return false
+}
+
+// handleIngestStream reads newline-delimited ingest records and publishes them
+// in chunks as they arrive. Chunks published before a bad line stay published;
+// the error response lists them so producers can resume after the last one.
+func handleIngestStream(deps Dependencies, w http.ResponseWriter, r *http.Request, tenantID, tableName string) {
+	waitForVisibility, visibilityTimeoutMs, err := parseStreamVisibilityParams(r)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusBadRequest, "INVALID_QUERY_PARAM", err.Error(), false, nil)
+		return
+	}
+
+	tableDef, ok := resolveIngestTable(deps, w, r, tenantID, tableName)
+	if !ok {
+		return
+	}
+	schema, err := loadIngestSchema(r.Context(), deps, tableDef.TableID)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusInternalServerError, "CATALOG_ERROR", "failed to resolve table schema", true, map[string]any{"details": err.Error()})
+		return
+	}
+
+	publisher := newIngestChunkPublisher(deps, w, r)
+	recordIndex := 0
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxIngestStreamLineBytes)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var record ingestRecord
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&record); err != nil {
+			(&ingestRecordError{code: "INVALID_JSON", message: "invalid ingest record", details: err.Error()}).write(r, w, recordIndex, publisher.progress())
+			return
+		}
+		if decoder.More() || decoder.InputOffset() < int64(len(line)) {
+			(&ingestRecordError{code: "INVALID_JSON", message: "invalid ingest record", details: "unexpected data after JSON value"}).write(r, w, recordIndex, publisher.progress())
+			return
+		}
+		envelope, recordErr := buildIngestEnvelope(tenantID, tableDef.TableID, record)
+		if recordErr != nil {
+			recordErr.write(r, w, recordIndex, publisher.progress())
+			return
+		}
+		if violations := schema.validate(record); len(violations) > 0 {
+			errContext := publisher.progress()
+			errContext["violations"] = recordViolations(recordIndex, violations)
+			(&ingestRecordError{code: "SCHEMA_VIOLATION", message: "record does not match the table schema"}).write(r, w, recordIndex, errContext)
+			return
+		}
+		if err := publisher.add(envelope, recordIndex); err != nil {
+			publisher.writePublishError(err)
+			return
+		}
+		recordIndex++
+	}
+	if err := scanner.Err(); err != nil {
+		code, message := "STREAM_READ_FAILED", "failed to read ingest stream"
+		if errors.Is(err, bufio.ErrTooLong) {
+			code, message = "RECORD_TOO_LARGE", "ingest record exceeds maximum line size"
+		}
+		errContext := publisher.progress()
+		errContext["record_index"] = recordIndex
+		errContext["details"] = err.Error()
+		writeError(r.Context(), w, http.StatusBadRequest, code, message, false, errContext)
+		return
+	}
+	if err := publisher.flush(); err != nil {
+		publisher.writePublishError(err)
+		return
+	}
+	if recordIndex == 0 {
+		writeError(r.Context(), w, http.StatusBadRequest, "RECORDS_REQUIRED", "at least one record is required", false, nil)
+		return
+	}
+
+	response := publisher.response
+	response.RecordCount = recordIndex
+	response.setStatus()
+	if waitForVisibility && !response.waitForVisibility(r, w, deps, tenantID, visibilityTimeoutMs) {
+		return
+	}
+	observability.ObserveIngestAck(response.AcceptedCount, response.DuplicateCount, time.Since(publisher.start))
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// ingestChunkPublisher buffers envelopes from a streamed body and publishes
+// them to the ingest bus once a chunk is full. Record indexes refer to the
+// position in the request body, so skipped or rejected input is not counted
+// against chunk boundaries.
+type ingestChunkPublisher struct {
+	deps         Dependencies
+	w            http.ResponseWriter
+	r            *http.Request
+	controller   *http.ResponseController
+	chunkSize    int
+	start        time.Time
+	pending      []bus.Envelope
+	pendingFirst int
+	pendingLast  int
+	response     *ingestStreamResponse
+}
+
+func newIngestChunkPublisher(deps Dependencies, w http.ResponseWriter, r *http.Request) *ingestChunkPublisher {
+	chunkSize := deps.IngestStreamChunkRecords
+	if chunkSize <= 0 {
+		chunkSize = defaultIngestStreamChunkRecords
+	}
+	p := &ingestChunkPublisher{
+		deps:       deps,
+		w:          w,
+		r:          r,
+		controller: http.NewResponseController(w),
+		chunkSize:  chunkSize,
+		start:      time.Now(),
+		pending:    make([]bus.Envelope, 0, chunkSize),
+		response:   &ingestStreamResponse{Chunks: make([]ingestChunkResult, 0)},
+	}
+	p.extendDeadlines()
+	return p
+}
+
+func (p *ingestChunkPublisher) extendDeadlines() {
+	if p.deps.IngestStreamIdleTimeout <= 0 {
+		return
+	}
+	deadline := time.Now().Add(p.deps.IngestStreamIdleTimeout)
+	_ = p.controller.SetReadDeadline(deadline)
+	_ = p.controller.SetWriteDeadline(deadline)
+}
+
+func (p *ingestChunkPublisher) add(envelope bus.Envelope, recordIndex int) error {
+	if len(p.pending) == 0 {
+		p.pendingFirst = recordIndex
+	}
+	p.pending = append(p.pending, envelope)
+	p.pendingLast = recordIndex
+	if len(p.pending) >= p.chunkSize {
+		return p.flush()
+	}
+	return nil
+}
+
+func (p *ingestChunkPublisher) flush() error {
+	if len(p.pending) == 0 {
+		return nil
+	}
+	published, err := p.deps.IngestBus.Publish(p.r.Context(), p.pending)
+	if err != nil {
+		return err
+	}
+	chunk := ingestResponse{}
+	chunk.addPublished(published)
+	p.response.addPublished(published)
+	p.response.Chunks = append(p.response.Chunks, ingestChunkResult{
+		ChunkIndex:         len(p.response.Chunks),
+		FirstRecordIndex:   p.pendingFirst,
+		LastRecordIndex:    p.pendingLast,
+		RecordCount:        len(p.pending),
+		AcceptedCount:      chunk.AcceptedCount,
+		DuplicateCount:     chunk.DuplicateCount,
+		MaxVisibilityToken: chunk.MaxVisibilityToken,
+	})
+	p.pending = p.pending[:0]
+	p.extendDeadlines()
+	return nil
+}
+
+func (p *ingestChunkPublisher) progress() map[string]any {
+	return map[string]any{
+		"chunks":               p.response.Chunks,
+		"max_visibility_token": p.response.MaxVisibilityToken,
+	}
+}
+
+func (p *ingestChunkPublisher) writePublishError(err error) {
+	errContext := p.progress()
+	errContext["details"] = err.Error()
+	writeError(p.r.Context(), p.w, http.StatusInternalServerError, "INGEST_PUBLISH_FAILED", "failed to publish ingest events", true, errContext)
+}
+
+func parseStreamVisibilityParams(r *http.Request) (bool, int, error) {
+	query := r.URL.Query()
+	waitForVisibility := false
+	if raw := strings.TrimSpace(query.Get("wait_for_visibility")); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return false, 0, errors.New("wait_for_visibility must be a boolean")
+		}
+		waitForVisibility = parsed
+	}
+	timeoutMs := 0
+	if raw := strings.TrimSpace(query.Get("visibility_timeout_ms")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			return false, 0, errors.New("visibility_timeout_ms must be a positive integer")
+		}
+		timeoutMs = parsed
+	}
+	return waitForVisibility, timeoutMs, nil
+}
@@ -67,6 +67,88 @@ func TestIngestEndpointAcceptsAndCountsDuplicates(t *testing.T) {
 	}
 }
 
+func TestIngestEndpointPartialModeReportsPerRecordResults(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+
+	repo := schemaCatalogRepo{
+		fakeCatalogRepo: &fakeCatalogRepo{table: catalog.TableDef{TableID: 42, TenantID: "tenant-1", TableName: "events"}},
+		schemaJSON:      `{"id":{"type":"bigint","required":true}}`,
+	}
+	busStub := &fakeIngestBus{publishResults: []bus.PublishResult{
+		{EventID: "100", VisibilityToken: 100, Inserted: true},
+		{EventID: "90", VisibilityToken: 90, Inserted: false},
+	}}
+	h := NewHandler(cfg, Dependencies{CatalogRepo: repo, IngestBus: busStub})
+
+	body := `{"partial":true,"records":[
+		{"idempotency_key":"k1","op":"insert","payload":{"id":1}},
+		{"idempotency_key":"","op":"insert","payload":{"id":2}},
+		{"idempotency_key":"k3","op":"insert","payload":{"id":"three"}},
+		{"idempotency_key":"k4","op":"upsert","payload":{"id":4}}
+	]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/ingest/events", strings.NewReader(body))
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	var response ingestResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response decode error: %v", err)
+	}
+	if response.Status != "partial_rejected" || response.AcceptedCount != 1 || response.DuplicateCount != 1 || response.RejectedCount != 2 {
+		t.Fatalf("response = %s", rr.Body.String())
+	}
+	if len(response.Results) != 4 {
+		t.Fatalf("results = %+v", response.Results)
+	}
+	if got := response.Results[0]; got.Status != "accepted" || got.EventID != "100" || got.VisibilityToken != 100 {
+		t.Fatalf("results[0] = %+v", got)
+	}
+	if got := response.Results[1]; got.Status != "rejected" || got.ErrorCode != "IDEMPOTENCY_KEY_REQUIRED" {
+		t.Fatalf("results[1] = %+v", got)
+	}
+	if got := response.Results[2]; got.Status != "rejected" || got.ErrorCode != "SCHEMA_VIOLATION" || len(got.Violations) != 1 || got.Violations[0].Field != "id" {
+		t.Fatalf("results[2] = %+v", got)
+	}
+	if got := response.Results[3]; got.RecordIndex != 3 || got.Status != "duplicate" || got.EventID != "90" {
+		t.Fatalf("results[3] = %+v", got)
+	}
+	if len(busStub.publishedEvents) != 2 || busStub.publishedEvents[1].IdempotencyKey != "k4" {
+		t.Fatalf("published events = %+v", busStub.publishedEvents)
+	}
+}
+
+func TestIngestEndpointPartialModeWithNoValidRecords(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+
+	busStub := &fakeIngestBus{}
+	h := NewHandler(cfg, Dependencies{
+		CatalogRepo: &fakeCatalogRepo{table: catalog.TableDef{TableID: 42, TenantID: "tenant-1", TableName: "events"}},
+		IngestBus:   busStub,
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/ingest/events", strings.NewReader(`{"partial":true,"records":[{"idempotency_key":"k1","op":"merge","payload":{}}]}`))
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"status":"rejected"`) || !strings.Contains(rr.Body.String(), "INVALID_OP") {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	if len(busStub.publishedEvents) != 0 {
+		t.Fatalf("published events = %d, want 0", len(busStub.publishedEvents))
+	}
+}
+
 func TestIngestEndpointRequiresIngestWriterRole(t *testing.T) {
 	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{
 		"DUCKMESH_AUTH_REQUIRED": "true",
@@ -204,6 +286,123 @@ func TestIngestEndpointWaitForVisibilityReturnsVisible(t *testing.T) {
 	}
 }
 
+func TestIngestStreamPublishesNDJSONInChunks(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+
+	repo := &fakeCatalogRepo{table: catalog.TableDef{TableID: 42, TenantID: "tenant-1", TableName: "events"}}
+	busStub := &fakeIngestBus{}
+	h := NewHandler(cfg, Dependencies{CatalogRepo: repo, IngestBus: busStub, IngestStreamChunkRecords: 2})
+
+	lines := []string{
+		`{"idempotency_key":"k1","op":"insert","payload":{"id":1}}`,
+		`{"idempotency_key":"k2","op":"insert","payload":{"id":2}}`,
+		``,
+		`{"idempotency_key":"k3","op":"upsert","payload":{"id":3}}`,
+		`{"idempotency_key":"k4","op":"insert","payload":{"id":4}}`,
+		`{"idempotency_key":"k5","op":"delete","payload":{"id":1}}`,
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v1/ingest/events", strings.NewReader(strings.Join(lines, "\n")))
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	var response struct {
+		AcceptedCount      int   `json:"accepted_count"`
+		RecordCount        int   `json:"record_count"`
+		MaxVisibilityToken int64 `json:"max_visibility_token"`
+		Chunks             []struct {
+			FirstRecordIndex int `json:"first_record_index"`
+			RecordCount      int `json:"record_count"`
+		} `json:"chunks"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response decode error: %v", err)
+	}
+	if response.AcceptedCount != 5 || response.RecordCount != 5 || response.MaxVisibilityToken != 1 {
+		t.Fatalf("response = %+v", response)
+	}
+	if len(response.Chunks) != 3 || response.Chunks[2].FirstRecordIndex != 4 || response.Chunks[2].RecordCount != 1 {
+		t.Fatalf("chunks = %+v", response.Chunks)
+	}
+	if len(busStub.publishedEvents) != 5 || busStub.publishedEvents[4].Op != "delete" {
+		t.Fatalf("published events = %+v", busStub.publishedEvents)
+	}
+}
+
+func TestIngestStreamReportsBadLineAfterPublishedChunks(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+
+	repo := &fakeCatalogRepo{table: catalog.TableDef{TableID: 42, TenantID: "tenant-1", TableName: "events"}}
+	busStub := &fakeIngestBus{}
+	h := NewHandler(cfg, Dependencies{CatalogRepo: repo, IngestBus: busStub, IngestStreamChunkRecords: 1})
+
+	body := `{"idempotency_key":"k1","op":"insert","payload":{"id":1}}` + "\n" + `{"idempotency_key":"k2","op":"merge","payload":{"id":2}}` + "\n"
+	req := httptest.NewRequest(http.MethodPost, "/v1/ingest/events", strings.NewReader(body))
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	var response struct {
+		ErrorCode string `json:"error_code"`
+		Context   struct {
+			RecordIndex int              `json:"record_index"`
+			Chunks      []map[string]any `json:"chunks"`
+		} `json:"context"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response decode error: %v", err)
+	}
+	if response.ErrorCode != "INVALID_OP" || response.Context.RecordIndex != 1 || len(response.Context.Chunks) != 1 {
+		t.Fatalf("response = %s", rr.Body.String())
+	}
+	if len(busStub.publishedEvents) != 1 {
+		t.Fatalf("published events = %d, want 1", len(busStub.publishedEvents))
+	}
+}
+
+func TestIngestStreamRejectsTrailingDataOnLine(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+
+	repo := &fakeCatalogRepo{table: catalog.TableDef{TableID: 42, TenantID: "tenant-1", TableName: "events"}}
+	busStub := &fakeIngestBus{}
+	h := NewHandler(cfg, Dependencies{CatalogRepo: repo, IngestBus: busStub})
+
+	for _, line := range []string{
+		`{"idempotency_key":"k1","op":"insert","payload":{"id":1}} trailing-garbage`,
+		`{"idempotency_key":"k1","op":"insert","payload":{"id":1}}{"idempotency_key":"k2","op":"insert","payload":{"id":2}}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/ingest/events", strings.NewReader(line+"\n"))
+		req.Header.Set("X-Tenant-ID", "tenant-1")
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `"INVALID_JSON"`) {
+			t.Fatalf("%s: status = %d, body = %s", line, rr.Code, rr.Body.String())
+		}
+	}
+	if len(busStub.publishedEvents) != 0 {
+		t.Fatalf("published events = %d, want 0", len(busStub.publishedEvents))
+	}
+}
+
 type fakeIngestBus struct {
 	publishResults  []bus.PublishResult
 	publishedEvents []bus.Envelope
@@ -61,6 +61,7 @@ func handleLag(deps Dependencies, w http.ResponseWriter, r *http.Request) {
 		"accepted_events":         stats.AcceptedEvents,
 		"claimed_events":          stats.ClaimedEvents,
 		"pending_events":          pending,
+		"failed_events":           stats.FailedEvents,
 		"oldest_pending_lag_ms":   oldestPendingLagMs,
 		"token_lag":               tokenLag,
 		"latest_visibility_token": stats.LatestVisibilityToken,
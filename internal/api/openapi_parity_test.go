@@ -28,11 +28,18 @@ func TestOpenAPIContainsImplementedOperationalPaths(t *testing.T) {
 		"/v1/metrics:",
 		"/v1/tables:",
 		"/v1/tables/{table}:",
+		"/v1/tables/{table}/stats:",
 		"/v1/ingest/{table}:",
 		"/v1/query:",
+		"/v1/query/jobs:",
+		"/v1/query/jobs/{id}:",
+		"/v1/query/jobs/{id}/result:",
 		"/v1/ui/schema:",
 		"/v1/query/translate:",
 		"/v1/lag:",
+		"/v1/ingest/failed:",
+		"/v1/ingest/failed/replay:",
+		"/v1/ingest/failed/discard:",
 		"/v1/compaction/run:",
 		"/v1/retention/run:",
 		"/v1/integrity/run:",
@@ -9,18 +9,19 @@ import (
 	"time"
 
 	"github.com/duckmesh/duckmesh/internal/catalog"
+	"github.com/duckmesh/duckmesh/internal/notify"
 	"github.com/duckmesh/duckmesh/internal/observability"
 	"github.com/duckmesh/duckmesh/internal/query"
 )
 
 type queryRequest struct {
-	SQL                  string         `json:"sql"`
-	Params               map[string]any `json:"params"`
-	SnapshotID           *int64         `json:"snapshot_id"`
-	SnapshotTime         *time.Time     `json:"snapshot_time"`
-	MinVisibilityToken   *int64         `json:"min_visibility_token"`
-	ConsistencyTimeoutMs int            `json:"consistency_timeout_ms"`
-	RowLimit             int            `json:"row_limit"`
+	SQL                  string          `json:"sql"`
+	Params               json.RawMessage `json:"params"`
+	SnapshotID           *int64          `json:"snapshot_id"`
+	SnapshotTime         *time.Time      `json:"snapshot_time"`
+	MinVisibilityToken   *int64          `json:"min_visibility_token"`
+	ConsistencyTimeoutMs int             `json:"consistency_timeout_ms"`
+	RowLimit             int             `json:"row_limit"`
 }
 
 type queryResponse struct {
@@ -56,21 +57,74 @@ func handleQuery(deps Dependencies, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if strings.TrimSpace(request.SQL) == "" {
-		writeError(r.Context(), w, http.StatusBadRequest, "SQL_REQUIRED", "sql is required", false, nil)
+	plan, ok := planQuery(deps, w, r, tenantID, request)
+	if !ok {
 		return
 	}
-	if !isAllowedSQL(request.SQL) {
-		writeError(r.Context(), w, http.StatusBadRequest, "SQL_NOT_ALLOWED", "only read-only SELECT/WITH queries are allowed", false, nil)
+	if format := queryStreamFormat(r.Header.Get("Accept")); format != "" {
+		streamQueryResult(deps, w, r, format, plan.request, plan.snapshot, plan.pruned)
 		return
 	}
-	if len(request.Params) > 0 {
-		writeError(r.Context(), w, http.StatusBadRequest, "PARAMS_UNSUPPORTED", "query params are not supported yet", false, nil)
+
+	result, err := deps.QueryEngine.Execute(r.Context(), plan.request)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusBadRequest, "QUERY_EXECUTION_FAILED", "query execution failed", false, map[string]any{"details": err.Error()})
 		return
 	}
+
+	writeJSON(w, http.StatusOK, queryResponse{
+		Columns:            result.Columns,
+		Rows:               result.Rows,
+		SnapshotID:         plan.snapshot.SnapshotID,
+		SnapshotTime:       plan.snapshot.CreatedAt,
+		MaxVisibilityToken: plan.snapshot.MaxVisibilityToken,
+		Stats: map[string]any{
+			"duration_ms":   result.Duration.Milliseconds(),
+			"scanned_files": result.ScannedFiles,
+			"scanned_bytes": result.ScannedBytes,
+			"pruned_files":  plan.pruned.count,
+			"pruned_bytes":  plan.pruned.bytes,
+		},
+	})
+}
+
+// queryPlan is a checked statement with the snapshot it reads and the files
+// left after pruning.
+type queryPlan struct {
+	request  query.Request
+	snapshot catalog.Snapshot
+	pruned   prunedFiles
+}
+
+// planQuery validates a query request against the policy and resolves its
+// snapshot and files. It writes the error response and reports false when
+// the query cannot run.
+func planQuery(deps Dependencies, w http.ResponseWriter, r *http.Request, tenantID string, request queryRequest) (queryPlan, bool) {
+	if strings.TrimSpace(request.SQL) == "" {
+		writeError(r.Context(), w, http.StatusBadRequest, "SQL_REQUIRED", "sql is required", false, nil)
+		return queryPlan{}, false
+	}
+	statement, err := deps.QueryEngine.Inspect(r.Context(), request.SQL)
+	if err != nil {
+		if errors.Is(err, query.ErrInvalidSQL) {
+			writeError(r.Context(), w, http.StatusBadRequest, "SQL_PARSE_FAILED", "sql could not be parsed", false, map[string]any{"details": err.Error()})
+			return queryPlan{}, false
+		}
+		writeError(r.Context(), w, http.StatusInternalServerError, "QUERY_PLANNING_FAILED", "failed to inspect sql", true, map[string]any{"details": err.Error()})
+		return queryPlan{}, false
+	}
+	if err := deps.QueryPolicy.Check(tenantID, statement); err != nil {
+		writeSQLNotAllowed(r, w, err)
+		return queryPlan{}, false
+	}
+	params, err := parseQueryParams(request.Params)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusBadRequest, "INVALID_PARAMS", "invalid query params", false, map[string]any{"details": err.Error()})
+		return queryPlan{}, false
+	}
 	if request.SnapshotID != nil && request.SnapshotTime != nil {
 		writeError(r.Context(), w, http.StatusBadRequest, "SNAPSHOT_SELECTOR_CONFLICT", "specify only one of snapshot_id or snapshot_time", false, nil)
-		return
+		return queryPlan{}, false
 	}
 
 	var snapshot catalog.Snapshot
@@ -84,21 +138,28 @@ func handleQuery(deps Dependencies, w http.ResponseWriter, r *http.Request) {
 	}
 	if err != nil {
 		handleSnapshotResolutionError(r, w, err)
-		return
+		return queryPlan{}, false
 	}
 
 	files, err := deps.CatalogRepo.ListSnapshotFiles(r.Context(), tenantID, snapshot.SnapshotID)
 	if err != nil {
 		writeError(r.Context(), w, http.StatusInternalServerError, "CATALOG_ERROR", "failed to load snapshot files", true, map[string]any{"details": err.Error()})
-		return
+		return queryPlan{}, false
 	}
 	if len(files) == 0 {
 		writeError(r.Context(), w, http.StatusNotFound, "SNAPSHOT_NOT_FOUND", "snapshot has no queryable files", false, map[string]any{"snapshot_id": snapshot.SnapshotID})
-		return
+		return queryPlan{}, false
 	}
 
-	queryFiles := make([]query.TableFile, 0, len(files))
-	for _, file := range files {
+	if err := query.CheckTables(statement, snapshotTables(files)); err != nil {
+		writeSQLNotAllowed(r, w, err)
+		return queryPlan{}, false
+	}
+	files = referencedFiles(files, statement)
+
+	pruned := pruneSnapshotFiles(r.Context(), deps, tenantID, request.SQL, files, time.Now())
+	queryFiles := make([]query.TableFile, 0, len(pruned.files))
+	for _, file := range pruned.files {
 		queryFiles = append(queryFiles, query.TableFile{
 			TableName:     file.TableName,
 			ObjectPath:    file.Path,
@@ -106,28 +167,17 @@ func handleQuery(deps Dependencies, w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	result, err := deps.QueryEngine.Execute(r.Context(), query.Request{
-		SQL:      request.SQL,
-		RowLimit: request.RowLimit,
-		Files:    queryFiles,
-	})
-	if err != nil {
-		writeError(r.Context(), w, http.StatusBadRequest, "QUERY_EXECUTION_FAILED", "query execution failed", false, map[string]any{"details": err.Error()})
-		return
-	}
-
-	writeJSON(w, http.StatusOK, queryResponse{
-		Columns:            result.Columns,
-		Rows:               result.Rows,
-		SnapshotID:         snapshot.SnapshotID,
-		SnapshotTime:       snapshot.CreatedAt,
-		MaxVisibilityToken: snapshot.MaxVisibilityToken,
-		Stats: map[string]any{
-			"duration_ms":   result.Duration.Milliseconds(),
-			"scanned_files": result.ScannedFiles,
-			"scanned_bytes": result.ScannedBytes,
+	return queryPlan{
+		request: query.Request{
+			SQL:         request.SQL,
+			RowLimit:    request.RowLimit,
+			Files:       queryFiles,
+			PrimaryKeys: snapshotPrimaryKeys(files),
+			Params:      params,
 		},
-	})
+		snapshot: snapshot,
+		pruned:   pruned,
+	}, true
 }
 
 func resolveSnapshotWithBarrier(r *http.Request, deps Dependencies, tenantID string, minToken *int64, timeoutMs int) (catalog.Snapshot, error) {
@@ -142,6 +192,16 @@ func resolveSnapshotWithBarrier(r *http.Request, deps Dependencies, tenantID str
 	}
 	deadline := time.Now().Add(timeout)
 	pollEvery := 50 * time.Millisecond
+	var wakeups <-chan struct{}
+	if deps.SnapshotNotifications != nil {
+		var unsubscribe func()
+		wakeups, unsubscribe = deps.SnapshotNotifications.Subscribe(notify.ChannelSnapshot, tenantID)
+		defer unsubscribe()
+		pollEvery = deps.BarrierFallbackPoll
+		if pollEvery <= 0 {
+			pollEvery = time.Second
+		}
+	}
 
 	for {
 		snapshot, err := deps.CatalogRepo.GetLatestSnapshot(r.Context(), tenantID)
@@ -160,10 +220,14 @@ func resolveSnapshotWithBarrier(r *http.Request, deps Dependencies, tenantID str
 			observability.IncrementConsistencyTimeout()
 			return catalog.Snapshot{}, &consistencyTimeoutError{RequestedToken: *minToken, LatestToken: latest}
 		}
+		timer := time.NewTimer(min(pollEvery, time.Until(deadline)))
 		select {
 		case <-r.Context().Done():
+			timer.Stop()
 			return catalog.Snapshot{}, r.Context().Err()
-		case <-time.After(pollEvery):
+		case <-wakeups:
+			timer.Stop()
+		case <-timer.C:
 		}
 	}
 }
@@ -184,15 +248,16 @@ func handleSnapshotResolutionError(r *http.Request, w http.ResponseWriter, err e
 	writeError(r.Context(), w, http.StatusInternalServerError, "CATALOG_ERROR", "failed to resolve snapshot", true, map[string]any{"details": err.Error()})
 }
 
-func isAllowedSQL(sqlText string) bool {
-	normalized := strings.ToLower(strings.TrimSpace(sqlText))
-	if normalized == "" {
-		return false
-	}
-	if strings.HasPrefix(normalized, "select") || strings.HasPrefix(normalized, "with") {
-		return true
+func writeSQLNotAllowed(r *http.Request, w http.ResponseWriter, err error) {
+	details := map[string]any{}
+	var violation *query.Violation
+	if errors.As(err, &violation) {
+		details["reason"] = violation.Reason
+		if violation.Name != "" {
+			details["name"] = violation.Name
+		}
 	}
-	return false
+	writeError(r.Context(), w, http.StatusBadRequest, "SQL_NOT_ALLOWED", err.Error(), false, details)
 }
 
 type consistencyTimeoutError struct {
@@ -203,3 +268,49 @@ type consistencyTimeoutError struct {
 func (e *consistencyTimeoutError) Error() string {
 	return fmt.Sprintf("consistency timeout waiting for token %d (latest=%d)", e.RequestedToken, e.LatestToken)
 }
+
+// referencedFiles keeps the files of the tables a statement reads. Table
+// names match case-insensitively, as DuckDB resolves them.
+func referencedFiles(files []catalog.SnapshotFileEntry, statement query.Statement) []catalog.SnapshotFileEntry {
+	if statement.All {
+		return files
+	}
+	names := make(map[string]bool, len(statement.Tables))
+	for _, table := range statement.Tables {
+		names[strings.ToLower(table.Name)] = true
+	}
+	kept := make([]catalog.SnapshotFileEntry, 0, len(files))
+	for _, file := range files {
+		if names[strings.ToLower(file.TableName)] {
+			kept = append(kept, file)
+		}
+	}
+	return kept
+}
+
+func snapshotTables(files []catalog.SnapshotFileEntry) []string {
+	seen := map[string]bool{}
+	tables := make([]string, 0)
+	for _, file := range files {
+		if !seen[file.TableName] {
+			seen[file.TableName] = true
+			tables = append(tables, file.TableName)
+		}
+	}
+	return tables
+}
+
+func snapshotPrimaryKeys(files []catalog.SnapshotFileEntry) map[string][]string {
+	primaryKeys := map[string][]string{}
+	for _, file := range files {
+		if _, ok := primaryKeys[file.TableName]; ok || len(file.PrimaryKeyCols) == 0 {
+			continue
+		}
+		var columns []string
+		if err := json.Unmarshal(file.PrimaryKeyCols, &columns); err != nil || len(columns) == 0 {
+			continue
+		}
+		primaryKeys[file.TableName] = columns
+	}
+	return primaryKeys
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/decimal128"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+
+	"github.com/duckmesh/duckmesh/internal/query"
+)
+
+// arrowEncoder writes an Arrow IPC stream with one record batch per flush.
+// Nothing is written until the first batch, so it can be built before the
+// response status is sent.
+type arrowEncoder struct {
+	writer  *ipc.Writer
+	builder *array.RecordBuilder
+	textual []bool
+}
+
+func newArrowEncoder(w io.Writer, columns []query.Column) *arrowEncoder {
+	fields := make([]arrow.Field, len(columns))
+	textual := make([]bool, len(columns))
+	for i, column := range columns {
+		fieldType, ok := arrowType(column.Type)
+		fields[i] = arrow.Field{Name: column.Name, Type: fieldType, Nullable: true}
+		textual[i] = !ok
+	}
+	schema := arrow.NewSchema(fields, nil)
+	return &arrowEncoder{
+		writer:  ipc.NewWriter(w, ipc.WithSchema(schema)),
+		builder: array.NewRecordBuilder(memory.DefaultAllocator, schema),
+		textual: textual,
+	}
+}
+
+func (e *arrowEncoder) writeRows(rows [][]any) error {
+	for _, row := range rows {
+		for i, value := range row {
+			if e.textual[i] && value != nil {
+				value = textValue(value)
+			}
+			if err := appendArrowValue(e.builder.Field(i), value); err != nil {
+				return fmt.Errorf("encode column %s: %w", e.builder.Schema().Field(i).Name, err)
+			}
+		}
+	}
+	record := e.builder.NewRecordBatch()
+	defer record.Release()
+	return e.writer.Write(record)
+}
+
+func (e *arrowEncoder) close() error {
+	e.builder.Release()
+	return e.writer.Close()
+}
+
+// arrowType maps a DuckDB type name to an Arrow type. Types without a
+// direct counterpart, such as lists, structs, maps, intervals, TIME and
+// HUGEINT, report false and are sent as Utf8 text.
+func arrowType(duckdbType string) (arrow.DataType, bool) {
+	types := map[string]arrow.DataType{
+		"TINYINT":      arrow.PrimitiveTypes.Int8,
+		"SMALLINT":     arrow.PrimitiveTypes.Int16,
+		"INTEGER":      arrow.PrimitiveTypes.Int32,
+		"BIGINT":       arrow.PrimitiveTypes.Int64,
+		"UTINYINT":     arrow.PrimitiveTypes.Uint8,
+		"USMALLINT":    arrow.PrimitiveTypes.Uint16,
+		"UINTEGER":     arrow.PrimitiveTypes.Uint32,
+		"UBIGINT":      arrow.PrimitiveTypes.Uint64,
+		"FLOAT":        arrow.PrimitiveTypes.Float32,
+		"DOUBLE":       arrow.PrimitiveTypes.Float64,
+		"BOOLEAN":      arrow.FixedWidthTypes.Boolean,
+		"VARCHAR":      arrow.BinaryTypes.String,
+		"ENUM":         arrow.BinaryTypes.String,
+		"BLOB":         arrow.BinaryTypes.Binary,
+		"DATE":         arrow.FixedWidthTypes.Date32,
+		"TIMESTAMP_S":  &arrow.TimestampType{Unit: arrow.Second},
+		"TIMESTAMP_MS": &arrow.TimestampType{Unit: arrow.Millisecond},
+		"TIMESTAMP":    &arrow.TimestampType{Unit: arrow.Microsecond},
+		"TIMESTAMP_NS": &arrow.TimestampType{Unit: arrow.Nanosecond},
+		"TIMESTAMPTZ":  &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"},
+	}
+	if fieldType, ok := types[duckdbType]; ok {
+		return fieldType, true
+	}
+
+	var precision, scale int32
+	if _, err := fmt.Sscanf(duckdbType, "DECIMAL(%d,%d)", &precision, &scale); err == nil && precision <= 38 {
+		return &arrow.Decimal128Type{Precision: precision, Scale: scale}, true
+	}
+	return arrow.BinaryTypes.String, false
+}
+
+// appendArrowValue appends one result value. Decimals arrive as exact
+// strings and BLOBs as strings, as normalized by the query engine.
+func appendArrowValue(builder array.Builder, value any) error {
+	if value == nil {
+		builder.AppendNull()
+		return nil
+	}
+	switch b := builder.(type) {
+	case *array.Int8Builder, *array.Int16Builder, *array.Int32Builder, *array.Int64Builder,
+		*array.Uint8Builder, *array.Uint16Builder, *array.Uint32Builder, *array.Uint64Builder:
+		return appendArrowInteger(builder, value)
+	case *array.Float32Builder:
+		if v, ok := value.(float32); ok {
+			b.Append(v)
+			return nil
+		}
+	case *array.Float64Builder:
+		if v, ok := value.(float64); ok {
+			b.Append(v)
+			return nil
+		}
+	case *array.BooleanBuilder:
+		if v, ok := value.(bool); ok {
+			b.Append(v)
+			return nil
+		}
+	case *array.StringBuilder:
+		if v, ok := value.(string); ok {
+			b.Append(v)
+			return nil
+		}
+	case *array.BinaryBuilder:
+		switch v := value.(type) {
+		case string:
+			b.AppendString(v)
+			return nil
+		case []byte:
+			b.Append(v)
+			return nil
+		}
+	case *array.Date32Builder:
+		if v, ok := value.(time.Time); ok {
+			b.Append(arrow.Date32FromTime(v))
+			return nil
+		}
+	case *array.TimestampBuilder:
+		if v, ok := value.(time.Time); ok {
+			ticks, err := arrow.TimestampFromTime(v, b.Type().(*arrow.TimestampType).Unit)
+			if err != nil {
+				return err
+			}
+			b.Append(ticks)
+			return nil
+		}
+	case *array.Decimal128Builder:
+		decimalType := b.Type().(*arrow.Decimal128Type)
+		var num decimal128.Num
+		switch v := value.(type) {
+		case string:
+			parsed, err := decimal128.FromString(v, decimalType.Precision, decimalType.Scale)
+			if err != nil {
+				return err
+			}
+			num = parsed
+		case *big.Int:
+			num = decimal128.FromBigInt(v)
+		default:
+			return fmt.Errorf("expected decimal, got %T", value)
+		}
+		if !num.FitsInPrecision(decimalType.Precision) {
+			return fmt.Errorf("decimal %v exceeds precision %d", value, decimalType.Precision)
+		}
+		b.Append(num)
+		return nil
+	}
+	return fmt.Errorf("unexpected %T for %s", value, builder.Type())
+}
+
+func appendArrowInteger(builder array.Builder, value any) error {
+	var signed int64
+	var unsigned uint64
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		signed, unsigned = v.Int(), uint64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		signed, unsigned = int64(v.Uint()), v.Uint()
+	default:
+		return fmt.Errorf("expected integer, got %T", value)
+	}
+	switch b := builder.(type) {
+	case *array.Int8Builder:
+		b.Append(int8(signed))
+	case *array.Int16Builder:
+		b.Append(int16(signed))
+	case *array.Int32Builder:
+		b.Append(int32(signed))
+	case *array.Int64Builder:
+		b.Append(signed)
+	case *array.Uint8Builder:
+		b.Append(uint8(unsigned))
+	case *array.Uint16Builder:
+		b.Append(uint16(unsigned))
+	case *array.Uint32Builder:
+		b.Append(uint32(unsigned))
+	case *array.Uint64Builder:
+		b.Append(unsigned)
+	}
+	return nil
+}
+
+// textValue renders a value without an Arrow type: strings as is, times of
+// day as HH:MM:SS.ffffff and everything else as JSON.
+func textValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format("15:04:05.999999")
+	case fmt.Stringer:
+		return v.String()
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+
+	"github.com/duckmesh/duckmesh/internal/query"
+)
+
+func TestArrowEncoderRoundTripsThroughIPCReader(t *testing.T) {
+	columns := []query.Column{
+		{Name: "tiny", Type: "TINYINT"},
+		{Name: "big", Type: "UBIGINT"},
+		{Name: "ratio", Type: "FLOAT"},
+		{Name: "ok", Type: "BOOLEAN"},
+		{Name: "raw", Type: "BLOB"},
+		{Name: "day", Type: "DATE"},
+		{Name: "at", Type: "TIMESTAMPTZ"},
+		{Name: "amount", Type: "DECIMAL(10,2)"},
+		{Name: "clock", Type: "TIME"},
+	}
+	day := time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC)
+	at := time.Date(2026, 2, 19, 10, 0, 0, 123456000, time.UTC)
+	clock := time.Date(1, 1, 1, 13, 45, 30, 0, time.UTC)
+
+	var out bytes.Buffer
+	encoder := newArrowEncoder(&out, columns)
+	if out.Len() != 0 {
+		t.Fatal("the encoder should not write before the first batch")
+	}
+	if err := encoder.writeRows([][]any{{int8(-3), uint64(1) << 63, float32(0.5), true, "\x00\x01", day, at, "-0.01", clock}}); err != nil {
+		t.Fatalf("writeRows() error = %v", err)
+	}
+	if err := encoder.writeRows([][]any{{nil, nil, nil, nil, nil, nil, nil, nil, nil}}); err != nil {
+		t.Fatalf("writeRows() error = %v", err)
+	}
+	if err := encoder.close(); err != nil {
+		t.Fatalf("close() error = %v", err)
+	}
+
+	reader, err := ipc.NewReader(&out)
+	if err != nil {
+		t.Fatalf("ipc.NewReader() error = %v", err)
+	}
+	defer reader.Release()
+	if got := reader.Schema().Field(6).Type; !arrow.TypeEqual(got, &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}) {
+		t.Fatalf("timestamp type = %s", got)
+	}
+
+	if !reader.Next() {
+		t.Fatalf("no first batch: %v", reader.Err())
+	}
+	record := reader.RecordBatch()
+	if got := record.Column(0).(*array.Int8).Value(0); got != -3 {
+		t.Fatalf("tiny = %d", got)
+	}
+	if got := record.Column(1).(*array.Uint64).Value(0); got != 1<<63 {
+		t.Fatalf("big = %d", got)
+	}
+	if got := record.Column(2).(*array.Float32).Value(0); got != 0.5 {
+		t.Fatalf("ratio = %v", got)
+	}
+	if !record.Column(3).(*array.Boolean).Value(0) || string(record.Column(4).(*array.Binary).Value(0)) != "\x00\x01" {
+		t.Fatal("bool or blob mismatch")
+	}
+	if got := record.Column(5).(*array.Date32).Value(0); got != -1 {
+		t.Fatalf("day = %d", got)
+	}
+	if got := record.Column(6).(*array.Timestamp).Value(0); int64(got) != at.UnixMicro() {
+		t.Fatalf("at = %d", got)
+	}
+	if got := record.Column(7).(*array.Decimal128).Value(0).BigInt().Int64(); got != -1 {
+		t.Fatalf("amount = %d", got)
+	}
+	if got := record.Column(8).(*array.String).Value(0); got != "13:45:30" {
+		t.Fatalf("clock = %q", got)
+	}
+
+	if !reader.Next() {
+		t.Fatalf("no second batch: %v", reader.Err())
+	}
+	for i, column := range reader.RecordBatch().Columns() {
+		if !column.IsNull(0) {
+			t.Fatalf("column %d should be null", i)
+		}
+	}
+	if reader.Next() || reader.Err() != nil {
+		t.Fatalf("unexpected trailing batch, err = %v", reader.Err())
+	}
+}
+
+func TestArrowEncoderRejectsMismatchedValues(t *testing.T) {
+	encoder := newArrowEncoder(&bytes.Buffer{}, []query.Column{{Name: "amount", Type: "DECIMAL(4,2)"}})
+	if err := encoder.writeRows([][]any{{"123.45"}}); err == nil {
+		t.Fatal("a decimal wider than its precision should fail")
+	}
+	encoder = newArrowEncoder(&bytes.Buffer{}, []query.Column{{Name: "id", Type: "BIGINT"}})
+	if err := encoder.writeRows([][]any{{"1"}}); err == nil {
+		t.Fatal("a string in an integer column should fail")
+	}
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/catalog"
+	"github.com/duckmesh/duckmesh/internal/query"
+	"github.com/duckmesh/duckmesh/internal/queryjob"
+)
+
+type QueryJobRunner interface {
+	Submit(ctx context.Context, request queryjob.SubmitRequest) (catalog.QueryJob, error)
+	Get(ctx context.Context, tenantID string, jobID int64) (catalog.QueryJob, error)
+	Cancel(ctx context.Context, tenantID string, jobID int64) (catalog.QueryJob, error)
+	Delete(ctx context.Context, tenantID string, jobID int64) error
+	OpenResult(ctx context.Context, tenantID string, jobID int64) (catalog.QueryJob, io.ReadCloser, error)
+}
+
+type queryJobRequest struct {
+	queryRequest
+	Format string `json:"format"`
+}
+
+type queryJobResponse struct {
+	JobID              int64      `json:"job_id"`
+	State              string     `json:"state"`
+	SQL                string     `json:"sql"`
+	Format             string     `json:"format"`
+	SnapshotID         int64      `json:"snapshot_id"`
+	MaxVisibilityToken int64      `json:"max_visibility_token"`
+	CancelRequested    bool       `json:"cancel_requested"`
+	ResultURL          string     `json:"result_url,omitempty"`
+	ResultRows         int64      `json:"result_rows"`
+	ResultBytes        int64      `json:"result_bytes"`
+	ScannedBytes       int64      `json:"scanned_bytes"`
+	Error              string     `json:"error,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	StartedAt          *time.Time `json:"started_at,omitempty"`
+	FinishedAt         *time.Time `json:"finished_at,omitempty"`
+}
+
+var queryJobContentTypes = map[string]string{
+	string(query.ExportParquet): "application/vnd.apache.parquet",
+	string(query.ExportCSV):     "text/csv",
+}
+
+func newQueryJobResponse(job catalog.QueryJob) queryJobResponse {
+	response := queryJobResponse{
+		JobID:              job.JobID,
+		State:              string(job.State),
+		SQL:                job.SQL,
+		Format:             job.Format,
+		SnapshotID:         job.SnapshotID,
+		MaxVisibilityToken: job.MaxVisibilityToken,
+		CancelRequested:    job.CancelRequested,
+		ResultRows:         job.ResultRows,
+		ResultBytes:        job.ResultBytes,
+		ScannedBytes:       job.ScannedBytes,
+		Error:              job.Error,
+		CreatedAt:          job.CreatedAt,
+		StartedAt:          job.StartedAt,
+		FinishedAt:         job.FinishedAt,
+	}
+	if job.State == catalog.QueryJobSucceeded {
+		response.ResultURL = fmt.Sprintf("/v1/query/jobs/%d/result", job.JobID)
+	}
+	return response
+}
+
+func handleSubmitQueryJob(deps Dependencies, w http.ResponseWriter, r *http.Request) {
+	if deps.CatalogRepo == nil || deps.QueryEngine == nil || deps.QueryJobs == nil {
+		writeError(r.Context(), w, http.StatusNotImplemented, "QUERY_JOBS_NOT_CONFIGURED", "query job dependencies are not configured", false, nil)
+		return
+	}
+
+	tenantID, err := tenantFromRequest(r)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusUnauthorized, "TENANT_REQUIRED", err.Error(), false, nil)
+		return
+	}
+	if err := requireRole(r, "query_reader"); err != nil {
+		writeError(r.Context(), w, http.StatusForbidden, "FORBIDDEN", err.Error(), false, nil)
+		return
+	}
+
+	var request queryJobRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeError(r.Context(), w, http.StatusBadRequest, "INVALID_JSON", "invalid query job request body", false, map[string]any{"details": err.Error()})
+		return
+	}
+	format := strings.ToLower(strings.TrimSpace(request.Format))
+	if format == "" {
+		format = string(query.ExportParquet)
+	}
+	if _, ok := queryJobContentTypes[format]; !ok {
+		writeError(r.Context(), w, http.StatusBadRequest, "INVALID_FORMAT", "format must be parquet or csv", false, map[string]any{"format": request.Format})
+		return
+	}
+
+	plan, ok := planQuery(deps, w, r, tenantID, request.queryRequest)
+	if !ok {
+		return
+	}
+	job, err := deps.QueryJobs.Submit(r.Context(), queryjob.SubmitRequest{
+		TenantID:           tenantID,
+		Format:             query.ExportFormat(format),
+		Request:            plan.request,
+		SnapshotID:         plan.snapshot.SnapshotID,
+		MaxVisibilityToken: plan.snapshot.MaxVisibilityToken,
+	})
+	if err != nil {
+		writeError(r.Context(), w, http.StatusInternalServerError, "QUERY_JOB_FAILED", "failed to submit query job", true, map[string]any{"details": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, newQueryJobResponse(job))
+}
+
+func handleGetQueryJob(deps Dependencies, w http.ResponseWriter, r *http.Request) {
+	tenantID, jobID, ok := queryJobFromRequest(deps, w, r)
+	if !ok {
+		return
+	}
+	job, err := deps.QueryJobs.Get(r.Context(), tenantID, jobID)
+	if err != nil {
+		writeQueryJobError(r, w, jobID, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newQueryJobResponse(job))
+}
+
+// handleDeleteQueryJob cancels a job that has not finished yet and removes
+// a finished one together with its result.
+func handleDeleteQueryJob(deps Dependencies, w http.ResponseWriter, r *http.Request) {
+	tenantID, jobID, ok := queryJobFromRequest(deps, w, r)
+	if !ok {
+		return
+	}
+	job, err := deps.QueryJobs.Cancel(r.Context(), tenantID, jobID)
+	if err == nil {
+		writeJSON(w, http.StatusAccepted, newQueryJobResponse(job))
+		return
+	}
+	if !errors.Is(err, catalog.ErrConflict) {
+		writeQueryJobError(r, w, jobID, err)
+		return
+	}
+	if err := deps.QueryJobs.Delete(r.Context(), tenantID, jobID); err != nil {
+		writeQueryJobError(r, w, jobID, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleGetQueryJobResult(deps Dependencies, w http.ResponseWriter, r *http.Request) {
+	tenantID, jobID, ok := queryJobFromRequest(deps, w, r)
+	if !ok {
+		return
+	}
+	job, reader, err := deps.QueryJobs.OpenResult(r.Context(), tenantID, jobID)
+	if err != nil {
+		if errors.Is(err, catalog.ErrConflict) {
+			writeError(r.Context(), w, http.StatusConflict, "QUERY_JOB_NOT_SUCCEEDED", "query job has no result", false, map[string]any{"job_id": jobID, "state": string(job.State)})
+			return
+		}
+		writeQueryJobError(r, w, jobID, err)
+		return
+	}
+	defer func() { _ = reader.Close() }()
+
+	header := w.Header()
+	header.Set("Content-Type", queryJobContentTypes[job.Format])
+	header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="query-%d.%s"`, job.JobID, job.Format))
+	header.Set("Content-Length", strconv.FormatInt(job.ResultBytes, 10))
+	header.Set("X-Snapshot-ID", strconv.FormatInt(job.SnapshotID, 10))
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, reader)
+}
+
+func queryJobFromRequest(deps Dependencies, w http.ResponseWriter, r *http.Request) (string, int64, bool) {
+	if deps.QueryJobs == nil {
+		writeError(r.Context(), w, http.StatusNotImplemented, "QUERY_JOBS_NOT_CONFIGURED", "query job dependencies are not configured", false, nil)
+		return "", 0, false
+	}
+	tenantID, err := tenantFromRequest(r)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusUnauthorized, "TENANT_REQUIRED", err.Error(), false, nil)
+		return "", 0, false
+	}
+	if err := requireRole(r, "query_reader"); err != nil {
+		writeError(r.Context(), w, http.StatusForbidden, "FORBIDDEN", err.Error(), false, nil)
+		return "", 0, false
+	}
+	jobID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || jobID <= 0 {
+		writeError(r.Context(), w, http.StatusBadRequest, "INVALID_JOB_ID", "job id must be a positive integer", false, map[string]any{"job_id": r.PathValue("id")})
+		return "", 0, false
+	}
+	return tenantID, jobID, true
+}
+
+func writeQueryJobError(r *http.Request, w http.ResponseWriter, jobID int64, err error) {
+	switch {
+	case errors.Is(err, catalog.ErrNotFound):
+		writeError(r.Context(), w, http.StatusNotFound, "QUERY_JOB_NOT_FOUND", "query job was not found", false, map[string]any{"job_id": jobID})
+	case errors.Is(err, catalog.ErrConflict):
+		writeError(r.Context(), w, http.StatusConflict, "QUERY_JOB_CONFLICT", "query job changed state, retry the request", true, map[string]any{"job_id": jobID})
+	default:
+		writeError(r.Context(), w, http.StatusInternalServerError, "CATALOG_ERROR", "failed to load query job", true, map[string]any{"details": err.Error()})
+	}
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/catalog"
+	"github.com/duckmesh/duckmesh/internal/config"
+	"github.com/duckmesh/duckmesh/internal/query"
+	"github.com/duckmesh/duckmesh/internal/queryjob"
+)
+
+func TestQueryJobLifecycle(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+	repo := &fakeQueryCatalogRepo{
+		snapshot: catalog.Snapshot{SnapshotID: 7, TenantID: "tenant-1", MaxVisibilityToken: 20, CreatedAt: time.Now().UTC()},
+		files: []catalog.SnapshotFileEntry{
+			{TableName: "events", Path: "k1", FileSizeBytes: 10},
+			{TableName: "users", Path: "k2", FileSizeBytes: 5},
+		},
+	}
+	engine := &fakeQueryEngine{statement: &query.Statement{Statements: 1, ReadOnly: true, Tables: []query.TableName{{Name: "events"}}}}
+	jobs := &fakeQueryJobs{}
+	service := NewHandler(cfg, Dependencies{CatalogRepo: repo, QueryEngine: engine, QueryJobs: jobs})
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-Tenant-ID", "tenant-1")
+		rr := httptest.NewRecorder()
+		service.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, "/v1/query/jobs", `{"sql":"SELECT * FROM events WHERE id > $1","params":[3],"format":"CSV"}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("submit status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	submitted := jobs.submitted
+	if submitted.Format != query.ExportCSV || submitted.SnapshotID != 7 || len(submitted.Request.Files) != 1 || submitted.Request.Params[0].Value != int64(3) {
+		t.Fatalf("submitted = %+v", submitted)
+	}
+
+	rr = do(http.MethodGet, "/v1/query/jobs/1/result", "")
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("result of queued job status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+
+	jobs.job.State = catalog.QueryJobSucceeded
+	jobs.job.ResultPath = "tenant-1/_query_jobs/1/result.csv"
+	jobs.job.ResultBytes = int64(len("id\n4\n"))
+	rr = do(http.MethodGet, "/v1/query/jobs/1", "")
+	var status map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("json decode failed: %v", err)
+	}
+	if rr.Code != http.StatusOK || status["state"] != "succeeded" || status["result_url"] != "/v1/query/jobs/1/result" {
+		t.Fatalf("status = %d %v", rr.Code, status)
+	}
+
+	rr = do(http.MethodGet, "/v1/query/jobs/1/result", "")
+	if rr.Code != http.StatusOK || rr.Body.String() != "id\n4\n" || rr.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("result = %d %q %v", rr.Code, rr.Body.String(), rr.Header())
+	}
+	if got := rr.Header().Get("Content-Disposition"); got != `attachment; filename="query-1.csv"` {
+		t.Fatalf("Content-Disposition = %q", got)
+	}
+
+	rr = do(http.MethodDelete, "/v1/query/jobs/1", "")
+	if rr.Code != http.StatusNoContent || !jobs.deleted {
+		t.Fatalf("delete status = %d, deleted = %v", rr.Code, jobs.deleted)
+	}
+	if rr = do(http.MethodGet, "/v1/query/jobs/1", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("deleted job status = %d", rr.Code)
+	}
+}
+
+func TestQueryJobDeleteCancelsRunningJob(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+	jobs := &fakeQueryJobs{job: catalog.QueryJob{JobID: 4, TenantID: "tenant-1", State: catalog.QueryJobRunning}}
+	service := NewHandler(cfg, Dependencies{QueryJobs: jobs})
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/query/jobs/4", nil)
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	rr := httptest.NewRecorder()
+	service.ServeHTTP(rr, req)
+	if rr.Code != http.StatusAccepted || !strings.Contains(rr.Body.String(), `"cancel_requested":true`) || jobs.deleted {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/query/jobs/abc", nil)
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	rr = httptest.NewRecorder()
+	service.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("invalid id status = %d", rr.Code)
+	}
+}
+
+type fakeQueryJobs struct {
+	job       catalog.QueryJob
+	submitted queryjob.SubmitRequest
+	deleted   bool
+}
+
+func (f *fakeQueryJobs) Submit(_ context.Context, request queryjob.SubmitRequest) (catalog.QueryJob, error) {
+	f.submitted = request
+	f.job = catalog.QueryJob{
+		JobID:      1,
+		TenantID:   request.TenantID,
+		State:      catalog.QueryJobQueued,
+		SQL:        request.Request.SQL,
+		Format:     string(request.Format),
+		SnapshotID: request.SnapshotID,
+		CreatedAt:  time.Now().UTC(),
+	}
+	return f.job, nil
+}
+
+func (f *fakeQueryJobs) Get(_ context.Context, tenantID string, jobID int64) (catalog.QueryJob, error) {
+	if f.deleted || f.job.JobID != jobID || f.job.TenantID != tenantID {
+		return catalog.QueryJob{}, catalog.ErrNotFound
+	}
+	return f.job, nil
+}
+
+func (f *fakeQueryJobs) Cancel(ctx context.Context, tenantID string, jobID int64) (catalog.QueryJob, error) {
+	job, err := f.Get(ctx, tenantID, jobID)
+	if err != nil {
+		return catalog.QueryJob{}, err
+	}
+	if job.State.Finished() {
+		return catalog.QueryJob{}, catalog.ErrConflict
+	}
+	f.job.CancelRequested = true
+	return f.job, nil
+}
+
+func (f *fakeQueryJobs) Delete(ctx context.Context, tenantID string, jobID int64) error {
+	if _, err := f.Get(ctx, tenantID, jobID); err != nil {
+		return err
+	}
+	f.deleted = true
+	return nil
+}
+
+func (f *fakeQueryJobs) OpenResult(ctx context.Context, tenantID string, jobID int64) (catalog.QueryJob, io.ReadCloser, error) {
+	job, err := f.Get(ctx, tenantID, jobID)
+	if err != nil {
+		return catalog.QueryJob{}, nil, err
+	}
+	if job.State != catalog.QueryJobSucceeded {
+		return job, nil, catalog.ErrConflict
+	}
+	return job, io.NopCloser(strings.NewReader("id\n4\n")), nil
+}
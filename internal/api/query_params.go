@@ -0,0 +1,155 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/duckmesh/duckmesh/internal/query"
+	"github.com/duckmesh/duckmesh/internal/tableschema"
+)
+
+var (
+	paramNamePattern    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	decimalParamPattern = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)$`)
+)
+
+// parseQueryParams decodes the params of a query request. An object binds
+// named params ($name) and an array positional params ($1 or ?). Values are
+// JSON scalars, arrays for lists, or {"type": ..., "value": ...} objects for
+// timestamps, dates and decimals.
+func parseQueryParams(raw json.RawMessage) ([]query.Param, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var decoded any
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	switch values := decoded.(type) {
+	case map[string]any:
+		names := make([]string, 0, len(values))
+		for name := range values {
+			if !paramNamePattern.MatchString(name) {
+				return nil, fmt.Errorf("param name %q is not an identifier", name)
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		params := make([]query.Param, 0, len(names))
+		for _, name := range names {
+			value, err := paramValue(values[name])
+			if err != nil {
+				return nil, fmt.Errorf("param %q: %w", name, err)
+			}
+			params = append(params, query.Param{Name: name, Value: value})
+		}
+		return params, nil
+	case []any:
+		params := make([]query.Param, 0, len(values))
+		for i, item := range values {
+			value, err := paramValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("param $%d: %w", i+1, err)
+			}
+			params = append(params, query.Param{Value: value})
+		}
+		return params, nil
+	}
+	return nil, fmt.Errorf("params must be an object of named params or an array of positional params")
+}
+
+func paramValue(value any) (any, error) {
+	switch v := value.(type) {
+	case nil, bool, string:
+		return v, nil
+	case json.Number:
+		return paramNumber(v)
+	case []any:
+		list := make([]any, len(v))
+		floats := false
+		for i, item := range v {
+			converted, err := paramValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("list item %d: %w", i, err)
+			}
+			_, isFloat := converted.(float64)
+			floats = floats || isFloat
+			list[i] = converted
+		}
+		// DuckDB infers one element type per list, so integers join the
+		// floats they are listed with.
+		if floats {
+			for i, item := range list {
+				if integer, ok := item.(int64); ok {
+					list[i] = float64(integer)
+				}
+			}
+		}
+		return list, nil
+	case map[string]any:
+		return typedParamValue(v)
+	}
+	return nil, fmt.Errorf("unsupported value %T", value)
+}
+
+func paramNumber(number json.Number) (any, error) {
+	if integer, err := number.Int64(); err == nil {
+		return integer, nil
+	}
+	if !strings.ContainsAny(number.String(), ".eE") {
+		if integer, ok := new(big.Int).SetString(number.String(), 10); ok {
+			return integer, nil
+		}
+	}
+	float, err := number.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("number %s is out of range", number)
+	}
+	return float, nil
+}
+
+func typedParamValue(object map[string]any) (any, error) {
+	kind, ok := object["type"].(string)
+	value, hasValue := object["value"]
+	if !ok || !hasValue || len(object) != 2 {
+		return nil, fmt.Errorf(`typed values are objects with exactly "type" and "value"`)
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	switch kind {
+	case "timestamp", "date":
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s value must be a string", kind)
+		}
+		fieldType := tableschema.TypeTimestamp
+		if kind == "date" {
+			fieldType = tableschema.TypeDate
+		}
+		return tableschema.Field{Type: fieldType}.Coerce(text)
+	case "decimal":
+		var text string
+		switch v := value.(type) {
+		case string:
+			text = strings.TrimSpace(v)
+		case json.Number:
+			text = v.String()
+		}
+		if !decimalParamPattern.MatchString(text) {
+			return nil, fmt.Errorf("expected decimal, got %v", value)
+		}
+		return query.Decimal(text), nil
+	}
+	return nil, fmt.Errorf("unsupported type %q (use timestamp, date or decimal)", kind)
+}
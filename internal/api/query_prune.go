@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/catalog"
+	"github.com/duckmesh/duckmesh/internal/colstats"
+	"github.com/duckmesh/duckmesh/internal/partition"
+	"github.com/duckmesh/duckmesh/internal/query/prune"
+)
+
+type prunedFiles struct {
+	files []catalog.SnapshotFileEntry
+	count int
+	bytes int64
+}
+
+// pruneSnapshotFiles drops the files the statement cannot read a row from,
+// judged by event time range, identity partition values and column
+// statistics. Keyed tables are only pruned on primary key columns, since
+// merge-on-read needs every version of a key. Each table keeps at least one
+// file so its view still exists.
+func pruneSnapshotFiles(ctx context.Context, deps Dependencies, tenantID, sqlText string, files []catalog.SnapshotFileEntry, now time.Time) prunedFiles {
+	analysis := prune.Analyze(sqlText, now)
+	primaryKeys := snapshotPrimaryKeys(files)
+
+	filters := map[string][][]prune.Predicate{}
+	for _, file := range files {
+		if _, seen := filters[file.TableName]; seen {
+			continue
+		}
+		tableFilters, ok := analysis.Filters(file.TableName)
+		if keys, keyed := primaryKeys[file.TableName]; ok && keyed {
+			tableFilters = restrictToColumns(tableFilters, keys)
+		}
+		if !ok || !everyReferenceFiltered(tableFilters) {
+			tableFilters = nil
+		}
+		filters[file.TableName] = tableFilters
+	}
+	partitioned := false
+	for _, file := range files {
+		partitioned = partitioned || filters[file.TableName] != nil && len(file.PartitionValues) > 0
+	}
+
+	identities := map[string]map[string]string{}
+	if partitioned {
+		// Partition pruning is an optimization; without the table
+		// definitions files are still pruned by their statistics.
+		if tables, err := deps.CatalogRepo.ListTables(ctx, tenantID); err == nil {
+			for _, table := range tables {
+				identities[table.TableName] = identityFields(table.PartitionSpec)
+			}
+		}
+	}
+
+	keep := make([]bool, len(files))
+	tableKept := map[string]bool{}
+	for i, file := range files {
+		tableFilters := filters[file.TableName]
+		keep[i] = tableFilters == nil || fileCanMatch(file, identities[file.TableName], tableFilters)
+		tableKept[file.TableName] = tableKept[file.TableName] || keep[i]
+	}
+	result := prunedFiles{files: make([]catalog.SnapshotFileEntry, 0, len(files))}
+	for i, file := range files {
+		if !keep[i] && !tableKept[file.TableName] {
+			keep[i] = true
+			tableKept[file.TableName] = true
+		}
+		if keep[i] {
+			result.files = append(result.files, file)
+			continue
+		}
+		result.count++
+		result.bytes += file.FileSizeBytes
+	}
+	return result
+}
+
+func fileCanMatch(file catalog.SnapshotFileEntry, identityColumns map[string]string, filters [][]prune.Predicate) bool {
+	candidate := prune.File{MinEventTime: file.MinEventTime, MaxEventTime: file.MaxEventTime}
+	if stats, err := colstats.Parse(file.StatsJSON); err == nil {
+		candidate.Columns = stats.Columns
+	}
+	if len(identityColumns) > 0 {
+		candidate.Identities = map[string]string{}
+		for name, value := range file.PartitionValues {
+			if column, ok := identityColumns[name]; ok {
+				candidate.Identities[column] = value
+			}
+		}
+	}
+	for _, conjunction := range filters {
+		if candidate.CanMatch(conjunction) {
+			return true
+		}
+	}
+	return false
+}
+
+// identityFields maps the names of identity partition fields to their
+// columns.
+func identityFields(rawSpec []byte) map[string]string {
+	spec, err := partition.Parse(rawSpec)
+	if err != nil {
+		return nil
+	}
+	fields := map[string]string{}
+	for _, field := range spec.Fields {
+		if field.Transform == partition.TransformIdentity {
+			fields[field.Name] = field.Column
+		}
+	}
+	return fields
+}
+
+func restrictToColumns(filters [][]prune.Predicate, columns []string) [][]prune.Predicate {
+	restricted := make([][]prune.Predicate, 0, len(filters))
+	for _, conjunction := range filters {
+		kept := make([]prune.Predicate, 0, len(conjunction))
+		for _, predicate := range conjunction {
+			for _, column := range columns {
+				if strings.EqualFold(column, predicate.Column) {
+					kept = append(kept, predicate)
+					break
+				}
+			}
+		}
+		restricted = append(restricted, kept)
+	}
+	return restricted
+}
+
+// everyReferenceFiltered is false when some reference to a table reads all of
+// its rows.
+func everyReferenceFiltered(filters [][]prune.Predicate) bool {
+	for _, conjunction := range filters {
+		if len(conjunction) == 0 {
+			return false
+		}
+	}
+	return len(filters) > 0
+}
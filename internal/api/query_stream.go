@@ -0,0 +1,189 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/catalog"
+	"github.com/duckmesh/duckmesh/internal/query"
+)
+
+const (
+	contentTypeNDJSON      = "application/x-ndjson"
+	contentTypeArrowStream = "application/vnd.apache.arrow.stream"
+	// queryStreamBatchRows is how many rows are encoded and flushed at once,
+	// and the size of each Arrow record batch.
+	queryStreamBatchRows = 1024
+)
+
+// Trailers of a streamed query result. X-Query-Error is only set when the
+// query failed after the first row was sent.
+const (
+	trailerRowCount   = "X-Row-Count"
+	trailerDurationMs = "X-Duration-Ms"
+	trailerQueryError = "X-Query-Error"
+)
+
+// queryStreamFormat picks the result format from the Accept header: the
+// first listed media type that is served wins, and "" means the JSON
+// document.
+func queryStreamFormat(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case contentTypeNDJSON:
+			return contentTypeNDJSON
+		case contentTypeArrowStream:
+			return contentTypeArrowStream
+		case "application/json", "application/*", "*/*":
+			return ""
+		}
+	}
+	return ""
+}
+
+// streamQueryResult writes the result in batches as the engine produces it.
+// Snapshot metadata and scan stats go in headers, the row count, duration
+// and any error after the first row in trailers.
+func streamQueryResult(deps Dependencies, w http.ResponseWriter, r *http.Request, format string, request query.Request, snapshot catalog.Snapshot, pruned prunedFiles) {
+	start := time.Now()
+	stream, err := deps.QueryEngine.Stream(r.Context(), request)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusBadRequest, "QUERY_EXECUTION_FAILED", "query execution failed", false, map[string]any{"details": err.Error()})
+		return
+	}
+	rows := stream.Rows
+	defer func() { _ = rows.Close() }()
+
+	// The first row is read before the status is sent, so statements that
+	// fail while starting still get an error response.
+	next := rows.Next()
+	if !next && rows.Err() != nil {
+		writeError(r.Context(), w, http.StatusBadRequest, "QUERY_EXECUTION_FAILED", "query execution failed", false, map[string]any{"details": rows.Err().Error()})
+		return
+	}
+
+	controller := http.NewResponseController(w)
+	extendDeadline := func() {
+		if deps.QueryStreamWriteTimeout > 0 {
+			_ = controller.SetWriteDeadline(time.Now().Add(deps.QueryStreamWriteTimeout))
+		}
+	}
+
+	buffered := bufio.NewWriterSize(w, 64<<10)
+	var encoder resultEncoder
+	if format == contentTypeArrowStream {
+		encoder = newArrowEncoder(buffered, rows.Columns())
+	} else {
+		encoder = newNDJSONEncoder(buffered, rows.Columns())
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", format)
+	header.Set("X-Snapshot-ID", strconv.FormatInt(snapshot.SnapshotID, 10))
+	header.Set("X-Snapshot-Time", snapshot.CreatedAt.UTC().Format(time.RFC3339Nano))
+	header.Set("X-Max-Visibility-Token", strconv.FormatInt(snapshot.MaxVisibilityToken, 10))
+	header.Set("X-Scanned-Files", strconv.Itoa(stream.ScannedFiles))
+	header.Set("X-Scanned-Bytes", strconv.FormatInt(stream.ScannedBytes, 10))
+	header.Set("X-Pruned-Files", strconv.Itoa(pruned.count))
+	header.Set("X-Pruned-Bytes", strconv.FormatInt(pruned.bytes, 10))
+	header.Set("Trailer", strings.Join([]string{trailerRowCount, trailerDurationMs, trailerQueryError}, ", "))
+	extendDeadline()
+	w.WriteHeader(http.StatusOK)
+
+	rowCount := 0
+	batch := make([][]any, 0, queryStreamBatchRows)
+	flush := func() error {
+		extendDeadline()
+		if err := encoder.writeRows(batch); err != nil {
+			return err
+		}
+		rowCount += len(batch)
+		batch = batch[:0]
+		if err := buffered.Flush(); err != nil {
+			return err
+		}
+		_ = controller.Flush()
+		return nil
+	}
+	for ; err == nil && next; next = rows.Next() {
+		batch = append(batch, rows.Values())
+		if len(batch) == queryStreamBatchRows {
+			err = flush()
+		}
+	}
+	if err == nil && len(batch) > 0 {
+		err = flush()
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	if err == nil {
+		err = encoder.close()
+	}
+	if err == nil {
+		err = buffered.Flush()
+	}
+
+	header.Set(trailerRowCount, strconv.Itoa(rowCount))
+	header.Set(trailerDurationMs, strconv.FormatInt(time.Since(start).Milliseconds(), 10))
+	if err != nil {
+		header.Set(trailerQueryError, err.Error())
+	}
+}
+
+type resultEncoder interface {
+	writeRows(rows [][]any) error
+	close() error
+}
+
+// ndjsonEncoder writes one JSON object per row, keyed by column name in
+// column order.
+type ndjsonEncoder struct {
+	w     io.Writer
+	names [][]byte
+	line  bytes.Buffer
+}
+
+func newNDJSONEncoder(w io.Writer, columns []query.Column) *ndjsonEncoder {
+	names := make([][]byte, len(columns))
+	for i, column := range columns {
+		names[i], _ = json.Marshal(column.Name)
+	}
+	return &ndjsonEncoder{w: w, names: names}
+}
+
+func (e *ndjsonEncoder) writeRows(rows [][]any) error {
+	for _, row := range rows {
+		e.line.Reset()
+		e.line.WriteByte('{')
+		for i, value := range row {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return fmt.Errorf("encode column %s: %w", e.names[i], err)
+			}
+			if i > 0 {
+				e.line.WriteByte(',')
+			}
+			e.line.Write(e.names[i])
+			e.line.WriteByte(':')
+			e.line.Write(encoded)
+		}
+		e.line.WriteString("}\n")
+		if _, err := e.w.Write(e.line.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *ndjsonEncoder) close() error {
+	return nil
+}
@@ -1,16 +1,25 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+
 	"github.com/duckmesh/duckmesh/internal/catalog"
 	"github.com/duckmesh/duckmesh/internal/config"
+	"github.com/duckmesh/duckmesh/internal/notify"
 	"github.com/duckmesh/duckmesh/internal/query"
 )
 
@@ -48,6 +57,349 @@ func TestQueryEndpointReturnsResults(t *testing.T) {
 	}
 }
 
+func TestQueryEndpointPrunesFilesOutsideEventTimeRange(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+
+	now := time.Now().UTC()
+	lastYear, lastYearEnd := now.AddDate(-1, 0, 0), now.AddDate(-1, 0, 1)
+	recent, recentEnd := now.Add(-30*time.Minute), now
+	repo := &fakeQueryCatalogRepo{
+		table:    catalog.TableDef{TableName: "events", PartitionSpec: []byte(`{"fields":[{"column":"region"}]}`)},
+		snapshot: catalog.Snapshot{SnapshotID: 7, TenantID: "tenant-1", MaxVisibilityToken: 20, CreatedAt: now},
+		files: []catalog.SnapshotFileEntry{
+			{TableName: "events", Path: "old", FileSizeBytes: 100, MinEventTime: &lastYear, MaxEventTime: &lastYearEnd, PartitionValues: map[string]string{"region": "eu"}},
+			{TableName: "events", Path: "recent-us", FileSizeBytes: 20, MinEventTime: &recent, MaxEventTime: &recentEnd, PartitionValues: map[string]string{"region": "us"}},
+			{TableName: "events", Path: "recent-eu", FileSizeBytes: 10, MinEventTime: &recent, MaxEventTime: &recentEnd, PartitionValues: map[string]string{"region": "eu"}},
+			{TableName: "users", Path: "users", FileSizeBytes: 5},
+		},
+	}
+	engine := &fakeQueryEngine{result: query.Result{Columns: []string{"c"}, Rows: [][]any{{int64(2)}}, ScannedFiles: 2, ScannedBytes: 15}}
+	service := NewHandler(cfg, Dependencies{CatalogRepo: repo, QueryEngine: engine})
+
+	sql := `SELECT count(*) AS c FROM events WHERE __event_time >= now() - INTERVAL 1 HOUR AND region = 'eu'`
+	req := httptest.NewRequest(http.MethodPost, "/v1/query", strings.NewReader(`{"sql":"`+sql+`"}`))
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	rr := httptest.NewRecorder()
+
+	service.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	paths := make([]string, 0)
+	for _, file := range engine.requests[0].Files {
+		paths = append(paths, file.ObjectPath)
+	}
+	if strings.Join(paths, ",") != "recent-eu,users" {
+		t.Fatalf("queried files = %v", paths)
+	}
+
+	var body struct {
+		Stats map[string]any `json:"stats"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("json decode failed: %v", err)
+	}
+	if body.Stats["pruned_files"] != float64(2) || body.Stats["pruned_bytes"] != float64(120) {
+		t.Fatalf("stats = %v", body.Stats)
+	}
+}
+
+func TestPruneSnapshotFilesKeepsKeyedTablesWhole(t *testing.T) {
+	now := time.Now().UTC()
+	lastYear := now.AddDate(-1, 0, 0)
+	files := []catalog.SnapshotFileEntry{
+		{TableName: "accounts", Path: "a1", PrimaryKeyCols: []byte(`["id"]`), MinEventTime: &lastYear, MaxEventTime: &lastYear, StatsJSON: []byte(`{"event_count":1,"columns":{"id":{"type":"bigint","min":1,"max":10}}}`)},
+		{TableName: "accounts", Path: "a2", PrimaryKeyCols: []byte(`["id"]`), MinEventTime: &now, MaxEventTime: &now, StatsJSON: []byte(`{"event_count":1,"columns":{"id":{"type":"bigint","min":11,"max":20}}}`)},
+	}
+	deps := Dependencies{CatalogRepo: &fakeQueryCatalogRepo{}}
+
+	byTime := pruneSnapshotFiles(context.Background(), deps, "tenant-1", "SELECT * FROM accounts WHERE __event_time > now() - INTERVAL 1 DAY", files, now)
+	if byTime.count != 0 || len(byTime.files) != 2 {
+		t.Fatalf("event time pruning of a keyed table = %+v", byTime)
+	}
+	byKey := pruneSnapshotFiles(context.Background(), deps, "tenant-1", "SELECT * FROM accounts WHERE id = 3", files, now)
+	if byKey.count != 1 || byKey.files[0].Path != "a1" {
+		t.Fatalf("key pruning = %+v", byKey)
+	}
+	none := pruneSnapshotFiles(context.Background(), deps, "tenant-1", "SELECT * FROM accounts WHERE id = 30", files, now)
+	if none.count != 1 || len(none.files) != 1 {
+		t.Fatalf("every table should keep a file, got %+v", none)
+	}
+}
+
+func TestQueryEndpointOnlyFetchesReferencedTables(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+
+	repo := &fakeQueryCatalogRepo{
+		snapshot: catalog.Snapshot{SnapshotID: 7, TenantID: "tenant-1", MaxVisibilityToken: 20, CreatedAt: time.Now().UTC()},
+		files: []catalog.SnapshotFileEntry{
+			{TableName: "events", Path: "events-1", FileSizeBytes: 1000},
+			{TableName: "Small_Table", Path: "small-1", FileSizeBytes: 10},
+		},
+	}
+	engine := &fakeQueryEngine{statement: &query.Statement{Statements: 1, ReadOnly: true, Tables: []query.TableName{{Name: "small_table"}}}}
+	service := NewHandler(cfg, Dependencies{CatalogRepo: repo, QueryEngine: engine})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/query", strings.NewReader(`{"sql":"SELECT count(*) FROM small_table"}`))
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	rr := httptest.NewRecorder()
+	service.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	files := engine.requests[0].Files
+	if len(files) != 1 || files[0].ObjectPath != "small-1" {
+		t.Fatalf("queried files = %+v", files)
+	}
+
+	engine.errInspect = fmt.Errorf("%w: syntax error", query.ErrInvalidSQL)
+	req = httptest.NewRequest(http.MethodPost, "/v1/query", strings.NewReader(`{"sql":"SELECT FROM FROM"}`))
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	rr = httptest.NewRecorder()
+	service.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "SQL_PARSE_FAILED") {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	if len(engine.requests) != 1 {
+		t.Fatalf("engine request count = %d", len(engine.requests))
+	}
+}
+
+func TestQueryEndpointEnforcesSQLPolicy(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+	repo := &fakeQueryCatalogRepo{
+		snapshot: catalog.Snapshot{SnapshotID: 7, TenantID: "tenant-1", CreatedAt: time.Now().UTC()},
+		files:    []catalog.SnapshotFileEntry{{TableName: "events", Path: "k1", FileSizeBytes: 10}},
+	}
+	engine := &fakeQueryEngine{}
+	policy := query.Policy{TenantAllowedFunctions: map[string][]string{"tenant-2": {"read_csv"}}}
+	service := NewHandler(cfg, Dependencies{CatalogRepo: repo, QueryEngine: engine, QueryPolicy: policy})
+
+	send := func(tenantID string, statement query.Statement) (int, map[string]any) {
+		engine.statement = &statement
+		req := httptest.NewRequest(http.MethodPost, "/v1/query", strings.NewReader(`{"sql":"SELECT 1"}`))
+		req.Header.Set("X-Tenant-ID", tenantID)
+		rr := httptest.NewRecorder()
+		service.ServeHTTP(rr, req)
+		var body map[string]any
+		_ = json.Unmarshal(rr.Body.Bytes(), &body)
+		return rr.Code, body
+	}
+
+	tests := []struct {
+		statement  query.Statement
+		wantReason string
+		wantName   string
+	}{
+		{statement: query.Statement{}, wantReason: query.ReasonNotReadOnly},
+		{statement: query.Statement{Statements: 2, ReadOnly: true}, wantReason: query.ReasonMultipleStatements},
+		{statement: query.Statement{Statements: 1, ReadOnly: true, TableFunctions: []string{"read_csv"}}, wantReason: query.ReasonTableFunctionNotAllowed, wantName: "read_csv"},
+		{statement: query.Statement{Statements: 1, ReadOnly: true, Tables: []query.TableName{{Name: "other_tenant_table"}}}, wantReason: query.ReasonTableNotAllowed, wantName: "other_tenant_table"},
+	}
+	for _, tc := range tests {
+		code, body := send("tenant-1", tc.statement)
+		details, _ := body["context"].(map[string]any)
+		if code != http.StatusBadRequest || body["error_code"] != "SQL_NOT_ALLOWED" || details["reason"] != tc.wantReason || (tc.wantName != "" && details["name"] != tc.wantName) {
+			t.Fatalf("statement %+v: status = %d, body = %v", tc.statement, code, body)
+		}
+	}
+	if len(engine.requests) != 0 {
+		t.Fatalf("engine request count = %d", len(engine.requests))
+	}
+
+	if code, body := send("tenant-2", query.Statement{Statements: 1, ReadOnly: true, TableFunctions: []string{"read_csv"}, Tables: []query.TableName{{Schema: "main", Name: "EVENTS"}}}); code != http.StatusOK {
+		t.Fatalf("tenant allowance: status = %d, body = %v", code, body)
+	}
+}
+
+func TestQueryEndpointBindsParams(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+	repo := &fakeQueryCatalogRepo{
+		snapshot: catalog.Snapshot{SnapshotID: 7, TenantID: "tenant-1", CreatedAt: time.Now().UTC()},
+		files:    []catalog.SnapshotFileEntry{{TableName: "events", Path: "k1", FileSizeBytes: 10}},
+	}
+	engine := &fakeQueryEngine{}
+	service := NewHandler(cfg, Dependencies{CatalogRepo: repo, QueryEngine: engine})
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/query", strings.NewReader(body))
+		req.Header.Set("X-Tenant-ID", "tenant-1")
+		rr := httptest.NewRecorder()
+		service.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := send(`{"sql":"SELECT * FROM events WHERE id = $id AND region IN (SELECT unnest($regions)) AND __event_time >= $since","params":{"id":42,"regions":["eu","us"],"since":{"type":"timestamp","value":"2026-02-19T10:00:00+01:00"}}}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	params := engine.requests[0].Params
+	if len(params) != 3 || params[0].Name != "id" || params[0].Value != int64(42) {
+		t.Fatalf("params = %#v", params)
+	}
+	if since, ok := params[2].Value.(time.Time); !ok || !since.Equal(time.Date(2026, 2, 19, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("since = %#v", params[2].Value)
+	}
+
+	rr = send(`{"sql":"SELECT $1","params":["a"]}`)
+	if rr.Code != http.StatusOK || engine.requests[1].Params[0] != (query.Param{Value: "a"}) {
+		t.Fatalf("status = %d, params = %#v", rr.Code, engine.requests[1].Params)
+	}
+
+	rr = send(`{"sql":"SELECT $1","params":[{"type":"money","value":"1"}]}`)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "INVALID_PARAMS") {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestParseQueryParamsConvertsTypedValues(t *testing.T) {
+	params, err := parseQueryParams(json.RawMessage(`[9223372036854775808, 1.5, [1, 2.5], {"type":"decimal","value":"-12.50"}, {"type":"date","value":"2026-02-19"}, null, true]`))
+	if err != nil {
+		t.Fatalf("parseQueryParams() error = %v", err)
+	}
+	if got := fmt.Sprint(params[0].Value); got != "9223372036854775808" {
+		t.Fatalf("big integer = %v", got)
+	}
+	if params[1].Value != 1.5 || fmt.Sprint(params[2].Value) != "[1 2.5]" || params[3].Value != query.Decimal("-12.50") {
+		t.Fatalf("params = %#v", params)
+	}
+	if day, ok := params[4].Value.(time.Time); !ok || !day.Equal(time.Date(2026, 2, 19, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("date = %#v", params[4].Value)
+	}
+	if params[5].Value != nil || params[6].Value != true {
+		t.Fatalf("params = %#v", params)
+	}
+
+	for _, raw := range []string{`"x"`, `{"1":2}`, `[{"type":"decimal","value":"1e5"}]`, `[{"type":"timestamp","value":"yesterday"}]`, `[{"type":"date","value":"2026-02-19","extra":1}]`} {
+		if _, err := parseQueryParams(json.RawMessage(raw)); err == nil {
+			t.Fatalf("parseQueryParams(%s) should fail", raw)
+		}
+	}
+}
+
+func TestQueryEndpointStreamsNDJSON(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+	repo := &fakeQueryCatalogRepo{
+		snapshot: catalog.Snapshot{SnapshotID: 7, TenantID: "tenant-1", MaxVisibilityToken: 20, CreatedAt: time.Now().UTC()},
+		files:    []catalog.SnapshotFileEntry{{TableName: "events", Path: "k1", FileSizeBytes: 10}},
+	}
+	engine := &fakeQueryEngine{result: query.Result{Columns: []string{"id", "name"}, Rows: [][]any{{int64(1), "a"}, {int64(2), nil}}, ScannedFiles: 1}}
+	service := NewHandler(cfg, Dependencies{CatalogRepo: repo, QueryEngine: engine})
+
+	send := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/query", strings.NewReader(`{"sql":"SELECT id, name FROM events"}`))
+		req.Header.Set("X-Tenant-ID", "tenant-1")
+		req.Header.Set("Accept", accept)
+		rr := httptest.NewRecorder()
+		service.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := send("application/x-ndjson")
+	trailer := rr.Result().Trailer
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/x-ndjson" || rr.Header().Get("X-Snapshot-ID") != "7" || rr.Header().Get("X-Max-Visibility-Token") != "20" {
+		t.Fatalf("status = %d, headers = %v", rr.Code, rr.Header())
+	}
+	if rr.Body.String() != "{\"id\":1,\"name\":\"a\"}\n{\"id\":2,\"name\":null}\n" {
+		t.Fatalf("body = %q", rr.Body.String())
+	}
+	if trailer.Get("X-Row-Count") != "2" || trailer.Get("X-Query-Error") != "" {
+		t.Fatalf("trailer = %v", trailer)
+	}
+
+	if rr := send("application/json, application/x-ndjson"); rr.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("a preferred JSON should get the JSON document, got %q", rr.Header().Get("Content-Type"))
+	}
+
+	engine.errRows = errors.New("out of memory")
+	rr = send("application/x-ndjson")
+	if rr.Code != http.StatusOK || rr.Result().Trailer.Get("X-Query-Error") != "out of memory" || rr.Result().Trailer.Get("X-Row-Count") != "2" {
+		t.Fatalf("status = %d, trailer = %v", rr.Code, rr.Result().Trailer)
+	}
+
+	engine.result.Rows = nil
+	if rr := send("application/x-ndjson"); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "QUERY_EXECUTION_FAILED") {
+		t.Fatalf("a failure before the first row should be an error response, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestQueryEndpointStreamsArrow(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+	repo := &fakeQueryCatalogRepo{
+		snapshot: catalog.Snapshot{SnapshotID: 7, TenantID: "tenant-1", CreatedAt: time.Now().UTC()},
+		files:    []catalog.SnapshotFileEntry{{TableName: "events", Path: "k1", FileSizeBytes: 10}},
+	}
+	engine := &fakeQueryEngine{
+		result:      query.Result{Columns: []string{"id", "amount", "tags"}, Rows: [][]any{{int64(1), "12.5", []any{"x", "y"}}, {nil, nil, nil}}},
+		columnTypes: []string{"BIGINT", "DECIMAL(10,2)", "VARCHAR[]"},
+	}
+	service := NewHandler(cfg, Dependencies{CatalogRepo: repo, QueryEngine: engine})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/query", strings.NewReader(`{"sql":"SELECT * FROM events"}`))
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	req.Header.Set("Accept", "application/vnd.apache.arrow.stream")
+	rr := httptest.NewRecorder()
+	service.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/vnd.apache.arrow.stream" {
+		t.Fatalf("status = %d, headers = %v", rr.Code, rr.Header())
+	}
+	if rr.Result().Trailer.Get("X-Row-Count") != "2" || rr.Result().Trailer.Get("X-Query-Error") != "" {
+		t.Fatalf("trailer = %v", rr.Result().Trailer)
+	}
+
+	reader, err := ipc.NewReader(bytes.NewReader(rr.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("ipc.NewReader() error = %v", err)
+	}
+	defer reader.Release()
+	wantSchema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+		{Name: "amount", Type: &arrow.Decimal128Type{Precision: 10, Scale: 2}, Nullable: true},
+		{Name: "tags", Type: arrow.BinaryTypes.String, Nullable: true},
+	}, nil)
+	if !reader.Schema().Equal(wantSchema) {
+		t.Fatalf("schema = %s", reader.Schema())
+	}
+	if !reader.Next() {
+		t.Fatalf("no record batch: %v", reader.Err())
+	}
+	record := reader.RecordBatch()
+	if record.NumRows() != 2 {
+		t.Fatalf("rows = %d", record.NumRows())
+	}
+	ids := record.Column(0).(*array.Int64)
+	amounts := record.Column(1).(*array.Decimal128)
+	tags := record.Column(2).(*array.String)
+	if ids.Value(0) != 1 || amounts.Value(0).BigInt().Int64() != 1250 || tags.Value(0) != `["x","y"]` {
+		t.Fatalf("first row = %v %v %q", ids.Value(0), amounts.Value(0), tags.Value(0))
+	}
+	if !ids.IsNull(1) || !amounts.IsNull(1) || !tags.IsNull(1) {
+		t.Fatal("second row should be null")
+	}
+	if reader.Next() || reader.Err() != nil {
+		t.Fatalf("unexpected trailing batch, err = %v", reader.Err())
+	}
+}
+
 func TestQueryEndpointConsistencyTimeout(t *testing.T) {
 	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
 	if err != nil {
@@ -67,6 +419,76 @@ func TestQueryEndpointConsistencyTimeout(t *testing.T) {
 	}
 }
 
+func TestVisibilityBarrierWakesOnSnapshotNotification(t *testing.T) {
+	repo := &notifiedCatalogRepo{fakeQueryCatalogRepo: &fakeQueryCatalogRepo{}, token: 1}
+	hub := notify.NewHub()
+	deps := Dependencies{CatalogRepo: repo, SnapshotNotifications: hub, BarrierFallbackPoll: time.Hour}
+
+	minToken := int64(5)
+	req := httptest.NewRequest(http.MethodPost, "/v1/query", nil)
+	type outcome struct {
+		snapshot catalog.Snapshot
+		err      error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		snapshot, err := resolveSnapshotWithBarrier(req, deps, "tenant-1", &minToken, 5000)
+		done <- outcome{snapshot: snapshot, err: err}
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for repo.latestCalls() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("barrier never read the latest snapshot")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	repo.publish(5)
+	hub.Notify(notify.ChannelSnapshot, "tenant-2")
+	hub.Notify(notify.ChannelSnapshot, "tenant-1")
+
+	select {
+	case result := <-done:
+		if result.err != nil {
+			t.Fatalf("resolveSnapshotWithBarrier() error = %v", result.err)
+		}
+		if result.snapshot.MaxVisibilityToken != 5 {
+			t.Fatalf("MaxVisibilityToken = %d, want 5", result.snapshot.MaxVisibilityToken)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("barrier did not wake on snapshot notification")
+	}
+	if calls := repo.latestCalls(); calls != 2 {
+		t.Fatalf("GetLatestSnapshot calls = %d, want 2", calls)
+	}
+}
+
+type notifiedCatalogRepo struct {
+	*fakeQueryCatalogRepo
+	mu    sync.Mutex
+	token int64
+	calls int
+}
+
+func (r *notifiedCatalogRepo) GetLatestSnapshot(context.Context, string) (catalog.Snapshot, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	return catalog.Snapshot{SnapshotID: r.token, TenantID: "tenant-1", MaxVisibilityToken: r.token}, nil
+}
+
+func (r *notifiedCatalogRepo) publish(token int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.token = token
+}
+
+func (r *notifiedCatalogRepo) latestCalls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
 type fakeQueryCatalogRepo struct {
 	table     catalog.TableDef
 	snapshot  catalog.Snapshot
@@ -127,6 +549,23 @@ type fakeQueryEngine struct {
 	requests []query.Request
 	result   query.Result
 	err      error
+	// statement is returned by Inspect; nil reports a single query that
+	// may read every table.
+	statement  *query.Statement
+	errInspect error
+	// columnTypes and errRows shape the rows handed out by Stream.
+	columnTypes []string
+	errRows     error
+}
+
+func (f *fakeQueryEngine) Inspect(context.Context, string) (query.Statement, error) {
+	if f.errInspect != nil {
+		return query.Statement{}, f.errInspect
+	}
+	if f.statement == nil {
+		return query.Statement{Statements: 1, ReadOnly: true, All: true}, nil
+	}
+	return *f.statement, nil
 }
 
 func (f *fakeQueryEngine) Execute(_ context.Context, request query.Request) (query.Result, error) {
@@ -136,3 +575,57 @@ func (f *fakeQueryEngine) Execute(_ context.Context, request query.Request) (que
 	}
 	return f.result, nil
 }
+
+func (f *fakeQueryEngine) Stream(_ context.Context, request query.Request) (query.Stream, error) {
+	f.requests = append(f.requests, request)
+	if f.err != nil {
+		return query.Stream{}, f.err
+	}
+	columns := make([]query.Column, len(f.result.Columns))
+	for i, name := range f.result.Columns {
+		columns[i] = query.Column{Name: name}
+		if i < len(f.columnTypes) {
+			columns[i].Type = f.columnTypes[i]
+		}
+	}
+	return query.Stream{
+		Rows:         &fakeRows{columns: columns, rows: f.result.Rows, err: f.errRows},
+		ScannedFiles: f.result.ScannedFiles,
+		ScannedBytes: f.result.ScannedBytes,
+	}, nil
+}
+
+// fakeRows yields rows and then fails with err, if set.
+type fakeRows struct {
+	columns []query.Column
+	rows    [][]any
+	err     error
+	next    int
+	closed  bool
+}
+
+func (f *fakeRows) Columns() []query.Column { return f.columns }
+
+func (f *fakeRows) Next() bool {
+	if f.next >= len(f.rows) {
+		return false
+	}
+	f.next++
+	return true
+}
+
+func (f *fakeRows) Values() []any {
+	return append([]any(nil), f.rows[f.next-1]...)
+}
+
+func (f *fakeRows) Err() error {
+	if f.next >= len(f.rows) {
+		return f.err
+	}
+	return nil
+}
+
+func (f *fakeRows) Close() error {
+	f.closed = true
+	return nil
+}
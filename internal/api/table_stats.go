@@ -0,0 +1,139 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/duckmesh/duckmesh/internal/catalog"
+	"github.com/duckmesh/duckmesh/internal/colstats"
+)
+
+type tableFileStats struct {
+	FileID          int64                      `json:"file_id"`
+	Path            string                     `json:"path"`
+	RecordCount     int64                      `json:"record_count"`
+	FileSizeBytes   int64                      `json:"file_size_bytes"`
+	PartitionValues map[string]string          `json:"partition_values"`
+	Columns         map[string]colstats.Column `json:"columns"`
+}
+
+// handleGetTableStats reports the column statistics of the data files visible
+// in the latest snapshot. Delete files are not counted; files written without
+// column statistics are only counted in files_without_stats.
+func handleGetTableStats(deps Dependencies, w http.ResponseWriter, r *http.Request) {
+	if deps.CatalogRepo == nil {
+		writeError(r.Context(), w, http.StatusNotImplemented, "TABLES_NOT_CONFIGURED", "catalog dependency is not configured", false, nil)
+		return
+	}
+	tenantID, err := tenantFromRequest(r)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusUnauthorized, "TENANT_REQUIRED", err.Error(), false, nil)
+		return
+	}
+	if err := requireAnyRole(r, "query_reader", "table_admin"); err != nil {
+		writeError(r.Context(), w, http.StatusForbidden, "FORBIDDEN", err.Error(), false, nil)
+		return
+	}
+	tableName := strings.TrimSpace(r.PathValue("table"))
+	if tableName == "" {
+		writeError(r.Context(), w, http.StatusBadRequest, "TABLE_REQUIRED", "table path parameter is required", false, nil)
+		return
+	}
+	includeFiles := false
+	if raw := strings.TrimSpace(r.URL.Query().Get("include_files")); raw != "" {
+		includeFiles, err = strconv.ParseBool(raw)
+		if err != nil {
+			writeError(r.Context(), w, http.StatusBadRequest, "INVALID_REQUEST", "include_files must be a boolean", false, nil)
+			return
+		}
+	}
+
+	table, err := deps.CatalogRepo.GetTableByName(r.Context(), tenantID, tableName)
+	if err != nil {
+		if errors.Is(err, catalog.ErrNotFound) {
+			writeError(r.Context(), w, http.StatusNotFound, "TABLE_NOT_FOUND", "table was not found", false, nil)
+			return
+		}
+		writeError(r.Context(), w, http.StatusInternalServerError, "CATALOG_ERROR", "failed to get table", true, map[string]any{"details": err.Error()})
+		return
+	}
+
+	response := map[string]any{
+		"tenant_id":           tenantID,
+		"table_name":          table.TableName,
+		"snapshot_id":         int64(0),
+		"file_count":          0,
+		"record_count":        int64(0),
+		"files_without_stats": 0,
+		"columns":             map[string]colstats.Column{},
+	}
+	if includeFiles {
+		response["files"] = []tableFileStats{}
+	}
+
+	snapshot, err := deps.CatalogRepo.GetLatestSnapshot(r.Context(), tenantID)
+	if err != nil {
+		if errors.Is(err, catalog.ErrNotFound) {
+			writeJSON(w, http.StatusOK, response)
+			return
+		}
+		writeError(r.Context(), w, http.StatusInternalServerError, "CATALOG_ERROR", "failed to resolve latest snapshot", true, map[string]any{"details": err.Error()})
+		return
+	}
+	entries, err := deps.CatalogRepo.ListSnapshotFiles(r.Context(), tenantID, snapshot.SnapshotID)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusInternalServerError, "CATALOG_ERROR", "failed to list snapshot files", true, map[string]any{"details": err.Error()})
+		return
+	}
+
+	parts := make([]colstats.Part, 0)
+	files := make([]tableFileStats, 0)
+	fileCount, withoutStats := 0, 0
+	var recordCount int64
+	for _, entry := range entries {
+		if entry.TableID != table.TableID || entry.Content == catalog.DataFileContentDelete {
+			continue
+		}
+		fileCount++
+		recordCount += entry.RecordCount
+		stats, err := colstats.Parse(entry.StatsJSON)
+		if err != nil || stats.Columns == nil {
+			withoutStats++
+		} else {
+			parts = append(parts, colstats.Part{Rows: entry.RecordCount, Columns: stats.Columns})
+		}
+		if includeFiles {
+			files = append(files, tableFileStats{
+				FileID:          entry.FileID,
+				Path:            entry.Path,
+				RecordCount:     entry.RecordCount,
+				FileSizeBytes:   entry.FileSizeBytes,
+				PartitionValues: entry.PartitionValues,
+				Columns:         withoutSketches(stats.Columns),
+			})
+		}
+	}
+
+	response["snapshot_id"] = snapshot.SnapshotID
+	response["file_count"] = fileCount
+	response["record_count"] = recordCount
+	response["files_without_stats"] = withoutStats
+	if merged := colstats.Merge(parts); merged != nil {
+		response["columns"] = withoutSketches(merged)
+	}
+	if includeFiles {
+		response["files"] = files
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func withoutSketches(columns map[string]colstats.Column) map[string]colstats.Column {
+	out := make(map[string]colstats.Column, len(columns))
+	for name, column := range columns {
+		column.Sketch = ""
+		out[name] = column
+	}
+	return out
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/duckmesh/duckmesh/internal/catalog"
+	"github.com/duckmesh/duckmesh/internal/colstats"
+	"github.com/duckmesh/duckmesh/internal/config"
+	"github.com/duckmesh/duckmesh/internal/tableschema"
+)
+
+func TestGetTableStatsMergesDataFileColumns(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+
+	schema := tableschema.Schema{Fields: []tableschema.Field{{Name: "id", Type: tableschema.TypeBigint}, {Name: "region", Type: tableschema.TypeVarchar}}}
+	first := colstats.NewCollector(schema)
+	first.Add([]any{int64(1), "eu"})
+	first.Add([]any{int64(2), nil})
+	second := colstats.NewCollector(schema)
+	second.Add([]any{int64(9), "us"})
+	statsJSON := func(collector *colstats.Collector, rows int64) []byte {
+		encoded, _ := json.Marshal(colstats.Stats{EventCount: rows, Columns: collector.Columns()})
+		return encoded
+	}
+
+	repo := newInMemoryTableCatalog()
+	repo.tables[tableKey("tenant-1", "events")] = catalog.TableDef{TableID: 7, TenantID: "tenant-1", TableName: "events"}
+	repo.files = []catalog.SnapshotFileEntry{
+		{TableID: 7, FileID: 1, Path: "a.parquet", Content: catalog.DataFileContentData, RecordCount: 2, StatsJSON: statsJSON(first, 2)},
+		{TableID: 7, FileID: 2, Path: "b.parquet", Content: catalog.DataFileContentData, RecordCount: 1, StatsJSON: statsJSON(second, 1)},
+		{TableID: 7, FileID: 3, Path: "c.parquet", Content: catalog.DataFileContentData, RecordCount: 4, StatsJSON: []byte(`{"event_count":4}`)},
+		{TableID: 7, FileID: 4, Path: "d.parquet", Content: catalog.DataFileContentDelete, RecordCount: 1},
+		{TableID: 8, FileID: 5, Path: "e.parquet", Content: catalog.DataFileContentData, RecordCount: 1},
+	}
+	h := NewHandler(cfg, Dependencies{CatalogRepo: repo})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/tables/events/stats?include_files=true", nil)
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%s", rr.Code, rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), "sketch") {
+		t.Fatalf("response exposes sketches: %s", rr.Body.String())
+	}
+
+	var response struct {
+		SnapshotID        int64                      `json:"snapshot_id"`
+		FileCount         int                        `json:"file_count"`
+		RecordCount       int64                      `json:"record_count"`
+		FilesWithoutStats int                        `json:"files_without_stats"`
+		Columns           map[string]colstats.Column `json:"columns"`
+		Files             []tableFileStats           `json:"files"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if response.SnapshotID != 1 || response.FileCount != 3 || response.RecordCount != 7 || response.FilesWithoutStats != 1 || len(response.Files) != 3 {
+		t.Fatalf("response = %+v", response)
+	}
+	id := response.Columns["id"]
+	if id.Min != float64(1) || id.Max != float64(9) || id.DistinctCount != 3 {
+		t.Fatalf("id stats = %+v", id)
+	}
+	region := response.Columns["region"]
+	if region.Min != "eu" || region.Max != "us" || region.NullCount != 1 || region.DistinctCount != 2 {
+		t.Fatalf("region stats = %+v", region)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/tables/missing/stats", nil)
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("missing table status = %d", rr.Code)
+	}
+}
@@ -10,6 +10,8 @@ import (
 
 	"github.com/duckmesh/duckmesh/internal/auth"
 	"github.com/duckmesh/duckmesh/internal/catalog"
+	"github.com/duckmesh/duckmesh/internal/partition"
+	"github.com/duckmesh/duckmesh/internal/tableschema"
 )
 
 type tableCreateRequest struct {
@@ -18,11 +20,13 @@ type tableCreateRequest struct {
 	PartitionSpec     map[string]any `json:"partition_spec"`
 	SchemaJSON        map[string]any `json:"schema_json"`
 	CompatibilityMode string         `json:"compatibility_mode"`
+	UnknownFields     string         `json:"unknown_fields"`
 }
 
 type tablePatchRequest struct {
 	SchemaJSON        map[string]any `json:"schema_json"`
 	CompatibilityMode string         `json:"compatibility_mode"`
+	UnknownFields     string         `json:"unknown_fields"`
 }
 
 type tableAdminCatalog interface {
@@ -96,8 +100,24 @@ func handleCreateTable(deps Dependencies, w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	pkJSON, _ := json.Marshal(req.PrimaryKeyCols)
+	schemaJSON, _ := json.Marshal(req.SchemaJSON)
+	if _, err := tableschema.Parse(schemaJSON); err != nil {
+		writeError(r.Context(), w, http.StatusBadRequest, "INVALID_SCHEMA", "schema_json is invalid", false, map[string]any{"details": err.Error()})
+		return
+	}
+	unknownFields, err := tableschema.ParseUnknownFieldPolicy(req.UnknownFields)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusBadRequest, "INVALID_SCHEMA", err.Error(), false, nil)
+		return
+	}
+
 	partitionJSON, _ := json.Marshal(req.PartitionSpec)
+	if _, err := partition.Parse(partitionJSON); err != nil {
+		writeError(r.Context(), w, http.StatusBadRequest, "INVALID_PARTITION_SPEC", "partition_spec is invalid", false, map[string]any{"details": err.Error()})
+		return
+	}
+
+	pkJSON, _ := json.Marshal(req.PrimaryKeyCols)
 	table, err := adminRepo.CreateTable(r.Context(), catalog.CreateTableInput{
 		TenantID:       tenantID,
 		TableName:      strings.TrimSpace(req.TableName),
@@ -111,7 +131,6 @@ func handleCreateTable(deps Dependencies, w http.ResponseWriter, r *http.Request
 	}
 
 	if len(req.SchemaJSON) > 0 {
-		schemaJSON, _ := json.Marshal(req.SchemaJSON)
 		compatibility := strings.TrimSpace(req.CompatibilityMode)
 		if compatibility == "" {
 			compatibility = "backward"
@@ -121,6 +140,7 @@ func handleCreateTable(deps Dependencies, w http.ResponseWriter, r *http.Request
 			SchemaVersion:     1,
 			SchemaJSON:        schemaJSON,
 			CompatibilityMode: compatibility,
+			UnknownFields:     unknownFields,
 		}); err != nil {
 			writeError(r.Context(), w, http.StatusInternalServerError, "CATALOG_ERROR", "failed to create table schema version", true, map[string]any{"details": err.Error()})
 			return
@@ -209,6 +229,16 @@ func handlePatchTable(deps Dependencies, w http.ResponseWriter, r *http.Request)
 		writeError(r.Context(), w, http.StatusBadRequest, "SCHEMA_REQUIRED", "schema_json is required", false, nil)
 		return
 	}
+	schemaJSON, _ := json.Marshal(req.SchemaJSON)
+	if _, err := tableschema.Parse(schemaJSON); err != nil {
+		writeError(r.Context(), w, http.StatusBadRequest, "INVALID_SCHEMA", "schema_json is invalid", false, map[string]any{"details": err.Error()})
+		return
+	}
+	unknownFields, err := tableschema.ParseUnknownFieldPolicy(req.UnknownFields)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusBadRequest, "INVALID_SCHEMA", err.Error(), false, nil)
+		return
+	}
 
 	table, err := deps.CatalogRepo.GetTableByName(r.Context(), tenantID, tableName)
 	if err != nil {
@@ -220,7 +250,6 @@ func handlePatchTable(deps Dependencies, w http.ResponseWriter, r *http.Request)
 		return
 	}
 	newVersion := table.SchemaVersion + 1
-	schemaJSON, _ := json.Marshal(req.SchemaJSON)
 	compatibility := strings.TrimSpace(req.CompatibilityMode)
 	if compatibility == "" {
 		compatibility = "backward"
@@ -231,6 +260,7 @@ func handlePatchTable(deps Dependencies, w http.ResponseWriter, r *http.Request)
 		SchemaVersion:     newVersion,
 		SchemaJSON:        schemaJSON,
 		CompatibilityMode: compatibility,
+		UnknownFields:     unknownFields,
 	}); err != nil {
 		writeError(r.Context(), w, http.StatusInternalServerError, "CATALOG_ERROR", "failed to upsert schema version", true, map[string]any{"details": err.Error()})
 		return
@@ -25,7 +25,7 @@ func TestTableLifecycleEndpoints(t *testing.T) {
 	repo := newInMemoryTableCatalog()
 	h := NewHandler(cfg, Dependencies{CatalogRepo: repo})
 
-	createBody := `{"table_name":"events","primary_key_cols":["id"],"partition_spec":{"date":"day"},"schema_json":{"id":"bigint"}}`
+	createBody := `{"table_name":"events","primary_key_cols":["id"],"partition_spec":{"fields":[{"column":"event_time","transform":"day"}]},"schema_json":{"id":"bigint"}}`
 	createReq := httptest.NewRequest(http.MethodPost, "/v1/tables", strings.NewReader(createBody))
 	createReq.Header.Set("X-Tenant-ID", "tenant-1")
 	createRR := httptest.NewRecorder()
@@ -102,9 +102,67 @@ func TestTableCreateRequiresTableAdminRole(t *testing.T) {
 	}
 }
 
+func TestTableCreateAndPatchRejectInvalidSchema(t *testing.T) {
+	cfg, err := config.Load("duckmesh-api", mapLookup(map[string]string{}))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+
+	repo := newInMemoryTableCatalog()
+	h := NewHandler(cfg, Dependencies{CatalogRepo: repo})
+
+	createReq := httptest.NewRequest(http.MethodPost, "/v1/tables", strings.NewReader(`{"table_name":"events","schema_json":{"id":"uuidv9"}}`))
+	createReq.Header.Set("X-Tenant-ID", "tenant-1")
+	createRR := httptest.NewRecorder()
+	h.ServeHTTP(createRR, createReq)
+	if createRR.Code != http.StatusBadRequest || !strings.Contains(createRR.Body.String(), "INVALID_SCHEMA") {
+		t.Fatalf("create status = %d, body=%s", createRR.Code, createRR.Body.String())
+	}
+	if len(repo.tables) != 0 {
+		t.Fatalf("tables = %d, want none created", len(repo.tables))
+	}
+
+	createReq = httptest.NewRequest(http.MethodPost, "/v1/tables", strings.NewReader(`{"table_name":"events","partition_spec":{"date":"day"}}`))
+	createReq.Header.Set("X-Tenant-ID", "tenant-1")
+	createRR = httptest.NewRecorder()
+	h.ServeHTTP(createRR, createReq)
+	if createRR.Code != http.StatusBadRequest || !strings.Contains(createRR.Body.String(), "INVALID_PARTITION_SPEC") {
+		t.Fatalf("create with invalid partition spec status = %d, body=%s", createRR.Code, createRR.Body.String())
+	}
+	if len(repo.tables) != 0 {
+		t.Fatalf("tables = %d, want none created", len(repo.tables))
+	}
+
+	createReq = httptest.NewRequest(http.MethodPost, "/v1/tables", strings.NewReader(`{"table_name":"events","schema_json":{"id":"bigint"}}`))
+	createReq.Header.Set("X-Tenant-ID", "tenant-1")
+	createRR = httptest.NewRecorder()
+	h.ServeHTTP(createRR, createReq)
+	if createRR.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body=%s", createRR.Code, createRR.Body.String())
+	}
+
+	patchReq := httptest.NewRequest(http.MethodPatch, "/v1/tables/events", strings.NewReader(`{"schema_json":{"__op":"varchar"}}`))
+	patchReq.Header.Set("X-Tenant-ID", "tenant-1")
+	patchRR := httptest.NewRecorder()
+	h.ServeHTTP(patchRR, patchReq)
+	if patchRR.Code != http.StatusBadRequest || !strings.Contains(patchRR.Body.String(), "INVALID_SCHEMA") {
+		t.Fatalf("patch status = %d, body=%s", patchRR.Code, patchRR.Body.String())
+	}
+
+	patchReq = httptest.NewRequest(http.MethodPatch, "/v1/tables/events", strings.NewReader(`{"schema_json":{"id":"bigint"},"unknown_fields":"drop"}`))
+	patchReq.Header.Set("X-Tenant-ID", "tenant-1")
+	patchRR = httptest.NewRecorder()
+	h.ServeHTTP(patchRR, patchReq)
+	if patchRR.Code != http.StatusBadRequest || !strings.Contains(patchRR.Body.String(), "INVALID_SCHEMA") {
+		t.Fatalf("patch status = %d, body=%s", patchRR.Code, patchRR.Body.String())
+	}
+}
+
 type inMemoryTableCatalog struct {
 	nextID int64
 	tables map[string]catalog.TableDef
+	// files, when set, are served as snapshot 1.
+	files []catalog.SnapshotFileEntry
 }
 
 func newInMemoryTableCatalog() *inMemoryTableCatalog {
@@ -183,12 +241,16 @@ func (r *inMemoryTableCatalog) UpsertTableSchemaVersion(_ context.Context, in ca
 		SchemaVersion:     in.SchemaVersion,
 		SchemaJSON:        in.SchemaJSON,
 		CompatibilityMode: in.CompatibilityMode,
+		UnknownFields:     in.UnknownFields,
 		CreatedAt:         time.Now().UTC(),
 	}, nil
 }
 
-func (r *inMemoryTableCatalog) GetLatestSnapshot(context.Context, string) (catalog.Snapshot, error) {
-	return catalog.Snapshot{}, catalog.ErrNotFound
+func (r *inMemoryTableCatalog) GetLatestSnapshot(_ context.Context, tenantID string) (catalog.Snapshot, error) {
+	if r.files == nil {
+		return catalog.Snapshot{}, catalog.ErrNotFound
+	}
+	return catalog.Snapshot{SnapshotID: 1, TenantID: tenantID}, nil
 }
 
 func (r *inMemoryTableCatalog) GetSnapshotByID(context.Context, string, int64) (catalog.Snapshot, error) {
@@ -200,5 +262,8 @@ func (r *inMemoryTableCatalog) GetSnapshotByTime(context.Context, string, time.T
 }
 
 func (r *inMemoryTableCatalog) ListSnapshotFiles(context.Context, string, int64) ([]catalog.SnapshotFileEntry, error) {
-	return nil, catalog.ErrNotFound
+	if r.files == nil {
+		return nil, catalog.ErrNotFound
+	}
+	return r.files, nil
 }
@@ -141,6 +141,7 @@ func buildTableContexts(ctx context.Context, deps Dependencies, tenantID string,
 			FileSizeBytes: file.FileSizeBytes,
 		})
 	}
+	primaryKeys := snapshotPrimaryKeys(files)
 
 	for i := range contexts {
 		filesForTable := byTable[contexts[i].TableName]
@@ -148,9 +149,10 @@ func buildTableContexts(ctx context.Context, deps Dependencies, tenantID string,
 			continue
 		}
 		result, err := deps.QueryEngine.Execute(ctx, query.Request{
-			SQL:      "SELECT * FROM " + quoteIdent(contexts[i].TableName) + " LIMIT " + strconv.Itoa(sampleRows),
-			Files:    filesForTable,
-			RowLimit: sampleRows,
+			SQL:         "SELECT * FROM " + quoteIdent(contexts[i].TableName) + " LIMIT " + strconv.Itoa(sampleRows),
+			Files:       filesForTable,
+			RowLimit:    sampleRows,
+			PrimaryKeys: primaryKeys,
 		})
 		if err != nil {
 			continue
@@ -0,0 +1,356 @@
+package bulkload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/duckmesh/duckmesh/internal/catalog"
+	catalogpostgres "github.com/duckmesh/duckmesh/internal/catalog/postgres"
+	"github.com/duckmesh/duckmesh/internal/storage"
+	"github.com/duckmesh/duckmesh/internal/tableschema"
+)
+
+var (
+	ErrSchemaRequired = errors.New("bulk load requires a declared table schema")
+	ErrKeyedTable     = errors.New("bulk load is not supported for tables with primary_key_cols")
+)
+
+type Catalog interface {
+	GetCurrentTableSchema(ctx context.Context, tableID int64) (catalog.TableSchemaVersion, error)
+	AllocateSnapshotID(ctx context.Context) (int64, error)
+	PublishBulkLoad(ctx context.Context, in catalogpostgres.PublishBulkLoadInput) (catalogpostgres.PublishBulkLoadResult, error)
+}
+
+type Service struct {
+	Catalog     Catalog
+	ObjectStore storage.ObjectStore
+	CreatedBy   string
+}
+
+// ValidationError reports a file that does not match the table schema.
+type ValidationError struct {
+	Path    string
+	Column  string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Column != "" {
+		return fmt.Sprintf("%s: column %q: %s", e.Path, e.Column, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+type ObjectsRequest struct {
+	TenantID string
+	Table    catalog.TableDef
+	Paths    []string
+}
+
+type UploadRequest struct {
+	TenantID string
+	Table    catalog.TableDef
+	Body     io.Reader
+}
+
+type FileResult struct {
+	Path          string `json:"path"`
+	FileID        int64  `json:"file_id"`
+	RecordCount   int64  `json:"record_count"`
+	FileSizeBytes int64  `json:"file_size_bytes"`
+}
+
+type Result struct {
+	SnapshotID         int64        `json:"snapshot_id"`
+	VisibilityToken    int64        `json:"visibility_token"`
+	MaxVisibilityToken int64        `json:"max_visibility_token"`
+	Files              []FileResult `json:"files"`
+}
+
+// LoadObjects validates Parquet files that already exist in the object store
+// under the tenant prefix and publishes them in one snapshot.
+func (s *Service) LoadObjects(ctx context.Context, request ObjectsRequest) (Result, error) {
+	if len(request.Paths) == 0 {
+		return Result{}, fmt.Errorf("at least one path is required")
+	}
+	schema, err := s.loadSchema(ctx, request.Table)
+	if err != nil {
+		return Result{}, err
+	}
+
+	files := make([]catalogpostgres.PublishFile, 0, len(request.Paths))
+	seen := make(map[string]struct{}, len(request.Paths))
+	for _, objectPath := range request.Paths {
+		if err := validateObjectPath(request.TenantID, objectPath); err != nil {
+			return Result{}, err
+		}
+		if _, ok := seen[objectPath]; ok {
+			return Result{}, &ValidationError{Path: objectPath, Message: "path is listed more than once"}
+		}
+		seen[objectPath] = struct{}{}
+
+		file, err := s.inspectObject(ctx, objectPath, schema)
+		if err != nil {
+			return Result{}, err
+		}
+		files = append(files, file)
+	}
+
+	snapshotID, err := s.Catalog.AllocateSnapshotID(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	return s.publish(ctx, request.TenantID, request.Table.TableID, snapshotID, files)
+}
+
+// LoadUpload validates an uploaded Parquet file, writes it to the table's bulk
+// directory and publishes it in a new snapshot.
+func (s *Service) LoadUpload(ctx context.Context, request UploadRequest) (Result, error) {
+	schema, err := s.loadSchema(ctx, request.Table)
+	if err != nil {
+		return Result{}, err
+	}
+
+	localFile, size, err := spoolToTempFile(request.Body)
+	if err != nil {
+		return Result{}, err
+	}
+	defer func() {
+		_ = localFile.Close()
+		_ = os.Remove(localFile.Name())
+	}()
+
+	snapshotID, err := s.Catalog.AllocateSnapshotID(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	tableDir := "table-" + strconv.FormatInt(request.Table.TableID, 10)
+	objectPath, err := storage.BuildBulkLoadFilePath(request.TenantID, tableDir, snapshotID, 0)
+	if err != nil {
+		return Result{}, err
+	}
+
+	file, err := inspectParquet(localFile, size, objectPath, schema)
+	if err != nil {
+		return Result{}, err
+	}
+	if _, err := localFile.Seek(0, io.SeekStart); err != nil {
+		return Result{}, fmt.Errorf("rewind uploaded file: %w", err)
+	}
+	if _, err := s.ObjectStore.Put(ctx, objectPath, localFile, size, storage.PutOptions{ContentType: "application/octet-stream"}); err != nil {
+		return Result{}, fmt.Errorf("put bulk load file %q: %w", objectPath, err)
+	}
+	result, err := s.publish(ctx, request.TenantID, request.Table.TableID, snapshotID, []catalogpostgres.PublishFile{file})
+	if err != nil {
+		// No data_file row points at the object, so GC would never remove it.
+		_ = s.ObjectStore.Delete(ctx, objectPath)
+		return Result{}, err
+	}
+	return result, nil
+}
+
+func (s *Service) publish(ctx context.Context, tenantID string, tableID, snapshotID int64, files []catalogpostgres.PublishFile) (Result, error) {
+	published, err := s.Catalog.PublishBulkLoad(ctx, catalogpostgres.PublishBulkLoadInput{
+		SnapshotID: snapshotID,
+		TenantID:   tenantID,
+		TableID:    tableID,
+		CreatedBy:  s.CreatedBy,
+		Files:      files,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{
+		SnapshotID:         published.SnapshotID,
+		VisibilityToken:    published.VisibilityToken,
+		MaxVisibilityToken: published.MaxVisibilityToken,
+		Files:              make([]FileResult, 0, len(files)),
+	}
+	for i, file := range files {
+		entry := FileResult{Path: file.Path, RecordCount: file.RecordCount, FileSizeBytes: file.FileSizeBytes}
+		if i < len(published.FileIDs) {
+			entry.FileID = published.FileIDs[i]
+		}
+		result.Files = append(result.Files, entry)
+	}
+	return result, nil
+}
+
+func (s *Service) loadSchema(ctx context.Context, table catalog.TableDef) (tableschema.Schema, error) {
+	if s.Catalog == nil {
+		return tableschema.Schema{}, fmt.Errorf("catalog is required")
+	}
+	if s.ObjectStore == nil {
+		return tableschema.Schema{}, fmt.Errorf("object store is required")
+	}
+
+	var primaryKey []string
+	if len(table.PrimaryKeyCols) > 0 {
+		if err := json.Unmarshal(table.PrimaryKeyCols, &primaryKey); err != nil {
+			return tableschema.Schema{}, fmt.Errorf("decode primary key columns: %w", err)
+		}
+	}
+	if len(primaryKey) > 0 {
+		return tableschema.Schema{}, ErrKeyedTable
+	}
+
+	version, err := s.Catalog.GetCurrentTableSchema(ctx, table.TableID)
+	if err != nil {
+		if errors.Is(err, catalog.ErrNotFound) {
+			return tableschema.Schema{}, ErrSchemaRequired
+		}
+		return tableschema.Schema{}, fmt.Errorf("get table schema: %w", err)
+	}
+	schema, err := tableschema.Parse(version.SchemaJSON)
+	if err != nil {
+		return tableschema.Schema{}, fmt.Errorf("parse table schema: %w", err)
+	}
+	if schema.IsEmpty() {
+		return tableschema.Schema{}, ErrSchemaRequired
+	}
+	return schema, nil
+}
+
+func (s *Service) inspectObject(ctx context.Context, objectPath string, schema tableschema.Schema) (catalogpostgres.PublishFile, error) {
+	reader, err := s.ObjectStore.Get(ctx, objectPath)
+	if err != nil {
+		return catalogpostgres.PublishFile{}, fmt.Errorf("get object %q: %w", objectPath, err)
+	}
+	localFile, size, err := spoolToTempFile(reader)
+	_ = reader.Close()
+	if err != nil {
+		return catalogpostgres.PublishFile{}, err
+	}
+	defer func() {
+		_ = localFile.Close()
+		_ = os.Remove(localFile.Name())
+	}()
+	return inspectParquet(localFile, size, objectPath, schema)
+}
+
+func spoolToTempFile(body io.Reader) (*os.File, int64, error) {
+	localFile, err := os.CreateTemp("", "duckmesh-bulkload-*.parquet")
+	if err != nil {
+		return nil, 0, fmt.Errorf("create bulk load temp file: %w", err)
+	}
+	size, err := io.Copy(localFile, body)
+	if err != nil {
+		_ = localFile.Close()
+		_ = os.Remove(localFile.Name())
+		return nil, 0, fmt.Errorf("spool bulk load file: %w", err)
+	}
+	return localFile, size, nil
+}
+
+func validateObjectPath(tenantID, objectPath string) error {
+	cleaned := path.Clean(objectPath)
+	if cleaned != objectPath || strings.Contains(objectPath, "..") {
+		return &ValidationError{Path: objectPath, Message: "path must be a clean object key"}
+	}
+	if !strings.HasPrefix(objectPath, tenantID+"/") {
+		return &ValidationError{Path: objectPath, Message: "path must be under the tenant prefix " + tenantID + "/"}
+	}
+	if !strings.HasSuffix(objectPath, ".parquet") {
+		return &ValidationError{Path: objectPath, Message: "path must be a .parquet object"}
+	}
+	return nil
+}
+
+// inspectParquet checks the file footer against the table schema: every
+// top-level column must be a declared field with a compatible physical type,
+// and required fields must be present without nulls.
+func inspectParquet(file io.ReaderAt, size int64, objectPath string, schema tableschema.Schema) (catalogpostgres.PublishFile, error) {
+	parquetFile, err := parquet.OpenFile(file, size, parquet.SkipPageIndex(true), parquet.SkipBloomFilters(true))
+	if err != nil {
+		return catalogpostgres.PublishFile{}, &ValidationError{Path: objectPath, Message: "not a readable parquet file: " + err.Error()}
+	}
+
+	columns := map[string]parquet.Field{}
+	for _, field := range parquetFile.Schema().Fields() {
+		name := field.Name()
+		if strings.HasPrefix(name, tableschema.ReservedPrefix) {
+			return catalogpostgres.PublishFile{}, &ValidationError{Path: objectPath, Column: name, Message: "reserved column names are not allowed"}
+		}
+		declared, ok := schema.Field(name)
+		if !ok {
+			return catalogpostgres.PublishFile{}, &ValidationError{Path: objectPath, Column: name, Message: "column is not declared in the table schema"}
+		}
+		if !field.Leaf() || field.Repeated() {
+			return catalogpostgres.PublishFile{}, &ValidationError{Path: objectPath, Column: name, Message: "nested and repeated columns are not supported"}
+		}
+		if !compatibleType(declared.Type, field.Type()) {
+			return catalogpostgres.PublishFile{}, &ValidationError{Path: objectPath, Column: name, Message: fmt.Sprintf("parquet type %s is not compatible with %s", field.Type(), declared.Type)}
+		}
+		columns[name] = field
+	}
+
+	for _, declared := range schema.Fields {
+		if !declared.Required {
+			continue
+		}
+		field, ok := columns[declared.Name]
+		if !ok {
+			return catalogpostgres.PublishFile{}, &ValidationError{Path: objectPath, Column: declared.Name, Message: "required column is missing"}
+		}
+		if field.Optional() && columnHasNulls(parquetFile, declared.Name) {
+			return catalogpostgres.PublishFile{}, &ValidationError{Path: objectPath, Column: declared.Name, Message: "required column contains nulls"}
+		}
+	}
+
+	return catalogpostgres.PublishFile{
+		Path:          objectPath,
+		Content:       catalog.DataFileContentData,
+		RecordCount:   parquetFile.NumRows(),
+		FileSizeBytes: size,
+	}, nil
+}
+
+func columnHasNulls(file *parquet.File, name string) bool {
+	leaf, ok := file.Schema().Lookup(name)
+	if !ok {
+		return true
+	}
+	for _, rowGroup := range file.Metadata().RowGroups {
+		if leaf.ColumnIndex >= len(rowGroup.Columns) {
+			return true
+		}
+		if rowGroup.Columns[leaf.ColumnIndex].MetaData.Statistics.NullCount > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func compatibleType(declared tableschema.Type, parquetType parquet.Type) bool {
+	logical := parquetType.LogicalType()
+	plainInteger := logical == nil || (logical.Integer != nil && logical.Integer.IsSigned)
+	switch declared {
+	case tableschema.TypeBigint:
+		return (parquetType.Kind() == parquet.Int64 || parquetType.Kind() == parquet.Int32) && plainInteger
+	case tableschema.TypeInteger:
+		return parquetType.Kind() == parquet.Int32 && plainInteger
+	case tableschema.TypeDouble:
+		return parquetType.Kind() == parquet.Double || parquetType.Kind() == parquet.Float
+	case tableschema.TypeBoolean:
+		return parquetType.Kind() == parquet.Boolean
+	case tableschema.TypeVarchar:
+		return parquetType.Kind() == parquet.ByteArray && (logical == nil || logical.UTF8 != nil || logical.Enum != nil)
+	case tableschema.TypeTimestamp:
+		return parquetType.Kind() == parquet.Int96 || (parquetType.Kind() == parquet.Int64 && logical != nil && logical.Timestamp != nil)
+	case tableschema.TypeDate:
+		return parquetType.Kind() == parquet.Int32 && logical != nil && logical.Date != nil
+	case tableschema.TypeJSON:
+		return parquetType.Kind() == parquet.ByteArray && (logical == nil || logical.Json != nil || logical.UTF8 != nil)
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+package bulkload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/duckmesh/duckmesh/internal/catalog"
+	catalogpostgres "github.com/duckmesh/duckmesh/internal/catalog/postgres"
+	"github.com/duckmesh/duckmesh/internal/storage"
+)
+
+type measurement struct {
+	ID    int64   `parquet:"id"`
+	Name  string  `parquet:"name"`
+	Value float64 `parquet:"value"`
+}
+
+type measurementWithExtra struct {
+	ID    int64  `parquet:"id"`
+	Extra string `parquet:"extra"`
+}
+
+type measurementWithStringID struct {
+	ID string `parquet:"id"`
+}
+
+func TestLoadObjectsPublishesValidatedFiles(t *testing.T) {
+	store := &memoryStore{objects: map[string][]byte{
+		"tenant-1/imports/a.parquet": mustParquet(t, []measurement{{ID: 1, Name: "a", Value: 1.5}, {ID: 2, Name: "b", Value: 2.5}}),
+		"tenant-1/imports/b.parquet": mustParquet(t, []measurement{{ID: 3, Name: "c", Value: 3.5}}),
+	}}
+	catalogStub := &fakeCatalog{schemaJSON: `{"id":{"type":"bigint","required":true},"name":"varchar","value":"double","note":"varchar"}`}
+	service := &Service{Catalog: catalogStub, ObjectStore: store}
+
+	result, err := service.LoadObjects(context.Background(), ObjectsRequest{
+		TenantID: "tenant-1",
+		Table:    catalog.TableDef{TableID: 7, TenantID: "tenant-1", TableName: "events"},
+		Paths:    []string{"tenant-1/imports/a.parquet", "tenant-1/imports/b.parquet"},
+	})
+	if err != nil {
+		t.Fatalf("LoadObjects() error = %v", err)
+	}
+	if result.SnapshotID != 501 || result.VisibilityToken != 900 || len(result.Files) != 2 {
+		t.Fatalf("result = %+v", result)
+	}
+	if result.Files[0].RecordCount != 2 || result.Files[1].RecordCount != 1 || result.Files[1].FileID != 2 {
+		t.Fatalf("files = %+v", result.Files)
+	}
+
+	published := catalogStub.published
+	if published.SnapshotID != 501 || published.TableID != 7 || len(published.Files) != 2 {
+		t.Fatalf("published = %+v", published)
+	}
+	if published.Files[0].Content != catalog.DataFileContentData || published.Files[0].FileSizeBytes != int64(len(store.objects["tenant-1/imports/a.parquet"])) {
+		t.Fatalf("published file = %+v", published.Files[0])
+	}
+}
+
+func TestLoadObjectsRejectsSchemaMismatches(t *testing.T) {
+	cases := map[string][]byte{
+		"tenant-1/imports/extra.parquet":   mustParquet(t, []measurementWithExtra{{ID: 1, Extra: "x"}}),
+		"tenant-1/imports/wrong.parquet":   mustParquet(t, []measurementWithStringID{{ID: "1"}}),
+		"tenant-1/imports/missing.parquet": mustParquet(t, []measurementWithExtra{{ID: 1}})[:16],
+	}
+	store := &memoryStore{objects: cases}
+	catalogStub := &fakeCatalog{schemaJSON: `{"id":"bigint"}`}
+	service := &Service{Catalog: catalogStub, ObjectStore: store}
+
+	for objectPath := range cases {
+		_, err := service.LoadObjects(context.Background(), ObjectsRequest{
+			TenantID: "tenant-1",
+			Table:    catalog.TableDef{TableID: 7, TenantID: "tenant-1", TableName: "events"},
+			Paths:    []string{objectPath},
+		})
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Fatalf("LoadObjects(%s) error = %v, want ValidationError", objectPath, err)
+		}
+	}
+	if catalogStub.published.SnapshotID != 0 {
+		t.Fatalf("unexpected publish: %+v", catalogStub.published)
+	}
+}
+
+func TestLoadObjectsRejectsForeignPathsAndKeyedTables(t *testing.T) {
+	service := &Service{Catalog: &fakeCatalog{schemaJSON: `{"id":"bigint"}`}, ObjectStore: &memoryStore{}}
+	table := catalog.TableDef{TableID: 7, TenantID: "tenant-1", TableName: "events"}
+
+	for _, objectPath := range []string{"tenant-2/imports/a.parquet", "tenant-1/../tenant-2/a.parquet", "tenant-1/imports/a.csv"} {
+		_, err := service.LoadObjects(context.Background(), ObjectsRequest{TenantID: "tenant-1", Table: table, Paths: []string{objectPath}})
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Fatalf("LoadObjects(%s) error = %v, want ValidationError", objectPath, err)
+		}
+	}
+
+	table.PrimaryKeyCols = []byte(`["id"]`)
+	_, err := service.LoadObjects(context.Background(), ObjectsRequest{TenantID: "tenant-1", Table: table, Paths: []string{"tenant-1/a.parquet"}})
+	if !errors.Is(err, ErrKeyedTable) {
+		t.Fatalf("LoadObjects() error = %v, want ErrKeyedTable", err)
+	}
+
+	service.Catalog = &fakeCatalog{}
+	table.PrimaryKeyCols = nil
+	_, err = service.LoadObjects(context.Background(), ObjectsRequest{TenantID: "tenant-1", Table: table, Paths: []string{"tenant-1/a.parquet"}})
+	if !errors.Is(err, ErrSchemaRequired) {
+		t.Fatalf("LoadObjects() error = %v, want ErrSchemaRequired", err)
+	}
+}
+
+func TestLoadUploadWritesBulkFile(t *testing.T) {
+	store := &memoryStore{objects: map[string][]byte{}}
+	catalogStub := &fakeCatalog{schemaJSON: `{"id":"bigint","name":"varchar","value":"double"}`}
+	service := &Service{Catalog: catalogStub, ObjectStore: store}
+	body := mustParquet(t, []measurement{{ID: 1, Name: "a", Value: 1}})
+
+	result, err := service.LoadUpload(context.Background(), UploadRequest{
+		TenantID: "tenant-1",
+		Table:    catalog.TableDef{TableID: 7, TenantID: "tenant-1", TableName: "events"},
+		Body:     bytes.NewReader(body),
+	})
+	if err != nil {
+		t.Fatalf("LoadUpload() error = %v", err)
+	}
+	wantPath := "tenant-1/table-7/bulk/bulk-501-00000.parquet"
+	if len(result.Files) != 1 || result.Files[0].Path != wantPath {
+		t.Fatalf("result = %+v", result)
+	}
+	if !bytes.Equal(store.objects[wantPath], body) {
+		t.Fatalf("stored object size = %d, want %d", len(store.objects[wantPath]), len(body))
+	}
+}
+
+func TestLoadUploadRemovesFileWhenPublishFails(t *testing.T) {
+	store := &memoryStore{objects: map[string][]byte{}}
+	catalogStub := &fakeCatalog{schemaJSON: `{"id":"bigint","name":"varchar","value":"double"}`, publishErr: errors.New("catalog down")}
+	service := &Service{Catalog: catalogStub, ObjectStore: store}
+
+	_, err := service.LoadUpload(context.Background(), UploadRequest{
+		TenantID: "tenant-1",
+		Table:    catalog.TableDef{TableID: 7, TenantID: "tenant-1", TableName: "events"},
+		Body:     bytes.NewReader(mustParquet(t, []measurement{{ID: 1, Name: "a", Value: 1}})),
+	})
+	if err == nil {
+		t.Fatal("LoadUpload() should fail")
+	}
+	if len(store.objects) != 0 {
+		t.Fatalf("objects left behind = %d", len(store.objects))
+	}
+}
+
+func mustParquet[T any](t *testing.T, rows []T) []byte {
+	t.Helper()
+	buf := bytes.NewBuffer(nil)
+	writer := parquet.NewGenericWriter[T](buf)
+	if _, err := writer.Write(rows); err != nil {
+		t.Fatalf("write parquet: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close parquet: %v", err)
+	}
+	return buf.Bytes()
+}
+
+type fakeCatalog struct {
+	schemaJSON string
+	publishErr error
+	published  catalogpostgres.PublishBulkLoadInput
+}
+
+func (f *fakeCatalog) GetCurrentTableSchema(_ context.Context, tableID int64) (catalog.TableSchemaVersion, error) {
+	if f.schemaJSON == "" {
+		return catalog.TableSchemaVersion{}, catalog.ErrNotFound
+	}
+	return catalog.TableSchemaVersion{TableID: tableID, SchemaVersion: 1, SchemaJSON: []byte(f.schemaJSON)}, nil
+}
+
+func (f *fakeCatalog) AllocateSnapshotID(context.Context) (int64, error) {
+	return 501, nil
+}
+
+func (f *fakeCatalog) PublishBulkLoad(_ context.Context, in catalogpostgres.PublishBulkLoadInput) (catalogpostgres.PublishBulkLoadResult, error) {
+	if f.publishErr != nil {
+		return catalogpostgres.PublishBulkLoadResult{}, f.publishErr
+	}
+	f.published = in
+	fileIDs := make([]int64, 0, len(in.Files))
+	for i := range in.Files {
+		fileIDs = append(fileIDs, int64(i+1))
+	}
+	return catalogpostgres.PublishBulkLoadResult{SnapshotID: in.SnapshotID, VisibilityToken: 900, MaxVisibilityToken: 900, FileIDs: fileIDs}, nil
+}
+
+type memoryStore struct {
+	objects map[string][]byte
+}
+
+func (m *memoryStore) Put(_ context.Context, key string, body io.Reader, _ int64, _ storage.PutOptions) (storage.ObjectInfo, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	m.objects[key] = data
+	return storage.ObjectInfo{Key: key, Size: int64(len(data))}, nil
+}
+
+func (m *memoryStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, storage.ErrObjectNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memoryStore) Stat(_ context.Context, key string) (storage.ObjectInfo, error) {
+	data, ok := m.objects[key]
+	if !ok {
+		return storage.ObjectInfo{}, storage.ErrObjectNotFound
+	}
+	return storage.ObjectInfo{Key: key, Size: int64(len(data))}, nil
+}
+
+func (m *memoryStore) Delete(_ context.Context, key string) error {
+	delete(m.objects, key)
+	return nil
+}
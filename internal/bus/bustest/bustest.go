@@ -0,0 +1,538 @@
+// Package bustest checks that a bus.IngestBus implementation honours the
+// contract the API and coordinator rely on. Adapters call Run from their own
+// tests:
+//
+//	func TestConformance(t *testing.T) {
+//		bustest.Run(t, bustest.Suite{New: func(t *testing.T) bus.IngestBus { return newBus(t) }})
+//	}
+package bustest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/bus"
+)
+
+type Table struct {
+	TenantID string
+	TableID  string
+}
+
+type Suite struct {
+	// New returns an empty bus. It is called once per subtest, so state left
+	// by one check never leaks into another.
+	New func(t *testing.T) bus.IngestBus
+	// Tables are the tenant/table pairs events are published to. They must
+	// cover at least two tenants. Defaults to tenant-a/1 and tenant-b/1.
+	Tables []Table
+	// MaxAttempts is the retry budget of buses returned by New, which must
+	// redeliver nacked events without backoff. When positive the suite checks
+	// that exhausted events stop being delivered, and buses implementing
+	// bus.DeadLetterQueue must list, replay and discard them.
+	MaxAttempts int
+}
+
+// leaseSeconds is the shortest lease the interface can express; lease tests
+// wait past it.
+const leaseSeconds = 1
+
+func Run(t *testing.T, suite Suite) {
+	t.Helper()
+	if suite.New == nil {
+		t.Fatal("bustest: Suite.New is required")
+	}
+	if len(suite.Tables) == 0 {
+		suite.Tables = []Table{{TenantID: "tenant-a", TableID: "1"}, {TenantID: "tenant-b", TableID: "1"}}
+	}
+	tenants := map[string]struct{}{}
+	for _, table := range suite.Tables {
+		tenants[table.TenantID] = struct{}{}
+	}
+	if len(tenants) < 2 {
+		t.Fatal("bustest: Suite.Tables must cover at least two tenants")
+	}
+
+	t.Run("IdempotentPublish", func(t *testing.T) { testIdempotentPublish(t, suite) })
+	t.Run("MonotonicTokens", func(t *testing.T) { testMonotonicTokens(t, suite) })
+	t.Run("ExclusiveClaims", func(t *testing.T) { testExclusiveClaims(t, suite) })
+	t.Run("LeaseExpiry", func(t *testing.T) { testLeaseExpiry(t, suite) })
+	t.Run("ExtendLease", func(t *testing.T) { testExtendLease(t, suite) })
+	t.Run("NackRedelivers", func(t *testing.T) { testNackRedelivers(t, suite) })
+	t.Run("PartialNack", func(t *testing.T) { testPartialNack(t, suite) })
+	t.Run("ConcurrentConsumers", func(t *testing.T) { testConcurrentConsumers(t, suite) })
+	if suite.MaxAttempts > 0 {
+		t.Run("RetryBudget", func(t *testing.T) { testRetryBudget(t, suite) })
+	}
+}
+
+func testIdempotentPublish(t *testing.T, suite Suite) {
+	ctx := context.Background()
+	ingestBus := suite.New(t)
+	table := suite.Tables[0]
+
+	first := publish(t, ingestBus, envelope(table, "k1"), envelope(table, "k2"), envelope(table, "k1"))
+	if !first[0].Inserted || !first[1].Inserted {
+		t.Fatalf("new events not inserted: %+v", first)
+	}
+	if first[2].Inserted || first[2].EventID != first[0].EventID || first[2].VisibilityToken != first[0].VisibilityToken {
+		t.Fatalf("duplicate in the same request = %+v, want event %+v", first[2], first[0])
+	}
+
+	again := publish(t, ingestBus, envelope(table, "k2"))
+	if again[0].Inserted || again[0].EventID != first[1].EventID || again[0].VisibilityToken != first[1].VisibilityToken {
+		t.Fatalf("duplicate in a later request = %+v, want event %+v", again[0], first[1])
+	}
+
+	// The same key in another tenant is a different event.
+	other := otherTenant(suite, table.TenantID)
+	separate := publish(t, ingestBus, envelope(other, "k1"))
+	if !separate[0].Inserted || separate[0].EventID == first[0].EventID {
+		t.Fatalf("key in another tenant = %+v", separate[0])
+	}
+
+	batch := claim(t, ingestBus, "consumer-1", 10)
+	if len(batch.EventIDs) != 3 {
+		t.Fatalf("claimed %d events, want 3 distinct events", len(batch.EventIDs))
+	}
+	if empty, err := ingestBus.Publish(ctx, nil); err != nil || len(empty) != 0 {
+		t.Fatalf("Publish(nil) = %+v, %v", empty, err)
+	}
+}
+
+func testMonotonicTokens(t *testing.T, suite Suite) {
+	ingestBus := suite.New(t)
+	a := suite.Tables[0]
+	b := otherTenant(suite, a.TenantID)
+
+	last := map[string]int64{}
+	seen := map[int64]string{}
+	for i := range 20 {
+		table := a
+		if i%3 == 0 {
+			table = b
+		}
+		result := publish(t, ingestBus, envelope(table, fmt.Sprintf("k%d", i)))[0]
+		if result.VisibilityToken <= last[table.TenantID] {
+			t.Fatalf("tenant %s token %d after %d", table.TenantID, result.VisibilityToken, last[table.TenantID])
+		}
+		if previous, ok := seen[result.VisibilityToken]; ok {
+			t.Fatalf("token %d issued twice (%s and %s)", result.VisibilityToken, previous, result.EventID)
+		}
+		last[table.TenantID] = result.VisibilityToken
+		seen[result.VisibilityToken] = result.EventID
+	}
+}
+
+func testExclusiveClaims(t *testing.T, suite Suite) {
+	ctx := context.Background()
+	ingestBus := suite.New(t)
+	table := suite.Tables[0]
+	published := publish(t, ingestBus, envelopes(table, "k", 5)...)
+	tokens := tokensByEventID(published)
+
+	first := claim(t, ingestBus, "consumer-1", 3)
+	second := claim(t, ingestBus, "consumer-2", 10)
+	if len(first.EventIDs) != 3 || len(second.EventIDs) != 2 {
+		t.Fatalf("claimed %d and %d events, want 3 and 2", len(first.EventIDs), len(second.EventIDs))
+	}
+	if first.BatchID == second.BatchID {
+		t.Fatalf("both claims returned batch %s", first.BatchID)
+	}
+	for _, eventID := range first.EventIDs {
+		if slices.Contains(second.EventIDs, eventID) {
+			t.Fatalf("event %s claimed by both consumers", eventID)
+		}
+	}
+	for _, batch := range []bus.Batch{first, second} {
+		assertBatchShape(t, batch, tokens)
+	}
+	if third := claim(t, ingestBus, "consumer-3", 10); len(third.EventIDs) != 0 {
+		t.Fatalf("third claim got %d events while all are leased", len(third.EventIDs))
+	}
+
+	if err := ingestBus.Ack(ctx, first.BatchID, first.EventIDs); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if err := ingestBus.Ack(ctx, second.BatchID, second.EventIDs); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if after := claim(t, ingestBus, "consumer-3", 10); len(after.EventIDs) != 0 {
+		t.Fatalf("acked events delivered again: %v", after.EventIDs)
+	}
+}
+
+func testLeaseExpiry(t *testing.T, suite Suite) {
+	ctx := context.Background()
+	ingestBus := suite.New(t)
+	table := suite.Tables[0]
+	publish(t, ingestBus, envelopes(table, "k", 4)...)
+
+	acked := claimWithLease(t, ingestBus, "consumer-1", 2, leaseSeconds)
+	expired := claimWithLease(t, ingestBus, "consumer-1", 2, leaseSeconds)
+	if err := ingestBus.Ack(ctx, acked.BatchID, acked.EventIDs); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if count, err := ingestBus.RequeueExpired(ctx); err != nil || count != 0 {
+		t.Fatalf("RequeueExpired() before expiry = %d, %v; want 0", count, err)
+	}
+
+	waitPastLease()
+	count, err := ingestBus.RequeueExpired(ctx)
+	if err != nil {
+		t.Fatalf("RequeueExpired() error = %v", err)
+	}
+	if count != len(expired.EventIDs) {
+		t.Fatalf("RequeueExpired() = %d, want %d", count, len(expired.EventIDs))
+	}
+
+	redelivered := claimWithLease(t, ingestBus, "consumer-2", 10, leaseSeconds)
+	if !sameEvents(redelivered.EventIDs, expired.EventIDs) {
+		t.Fatalf("redelivered %v, want %v", redelivered.EventIDs, expired.EventIDs)
+	}
+	if err := ingestBus.ExtendLease(ctx, expired.BatchID, 30); err == nil {
+		t.Fatal("ExtendLease() on an expired claim error = nil")
+	}
+
+	// An ack from the expired claim must not finish events now owned by the
+	// new claim, so they expire and come back once more.
+	if err := ingestBus.Ack(ctx, expired.BatchID, expired.EventIDs); err != nil {
+		t.Fatalf("stale Ack() error = %v", err)
+	}
+	waitPastLease()
+	if count, err := ingestBus.RequeueExpired(ctx); err != nil || count != len(expired.EventIDs) {
+		t.Fatalf("RequeueExpired() after stale ack = %d, %v; want %d", count, err, len(expired.EventIDs))
+	}
+	if again := claim(t, ingestBus, "consumer-3", 10); !sameEvents(again.EventIDs, expired.EventIDs) {
+		t.Fatalf("after stale ack got %v, want %v", again.EventIDs, expired.EventIDs)
+	}
+}
+
+func testExtendLease(t *testing.T, suite Suite) {
+	ctx := context.Background()
+	ingestBus := suite.New(t)
+	publish(t, ingestBus, envelopes(suite.Tables[0], "k", 2)...)
+
+	batch := claimWithLease(t, ingestBus, "consumer-1", 10, leaseSeconds)
+	if err := ingestBus.ExtendLease(ctx, batch.BatchID, 30); err != nil {
+		t.Fatalf("ExtendLease() error = %v", err)
+	}
+	waitPastLease()
+	if count, err := ingestBus.RequeueExpired(ctx); err != nil || count != 0 {
+		t.Fatalf("RequeueExpired() after extension = %d, %v; want 0", count, err)
+	}
+	if other := claim(t, ingestBus, "consumer-2", 10); len(other.EventIDs) != 0 {
+		t.Fatalf("extended claim lost events %v", other.EventIDs)
+	}
+	if err := ingestBus.Ack(ctx, batch.BatchID, batch.EventIDs); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+
+	if err := ingestBus.ExtendLease(ctx, "987654321", 30); err == nil {
+		t.Fatal("ExtendLease() on an unknown batch error = nil")
+	}
+}
+
+func testNackRedelivers(t *testing.T, suite Suite) {
+	ctx := context.Background()
+	ingestBus := suite.New(t)
+	table := suite.Tables[0]
+	published := publish(t, ingestBus, envelopes(table, "k", 2)...)
+
+	batch := claim(t, ingestBus, "consumer-1", 10)
+	if len(batch.EventIDs) != 2 {
+		t.Fatalf("claimed %d events, want 2", len(batch.EventIDs))
+	}
+	if err := ingestBus.Nack(ctx, batch.BatchID, []string{published[1].EventID}, "write failed"); err != nil {
+		t.Fatalf("Nack() error = %v", err)
+	}
+	if err := ingestBus.Ack(ctx, batch.BatchID, []string{published[0].EventID}); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+
+	redelivered := claim(t, ingestBus, "consumer-2", 10)
+	if !sameEvents(redelivered.EventIDs, []string{published[1].EventID}) {
+		t.Fatalf("redelivered %v, want [%s]", redelivered.EventIDs, published[1].EventID)
+	}
+	if envelope := redelivered.Envelopes[0]; envelope.IdempotencyKey != "k-1" || string(envelope.PayloadJSON) != `{"n":1}` {
+		t.Fatalf("redelivered envelope = %+v", envelope)
+	}
+	if err := ingestBus.Ack(ctx, redelivered.BatchID, redelivered.EventIDs); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if after := claim(t, ingestBus, "consumer-2", 10); len(after.EventIDs) != 0 {
+		t.Fatalf("acked events delivered again: %v", after.EventIDs)
+	}
+}
+
+// testPartialNack nacks one event of a claim while the rest is still in
+// flight, as the coordinator does when one table of a batch fails.
+func testPartialNack(t *testing.T, suite Suite) {
+	ctx := context.Background()
+	ingestBus := suite.New(t)
+	published := publish(t, ingestBus, envelopes(suite.Tables[0], "k", 3)...)
+
+	batch := claimWithLease(t, ingestBus, "consumer-1", 10, leaseSeconds)
+	if len(batch.EventIDs) != 3 {
+		t.Fatalf("claimed %d events, want 3", len(batch.EventIDs))
+	}
+	if err := ingestBus.Nack(ctx, batch.BatchID, []string{published[1].EventID}, "write failed"); err != nil {
+		t.Fatalf("Nack() error = %v", err)
+	}
+	if err := ingestBus.ExtendLease(ctx, batch.BatchID, 30); err != nil {
+		t.Fatalf("ExtendLease() after a partial nack error = %v", err)
+	}
+	waitPastLease()
+	if count, err := ingestBus.RequeueExpired(ctx); err != nil || count != 0 {
+		t.Fatalf("RequeueExpired() after extension = %d, %v; want 0", count, err)
+	}
+
+	redelivered := claim(t, ingestBus, "consumer-2", 10)
+	if !sameEvents(redelivered.EventIDs, []string{published[1].EventID}) {
+		t.Fatalf("redelivered %v, want [%s]", redelivered.EventIDs, published[1].EventID)
+	}
+	rest := []string{published[0].EventID, published[2].EventID}
+	if err := ingestBus.Ack(ctx, batch.BatchID, rest); err != nil {
+		t.Fatalf("Ack() of the rest error = %v", err)
+	}
+	if err := ingestBus.Ack(ctx, redelivered.BatchID, redelivered.EventIDs); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if err := ingestBus.ExtendLease(ctx, batch.BatchID, 30); err == nil {
+		t.Fatal("ExtendLease() on a settled claim error = nil")
+	}
+	if after := claim(t, ingestBus, "consumer-3", 10); len(after.EventIDs) != 0 {
+		t.Fatalf("settled events delivered again: %v", after.EventIDs)
+	}
+}
+
+func testRetryBudget(t *testing.T, suite Suite) {
+	ctx := context.Background()
+	ingestBus := suite.New(t)
+	table := suite.Tables[0]
+	published := publish(t, ingestBus, envelopes(table, "k", 2)...)
+	eventIDs := []string{published[0].EventID, published[1].EventID}
+
+	for attempt := 1; attempt <= suite.MaxAttempts; attempt++ {
+		batch := claim(t, ingestBus, "consumer-1", 10)
+		if !sameEvents(batch.EventIDs, eventIDs) {
+			t.Fatalf("attempt %d claimed %v, want %v", attempt, batch.EventIDs, eventIDs)
+		}
+		if batch.Attempt != attempt {
+			t.Fatalf("batch.Attempt = %d, want %d", batch.Attempt, attempt)
+		}
+		if err := ingestBus.Nack(ctx, batch.BatchID, batch.EventIDs, "poison record"); err != nil {
+			t.Fatalf("Nack() error = %v", err)
+		}
+	}
+	if batch := claim(t, ingestBus, "consumer-1", 10); len(batch.EventIDs) != 0 {
+		t.Fatalf("events past the retry budget delivered again: %v", batch.EventIDs)
+	}
+
+	queue, ok := ingestBus.(bus.DeadLetterQueue)
+	if !ok {
+		return
+	}
+	failed, err := queue.ListFailed(ctx, bus.FailedEventFilter{TenantID: table.TenantID})
+	if err != nil {
+		t.Fatalf("ListFailed() error = %v", err)
+	}
+	if len(failed) != 2 {
+		t.Fatalf("ListFailed() = %+v, want 2 events", failed)
+	}
+	for _, event := range failed {
+		if event.Attempts != suite.MaxAttempts || event.LastError != "poison record" || event.FailedAt.IsZero() || event.TableID != table.TableID {
+			t.Fatalf("failed event = %+v", event)
+		}
+	}
+	other := otherTenant(suite, table.TenantID)
+	if failed, err := queue.ListFailed(ctx, bus.FailedEventFilter{TenantID: other.TenantID}); err != nil || len(failed) != 0 {
+		t.Fatalf("ListFailed() for %s = %+v, %v; want none", other.TenantID, failed, err)
+	}
+	if count, err := queue.ReplayFailed(ctx, other.TenantID, eventIDs); err != nil || count != 0 {
+		t.Fatalf("ReplayFailed() across tenants = %d, %v; want 0", count, err)
+	}
+
+	if count, err := queue.ReplayFailed(ctx, table.TenantID, eventIDs[:1]); err != nil || count != 1 {
+		t.Fatalf("ReplayFailed() = %d, %v; want 1", count, err)
+	}
+	if count, err := queue.DiscardFailed(ctx, table.TenantID, eventIDs[1:]); err != nil || count != 1 {
+		t.Fatalf("DiscardFailed() = %d, %v; want 1", count, err)
+	}
+	if failed, err := queue.ListFailed(ctx, bus.FailedEventFilter{TenantID: table.TenantID}); err != nil || len(failed) != 0 {
+		t.Fatalf("ListFailed() after replay and discard = %+v, %v; want none", failed, err)
+	}
+
+	replayed := claim(t, ingestBus, "consumer-2", 10)
+	if !sameEvents(replayed.EventIDs, eventIDs[:1]) || replayed.Attempt != 1 {
+		t.Fatalf("replayed batch = %+v, want %s on attempt 1", replayed, eventIDs[0])
+	}
+	if err := ingestBus.Ack(ctx, replayed.BatchID, replayed.EventIDs); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if after := claim(t, ingestBus, "consumer-2", 10); len(after.EventIDs) != 0 {
+		t.Fatalf("discarded event delivered again: %v", after.EventIDs)
+	}
+}
+
+func testConcurrentConsumers(t *testing.T, suite Suite) {
+	ctx := context.Background()
+	ingestBus := suite.New(t)
+	const total = 120
+	events := make([]bus.Envelope, 0, total)
+	for i := range total {
+		events = append(events, envelope(suite.Tables[i%len(suite.Tables)], fmt.Sprintf("k-%d", i)))
+	}
+	publish(t, ingestBus, events...)
+
+	var (
+		mu      sync.Mutex
+		claimed = map[string]string{}
+		errs    []error
+		wg      sync.WaitGroup
+	)
+	for worker := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			consumerID := fmt.Sprintf("consumer-%d", worker)
+			for {
+				batch, err := ingestBus.ClaimBatch(ctx, consumerID, 7, 30)
+				if err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("%s: ClaimBatch: %w", consumerID, err))
+					mu.Unlock()
+					return
+				}
+				if len(batch.EventIDs) == 0 {
+					return
+				}
+				mu.Lock()
+				for _, eventID := range batch.EventIDs {
+					if owner, ok := claimed[eventID]; ok {
+						errs = append(errs, fmt.Errorf("event %s claimed by %s and %s", eventID, owner, consumerID))
+					}
+					claimed[eventID] = consumerID
+				}
+				mu.Unlock()
+				if err := ingestBus.Ack(ctx, batch.BatchID, batch.EventIDs); err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("%s: Ack: %w", consumerID, err))
+					mu.Unlock()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		t.Error(err)
+	}
+	if len(claimed) != total {
+		t.Fatalf("claimed %d distinct events, want %d", len(claimed), total)
+	}
+}
+
+func assertBatchShape(t *testing.T, batch bus.Batch, tokens map[string]int64) {
+	t.Helper()
+	if batch.BatchID == "" || len(batch.EventIDs) != len(batch.Envelopes) {
+		t.Fatalf("batch %q has %d event ids and %d envelopes", batch.BatchID, len(batch.EventIDs), len(batch.Envelopes))
+	}
+	var maxToken int64
+	for i, eventID := range batch.EventIDs {
+		if batch.Envelopes[i].EventID != eventID {
+			t.Fatalf("envelope %d event id = %q, want %q", i, batch.Envelopes[i].EventID, eventID)
+		}
+		token, ok := tokens[eventID]
+		if !ok {
+			t.Fatalf("claimed unknown event %s", eventID)
+		}
+		maxToken = max(maxToken, token)
+	}
+	if batch.Visibility != maxToken {
+		t.Fatalf("batch visibility = %d, want max event token %d", batch.Visibility, maxToken)
+	}
+	if batch.LeaseUntil <= batch.ClaimedUnix {
+		t.Fatalf("lease until %d is not after claim time %d", batch.LeaseUntil, batch.ClaimedUnix)
+	}
+}
+
+func publish(t *testing.T, ingestBus bus.IngestBus, events ...bus.Envelope) []bus.PublishResult {
+	t.Helper()
+	results, err := ingestBus.Publish(context.Background(), events)
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if len(results) != len(events) {
+		t.Fatalf("Publish() returned %d results for %d events", len(results), len(events))
+	}
+	return results
+}
+
+func claim(t *testing.T, ingestBus bus.IngestBus, consumerID string, limit int) bus.Batch {
+	t.Helper()
+	return claimWithLease(t, ingestBus, consumerID, limit, 30)
+}
+
+func claimWithLease(t *testing.T, ingestBus bus.IngestBus, consumerID string, limit, lease int) bus.Batch {
+	t.Helper()
+	batch, err := ingestBus.ClaimBatch(context.Background(), consumerID, limit, lease)
+	if err != nil {
+		t.Fatalf("ClaimBatch() error = %v", err)
+	}
+	return batch
+}
+
+func envelope(table Table, key string) bus.Envelope {
+	var n int
+	_, _ = fmt.Sscanf(key, "k-%d", &n)
+	return bus.Envelope{
+		TenantID:       table.TenantID,
+		TableID:        table.TableID,
+		IdempotencyKey: key,
+		Op:             "insert",
+		PayloadJSON:    []byte(fmt.Sprintf(`{"n":%d}`, n)),
+	}
+}
+
+func envelopes(table Table, prefix string, count int) []bus.Envelope {
+	events := make([]bus.Envelope, 0, count)
+	for i := range count {
+		events = append(events, envelope(table, fmt.Sprintf("%s-%d", prefix, i)))
+	}
+	return events
+}
+
+func otherTenant(suite Suite, tenantID string) Table {
+	for _, table := range suite.Tables {
+		if table.TenantID != tenantID {
+			return table
+		}
+	}
+	return suite.Tables[0]
+}
+
+func tokensByEventID(results []bus.PublishResult) map[string]int64 {
+	tokens := make(map[string]int64, len(results))
+	for _, result := range results {
+		tokens[result.EventID] = result.VisibilityToken
+	}
+	return tokens
+}
+
+func sameEvents(got, want []string) bool {
+	got = slices.Clone(got)
+	want = slices.Clone(want)
+	slices.Sort(got)
+	slices.Sort(want)
+	return slices.Equal(got, want)
+}
+
+func waitPastLease() {
+	time.Sleep(leaseSeconds*time.Second + 500*time.Millisecond)
+}
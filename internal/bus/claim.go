@@ -0,0 +1,54 @@
+package bus
+
+// ClaimPolicy decides how one claim is shared between tenants. The zero
+// value claims the globally oldest events first.
+type ClaimPolicy struct {
+	// Fair interleaves tenants round-robin so a tenant with a large backlog
+	// cannot starve the others.
+	Fair bool
+	// TenantWeights lets a tenant take that many events per round when Fair
+	// is set; unlisted tenants take one.
+	TenantWeights map[string]int
+}
+
+func (p ClaimPolicy) Weight(tenantID string) int {
+	if weight := p.TenantWeights[tenantID]; weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+// Pick chooses up to limit candidates, given as the tenant of each one in
+// claim order, round-robin across tenants by weight. It returns the indexes
+// of the chosen candidates in ascending order.
+func (p ClaimPolicy) Pick(tenantIDs []string, limit int) []int {
+	picked := make([]bool, len(tenantIDs))
+	count := 0
+	for count < limit {
+		taken := map[string]int{}
+		progressed := false
+		for i, tenantID := range tenantIDs {
+			if count >= limit {
+				break
+			}
+			if picked[i] || taken[tenantID] >= p.Weight(tenantID) {
+				continue
+			}
+			taken[tenantID]++
+			picked[i] = true
+			count++
+			progressed = true
+		}
+		if !progressed {
+			break
+		}
+	}
+
+	indexes := make([]int, 0, count)
+	for i := range picked {
+		if picked[i] {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
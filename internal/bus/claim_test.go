@@ -0,0 +1,21 @@
+package bus
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestClaimPolicyPickInterleavesTenantsByWeight(t *testing.T) {
+	tenants := []string{"a", "a", "a", "a", "a", "b", "b", "c"}
+
+	if got := (ClaimPolicy{Fair: true}).Pick(tenants, 4); fmt.Sprint(got) != "[0 1 5 7]" {
+		t.Fatalf("Pick() = %v", got)
+	}
+	weighted := ClaimPolicy{Fair: true, TenantWeights: map[string]int{"b": 2}}
+	if got := weighted.Pick(tenants, 4); fmt.Sprint(got) != "[0 5 6 7]" {
+		t.Fatalf("weighted Pick() = %v", got)
+	}
+	if got := (ClaimPolicy{Fair: true}).Pick(tenants, 100); len(got) != len(tenants) {
+		t.Fatalf("Pick() with spare limit = %v", got)
+	}
+}
@@ -0,0 +1,127 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Record is one message of a topic partition.
+type Record struct {
+	Offset int64
+	Key    []byte
+	Value  []byte
+	// Timestamp is set by the broker when the record is appended.
+	Timestamp time.Time
+}
+
+// Broker is the subset of a Kafka client the ingest bus needs: partitioned
+// append-only topics and committed consumer group offsets. Implementations
+// wrap a real client; MemoryBroker is an in-process fake.
+type Broker interface {
+	Partitions(ctx context.Context, topic string) (int32, error)
+	// Produce appends records to one partition and returns their offsets.
+	Produce(ctx context.Context, topic string, partition int32, records []Record) ([]int64, error)
+	// Fetch returns up to maxRecords records starting at offset.
+	Fetch(ctx context.Context, topic string, partition int32, offset int64, maxRecords int) ([]Record, error)
+	CommitOffset(ctx context.Context, group, topic string, partition int32, offset int64) error
+	// CommittedOffset returns the next offset to consume for the group, or 0
+	// when the group has not committed yet.
+	CommittedOffset(ctx context.Context, group, topic string, partition int32) (int64, error)
+}
+
+// MemoryBroker keeps topics in process memory. Every topic is created on
+// first use with the configured partition count.
+type MemoryBroker struct {
+	mu         sync.Mutex
+	partitions int32
+	topics     map[string][][]Record
+	offsets    map[string]int64
+	clock      func() time.Time
+}
+
+func NewMemoryBroker(partitions int32) *MemoryBroker {
+	if partitions <= 0 {
+		partitions = 1
+	}
+	return &MemoryBroker{
+		partitions: partitions,
+		topics:     map[string][][]Record{},
+		offsets:    map[string]int64{},
+		clock:      time.Now,
+	}
+}
+
+func (b *MemoryBroker) Partitions(_ context.Context, _ string) (int32, error) {
+	return b.partitions, nil
+}
+
+func (b *MemoryBroker) Produce(_ context.Context, topic string, partition int32, records []Record) ([]int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	log, err := b.partitionLog(topic, partition)
+	if err != nil {
+		return nil, err
+	}
+	offsets := make([]int64, 0, len(records))
+	for _, record := range records {
+		record.Offset = int64(len(*log))
+		record.Timestamp = b.clock().UTC()
+		record.Key = append([]byte(nil), record.Key...)
+		record.Value = append([]byte(nil), record.Value...)
+		*log = append(*log, record)
+		offsets = append(offsets, record.Offset)
+	}
+	return offsets, nil
+}
+
+func (b *MemoryBroker) Fetch(_ context.Context, topic string, partition int32, offset int64, maxRecords int) ([]Record, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	log, err := b.partitionLog(topic, partition)
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 || offset >= int64(len(*log)) || maxRecords <= 0 {
+		return nil, nil
+	}
+	end := min(offset+int64(maxRecords), int64(len(*log)))
+	return append([]Record(nil), (*log)[offset:end]...), nil
+}
+
+func (b *MemoryBroker) CommitOffset(_ context.Context, group, topic string, partition int32, offset int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if partition < 0 || partition >= b.partitions {
+		return fmt.Errorf("partition %d out of range for topic %q", partition, topic)
+	}
+	b.offsets[offsetKey(group, topic, partition)] = offset
+	return nil
+}
+
+func (b *MemoryBroker) CommittedOffset(_ context.Context, group, topic string, partition int32) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.offsets[offsetKey(group, topic, partition)], nil
+}
+
+func (b *MemoryBroker) partitionLog(topic string, partition int32) (*[]Record, error) {
+	if partition < 0 || partition >= b.partitions {
+		return nil, fmt.Errorf("partition %d out of range for topic %q", partition, topic)
+	}
+	logs, ok := b.topics[topic]
+	if !ok {
+		logs = make([][]Record, b.partitions)
+		b.topics[topic] = logs
+	}
+	return &logs[partition], nil
+}
+
+func offsetKey(group, topic string, partition int32) string {
+	return fmt.Sprintf("%s\x1f%s\x1f%d", group, topic, partition)
+}
+
+var _ Broker = (*MemoryBroker)(nil)
@@ -0,0 +1,720 @@
+package kafka
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/bus"
+)
+
+const (
+	defaultTopic        = "duckmesh.ingest"
+	defaultGroup        = "duckmesh-coordinator"
+	defaultFetchRecords = 500
+	defaultDedupeWindow = 24 * time.Hour
+)
+
+type Config struct {
+	// Topic holds ingest events. A tenant always maps to the same partition,
+	// so its events keep publish order.
+	Topic string
+	// KeysTopic records the first event token per idempotency key. It must
+	// have the same partition count as Topic and should use
+	// cleanup.policy=compact,delete with retention.ms set to DedupeWindow,
+	// so a starting process only replays keys it still remembers.
+	KeysTopic string
+	// DedupeWindow is how long an idempotency key is remembered after it is
+	// first published; a key published again later is a new event. Defaults
+	// to 24 hours.
+	DedupeWindow time.Duration
+	// FailedTopic receives records that cannot be decoded as events and
+	// events whose retry budget ran out.
+	FailedTopic string
+	Group       string
+	// PartitionCount is the partition count of Topic and is required.
+	// Visibility tokens and tenant placement are derived from it, so it can
+	// never change: adding partitions would move tenants to partitions with
+	// lower offsets and send their tokens backwards. The bus refuses to
+	// publish or claim while the topic reports another count; to grow,
+	// move to a new topic.
+	PartitionCount int32
+	// Partitions limits claims to a subset of Topic partitions. Coordinators
+	// sharing a topic must use disjoint subsets; empty claims all partitions.
+	Partitions   []int32
+	FetchRecords int
+}
+
+// IngestBus implements bus.IngestBus on Kafka topics. Visibility tokens are
+// derived from partition offsets as offset*partitions + partition + 1, which
+// keeps them unique across partitions and monotonic per tenant as long as the
+// partition count stays fixed. Leases and
+// per-event acks are tracked in memory; the consumer group offset of a
+// partition is committed once every event below it has been acked or
+// dead-lettered, so events claimed but not acked before a restart are
+// delivered again. Attempt counts are kept in memory too and restart at zero.
+type IngestBus struct {
+	broker Broker
+	config Config
+	clock  func() time.Time
+	retry  bus.RetryPolicy
+
+	partitionsMu sync.Mutex
+	checked      bool
+
+	// keysMu guards the idempotency key index and serializes publishes.
+	keysMu sync.Mutex
+	keys   map[int32]*keyIndex
+
+	mu          sync.Mutex
+	consumers   map[int32]*partitionState
+	batches     map[string]*claimBatch
+	nextBatchID int64
+}
+
+// keyIndex holds the idempotency keys of one partition registered within
+// the dedupe window.
+type keyIndex struct {
+	next   int64
+	tokens map[string]registeredKey
+	// added lists registrations oldest first, for expiry.
+	added []addedKey
+}
+
+type registeredKey struct {
+	token int64
+	at    time.Time
+}
+
+type addedKey struct {
+	key string
+	at  time.Time
+}
+
+type partitionState struct {
+	committed int64
+	position  int64
+	done      map[int64]struct{}
+	requeued  []claimedEvent
+}
+
+type claimedEvent struct {
+	partition int32
+	offset    int64
+	envelope  bus.Envelope
+	attempts  int
+	notBefore time.Time
+}
+
+type claimBatch struct {
+	consumerID string
+	leaseUntil time.Time
+	events     map[string]claimedEvent
+}
+
+type eventRecord struct {
+	TenantID        string          `json:"tenant_id"`
+	TableID         string          `json:"table_id"`
+	IdempotencyKey  string          `json:"idempotency_key"`
+	Op              string          `json:"op"`
+	PayloadJSON     json.RawMessage `json:"payload_json"`
+	EventTimeUnixMs int64           `json:"event_time_unix_ms,omitempty"`
+}
+
+type failedRecord struct {
+	eventRecord
+	EventID  string `json:"event_id"`
+	Reason   string `json:"reason"`
+	FailedAt int64  `json:"failed_at_unix_ms"`
+}
+
+func NewIngestBus(broker Broker, config Config) *IngestBus {
+	if config.Topic == "" {
+		config.Topic = defaultTopic
+	}
+	if config.KeysTopic == "" {
+		config.KeysTopic = config.Topic + ".keys"
+	}
+	if config.FailedTopic == "" {
+		config.FailedTopic = config.Topic + ".failed"
+	}
+	if config.Group == "" {
+		config.Group = defaultGroup
+	}
+	if config.FetchRecords <= 0 {
+		config.FetchRecords = defaultFetchRecords
+	}
+	if config.DedupeWindow <= 0 {
+		config.DedupeWindow = defaultDedupeWindow
+	}
+	return &IngestBus{
+		broker:    broker,
+		config:    config,
+		clock:     time.Now,
+		retry:     bus.DefaultRetryPolicy(),
+		keys:      map[int32]*keyIndex{},
+		consumers: map[int32]*partitionState{},
+		batches:   map[string]*claimBatch{},
+	}
+}
+
+// WithRetryPolicy sets how nacked and expired events are redelivered
+// before they are moved to FailedTopic.
+func (b *IngestBus) WithRetryPolicy(policy bus.RetryPolicy) *IngestBus {
+	b.mu.Lock()
+	b.retry = policy
+	b.mu.Unlock()
+	return b
+}
+
+func (b *IngestBus) Publish(ctx context.Context, events []bus.Envelope) ([]bus.PublishResult, error) {
+	if len(events) == 0 {
+		return []bus.PublishResult{}, nil
+	}
+	partitions, err := b.partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byPartition := map[int32][]int{}
+	for i, event := range events {
+		if _, err := strconv.ParseInt(event.TableID, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid table id %q: %w", event.TableID, err)
+		}
+		partition := tenantPartition(event.TenantID, partitions)
+		byPartition[partition] = append(byPartition[partition], i)
+	}
+
+	b.keysMu.Lock()
+	defer b.keysMu.Unlock()
+
+	results := make([]bus.PublishResult, len(events))
+	for _, partition := range sortedPartitions(byPartition) {
+		index, err := b.catchUpKeys(ctx, partition)
+		if err != nil {
+			return nil, err
+		}
+
+		firstInRequest := map[string]int{}
+		newRecords := make([]Record, 0, len(byPartition[partition]))
+		newEvents := make([]int, 0, len(byPartition[partition]))
+		duplicates := map[int]int{}
+		for _, i := range byPartition[partition] {
+			key := idempotencyKey(events[i])
+			if registered, ok := index.tokens[key]; ok {
+				results[i] = bus.PublishResult{EventID: strconv.FormatInt(registered.token, 10), VisibilityToken: registered.token}
+				continue
+			}
+			if first, ok := firstInRequest[key]; ok {
+				duplicates[i] = first
+				continue
+			}
+			value, err := encodeEvent(events[i])
+			if err != nil {
+				return nil, err
+			}
+			firstInRequest[key] = i
+			newRecords = append(newRecords, Record{Key: []byte(events[i].TenantID), Value: value})
+			newEvents = append(newEvents, i)
+		}
+		if len(newRecords) == 0 {
+			continue
+		}
+
+		offsets, err := b.broker.Produce(ctx, b.config.Topic, partition, newRecords)
+		if err != nil {
+			return nil, fmt.Errorf("produce events to partition %d: %w", partition, err)
+		}
+		if len(offsets) != len(newRecords) {
+			return nil, fmt.Errorf("produce events to partition %d: got %d offsets for %d records", partition, len(offsets), len(newRecords))
+		}
+		keyRecords := make([]Record, 0, len(newEvents))
+		now := b.clock().UTC()
+		for j, i := range newEvents {
+			token := visibilityToken(partition, offsets[j], partitions)
+			results[i] = bus.PublishResult{EventID: strconv.FormatInt(token, 10), VisibilityToken: token, Inserted: true}
+			key := idempotencyKey(events[i])
+			index.register(key, token, now)
+			keyRecords = append(keyRecords, Record{Key: []byte(key), Value: []byte(strconv.FormatInt(token, 10))})
+		}
+		if _, err := b.broker.Produce(ctx, b.config.KeysTopic, partition, keyRecords); err != nil {
+			return nil, fmt.Errorf("produce idempotency keys to partition %d: %w", partition, err)
+		}
+		for i, first := range duplicates {
+			results[i] = results[first]
+			results[i].Inserted = false
+		}
+	}
+	return results, nil
+}
+
+func (b *IngestBus) ClaimBatch(ctx context.Context, consumerID string, limit int, leaseSeconds int) (bus.Batch, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if leaseSeconds <= 0 {
+		leaseSeconds = 30
+	}
+	partitions, err := b.partitions(ctx)
+	if err != nil {
+		return bus.Batch{}, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock().UTC()
+	claimed := make([]claimedEvent, 0, limit)
+	for _, partition := range b.assignedPartitions(partitions) {
+		if len(claimed) >= limit {
+			break
+		}
+		state, err := b.partitionState(ctx, partition)
+		if err != nil {
+			return bus.Batch{}, err
+		}
+		waiting := state.requeued[:0]
+		for _, event := range state.requeued {
+			if len(claimed) < limit && !event.notBefore.After(now) {
+				claimed = append(claimed, event)
+				continue
+			}
+			waiting = append(waiting, event)
+		}
+		state.requeued = waiting
+
+		skipped := false
+		for len(claimed) < limit {
+			records, err := b.broker.Fetch(ctx, b.config.Topic, partition, state.position, min(limit-len(claimed), b.config.FetchRecords))
+			if err != nil {
+				return bus.Batch{}, fmt.Errorf("fetch partition %d at offset %d: %w", partition, state.position, err)
+			}
+			if len(records) == 0 {
+				break
+			}
+			index, err := b.lockedCatchUpKeys(ctx, partition)
+			if err != nil {
+				return bus.Batch{}, err
+			}
+			for _, record := range records {
+				state.position = record.Offset + 1
+				token := visibilityToken(partition, record.Offset, partitions)
+				envelope, err := decodeEvent(record.Value, token)
+				if err != nil {
+					if err := b.produceFailed(ctx, partition, bus.Envelope{EventID: strconv.FormatInt(token, 10)}, "decode event: "+err.Error()); err != nil {
+						return bus.Batch{}, err
+					}
+					state.done[record.Offset] = struct{}{}
+					skipped = true
+					continue
+				}
+				if first, ok := index[idempotencyKey(envelope)]; ok && first.token != token {
+					// A concurrent publisher in another process wrote the same
+					// key; the first registered event wins.
+					state.done[record.Offset] = struct{}{}
+					skipped = true
+					continue
+				}
+				claimed = append(claimed, claimedEvent{partition: partition, offset: record.Offset, envelope: envelope})
+			}
+		}
+		if skipped {
+			if err := b.advance(ctx, partition, state); err != nil {
+				return bus.Batch{}, err
+			}
+		}
+	}
+	if len(claimed) == 0 {
+		return bus.Batch{}, nil
+	}
+
+	leaseUntil := now.Add(time.Duration(leaseSeconds) * time.Second)
+	b.nextBatchID++
+	batchID := strconv.FormatInt(b.nextBatchID, 10)
+	pending := &claimBatch{consumerID: consumerID, leaseUntil: leaseUntil, events: make(map[string]claimedEvent, len(claimed))}
+	batch := bus.Batch{
+		BatchID:     batchID,
+		ConsumerID:  consumerID,
+		LeaseUntil:  leaseUntil.UnixMilli(),
+		EventIDs:    make([]string, 0, len(claimed)),
+		Envelopes:   make([]bus.Envelope, 0, len(claimed)),
+		ClaimedUnix: now.UnixMilli(),
+	}
+	for _, event := range claimed {
+		event.attempts++
+		event.notBefore = time.Time{}
+		if event.attempts > batch.Attempt {
+			batch.Attempt = event.attempts
+		}
+		pending.events[event.envelope.EventID] = event
+		batch.EventIDs = append(batch.EventIDs, event.envelope.EventID)
+		batch.Envelopes = append(batch.Envelopes, event.envelope)
+		if token := visibilityToken(event.partition, event.offset, partitions); token > batch.Visibility {
+			batch.Visibility = token
+		}
+	}
+	b.batches[batchID] = pending
+	return batch, nil
+}
+
+func (b *IngestBus) Ack(ctx context.Context, batchID string, eventIDs []string) error {
+	if _, err := strconv.ParseInt(batchID, 10, 64); err != nil {
+		return fmt.Errorf("invalid batch id %q: %w", batchID, err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.finish(ctx, batchID, eventIDs)
+}
+
+// Nack keeps the events uncommitted and hands them to a claim after the
+// retry backoff. Events out of attempts are moved to FailedTopic with reason.
+func (b *IngestBus) Nack(ctx context.Context, batchID string, eventIDs []string, reason string) error {
+	if _, err := strconv.ParseInt(batchID, 10, 64); err != nil {
+		return fmt.Errorf("invalid batch id %q: %w", batchID, err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pending, ok := b.batches[batchID]
+	if !ok {
+		return nil
+	}
+	now := b.clock().UTC()
+	touched := map[int32]*partitionState{}
+	for _, eventID := range eventIDs {
+		event, ok := pending.events[eventID]
+		if !ok {
+			continue
+		}
+		delete(pending.events, eventID)
+		touched[event.partition] = b.consumers[event.partition]
+		if err := b.release(ctx, event, now, reason); err != nil {
+			return err
+		}
+	}
+	if len(pending.events) == 0 {
+		delete(b.batches, batchID)
+	}
+	return b.settle(ctx, touched)
+}
+
+func (b *IngestBus) ExtendLease(_ context.Context, batchID string, leaseSeconds int) error {
+	if _, err := strconv.ParseInt(batchID, 10, 64); err != nil {
+		return fmt.Errorf("invalid batch id %q: %w", batchID, err)
+	}
+	if leaseSeconds <= 0 {
+		leaseSeconds = 30
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pending, ok := b.batches[batchID]
+	if !ok {
+		return fmt.Errorf("claim batch %s not found or not claimable", batchID)
+	}
+	pending.leaseUntil = b.clock().UTC().Add(time.Duration(leaseSeconds) * time.Second)
+	return nil
+}
+
+func (b *IngestBus) RequeueExpired(ctx context.Context) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock().UTC()
+	count := 0
+	touched := map[int32]*partitionState{}
+	for batchID, pending := range b.batches {
+		if !pending.leaseUntil.Before(now) {
+			continue
+		}
+		for _, event := range pending.events {
+			touched[event.partition] = b.consumers[event.partition]
+			if b.retry.Exhausted(event.attempts) {
+				if err := b.release(ctx, event, now, "lease expired"); err != nil {
+					return count, err
+				}
+			} else {
+				state := b.consumers[event.partition]
+				state.requeued = append(state.requeued, event)
+			}
+			count++
+		}
+		delete(b.batches, batchID)
+	}
+	return count, b.settle(ctx, touched)
+}
+
+// release schedules a failed event for redelivery after its backoff, or
+// moves it to FailedTopic and marks it done once the retry budget is spent.
+// Callers hold b.mu.
+func (b *IngestBus) release(ctx context.Context, event claimedEvent, now time.Time, reason string) error {
+	state := b.consumers[event.partition]
+	if b.retry.Exhausted(event.attempts) {
+		if err := b.produceFailed(ctx, event.partition, event.envelope, reason); err != nil {
+			return err
+		}
+		state.done[event.offset] = struct{}{}
+		return nil
+	}
+	event.notBefore = now.Add(b.retry.Backoff(event.attempts))
+	state.requeued = append(state.requeued, event)
+	return nil
+}
+
+// settle orders requeued events and commits offsets past dead-lettered ones.
+func (b *IngestBus) settle(ctx context.Context, touched map[int32]*partitionState) error {
+	for partition, state := range touched {
+		sortRequeued(state)
+		if err := b.advance(ctx, partition, state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *IngestBus) finish(ctx context.Context, batchID string, eventIDs []string) error {
+	pending, ok := b.batches[batchID]
+	if !ok {
+		return nil
+	}
+	touched := map[int32]struct{}{}
+	for _, eventID := range eventIDs {
+		event, ok := pending.events[eventID]
+		if !ok {
+			continue
+		}
+		b.consumers[event.partition].done[event.offset] = struct{}{}
+		delete(pending.events, eventID)
+		touched[event.partition] = struct{}{}
+	}
+	if len(pending.events) == 0 {
+		delete(b.batches, batchID)
+	}
+	for partition := range touched {
+		if err := b.advance(ctx, partition, b.consumers[partition]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// advance moves the committed offset over every finished event and commits
+// it for the consumer group.
+func (b *IngestBus) advance(ctx context.Context, partition int32, state *partitionState) error {
+	start := state.committed
+	for {
+		if _, ok := state.done[state.committed]; !ok {
+			break
+		}
+		delete(state.done, state.committed)
+		state.committed++
+	}
+	if state.committed == start {
+		return nil
+	}
+	if err := b.broker.CommitOffset(ctx, b.config.Group, b.config.Topic, partition, state.committed); err != nil {
+		return fmt.Errorf("commit offset %d for partition %d: %w", state.committed, partition, err)
+	}
+	return nil
+}
+
+func (b *IngestBus) partitionState(ctx context.Context, partition int32) (*partitionState, error) {
+	if state, ok := b.consumers[partition]; ok {
+		return state, nil
+	}
+	committed, err := b.broker.CommittedOffset(ctx, b.config.Group, b.config.Topic, partition)
+	if err != nil {
+		return nil, fmt.Errorf("read committed offset for partition %d: %w", partition, err)
+	}
+	state := &partitionState{committed: committed, position: committed, done: map[int64]struct{}{}}
+	b.consumers[partition] = state
+	return state, nil
+}
+
+func (b *IngestBus) produceFailed(ctx context.Context, partition int32, envelope bus.Envelope, reason string) error {
+	value, err := json.Marshal(failedRecord{
+		eventRecord: newEventRecord(envelope),
+		EventID:     envelope.EventID,
+		Reason:      reason,
+		FailedAt:    b.clock().UTC().UnixMilli(),
+	})
+	if err != nil {
+		return fmt.Errorf("encode failed event %s: %w", envelope.EventID, err)
+	}
+	if _, err := b.broker.Produce(ctx, b.config.FailedTopic, partition, []Record{{Key: []byte(envelope.TenantID), Value: value}}); err != nil {
+		return fmt.Errorf("produce failed event %s: %w", envelope.EventID, err)
+	}
+	return nil
+}
+
+func (b *IngestBus) lockedCatchUpKeys(ctx context.Context, partition int32) (map[string]registeredKey, error) {
+	b.keysMu.Lock()
+	defer b.keysMu.Unlock()
+	index, err := b.catchUpKeys(ctx, partition)
+	if err != nil {
+		return nil, err
+	}
+	return index.tokens, nil
+}
+
+// catchUpKeys forgets keys older than the dedupe window and reads key
+// registrations written since the last call, including those of other
+// processes. Callers hold keysMu.
+func (b *IngestBus) catchUpKeys(ctx context.Context, partition int32) (*keyIndex, error) {
+	index, ok := b.keys[partition]
+	if !ok {
+		index = &keyIndex{tokens: map[string]registeredKey{}}
+		b.keys[partition] = index
+	}
+	cutoff := b.clock().UTC().Add(-b.config.DedupeWindow)
+	index.expire(cutoff)
+	for {
+		records, err := b.broker.Fetch(ctx, b.config.KeysTopic, partition, index.next, b.config.FetchRecords)
+		if err != nil {
+			return nil, fmt.Errorf("fetch idempotency keys for partition %d: %w", partition, err)
+		}
+		if len(records) == 0 {
+			return index, nil
+		}
+		for _, record := range records {
+			index.next = record.Offset + 1
+			if record.Timestamp.Before(cutoff) {
+				continue
+			}
+			token, err := strconv.ParseInt(string(record.Value), 10, 64)
+			if err != nil {
+				continue
+			}
+			if _, exists := index.tokens[string(record.Key)]; !exists {
+				index.register(string(record.Key), token, record.Timestamp)
+			}
+		}
+	}
+}
+
+func (index *keyIndex) register(key string, token int64, at time.Time) {
+	index.tokens[key] = registeredKey{token: token, at: at}
+	index.added = append(index.added, addedKey{key: key, at: at})
+}
+
+// expire forgets keys registered before cutoff.
+func (index *keyIndex) expire(cutoff time.Time) {
+	expired := 0
+	for _, added := range index.added {
+		if !added.at.Before(cutoff) {
+			break
+		}
+		if registered, ok := index.tokens[added.key]; ok && !registered.at.After(added.at) {
+			delete(index.tokens, added.key)
+		}
+		expired++
+	}
+	index.added = index.added[expired:]
+}
+
+// partitions returns Config.PartitionCount once the topic has been checked
+// to still have that many partitions.
+func (b *IngestBus) partitions(ctx context.Context) (int32, error) {
+	b.partitionsMu.Lock()
+	defer b.partitionsMu.Unlock()
+	if b.checked {
+		return b.config.PartitionCount, nil
+	}
+	if b.config.PartitionCount <= 0 {
+		return 0, fmt.Errorf("partition count of topic %q is required", b.config.Topic)
+	}
+	count, err := b.broker.Partitions(ctx, b.config.Topic)
+	if err != nil {
+		return 0, fmt.Errorf("describe topic %q: %w", b.config.Topic, err)
+	}
+	if count != b.config.PartitionCount {
+		return 0, fmt.Errorf("topic %q has %d partitions, want %d: visibility tokens depend on the partition count, so it cannot change", b.config.Topic, count, b.config.PartitionCount)
+	}
+	b.checked = true
+	return count, nil
+}
+
+func (b *IngestBus) assignedPartitions(count int32) []int32 {
+	if len(b.config.Partitions) > 0 {
+		return b.config.Partitions
+	}
+	all := make([]int32, 0, count)
+	for partition := int32(0); partition < count; partition++ {
+		all = append(all, partition)
+	}
+	return all
+}
+
+func tenantPartition(tenantID string, partitions int32) int32 {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(tenantID))
+	return int32(hash.Sum32() % uint32(partitions))
+}
+
+func visibilityToken(partition int32, offset int64, partitions int32) int64 {
+	return offset*int64(partitions) + int64(partition) + 1
+}
+
+func idempotencyKey(event bus.Envelope) string {
+	return event.TenantID + "\x1f" + event.TableID + "\x1f" + event.IdempotencyKey
+}
+
+func newEventRecord(event bus.Envelope) eventRecord {
+	payload := event.PayloadJSON
+	if len(payload) == 0 {
+		payload = []byte("{}")
+	}
+	return eventRecord{
+		TenantID:        event.TenantID,
+		TableID:         event.TableID,
+		IdempotencyKey:  event.IdempotencyKey,
+		Op:              event.Op,
+		PayloadJSON:     payload,
+		EventTimeUnixMs: event.EventTimeUnixMs,
+	}
+}
+
+func encodeEvent(event bus.Envelope) ([]byte, error) {
+	value, err := json.Marshal(newEventRecord(event))
+	if err != nil {
+		return nil, fmt.Errorf("encode event %q: %w", event.IdempotencyKey, err)
+	}
+	return value, nil
+}
+
+func decodeEvent(value []byte, token int64) (bus.Envelope, error) {
+	var record eventRecord
+	if err := json.Unmarshal(value, &record); err != nil {
+		return bus.Envelope{}, err
+	}
+	return bus.Envelope{
+		EventID:         strconv.FormatInt(token, 10),
+		TenantID:        record.TenantID,
+		TableID:         record.TableID,
+		IdempotencyKey:  record.IdempotencyKey,
+		Op:              record.Op,
+		PayloadJSON:     record.PayloadJSON,
+		EventTimeUnixMs: record.EventTimeUnixMs,
+	}, nil
+}
+
+func sortRequeued(state *partitionState) {
+	slices.SortFunc(state.requeued, func(a, b claimedEvent) int { return cmp.Compare(a.offset, b.offset) })
+}
+
+func sortedPartitions(byPartition map[int32][]int) []int32 {
+	partitions := make([]int32, 0, len(byPartition))
+	for partition := range byPartition {
+		partitions = append(partitions, partition)
+	}
+	slices.Sort(partitions)
+	return partitions
+}
+
+var _ bus.IngestBus = (*IngestBus)(nil)
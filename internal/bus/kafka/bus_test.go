@@ -0,0 +1,384 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/bus"
+	"github.com/duckmesh/duckmesh/internal/bus/bustest"
+)
+
+func TestConformance(t *testing.T) {
+	bustest.Run(t, bustest.Suite{
+		MaxAttempts: 3,
+		New: func(*testing.T) bus.IngestBus {
+			return NewIngestBus(NewMemoryBroker(3), Config{PartitionCount: 3}).WithRetryPolicy(bus.RetryPolicy{MaxAttempts: 3})
+		},
+	})
+}
+
+func TestPublishDeduplicatesAndKeepsTenantTokensMonotonic(t *testing.T) {
+	ctx := context.Background()
+	broker := NewMemoryBroker(4)
+	ingestBus := NewIngestBus(broker, Config{PartitionCount: 4})
+
+	results, err := ingestBus.Publish(ctx, []bus.Envelope{
+		event("tenant-a", "k1"),
+		event("tenant-b", "k1"),
+		event("tenant-a", "k2"),
+		event("tenant-a", "k1"),
+	})
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if !results[0].Inserted || !results[1].Inserted || !results[2].Inserted || results[3].Inserted {
+		t.Fatalf("results = %+v", results)
+	}
+	if results[3].VisibilityToken != results[0].VisibilityToken || results[3].EventID != results[0].EventID {
+		t.Fatalf("duplicate result = %+v, want token of %+v", results[3], results[0])
+	}
+	if results[2].VisibilityToken <= results[0].VisibilityToken {
+		t.Fatalf("tenant-a tokens not monotonic: %d then %d", results[0].VisibilityToken, results[2].VisibilityToken)
+	}
+
+	// A second process sharing the broker sees keys registered by the first.
+	other := NewIngestBus(broker, Config{PartitionCount: 4})
+	again, err := other.Publish(ctx, []bus.Envelope{event("tenant-a", "k2"), event("tenant-a", "k3")})
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if again[0].Inserted || again[0].VisibilityToken != results[2].VisibilityToken {
+		t.Fatalf("cross-process duplicate = %+v", again[0])
+	}
+	if !again[1].Inserted || again[1].VisibilityToken <= results[2].VisibilityToken {
+		t.Fatalf("new event = %+v", again[1])
+	}
+}
+
+func TestPublishForgetsKeysOutsideDedupeWindow(t *testing.T) {
+	now := time.Unix(1700000000, 0).UTC()
+	clock := func() time.Time { return now }
+	broker := NewMemoryBroker(1)
+	broker.clock = clock
+	newBus := func() *IngestBus {
+		ingestBus := NewIngestBus(broker, Config{PartitionCount: 1, DedupeWindow: time.Hour})
+		ingestBus.clock = clock
+		return ingestBus
+	}
+
+	ingestBus := newBus()
+	first := mustPublish(t, ingestBus, event("tenant-a", "k1"), event("tenant-a", "k2"))
+	now = now.Add(30 * time.Minute)
+	again := mustPublish(t, ingestBus, event("tenant-a", "k1"), event("tenant-a", "k3"))
+	if again[0].Inserted || again[0].VisibilityToken != first[0].VisibilityToken {
+		t.Fatalf("duplicate within the window = %+v", again[0])
+	}
+
+	// k1 and k2 are now 75 minutes old and k3 45 minutes old. A starting
+	// process only replays k3.
+	now = now.Add(45 * time.Minute)
+	restarted := newBus()
+	results := mustPublish(t, restarted, event("tenant-a", "k3"), event("tenant-a", "k1"))
+	if results[0].Inserted || results[0].VisibilityToken != again[1].VisibilityToken {
+		t.Fatalf("key within the window = %+v", results[0])
+	}
+	if !results[1].Inserted {
+		t.Fatalf("key outside the window = %+v, want a new event", results[1])
+	}
+	if got := len(restarted.keys[0].tokens); got != 2 {
+		t.Fatalf("restarted index holds %d keys, want 2", got)
+	}
+
+	// The first process drops k2 and picks up the new k1.
+	mustPublish(t, ingestBus, event("tenant-a", "k4"))
+	index := ingestBus.keys[0]
+	if _, ok := index.tokens[idempotencyKey(event("tenant-a", "k2"))]; ok || len(index.tokens) != 3 {
+		t.Fatalf("index = %+v, want k1, k3 and k4", index.tokens)
+	}
+	if index.tokens[idempotencyKey(event("tenant-a", "k1"))].token != results[1].VisibilityToken {
+		t.Fatalf("k1 token = %d, want %d", index.tokens[idempotencyKey(event("tenant-a", "k1"))].token, results[1].VisibilityToken)
+	}
+}
+
+func TestClaimAckCommitsOffsetsAndResumes(t *testing.T) {
+	ctx := context.Background()
+	broker := NewMemoryBroker(1)
+	ingestBus := NewIngestBus(broker, Config{PartitionCount: 1})
+	published := mustPublish(t, ingestBus, event("tenant-a", "k1"), event("tenant-a", "k2"), event("tenant-a", "k3"))
+
+	first, err := ingestBus.ClaimBatch(ctx, "worker-1", 2, 30)
+	if err != nil {
+		t.Fatalf("ClaimBatch() error = %v", err)
+	}
+	if len(first.EventIDs) != 2 || first.Visibility != published[1].VisibilityToken {
+		t.Fatalf("first batch = %+v", first)
+	}
+	second, err := ingestBus.ClaimBatch(ctx, "worker-2", 10, 30)
+	if err != nil {
+		t.Fatalf("ClaimBatch() error = %v", err)
+	}
+	if len(second.EventIDs) != 1 || second.EventIDs[0] != published[2].EventID {
+		t.Fatalf("second batch = %+v", second)
+	}
+
+	// Acking the later batch first must not commit past the unacked events.
+	if err := ingestBus.Ack(ctx, second.BatchID, second.EventIDs); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if offset, _ := broker.CommittedOffset(ctx, defaultGroup, defaultTopic, 0); offset != 0 {
+		t.Fatalf("committed offset = %d, want 0", offset)
+	}
+	if err := ingestBus.Ack(ctx, first.BatchID, first.EventIDs); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if offset, _ := broker.CommittedOffset(ctx, defaultGroup, defaultTopic, 0); offset != 3 {
+		t.Fatalf("committed offset = %d, want 3", offset)
+	}
+
+	mustPublish(t, ingestBus, event("tenant-a", "k4"))
+	restarted := NewIngestBus(broker, Config{PartitionCount: 1})
+	batch, err := restarted.ClaimBatch(ctx, "worker-1", 10, 30)
+	if err != nil {
+		t.Fatalf("ClaimBatch() error = %v", err)
+	}
+	if len(batch.Envelopes) != 1 || batch.Envelopes[0].IdempotencyKey != "k4" || string(batch.Envelopes[0].PayloadJSON) != `{"id":1}` {
+		t.Fatalf("resumed batch = %+v", batch)
+	}
+}
+
+func TestRequeueExpiredRedeliversAndExtendLease(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0).UTC()
+	ingestBus := NewIngestBus(NewMemoryBroker(2), Config{PartitionCount: 2})
+	ingestBus.clock = func() time.Time { return now }
+	mustPublish(t, ingestBus, event("tenant-a", "k1"), event("tenant-b", "k1"))
+
+	batch, err := ingestBus.ClaimBatch(ctx, "worker-1", 10, 30)
+	if err != nil {
+		t.Fatalf("ClaimBatch() error = %v", err)
+	}
+	if len(batch.EventIDs) != 2 {
+		t.Fatalf("batch = %+v", batch)
+	}
+
+	now = now.Add(20 * time.Second)
+	if err := ingestBus.ExtendLease(ctx, batch.BatchID, 30); err != nil {
+		t.Fatalf("ExtendLease() error = %v", err)
+	}
+	now = now.Add(20 * time.Second)
+	if count, err := ingestBus.RequeueExpired(ctx); err != nil || count != 0 {
+		t.Fatalf("RequeueExpired() = %d, %v; want 0", count, err)
+	}
+
+	now = now.Add(20 * time.Second)
+	if count, err := ingestBus.RequeueExpired(ctx); err != nil || count != 2 {
+		t.Fatalf("RequeueExpired() = %d, %v; want 2", count, err)
+	}
+	if err := ingestBus.ExtendLease(ctx, batch.BatchID, 30); err == nil {
+		t.Fatal("ExtendLease() on requeued batch error = nil")
+	}
+	if err := ingestBus.Ack(ctx, batch.BatchID, batch.EventIDs); err != nil {
+		t.Fatalf("stale Ack() error = %v", err)
+	}
+
+	redelivered, err := ingestBus.ClaimBatch(ctx, "worker-2", 10, 30)
+	if err != nil {
+		t.Fatalf("ClaimBatch() error = %v", err)
+	}
+	if len(redelivered.EventIDs) != 2 || redelivered.BatchID == batch.BatchID {
+		t.Fatalf("redelivered batch = %+v", redelivered)
+	}
+}
+
+func TestNackRedeliversWithoutCommitting(t *testing.T) {
+	ctx := context.Background()
+	broker := NewMemoryBroker(1)
+	ingestBus := NewIngestBus(broker, Config{PartitionCount: 1}).WithRetryPolicy(bus.RetryPolicy{MaxAttempts: 3})
+	published := mustPublish(t, ingestBus, event("tenant-a", "k1"), event("tenant-a", "k2"))
+
+	batch, err := ingestBus.ClaimBatch(ctx, "worker-1", 10, 30)
+	if err != nil {
+		t.Fatalf("ClaimBatch() error = %v", err)
+	}
+	if err := ingestBus.Nack(ctx, batch.BatchID, []string{published[0].EventID}, "write parquet: disk full"); err != nil {
+		t.Fatalf("Nack() error = %v", err)
+	}
+	if err := ingestBus.Ack(ctx, batch.BatchID, []string{published[1].EventID}); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if offset, _ := broker.CommittedOffset(ctx, defaultGroup, defaultTopic, 0); offset != 0 {
+		t.Fatalf("committed offset = %d, want 0 while the nacked event is pending", offset)
+	}
+
+	redelivered, err := ingestBus.ClaimBatch(ctx, "worker-2", 10, 30)
+	if err != nil {
+		t.Fatalf("ClaimBatch() error = %v", err)
+	}
+	if len(redelivered.EventIDs) != 1 || redelivered.EventIDs[0] != published[0].EventID {
+		t.Fatalf("redelivered batch = %+v", redelivered)
+	}
+	if err := ingestBus.Ack(ctx, redelivered.BatchID, redelivered.EventIDs); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if offset, _ := broker.CommittedOffset(ctx, defaultGroup, defaultTopic, 0); offset != 2 {
+		t.Fatalf("committed offset = %d, want 2", offset)
+	}
+}
+
+func TestNackBacksOffThenMovesExhaustedEventsToFailedTopic(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0).UTC()
+	broker := NewMemoryBroker(1)
+	ingestBus := NewIngestBus(broker, Config{PartitionCount: 1}).WithRetryPolicy(bus.RetryPolicy{MaxAttempts: 2, BaseBackoff: 10 * time.Second})
+	ingestBus.clock = func() time.Time { return now }
+	published := mustPublish(t, ingestBus, event("tenant-a", "k1"))
+
+	batch, err := ingestBus.ClaimBatch(ctx, "worker-1", 10, 30)
+	if err != nil {
+		t.Fatalf("ClaimBatch() error = %v", err)
+	}
+	if err := ingestBus.Nack(ctx, batch.BatchID, batch.EventIDs, "bad payload"); err != nil {
+		t.Fatalf("Nack() error = %v", err)
+	}
+	if early, err := ingestBus.ClaimBatch(ctx, "worker-1", 10, 30); err != nil || len(early.EventIDs) != 0 {
+		t.Fatalf("ClaimBatch() during backoff = %+v, %v", early, err)
+	}
+
+	now = now.Add(10 * time.Second)
+	retried, err := ingestBus.ClaimBatch(ctx, "worker-1", 10, 30)
+	if err != nil || len(retried.EventIDs) != 1 || retried.Attempt != 2 {
+		t.Fatalf("ClaimBatch() after backoff = %+v, %v", retried, err)
+	}
+	if err := ingestBus.Nack(ctx, retried.BatchID, retried.EventIDs, "bad payload"); err != nil {
+		t.Fatalf("Nack() error = %v", err)
+	}
+
+	records, err := broker.Fetch(ctx, defaultTopic+".failed", 0, 0, 10)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("failed records = %d, %v", len(records), err)
+	}
+	var failed failedRecord
+	if err := json.Unmarshal(records[0].Value, &failed); err != nil {
+		t.Fatalf("decode failed record: %v", err)
+	}
+	if failed.EventID != published[0].EventID || failed.Reason != "bad payload" || failed.IdempotencyKey != "k1" {
+		t.Fatalf("failed record = %+v", failed)
+	}
+	if offset, _ := broker.CommittedOffset(ctx, defaultGroup, defaultTopic, 0); offset != 1 {
+		t.Fatalf("committed offset = %d, want 1", offset)
+	}
+}
+
+func TestClaimMovesUndecodableRecordsToFailedTopic(t *testing.T) {
+	ctx := context.Background()
+	broker := NewMemoryBroker(1)
+	ingestBus := NewIngestBus(broker, Config{PartitionCount: 1})
+	if _, err := broker.Produce(ctx, defaultTopic, 0, []Record{{Value: []byte("not json")}}); err != nil {
+		t.Fatalf("Produce() error = %v", err)
+	}
+	published := mustPublish(t, ingestBus, event("tenant-a", "k1"))
+
+	batch, err := ingestBus.ClaimBatch(ctx, "worker-1", 10, 30)
+	if err != nil {
+		t.Fatalf("ClaimBatch() error = %v", err)
+	}
+	if len(batch.EventIDs) != 1 || batch.EventIDs[0] != published[0].EventID {
+		t.Fatalf("batch = %+v", batch)
+	}
+	records, err := broker.Fetch(ctx, defaultTopic+".failed", 0, 0, 10)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("failed records = %d, %v", len(records), err)
+	}
+	var failed failedRecord
+	if err := json.Unmarshal(records[0].Value, &failed); err != nil {
+		t.Fatalf("decode failed record: %v", err)
+	}
+	if failed.EventID != "1" || !strings.HasPrefix(failed.Reason, "decode event") {
+		t.Fatalf("failed record = %+v", failed)
+	}
+	if offset, _ := broker.CommittedOffset(ctx, defaultGroup, defaultTopic, 0); offset != 1 {
+		t.Fatalf("committed offset = %d, want 1", offset)
+	}
+}
+
+func TestClaimSkipsEventsRacedOnIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	broker := NewMemoryBroker(1)
+	ingestBus := NewIngestBus(broker, Config{PartitionCount: 1})
+	first := mustPublish(t, ingestBus, event("tenant-a", "k1"))
+
+	// Simulate a concurrent publisher that appended the same key before
+	// seeing the first registration.
+	value, err := encodeEvent(event("tenant-a", "k1"))
+	if err != nil {
+		t.Fatalf("encodeEvent() error = %v", err)
+	}
+	if _, err := broker.Produce(ctx, defaultTopic, 0, []Record{{Key: []byte("tenant-a"), Value: value}}); err != nil {
+		t.Fatalf("Produce() error = %v", err)
+	}
+
+	batch, err := ingestBus.ClaimBatch(ctx, "worker-1", 10, 30)
+	if err != nil {
+		t.Fatalf("ClaimBatch() error = %v", err)
+	}
+	if len(batch.EventIDs) != 1 || batch.EventIDs[0] != first[0].EventID {
+		t.Fatalf("batch = %+v", batch)
+	}
+	if err := ingestBus.Ack(ctx, batch.BatchID, batch.EventIDs); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if offset, _ := broker.CommittedOffset(ctx, defaultGroup, defaultTopic, 0); offset != 2 {
+		t.Fatalf("committed offset = %d, want 2", offset)
+	}
+}
+
+func TestTokensAreUniqueAcrossPartitions(t *testing.T) {
+	seen := map[int64]string{}
+	for partition := int32(0); partition < 3; partition++ {
+		for offset := int64(0); offset < 5; offset++ {
+			token := visibilityToken(partition, offset, 3)
+			key := strconv.Itoa(int(partition)) + "/" + strconv.FormatInt(offset, 10)
+			if previous, ok := seen[token]; ok {
+				t.Fatalf("token %d used by %s and %s", token, previous, key)
+			}
+			seen[token] = key
+		}
+	}
+}
+
+func TestRefusesTopicWithChangedPartitionCount(t *testing.T) {
+	ctx := context.Background()
+	broker := NewMemoryBroker(4)
+
+	// The topic was expanded from 3 partitions; tokens derived from 4 would
+	// go backwards for tenants that move.
+	expanded := NewIngestBus(broker, Config{PartitionCount: 3})
+	if _, err := expanded.Publish(ctx, []bus.Envelope{event("tenant-a", "k1")}); err == nil || !strings.Contains(err.Error(), "has 4 partitions, want 3") {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if _, err := expanded.ClaimBatch(ctx, "worker-1", 10, 30); err == nil || !strings.Contains(err.Error(), "has 4 partitions, want 3") {
+		t.Fatalf("ClaimBatch() error = %v", err)
+	}
+
+	unpinned := NewIngestBus(broker, Config{})
+	if _, err := unpinned.Publish(ctx, []bus.Envelope{event("tenant-a", "k1")}); err == nil || !strings.Contains(err.Error(), "partition count") {
+		t.Fatalf("Publish() without a partition count error = %v", err)
+	}
+}
+
+func event(tenantID, key string) bus.Envelope {
+	return bus.Envelope{TenantID: tenantID, TableID: "7", IdempotencyKey: key, Op: "insert", PayloadJSON: []byte(`{"id":1}`)}
+}
+
+func mustPublish(t *testing.T, ingestBus *IngestBus, events ...bus.Envelope) []bus.PublishResult {
+	t.Helper()
+	results, err := ingestBus.Publish(context.Background(), events)
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	return results
+}
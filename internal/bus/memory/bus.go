@@ -0,0 +1,444 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/bus"
+	"github.com/duckmesh/duckmesh/internal/notify"
+)
+
+// IngestBus keeps events, claims and leases in process memory with the same
+// state transitions as the Postgres bus. Events are lost when the process
+// exits, so it is meant for tests and embedded single-process deployments.
+type IngestBus struct {
+	mu          sync.Mutex
+	clock       func() time.Time
+	retry       bus.RetryPolicy
+	claims      bus.ClaimPolicy
+	notifier    notify.Notifier
+	nextEventID int64
+	nextBatchID int64
+	events      []*event
+	// open is the index of the first event that is not committed or failed.
+	open    int
+	keys    map[string]*event
+	batches map[int64]*claimBatch
+}
+
+type event struct {
+	envelope   bus.Envelope
+	id         int64
+	state      bus.EventState
+	batchID    int64
+	leaseUntil time.Time
+	notBefore  time.Time
+	attempts   int
+	lastError  string
+	failedAt   time.Time
+	discarded  bool
+}
+
+type claimBatch struct {
+	leaseUntil time.Time
+	events     []*event
+}
+
+func NewIngestBus() *IngestBus {
+	return &IngestBus{
+		clock:   time.Now,
+		retry:   bus.DefaultRetryPolicy(),
+		keys:    map[string]*event{},
+		batches: map[int64]*claimBatch{},
+	}
+}
+
+// WithRetryPolicy sets how nacked and expired events are redelivered
+// before they are dead-lettered.
+func (b *IngestBus) WithRetryPolicy(policy bus.RetryPolicy) *IngestBus {
+	b.mu.Lock()
+	b.retry = policy
+	b.mu.Unlock()
+	return b
+}
+
+// WithClaimPolicy sets how claims are shared between tenants.
+func (b *IngestBus) WithClaimPolicy(policy bus.ClaimPolicy) *IngestBus {
+	b.mu.Lock()
+	b.claims = policy
+	b.mu.Unlock()
+	return b
+}
+
+// WithNotifier raises notify.ChannelIngest for every tenant that gets new
+// claimable events, so consumers need not poll.
+func (b *IngestBus) WithNotifier(notifier notify.Notifier) *IngestBus {
+	b.mu.Lock()
+	b.notifier = notifier
+	b.mu.Unlock()
+	return b
+}
+
+func (b *IngestBus) Publish(_ context.Context, events []bus.Envelope) ([]bus.PublishResult, error) {
+	if len(events) == 0 {
+		return []bus.PublishResult{}, nil
+	}
+	for _, envelope := range events {
+		if _, err := strconv.ParseInt(envelope.TableID, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid table id %q: %w", envelope.TableID, err)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	results := make([]bus.PublishResult, 0, len(events))
+	for _, envelope := range events {
+		key := envelope.TenantID + "\x1f" + envelope.TableID + "\x1f" + envelope.IdempotencyKey
+		if existing, ok := b.keys[key]; ok {
+			results = append(results, bus.PublishResult{EventID: existing.envelope.EventID, VisibilityToken: existing.id})
+			continue
+		}
+
+		b.nextEventID++
+		envelope.EventID = strconv.FormatInt(b.nextEventID, 10)
+		envelope.PayloadJSON = append([]byte(nil), envelope.PayloadJSON...)
+		if len(envelope.PayloadJSON) == 0 {
+			envelope.PayloadJSON = []byte("{}")
+		}
+		stored := &event{envelope: envelope, id: b.nextEventID, state: bus.StateAccepted}
+		b.events = append(b.events, stored)
+		b.keys[key] = stored
+		results = append(results, bus.PublishResult{EventID: envelope.EventID, VisibilityToken: stored.id, Inserted: true})
+		b.notify(envelope.TenantID)
+	}
+	return results, nil
+}
+
+func (b *IngestBus) ClaimBatch(_ context.Context, consumerID string, limit int, leaseSeconds int) (bus.Batch, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if leaseSeconds <= 0 {
+		leaseSeconds = 30
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock().UTC()
+	selected := make([]*event, 0, limit)
+	perTenant := map[string]int{}
+	for _, candidate := range b.events[b.open:] {
+		if len(selected) == limit && !b.claims.Fair {
+			break
+		}
+		if candidate.state != bus.StateAccepted || candidate.notBefore.After(now) {
+			continue
+		}
+		// A fair claim never takes more than limit events of one tenant, so
+		// only that many of each are candidates.
+		if b.claims.Fair && perTenant[candidate.envelope.TenantID] == limit {
+			continue
+		}
+		perTenant[candidate.envelope.TenantID]++
+		selected = append(selected, candidate)
+	}
+	if b.claims.Fair {
+		tenantIDs := make([]string, len(selected))
+		for i, candidate := range selected {
+			tenantIDs[i] = candidate.envelope.TenantID
+		}
+		picked := make([]*event, 0, limit)
+		for _, i := range b.claims.Pick(tenantIDs, limit) {
+			picked = append(picked, selected[i])
+		}
+		selected = picked
+	}
+	if len(selected) == 0 {
+		return bus.Batch{}, nil
+	}
+
+	leaseUntil := now.Add(time.Duration(leaseSeconds) * time.Second)
+	b.nextBatchID++
+	b.batches[b.nextBatchID] = &claimBatch{leaseUntil: leaseUntil, events: selected}
+
+	batch := bus.Batch{
+		BatchID:     strconv.FormatInt(b.nextBatchID, 10),
+		ConsumerID:  consumerID,
+		LeaseUntil:  leaseUntil.UnixMilli(),
+		EventIDs:    make([]string, 0, len(selected)),
+		Envelopes:   make([]bus.Envelope, 0, len(selected)),
+		ClaimedUnix: now.UnixMilli(),
+	}
+	for _, claimed := range selected {
+		claimed.state = bus.StateClaimed
+		claimed.batchID = b.nextBatchID
+		claimed.leaseUntil = leaseUntil
+		claimed.notBefore = time.Time{}
+		claimed.attempts++
+		if claimed.attempts > batch.Attempt {
+			batch.Attempt = claimed.attempts
+		}
+		batch.EventIDs = append(batch.EventIDs, claimed.envelope.EventID)
+		batch.Envelopes = append(batch.Envelopes, claimed.envelope)
+		if claimed.id > batch.Visibility {
+			batch.Visibility = claimed.id
+		}
+	}
+	return batch, nil
+}
+
+func (b *IngestBus) Ack(_ context.Context, batchID string, eventIDs []string) error {
+	return b.finish(batchID, eventIDs, func(claimed *event, _ time.Time) {
+		claimed.state = bus.StateCommitted
+		claimed.envelope.PayloadJSON = nil
+	})
+}
+
+// Nack records reason on the events and returns them to the accepted state
+// after a backoff, or marks them failed once the retry budget is spent.
+func (b *IngestBus) Nack(_ context.Context, batchID string, eventIDs []string, reason string) error {
+	return b.finish(batchID, eventIDs, func(claimed *event, now time.Time) {
+		b.release(claimed, now, reason)
+	})
+}
+
+func (b *IngestBus) ExtendLease(_ context.Context, batchID string, leaseSeconds int) error {
+	batchIDInt, err := parseInt64(batchID, "batch id")
+	if err != nil {
+		return err
+	}
+	if leaseSeconds <= 0 {
+		leaseSeconds = 30
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	batch, ok := b.batches[batchIDInt]
+	if !ok {
+		return fmt.Errorf("claim batch %d not found or not claimable", batchIDInt)
+	}
+	batch.leaseUntil = b.clock().UTC().Add(time.Duration(leaseSeconds) * time.Second)
+	for _, claimed := range batch.events {
+		if claimed.state == bus.StateClaimed && claimed.batchID == batchIDInt {
+			claimed.leaseUntil = batch.leaseUntil
+		}
+	}
+	return nil
+}
+
+func (b *IngestBus) RequeueExpired(_ context.Context) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock().UTC()
+	count := 0
+	for _, candidate := range b.events[b.open:] {
+		if candidate.state == bus.StateClaimed && candidate.leaseUntil.Before(now) {
+			candidate.batchID = 0
+			candidate.leaseUntil = time.Time{}
+			if b.retry.Exhausted(candidate.attempts) {
+				candidate.state = bus.StateFailed
+				candidate.lastError = "lease expired"
+				candidate.failedAt = now
+			} else {
+				candidate.state = bus.StateAccepted
+			}
+			count++
+		}
+	}
+	for batchID, batch := range b.batches {
+		if batch.leaseUntil.Before(now) {
+			delete(b.batches, batchID)
+		}
+	}
+	b.advanceOpen()
+	return count, nil
+}
+
+func (b *IngestBus) ListFailed(_ context.Context, filter bus.FailedEventFilter) ([]bus.FailedEvent, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	failed := make([]bus.FailedEvent, 0)
+	for _, candidate := range b.events {
+		if len(failed) == limit {
+			break
+		}
+		if candidate.state != bus.StateFailed || candidate.discarded || candidate.envelope.TenantID != filter.TenantID {
+			continue
+		}
+		if filter.TableID != "" && candidate.envelope.TableID != filter.TableID {
+			continue
+		}
+		envelope := candidate.envelope
+		envelope.PayloadJSON = slices.Clone(envelope.PayloadJSON)
+		failed = append(failed, bus.FailedEvent{
+			Envelope:  envelope,
+			Attempts:  candidate.attempts,
+			LastError: candidate.lastError,
+			FailedAt:  candidate.failedAt,
+		})
+	}
+	return failed, nil
+}
+
+// ReplayFailed returns failed events to the accepted state with a fresh
+// retry budget.
+func (b *IngestBus) ReplayFailed(_ context.Context, tenantID string, eventIDs []string) (int, error) {
+	return b.updateFailed(tenantID, eventIDs, func(candidate *event) {
+		candidate.state = bus.StateAccepted
+		candidate.attempts = 0
+		candidate.failedAt = time.Time{}
+		candidate.notBefore = time.Time{}
+		b.open = min(b.open, int(candidate.id-1))
+		b.notify(tenantID)
+	})
+}
+
+// DiscardFailed hides failed events from the dead-letter view.
+func (b *IngestBus) DiscardFailed(_ context.Context, tenantID string, eventIDs []string) (int, error) {
+	return b.updateFailed(tenantID, eventIDs, func(candidate *event) {
+		candidate.discarded = true
+	})
+}
+
+func (b *IngestBus) notify(tenantID string) {
+	if b.notifier != nil {
+		b.notifier.Notify(notify.ChannelIngest, tenantID)
+	}
+}
+
+func (b *IngestBus) updateFailed(tenantID string, eventIDs []string, apply func(*event)) (int, error) {
+	ids := make([]int64, 0, len(eventIDs))
+	for _, eventID := range eventIDs {
+		id, err := parseInt64(eventID, "event id")
+		if err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	count := 0
+	for _, id := range ids {
+		if id <= 0 || id > int64(len(b.events)) {
+			continue
+		}
+		candidate := b.events[id-1]
+		if candidate.state != bus.StateFailed || candidate.discarded || candidate.envelope.TenantID != tenantID {
+			continue
+		}
+		apply(candidate)
+		count++
+	}
+	return count, nil
+}
+
+// release records a failed delivery and either schedules a retry or
+// dead-letters the event. Callers hold b.mu.
+func (b *IngestBus) release(claimed *event, now time.Time, reason string) {
+	claimed.lastError = reason
+	if b.retry.Exhausted(claimed.attempts) {
+		claimed.state = bus.StateFailed
+		claimed.failedAt = now
+		return
+	}
+	claimed.state = bus.StateAccepted
+	claimed.notBefore = now.Add(b.retry.Backoff(claimed.attempts))
+}
+
+// State reports the current state of a published event, for tests that
+// assert on delivery progress.
+func (b *IngestBus) State(eventID string) (bus.EventState, bool) {
+	id, err := strconv.ParseInt(eventID, 10, 64)
+	if err != nil {
+		return "", false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if id <= 0 || id > int64(len(b.events)) {
+		return "", false
+	}
+	return b.events[id-1].state, true
+}
+
+func (b *IngestBus) finish(batchID string, eventIDs []string, apply func(*event, time.Time)) error {
+	batchIDInt, err := parseInt64(batchID, "batch id")
+	if err != nil {
+		return err
+	}
+	ids := make(map[string]struct{}, len(eventIDs))
+	for _, eventID := range eventIDs {
+		if _, err := parseInt64(eventID, "event id"); err != nil {
+			return err
+		}
+		ids[eventID] = struct{}{}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	batch, ok := b.batches[batchIDInt]
+	if !ok {
+		return nil
+	}
+	now := b.clock().UTC()
+	pending := false
+	for _, claimed := range batch.events {
+		if claimed.batchID != batchIDInt || claimed.state != bus.StateClaimed {
+			continue
+		}
+		if _, ok := ids[claimed.envelope.EventID]; ok {
+			claimed.batchID = 0
+			claimed.leaseUntil = time.Time{}
+			apply(claimed, now)
+			continue
+		}
+		pending = true
+	}
+	if !pending {
+		delete(b.batches, batchIDInt)
+	}
+	b.advanceOpen()
+	return nil
+}
+
+func (b *IngestBus) advanceOpen() {
+	for b.open < len(b.events) {
+		if current := b.events[b.open].state; current != bus.StateCommitted && current != bus.StateFailed {
+			break
+		}
+		b.open++
+	}
+}
+
+func parseInt64(value string, field string) (int64, error) {
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", field, value, err)
+	}
+	return parsed, nil
+}
+
+var (
+	_ bus.IngestBus       = (*IngestBus)(nil)
+	_ bus.DeadLetterQueue = (*IngestBus)(nil)
+)
@@ -0,0 +1,215 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/bus"
+	"github.com/duckmesh/duckmesh/internal/bus/bustest"
+	"github.com/duckmesh/duckmesh/internal/notify"
+)
+
+func TestConformance(t *testing.T) {
+	bustest.Run(t, bustest.Suite{
+		MaxAttempts: 3,
+		New: func(*testing.T) bus.IngestBus {
+			return NewIngestBus().WithRetryPolicy(bus.RetryPolicy{MaxAttempts: 3})
+		},
+	})
+}
+
+func TestPublishClaimAckAndIdempotency(t *testing.T) {
+	ctx := context.Background()
+	ingestBus := NewIngestBus()
+
+	results, err := ingestBus.Publish(ctx, []bus.Envelope{
+		{TenantID: "tenant-1", TableID: "7", IdempotencyKey: "k1", Op: "insert", PayloadJSON: []byte(`{"id":1}`)},
+		{TenantID: "tenant-1", TableID: "7", IdempotencyKey: "k2", Op: "insert"},
+		{TenantID: "tenant-1", TableID: "7", IdempotencyKey: "k1", Op: "insert"},
+	})
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if !results[0].Inserted || !results[1].Inserted || results[2].Inserted || results[2].VisibilityToken != results[0].VisibilityToken {
+		t.Fatalf("results = %+v", results)
+	}
+	if results[1].VisibilityToken <= results[0].VisibilityToken {
+		t.Fatalf("tokens not monotonic: %+v", results)
+	}
+	if _, err := ingestBus.Publish(ctx, []bus.Envelope{{TenantID: "tenant-1", TableID: "events"}}); err == nil {
+		t.Fatal("Publish() with non-numeric table id error = nil")
+	}
+
+	batch, err := ingestBus.ClaimBatch(ctx, "worker-1", 10, 30)
+	if err != nil {
+		t.Fatalf("ClaimBatch() error = %v", err)
+	}
+	if len(batch.EventIDs) != 2 || batch.Visibility != results[1].VisibilityToken || string(batch.Envelopes[1].PayloadJSON) != "{}" {
+		t.Fatalf("batch = %+v", batch)
+	}
+	if empty, err := ingestBus.ClaimBatch(ctx, "worker-2", 10, 30); err != nil || len(empty.EventIDs) != 0 {
+		t.Fatalf("second ClaimBatch() = %+v, %v", empty, err)
+	}
+
+	if err := ingestBus.Ack(ctx, batch.BatchID, batch.EventIDs[:1]); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if state, _ := ingestBus.State(batch.EventIDs[0]); state != bus.StateCommitted {
+		t.Fatalf("state = %q, want committed", state)
+	}
+	if err := ingestBus.Nack(ctx, batch.BatchID, batch.EventIDs[1:], "bad payload"); err != nil {
+		t.Fatalf("Nack() error = %v", err)
+	}
+	if state, _ := ingestBus.State(batch.EventIDs[1]); state != bus.StateAccepted {
+		t.Fatalf("state = %q, want accepted", state)
+	}
+	if err := ingestBus.ExtendLease(ctx, batch.BatchID, 30); err == nil {
+		t.Fatal("ExtendLease() on finished batch error = nil")
+	}
+}
+
+func TestLeaseExpiryRequeuesEvents(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0).UTC()
+	ingestBus := NewIngestBus()
+	ingestBus.clock = func() time.Time { return now }
+
+	if _, err := ingestBus.Publish(ctx, []bus.Envelope{{TenantID: "tenant-1", TableID: "7", IdempotencyKey: "k1", Op: "insert"}}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	batch, err := ingestBus.ClaimBatch(ctx, "worker-1", 10, 30)
+	if err != nil {
+		t.Fatalf("ClaimBatch() error = %v", err)
+	}
+
+	now = now.Add(20 * time.Second)
+	if err := ingestBus.ExtendLease(ctx, batch.BatchID, 30); err != nil {
+		t.Fatalf("ExtendLease() error = %v", err)
+	}
+	now = now.Add(20 * time.Second)
+	if count, err := ingestBus.RequeueExpired(ctx); err != nil || count != 0 {
+		t.Fatalf("RequeueExpired() = %d, %v; want 0", count, err)
+	}
+
+	now = now.Add(20 * time.Second)
+	if count, err := ingestBus.RequeueExpired(ctx); err != nil || count != 1 {
+		t.Fatalf("RequeueExpired() = %d, %v; want 1", count, err)
+	}
+	if err := ingestBus.ExtendLease(ctx, batch.BatchID, 30); err == nil {
+		t.Fatal("ExtendLease() on expired batch error = nil")
+	}
+
+	redelivered, err := ingestBus.ClaimBatch(ctx, "worker-2", 10, 30)
+	if err != nil {
+		t.Fatalf("ClaimBatch() error = %v", err)
+	}
+	if len(redelivered.EventIDs) != 1 || redelivered.EventIDs[0] != batch.EventIDs[0] {
+		t.Fatalf("redelivered = %+v", redelivered)
+	}
+
+	// A stale ack from the expired claim must not commit the redelivered event.
+	if err := ingestBus.Ack(ctx, batch.BatchID, batch.EventIDs); err != nil {
+		t.Fatalf("stale Ack() error = %v", err)
+	}
+	if state, _ := ingestBus.State(batch.EventIDs[0]); state != bus.StateClaimed {
+		t.Fatalf("state = %q, want claimed", state)
+	}
+}
+
+func TestNackBacksOffAndExpiryDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0).UTC()
+	ingestBus := NewIngestBus().WithRetryPolicy(bus.RetryPolicy{MaxAttempts: 2, BaseBackoff: 10 * time.Second, MaxBackoff: time.Minute})
+	ingestBus.clock = func() time.Time { return now }
+
+	if _, err := ingestBus.Publish(ctx, []bus.Envelope{{TenantID: "tenant-1", TableID: "7", IdempotencyKey: "k1", Op: "insert"}}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	batch, err := ingestBus.ClaimBatch(ctx, "worker-1", 10, 30)
+	if err != nil {
+		t.Fatalf("ClaimBatch() error = %v", err)
+	}
+	if err := ingestBus.Nack(ctx, batch.BatchID, batch.EventIDs, "bad payload"); err != nil {
+		t.Fatalf("Nack() error = %v", err)
+	}
+
+	now = now.Add(5 * time.Second)
+	if early, err := ingestBus.ClaimBatch(ctx, "worker-1", 10, 30); err != nil || len(early.EventIDs) != 0 {
+		t.Fatalf("ClaimBatch() during backoff = %+v, %v", early, err)
+	}
+	now = now.Add(5 * time.Second)
+	retried, err := ingestBus.ClaimBatch(ctx, "worker-1", 10, 30)
+	if err != nil || len(retried.EventIDs) != 1 || retried.Attempt != 2 {
+		t.Fatalf("ClaimBatch() after backoff = %+v, %v", retried, err)
+	}
+
+	now = now.Add(time.Minute)
+	if count, err := ingestBus.RequeueExpired(ctx); err != nil || count != 1 {
+		t.Fatalf("RequeueExpired() = %d, %v; want 1", count, err)
+	}
+	if state, _ := ingestBus.State(batch.EventIDs[0]); state != bus.StateFailed {
+		t.Fatalf("state = %q, want failed", state)
+	}
+	failed, err := ingestBus.ListFailed(ctx, bus.FailedEventFilter{TenantID: "tenant-1", TableID: "7"})
+	if err != nil || len(failed) != 1 || failed[0].LastError != "lease expired" || failed[0].Attempts != 2 || !failed[0].FailedAt.Equal(now) {
+		t.Fatalf("ListFailed() = %+v, %v", failed, err)
+	}
+}
+
+func TestPublishNotifiesIngestChannelForNewEventsOnly(t *testing.T) {
+	ctx := context.Background()
+	hub := notify.NewHub()
+	wakeups, stop := hub.Subscribe(notify.ChannelIngest, "tenant-1")
+	defer stop()
+	ingestBus := NewIngestBus().WithNotifier(hub)
+
+	envelope := bus.Envelope{TenantID: "tenant-1", TableID: "7", IdempotencyKey: "k1", Op: "insert"}
+	if _, err := ingestBus.Publish(ctx, []bus.Envelope{envelope}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	select {
+	case <-wakeups:
+	default:
+		t.Fatal("Publish() did not notify the ingest channel")
+	}
+
+	if _, err := ingestBus.Publish(ctx, []bus.Envelope{envelope}); err != nil {
+		t.Fatalf("duplicate Publish() error = %v", err)
+	}
+	select {
+	case <-wakeups:
+		t.Fatal("duplicate Publish() notified the ingest channel")
+	default:
+	}
+}
+
+func TestFairClaimPolicyInterleavesTenants(t *testing.T) {
+	ctx := context.Background()
+	ingestBus := NewIngestBus().WithClaimPolicy(bus.ClaimPolicy{Fair: true, TenantWeights: map[string]int{"quiet": 2}})
+
+	events := make([]bus.Envelope, 0, 12)
+	for i := range 10 {
+		events = append(events, bus.Envelope{TenantID: "noisy", TableID: "1", IdempotencyKey: fmt.Sprintf("n%d", i), Op: "insert"})
+	}
+	events = append(events,
+		bus.Envelope{TenantID: "quiet", TableID: "1", IdempotencyKey: "q1", Op: "insert"},
+		bus.Envelope{TenantID: "quiet", TableID: "1", IdempotencyKey: "q2", Op: "insert"},
+	)
+	if _, err := ingestBus.Publish(ctx, events); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	batch, err := ingestBus.ClaimBatch(ctx, "worker-1", 4, 30)
+	if err != nil {
+		t.Fatalf("ClaimBatch() error = %v", err)
+	}
+	tenants := make([]string, 0, len(batch.Envelopes))
+	for _, envelope := range batch.Envelopes {
+		tenants = append(tenants, envelope.TenantID)
+	}
+	if fmt.Sprint(tenants) != "[noisy noisy quiet quiet]" || fmt.Sprint(batch.EventIDs) != "[1 2 11 12]" {
+		t.Fatalf("claimed %v as %v", batch.EventIDs, tenants)
+	}
+}
@@ -11,12 +11,27 @@ import (
 )
 
 type IngestBus struct {
-	db    *sql.DB
-	clock func() time.Time
+	db     *sql.DB
+	clock  func() time.Time
+	retry  bus.RetryPolicy
+	claims bus.ClaimPolicy
 }
 
 func NewIngestBus(db *sql.DB) *IngestBus {
-	return &IngestBus{db: db, clock: time.Now}
+	return &IngestBus{db: db, clock: time.Now, retry: bus.DefaultRetryPolicy()}
+}
+
+// WithRetryPolicy sets how nacked and expired events are redelivered
+// before they are dead-lettered.
+func (b *IngestBus) WithRetryPolicy(policy bus.RetryPolicy) *IngestBus {
+	b.retry = policy
+	return b
+}
+
+// WithClaimPolicy sets how claims are shared between tenants.
+func (b *IngestBus) WithClaimPolicy(policy bus.ClaimPolicy) *IngestBus {
+	b.claims = policy
+	return b
 }
 
 func (b *IngestBus) Publish(ctx context.Context, events []bus.Envelope) ([]bus.PublishResult, error) {
@@ -103,7 +118,19 @@ ORDER BY event_id ASC
 FOR UPDATE SKIP LOCKED
 LIMIT $1`
 
-	rows, err := tx.QueryContext(ctx, selectionQuery, limit)
+	args := []any{limit}
+	if b.claims.Fair {
+		selectionQuery = fairSelectionQuery
+		tenantIDs := make([]string, 0, len(b.claims.TenantWeights))
+		weights := make([]int64, 0, len(b.claims.TenantWeights))
+		for tenantID := range b.claims.TenantWeights {
+			tenantIDs = append(tenantIDs, tenantID)
+			weights = append(weights, int64(b.claims.Weight(tenantID)))
+		}
+		args = append(args, tenantIDs, weights)
+	}
+
+	rows, err := tx.QueryContext(ctx, selectionQuery, args...)
 	if err != nil {
 		return bus.Batch{}, fmt.Errorf("select claim candidates: %w", err)
 	}
@@ -160,8 +187,9 @@ RETURNING batch_id, claimed_at`
 
 	updateEventQuery := `
 UPDATE ingest_event
-SET state = 'claimed', lease_owner = $1, lease_until = $2
-WHERE event_id = $3`
+SET state = 'claimed', lease_owner = $1, lease_until = $2, attempts = attempts + 1
+WHERE event_id = $3
+RETURNING attempts`
 	claimItemQuery := `
 INSERT INTO ingest_claim_item (batch_id, event_id)
 VALUES ($1, $2)`
@@ -176,9 +204,13 @@ VALUES ($1, $2)`
 	}
 
 	for _, event := range selected {
-		if _, err := tx.ExecContext(ctx, updateEventQuery, consumerID, leaseUntil, event.eventID); err != nil {
+		var attempts int
+		if err := tx.QueryRowContext(ctx, updateEventQuery, consumerID, leaseUntil, event.eventID).Scan(&attempts); err != nil {
 			return bus.Batch{}, fmt.Errorf("update claimed event %d: %w", event.eventID, err)
 		}
+		if attempts > batch.Attempt {
+			batch.Attempt = attempts
+		}
 		if _, err := tx.ExecContext(ctx, claimItemQuery, batchID, event.eventID); err != nil {
 			return bus.Batch{}, fmt.Errorf("insert claim item %d: %w", event.eventID, err)
 		}
@@ -233,7 +265,7 @@ func (b *IngestBus) Ack(ctx context.Context, batchID string, eventIDs []string)
 UPDATE ingest_event AS e
 SET state = 'committed', lease_owner = NULL, lease_until = NULL
 FROM ingest_claim_item AS i
-WHERE i.batch_id = $1 AND i.event_id = $2 AND e.event_id = i.event_id`
+WHERE i.batch_id = $1 AND i.event_id = $2 AND e.event_id = i.event_id` + currentClaimCondition
 
 	for _, eventID := range parsedEventIDs {
 		if _, err := tx.ExecContext(ctx, ackQuery, batchIDInt, eventID); err != nil {
@@ -241,18 +273,7 @@ WHERE i.batch_id = $1 AND i.event_id = $2 AND e.event_id = i.event_id`
 		}
 	}
 
-	if _, err := tx.ExecContext(ctx, `
-UPDATE ingest_claim_batch
-SET state = CASE
-    WHEN EXISTS (
-        SELECT 1
-        FROM ingest_claim_item AS i
-        JOIN ingest_event AS e ON e.event_id = i.event_id
-        WHERE i.batch_id = $1 AND e.state = 'claimed'
-    ) THEN 'claimed'::duckmesh_ingest_state
-    ELSE 'committed'::duckmesh_ingest_state
-END
-WHERE batch_id = $1`, batchIDInt); err != nil {
+	if _, err := tx.ExecContext(ctx, settleClaimBatchQuery, batchIDInt, "committed"); err != nil {
 		return fmt.Errorf("update claim batch state: %w", err)
 	}
 
@@ -262,7 +283,9 @@ WHERE batch_id = $1`, batchIDInt); err != nil {
 	return nil
 }
 
-func (b *IngestBus) Nack(ctx context.Context, batchID string, eventIDs []string, _ string) error {
+// Nack records reason on the events and releases them back to the accepted
+// state after a backoff, or marks them failed once the retry budget is spent.
+func (b *IngestBus) Nack(ctx context.Context, batchID string, eventIDs []string, reason string) error {
 	batchIDInt, err := parseInt64(batchID, "batch id")
 	if err != nil {
 		return err
@@ -283,18 +306,25 @@ func (b *IngestBus) Nack(ctx context.Context, batchID string, eventIDs []string,
 
 	nackQuery := `
 UPDATE ingest_event AS e
-SET state = 'failed', lease_owner = NULL, lease_until = NULL
+SET state = CASE WHEN $4::int > 0 AND e.attempts >= $4::int THEN 'failed'::duckmesh_ingest_state ELSE 'accepted'::duckmesh_ingest_state END,
+    lease_owner = NULL,
+    lease_until = CASE WHEN $4::int > 0 AND e.attempts >= $4::int THEN NULL ELSE NOW() + ` + backoffExpression + ` END,
+    last_error = $3,
+    failed_at = CASE WHEN $4::int > 0 AND e.attempts >= $4::int THEN NOW() ELSE NULL END
 FROM ingest_claim_item AS i
-WHERE i.batch_id = $1 AND i.event_id = $2 AND e.event_id = i.event_id`
+WHERE i.batch_id = $1 AND i.event_id = $2 AND e.event_id = i.event_id` + currentClaimCondition
 
+	baseMillis, maxMillis := b.backoffMillis()
 	for _, eventID := range parsedEventIDs {
-		if _, err := tx.ExecContext(ctx, nackQuery, batchIDInt, eventID); err != nil {
+		if _, err := tx.ExecContext(ctx, nackQuery, batchIDInt, eventID, reason, b.retry.MaxAttempts, baseMillis, maxMillis); err != nil {
 			return fmt.Errorf("nack event %d: %w", eventID, err)
 		}
 	}
 
-	if _, err := tx.ExecContext(ctx, `UPDATE ingest_claim_batch SET state = 'failed' WHERE batch_id = $1`, batchIDInt); err != nil {
-		return fmt.Errorf("update claim batch state to failed: %w", err)
+	// The rest of the batch may still be in flight; it stays claimed so its
+	// lease can be extended until the last event is settled.
+	if _, err := tx.ExecContext(ctx, settleClaimBatchQuery, batchIDInt, "failed"); err != nil {
+		return fmt.Errorf("update claim batch state: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -352,14 +382,18 @@ func (b *IngestBus) RequeueExpired(ctx context.Context) (int, error) {
 	requeueQuery := `
 WITH moved AS (
     UPDATE ingest_event
-    SET state = 'accepted', lease_owner = NULL, lease_until = NULL
+    SET state = CASE WHEN $1::int > 0 AND attempts >= $1::int THEN 'failed'::duckmesh_ingest_state ELSE 'accepted'::duckmesh_ingest_state END,
+        lease_owner = NULL,
+        lease_until = NULL,
+        last_error = CASE WHEN $1::int > 0 AND attempts >= $1::int THEN 'lease expired' ELSE last_error END,
+        failed_at = CASE WHEN $1::int > 0 AND attempts >= $1::int THEN NOW() ELSE NULL END
     WHERE state = 'claimed' AND lease_until IS NOT NULL AND lease_until < NOW()
     RETURNING event_id
 )
 SELECT COUNT(*) FROM moved`
 
 	var count int
-	if err := b.db.QueryRowContext(ctx, requeueQuery).Scan(&count); err != nil {
+	if err := b.db.QueryRowContext(ctx, requeueQuery, b.retry.MaxAttempts).Scan(&count); err != nil {
 		return 0, fmt.Errorf("requeue expired events: %w", err)
 	}
 
@@ -371,6 +405,144 @@ WHERE state = 'claimed' AND lease_until < NOW()`)
 	return count, nil
 }
 
+func (b *IngestBus) ListFailed(ctx context.Context, filter bus.FailedEventFilter) ([]bus.FailedEvent, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	query := `
+SELECT event_id, tenant_id, table_id, idempotency_key, op::text, payload_json, event_time, attempts, COALESCE(last_error, ''), failed_at
+FROM ingest_event
+WHERE tenant_id = $1 AND state = 'failed' AND discarded_at IS NULL`
+	args := []any{filter.TenantID, limit}
+	if filter.TableID != "" {
+		tableID, err := parseInt64(filter.TableID, "table id")
+		if err != nil {
+			return nil, err
+		}
+		query += ` AND table_id = $3`
+		args = append(args, tableID)
+	}
+	query += `
+ORDER BY event_id ASC
+LIMIT $2`
+
+	rows, err := b.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list failed events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	failed := make([]bus.FailedEvent, 0)
+	for rows.Next() {
+		var (
+			eventID   int64
+			tableID   int64
+			eventTime *time.Time
+			failedAt  *time.Time
+			item      bus.FailedEvent
+		)
+		if err := rows.Scan(
+			&eventID,
+			&item.TenantID,
+			&tableID,
+			&item.IdempotencyKey,
+			&item.Op,
+			&item.PayloadJSON,
+			&eventTime,
+			&item.Attempts,
+			&item.LastError,
+			&failedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan failed event: %w", err)
+		}
+		item.EventID = strconv.FormatInt(eventID, 10)
+		item.TableID = strconv.FormatInt(tableID, 10)
+		if eventTime != nil {
+			item.EventTimeUnixMs = eventTime.UTC().UnixMilli()
+		}
+		if failedAt != nil {
+			item.FailedAt = failedAt.UTC()
+		}
+		failed = append(failed, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate failed events: %w", err)
+	}
+	return failed, nil
+}
+
+// ReplayFailed returns failed events to the accepted state with a fresh
+// retry budget. The last error is kept until the next failure.
+func (b *IngestBus) ReplayFailed(ctx context.Context, tenantID string, eventIDs []string) (int, error) {
+	return b.updateFailed(ctx, "replay", `
+UPDATE ingest_event
+SET state = 'accepted', attempts = 0, lease_owner = NULL, lease_until = NULL, failed_at = NULL
+WHERE tenant_id = $1 AND event_id = ANY($2::bigint[]) AND state = 'failed' AND discarded_at IS NULL`, tenantID, eventIDs)
+}
+
+// DiscardFailed hides failed events from the dead-letter view. The rows are
+// kept for audit.
+func (b *IngestBus) DiscardFailed(ctx context.Context, tenantID string, eventIDs []string) (int, error) {
+	return b.updateFailed(ctx, "discard", `
+UPDATE ingest_event
+SET discarded_at = NOW()
+WHERE tenant_id = $1 AND event_id = ANY($2::bigint[]) AND state = 'failed' AND discarded_at IS NULL`, tenantID, eventIDs)
+}
+
+func (b *IngestBus) updateFailed(ctx context.Context, action string, query string, tenantID string, eventIDs []string) (int, error) {
+	parsedEventIDs, err := parseEventIDs(eventIDs)
+	if err != nil {
+		return 0, err
+	}
+	if len(parsedEventIDs) == 0 {
+		return 0, nil
+	}
+	result, err := b.db.ExecContext(ctx, query, tenantID, parsedEventIDs)
+	if err != nil {
+		return 0, fmt.Errorf("%s failed events: %w", action, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read %s rows affected: %w", action, err)
+	}
+	return int(rowsAffected), nil
+}
+
+func (b *IngestBus) backoffMillis() (int64, int64) {
+	return b.retry.BaseBackoff.Milliseconds(), b.retry.MaxBackoff.Milliseconds()
+}
+
+// backoffExpression doubles $5 milliseconds per prior attempt, capped at $6
+// when that is positive. The exponent stops at 30, as in bus.RetryPolicy, so
+// events retried forever do not overflow.
+const backoffExpression = `(CASE
+        WHEN $6::bigint > 0 THEN LEAST($5::bigint * power(2, LEAST(GREATEST(e.attempts - 1, 0), 30)), $6::bigint)
+        ELSE $5::bigint * power(2, LEAST(GREATEST(e.attempts - 1, 0), 30))
+    END) * INTERVAL '1 millisecond'`
+
+// currentClaimCondition limits ack and nack to events still held by the
+// batch, so a claim whose lease expired cannot finish a redelivered event.
+const currentClaimCondition = `
+  AND e.state = 'claimed'
+  AND i.batch_id = (SELECT MAX(latest.batch_id) FROM ingest_claim_item AS latest WHERE latest.event_id = e.event_id)`
+
+// settleClaimBatchQuery moves a claim batch to the state in $2 once none of
+// its events is still claimed by it. Events redelivered to a later claim do
+// not keep it open.
+const settleClaimBatchQuery = `
+UPDATE ingest_claim_batch
+SET state = CASE
+    WHEN EXISTS (
+        SELECT 1
+        FROM ingest_claim_item AS i
+        JOIN ingest_event AS e ON e.event_id = i.event_id
+        WHERE i.batch_id = $1` + currentClaimCondition + `
+    ) THEN 'claimed'::duckmesh_ingest_state
+    ELSE $2::duckmesh_ingest_state
+END
+WHERE batch_id = $1 AND state = 'claimed'`
+
 func parseEventIDs(eventIDs []string) ([]int64, error) {
 	parsed := make([]int64, 0, len(eventIDs))
 	for _, eventID := range eventIDs {
@@ -391,4 +563,34 @@ func parseInt64(value string, field string) (int64, error) {
 	return parsed, nil
 }
 
-var _ bus.IngestBus = (*IngestBus)(nil)
+var (
+	_ bus.IngestBus       = (*IngestBus)(nil)
+	_ bus.DeadLetterQueue = (*IngestBus)(nil)
+)
+
+// fairSelectionQuery locks up to $1 claimable events of every tenant and
+// keeps $1 of them round-robin, a tenant taking its weight from $2/$3 (one
+// by default) per round. Locks on the events left out end with the claim
+// transaction.
+const fairSelectionQuery = `
+SELECT event_id, tenant_id, table_id, idempotency_key, op, payload_json, event_time
+FROM (
+	SELECT candidate.*,
+		(ROW_NUMBER() OVER (PARTITION BY candidate.tenant_id ORDER BY candidate.event_id) - 1) / COALESCE(weight.weight, 1) AS round
+	FROM (
+		SELECT DISTINCT tenant_id
+		FROM ingest_event
+		WHERE state = 'accepted' AND (lease_until IS NULL OR lease_until <= NOW())
+	) tenant
+	CROSS JOIN LATERAL (
+		SELECT event_id, tenant_id, table_id, idempotency_key, op::text AS op, payload_json, event_time
+		FROM ingest_event
+		WHERE tenant_id = tenant.tenant_id AND state = 'accepted' AND (lease_until IS NULL OR lease_until <= NOW())
+		ORDER BY event_id ASC
+		FOR UPDATE SKIP LOCKED
+		LIMIT $1
+	) candidate
+	LEFT JOIN unnest($2::text[], $3::bigint[]) AS weight(tenant_id, weight) ON weight.tenant_id = candidate.tenant_id
+) ranked
+ORDER BY round ASC, event_id ASC
+LIMIT $1`
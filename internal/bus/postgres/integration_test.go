@@ -15,6 +15,7 @@ import (
 	_ "github.com/jackc/pgx/v5/stdlib"
 
 	"github.com/duckmesh/duckmesh/internal/bus"
+	"github.com/duckmesh/duckmesh/internal/bus/bustest"
 	"github.com/duckmesh/duckmesh/internal/migrations"
 )
 
@@ -135,6 +136,146 @@ func TestIngestBusPublishClaimAckAndRequeue(t *testing.T) {
 	assertEventState(t, db, published2[0].EventID, "accepted")
 }
 
+func TestIngestBusConformance(t *testing.T) {
+	adminDSN := strings.TrimSpace(os.Getenv("DUCKMESH_TEST_CATALOG_DSN"))
+	if adminDSN == "" {
+		t.Skip("DUCKMESH_TEST_CATALOG_DSN is not set")
+	}
+
+	testDSN, cleanup := createTemporaryDatabase(t, adminDSN)
+	defer cleanup()
+
+	db := openDB(t, testDSN)
+	defer func() { _ = db.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	if _, err := migrations.NewRunner().Up(ctx, db, 0); err != nil {
+		t.Fatalf("runner.Up() error = %v", err)
+	}
+
+	seedTenantAndTable(t, db, "tenant-a", "events")
+	seedTenantAndTable(t, db, "tenant-b", "events")
+	tables := []bustest.Table{
+		{TenantID: "tenant-a", TableID: fmt.Sprintf("%d", fetchTableID(t, db, "tenant-a", "events"))},
+		{TenantID: "tenant-b", TableID: fmt.Sprintf("%d", fetchTableID(t, db, "tenant-b", "events"))},
+	}
+
+	bustest.Run(t, bustest.Suite{
+		Tables:      tables,
+		MaxAttempts: 3,
+		New: func(t *testing.T) bus.IngestBus {
+			if _, err := db.Exec(`TRUNCATE ingest_claim_item, ingest_claim_batch, ingest_event CASCADE`); err != nil {
+				t.Fatalf("truncate ingest tables: %v", err)
+			}
+			return NewIngestBus(db).WithRetryPolicy(bus.RetryPolicy{MaxAttempts: 3})
+		},
+	})
+}
+
+func TestIngestBusFairClaimInterleavesTenants(t *testing.T) {
+	adminDSN := strings.TrimSpace(os.Getenv("DUCKMESH_TEST_CATALOG_DSN"))
+	if adminDSN == "" {
+		t.Skip("DUCKMESH_TEST_CATALOG_DSN is not set")
+	}
+
+	testDSN, cleanup := createTemporaryDatabase(t, adminDSN)
+	defer cleanup()
+
+	db := openDB(t, testDSN)
+	defer func() { _ = db.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if _, err := migrations.NewRunner().Up(ctx, db, 0); err != nil {
+		t.Fatalf("runner.Up() error = %v", err)
+	}
+	seedTenantAndTable(t, db, "noisy", "events")
+	seedTenantAndTable(t, db, "quiet", "events")
+	noisyTableID := fetchTableID(t, db, "noisy", "events")
+	quietTableID := fetchTableID(t, db, "quiet", "events")
+
+	ingestBus := NewIngestBus(db).WithClaimPolicy(bus.ClaimPolicy{Fair: true, TenantWeights: map[string]int{"quiet": 2}})
+	events := make([]bus.Envelope, 0, 12)
+	for i := range 10 {
+		events = append(events, bus.Envelope{TenantID: "noisy", TableID: fmt.Sprintf("%d", noisyTableID), IdempotencyKey: fmt.Sprintf("n%d", i), Op: "insert"})
+	}
+	for i := range 2 {
+		events = append(events, bus.Envelope{TenantID: "quiet", TableID: fmt.Sprintf("%d", quietTableID), IdempotencyKey: fmt.Sprintf("q%d", i), Op: "insert"})
+	}
+	if _, err := ingestBus.Publish(ctx, events); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	batch, err := ingestBus.ClaimBatch(ctx, "worker-1", 4, 30)
+	if err != nil {
+		t.Fatalf("ClaimBatch() error = %v", err)
+	}
+	claimed := map[string]int{}
+	for _, envelope := range batch.Envelopes {
+		claimed[envelope.TenantID]++
+	}
+	if claimed["noisy"] != 2 || claimed["quiet"] != 2 {
+		t.Fatalf("claimed per tenant = %v", claimed)
+	}
+
+	// Events left out of the fair claim stay claimable.
+	next, err := ingestBus.ClaimBatch(ctx, "worker-2", 100, 30)
+	if err != nil {
+		t.Fatalf("second ClaimBatch() error = %v", err)
+	}
+	if len(next.Envelopes) != 8 {
+		t.Fatalf("second claim = %d events, want 8", len(next.Envelopes))
+	}
+}
+
+func TestIngestBusNackCapsBackoffForUnlimitedRetries(t *testing.T) {
+	adminDSN := strings.TrimSpace(os.Getenv("DUCKMESH_TEST_CATALOG_DSN"))
+	if adminDSN == "" {
+		t.Skip("DUCKMESH_TEST_CATALOG_DSN is not set")
+	}
+
+	testDSN, cleanup := createTemporaryDatabase(t, adminDSN)
+	defer cleanup()
+
+	db := openDB(t, testDSN)
+	defer func() { _ = db.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if _, err := migrations.NewRunner().Up(ctx, db, 0); err != nil {
+		t.Fatalf("runner.Up() error = %v", err)
+	}
+	seedTenantAndTable(t, db, "tenant-a", "events")
+	tableID := fetchTableID(t, db, "tenant-a", "events")
+
+	ingestBus := NewIngestBus(db).WithRetryPolicy(bus.RetryPolicy{BaseBackoff: time.Millisecond})
+	if _, err := ingestBus.Publish(ctx, []bus.Envelope{{TenantID: "tenant-a", TableID: fmt.Sprintf("%d", tableID), IdempotencyKey: "k1", Op: "insert"}}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	batch, err := ingestBus.ClaimBatch(ctx, "worker-1", 10, 30)
+	if err != nil || len(batch.EventIDs) != 1 {
+		t.Fatalf("ClaimBatch() = %+v, %v", batch, err)
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE ingest_event SET attempts = 5000`); err != nil {
+		t.Fatalf("set attempts: %v", err)
+	}
+	if err := ingestBus.Nack(ctx, batch.BatchID, batch.EventIDs, "boom"); err != nil {
+		t.Fatalf("Nack() error = %v", err)
+	}
+
+	var backoffSeconds float64
+	if err := db.QueryRowContext(ctx, `SELECT EXTRACT(EPOCH FROM lease_until - NOW()) FROM ingest_event`).Scan(&backoffSeconds); err != nil {
+		t.Fatalf("read lease_until: %v", err)
+	}
+	want := (time.Millisecond << 30).Seconds()
+	if backoffSeconds < want-60 || backoffSeconds > want+60 {
+		t.Fatalf("backoff = %.0fs, want about %.0fs", backoffSeconds, want)
+	}
+}
+
 func openDB(t *testing.T, dsn string) *sql.DB {
 	t.Helper()
 	db, err := sql.Open("pgx", dsn)
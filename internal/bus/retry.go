@@ -0,0 +1,70 @@
+package bus
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// RetryPolicy bounds how often a nacked or expired event is redelivered.
+// MaxAttempts of zero retries forever; a zero BaseBackoff redelivers
+// immediately.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// maxBackoffDoublings bounds the exponent so events retried forever without
+// a MaxBackoff do not overflow. The Postgres bus uses the same bound.
+const maxBackoffDoublings = 30
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 5, BaseBackoff: time.Second, MaxBackoff: time.Minute}
+}
+
+// Exhausted reports whether an event delivered attempts times must be
+// dead-lettered instead of redelivered.
+func (p RetryPolicy) Exhausted(attempts int) bool {
+	return p.MaxAttempts > 0 && attempts >= p.MaxAttempts
+}
+
+// Backoff returns the delay before redelivering an event that failed its
+// attempt-th delivery, doubling from BaseBackoff up to MaxBackoff.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if p.BaseBackoff <= 0 || attempt <= 0 {
+		return 0
+	}
+	delay := p.BaseBackoff
+	for i := 1; i < attempt && i <= maxBackoffDoublings; i++ {
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff || delay > math.MaxInt64/2 {
+			break
+		}
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	return delay
+}
+
+type FailedEvent struct {
+	Envelope
+	Attempts  int
+	LastError string
+	FailedAt  time.Time
+}
+
+type FailedEventFilter struct {
+	TenantID string
+	TableID  string
+	Limit    int
+}
+
+// DeadLetterQueue is implemented by buses that keep events whose retry
+// budget ran out so operators can inspect, replay or discard them.
+type DeadLetterQueue interface {
+	ListFailed(ctx context.Context, filter FailedEventFilter) ([]FailedEvent, error)
+	ReplayFailed(ctx context.Context, tenantID string, eventIDs []string) (int, error)
+	DiscardFailed(ctx context.Context, tenantID string, eventIDs []string) (int, error)
+}
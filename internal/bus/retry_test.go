@@ -0,0 +1,43 @@
+package bus
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoffDoublesUpToMax(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	want := []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for attempt, expected := range want {
+		if got := policy.Backoff(attempt); got != expected {
+			t.Fatalf("Backoff(%d) = %s, want %s", attempt, got, expected)
+		}
+	}
+	if (RetryPolicy{}).Backoff(3) != 0 {
+		t.Fatal("zero policy should not back off")
+	}
+}
+
+func TestRetryPolicyBackoffStaysPositiveWithoutMax(t *testing.T) {
+	policy := RetryPolicy{BaseBackoff: time.Second}
+	want := time.Second << 30
+	for _, attempt := range []int{31, 40, 64, 1024, math.MaxInt32} {
+		if got := policy.Backoff(attempt); got != want {
+			t.Fatalf("Backoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+	if got := (RetryPolicy{BaseBackoff: time.Hour << 20}).Backoff(1024); got <= 0 {
+		t.Fatalf("Backoff() of a huge base = %s", got)
+	}
+}
+
+func TestRetryPolicyExhausted(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3}
+	if policy.Exhausted(2) || !policy.Exhausted(3) {
+		t.Fatal("MaxAttempts=3 should be exhausted from the third attempt")
+	}
+	if (RetryPolicy{}).Exhausted(1000) {
+		t.Fatal("zero MaxAttempts should retry forever")
+	}
+}
@@ -7,6 +7,7 @@ import (
 )
 
 var ErrNotFound = errors.New("catalog: not found")
+var ErrConflict = errors.New("catalog: conflict")
 
 type Repository interface {
 	HealthCheck(ctx context.Context) error
@@ -15,10 +16,12 @@ type Repository interface {
 	CreateAPIKey(ctx context.Context, in CreateAPIKeyInput) (APIKey, error)
 	CreateTable(ctx context.Context, in CreateTableInput) (TableDef, error)
 	GetTableByName(ctx context.Context, tenantID, tableName string) (TableDef, error)
+	GetTableByID(ctx context.Context, tableID int64) (TableDef, error)
 	ListTables(ctx context.Context, tenantID string) ([]TableDef, error)
 	DeleteTableByName(ctx context.Context, tenantID, tableName string) (bool, error)
 	SetTableSchemaVersion(ctx context.Context, tableID int64, schemaVersion int) error
 	UpsertTableSchemaVersion(ctx context.Context, in UpsertTableSchemaVersionInput) (TableSchemaVersion, error)
+	GetCurrentTableSchema(ctx context.Context, tableID int64) (TableSchemaVersion, error)
 	InsertIngestEvent(ctx context.Context, in InsertIngestEventInput) (InsertIngestEventResult, error)
 	CreateSnapshot(ctx context.Context, in CreateSnapshotInput) (Snapshot, error)
 	ListSnapshots(ctx context.Context, tenantID string, limit int) ([]Snapshot, error)
@@ -62,6 +65,7 @@ type TableSchemaVersion struct {
 	SchemaVersion     int
 	SchemaJSON        []byte
 	CompatibilityMode string
+	UnknownFields     string
 	CreatedAt         time.Time
 }
 
@@ -80,27 +84,44 @@ type Snapshot struct {
 	CreatedAt          time.Time
 }
 
+type DataFileContent string
+
+const (
+	DataFileContentData   DataFileContent = "data"
+	DataFileContentDelete DataFileContent = "delete"
+)
+
 type DataFile struct {
 	FileID        int64
 	TenantID      string
 	TableID       int64
 	Path          string
 	Format        string
+	Content       DataFileContent
 	RecordCount   int64
 	FileSizeBytes int64
 	MinEventTime  *time.Time
 	MaxEventTime  *time.Time
 	StatsJSON     []byte
-	CreatedAt     time.Time
+	// PartitionValues maps partition field names to the values every row of
+	// the file shares.
+	PartitionValues map[string]string
+	CreatedAt       time.Time
 }
 
 type SnapshotFileEntry struct {
-	TableID       int64
-	TableName     string
-	FileID        int64
-	Path          string
-	FileSizeBytes int64
-	RecordCount   int64
+	TableID         int64
+	TableName       string
+	PrimaryKeyCols  []byte
+	FileID          int64
+	Path            string
+	Content         DataFileContent
+	FileSizeBytes   int64
+	RecordCount     int64
+	MinEventTime    *time.Time
+	MaxEventTime    *time.Time
+	StatsJSON       []byte
+	PartitionValues map[string]string
 }
 
 type SnapshotChangeType string
@@ -115,11 +136,52 @@ type IngestLagStats struct {
 	ClaimedEvents         int64
 	OldestPendingIngestAt *time.Time
 	MaxPendingToken       int64
+	FailedEvents          int64
 	LatestSnapshotID      *int64
 	LatestSnapshotAt      *time.Time
 	LatestVisibilityToken int64
 }
 
+type QueryJobState string
+
+const (
+	QueryJobQueued    QueryJobState = "queued"
+	QueryJobRunning   QueryJobState = "running"
+	QueryJobSucceeded QueryJobState = "succeeded"
+	QueryJobFailed    QueryJobState = "failed"
+	QueryJobCancelled QueryJobState = "cancelled"
+)
+
+// Finished reports whether a job in this state will not change anymore.
+func (s QueryJobState) Finished() bool {
+	return s == QueryJobSucceeded || s == QueryJobFailed || s == QueryJobCancelled
+}
+
+// QueryJob is an asynchronous query. PlanJSON holds the resolved request,
+// so any replica can run it; Owner and LeaseUntil belong to the replica
+// running it.
+type QueryJob struct {
+	JobID              int64
+	TenantID           string
+	State              QueryJobState
+	SQL                string
+	Format             string
+	PlanJSON           []byte
+	SnapshotID         int64
+	MaxVisibilityToken int64
+	CancelRequested    bool
+	Owner              string
+	LeaseUntil         *time.Time
+	ResultPath         string
+	ResultRows         int64
+	ResultBytes        int64
+	ScannedBytes       int64
+	Error              string
+	CreatedAt          time.Time
+	StartedAt          *time.Time
+	FinishedAt         *time.Time
+}
+
 type CreateTenantInput struct {
 	TenantID string
 	Name     string
@@ -146,6 +208,7 @@ type UpsertTableSchemaVersionInput struct {
 	SchemaVersion     int
 	SchemaJSON        []byte
 	CompatibilityMode string
+	UnknownFields     string
 }
 
 type InsertIngestEventInput struct {
@@ -171,15 +234,17 @@ type UpsertSnapshotTableWatermarkInput struct {
 }
 
 type RegisterDataFileInput struct {
-	TenantID      string
-	TableID       int64
-	Path          string
-	Format        string
-	RecordCount   int64
-	FileSizeBytes int64
-	MinEventTime  *time.Time
-	MaxEventTime  *time.Time
-	StatsJSON     []byte
+	TenantID        string
+	TableID         int64
+	Path            string
+	Format          string
+	Content         DataFileContent
+	RecordCount     int64
+	FileSizeBytes   int64
+	MinEventTime    *time.Time
+	MaxEventTime    *time.Time
+	StatsJSON       []byte
+	PartitionValues map[string]string
 }
 
 type AddSnapshotFileInput struct {
@@ -188,3 +253,25 @@ type AddSnapshotFileInput struct {
 	FileID     int64
 	ChangeType SnapshotChangeType
 }
+
+type CreateQueryJobInput struct {
+	TenantID           string
+	SQL                string
+	Format             string
+	PlanJSON           []byte
+	SnapshotID         int64
+	MaxVisibilityToken int64
+}
+
+// FinishQueryJobInput records the outcome of a running job. Result fields
+// are only set for QueryJobSucceeded.
+type FinishQueryJobInput struct {
+	JobID        int64
+	Owner        string
+	State        QueryJobState
+	ResultPath   string
+	ResultRows   int64
+	ResultBytes  int64
+	ScannedBytes int64
+	Error        string
+}
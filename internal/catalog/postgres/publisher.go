@@ -4,29 +4,74 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"slices"
 	"strconv"
 	"time"
+
+	"github.com/duckmesh/duckmesh/internal/catalog"
 )
 
+// PublishBatchInput publishes the claimed events of one tenant, across any
+// number of tables, as a single snapshot.
 type PublishBatchInput struct {
-	SnapshotID         int64
-	TenantID           string
-	TableID            int64
-	BatchID            string
-	EventIDs           []string
-	CreatedBy          string
+	SnapshotID int64
+	TenantID   string
+	// BatchIDs lists every claim batch the events were claimed in; the
+	// coordinator may accumulate one file across several claims.
+	BatchIDs  []string
+	CreatedBy string
+	// MaxVisibilityToken is the highest token among the published events.
+	// The snapshot records less while older events of the tenant are still
+	// pending.
 	MaxVisibilityToken int64
-	DataFilePath       string
-	RecordCount        int64
-	FileSizeBytes      int64
-	MinEventTime       *time.Time
-	MaxEventTime       *time.Time
-	StatsJSON          []byte
+	Tables             []PublishTable
+	// RejectedEventIDs are claimed events of the tenant that failed their
+	// table schema. They are acked along with the snapshot, so they do not
+	// hold its watermark back.
+	RejectedEventIDs []string
+}
+
+type PublishTable struct {
+	TableID  int64
+	EventIDs []string
+	Files    []PublishFile
+}
+
+type PublishFile struct {
+	Path            string
+	Content         catalog.DataFileContent
+	RecordCount     int64
+	FileSizeBytes   int64
+	MinEventTime    *time.Time
+	MaxEventTime    *time.Time
+	StatsJSON       []byte
+	PartitionValues map[string]string
 }
 
 type PublishBatchResult struct {
 	SnapshotID int64
-	FileID     int64
+	// MaxVisibilityToken is the watermark recorded for the snapshot and its
+	// tables.
+	MaxVisibilityToken int64
+	FileIDs            []int64
+}
+
+type PublishBulkLoadInput struct {
+	SnapshotID int64
+	TenantID   string
+	TableID    int64
+	CreatedBy  string
+	Files      []PublishFile
+}
+
+type PublishBulkLoadResult struct {
+	SnapshotID int64
+	// VisibilityToken is the token allocated for the load and recorded as the
+	// table watermark. MaxVisibilityToken is the snapshot watermark, which only
+	// advances to VisibilityToken when no older ingest events are in flight.
+	VisibilityToken    int64
+	MaxVisibilityToken int64
+	FileIDs            []int64
 }
 
 type PublishCompactionInput struct {
@@ -38,7 +83,10 @@ type PublishCompactionInput struct {
 	DataFilePath       string
 	RecordCount        int64
 	FileSizeBytes      int64
+	MinEventTime       *time.Time
+	MaxEventTime       *time.Time
 	StatsJSON          []byte
+	PartitionValues    map[string]string
 	RemovedFileIDs     []int64
 }
 
@@ -60,26 +108,40 @@ func (r *Repository) PublishBatch(ctx context.Context, in PublishBatchInput) (Pu
 	if in.SnapshotID <= 0 {
 		return PublishBatchResult{}, fmt.Errorf("snapshot id is required")
 	}
-	if in.TableID <= 0 {
-		return PublishBatchResult{}, fmt.Errorf("table id is required")
+	if len(in.Tables) == 0 {
+		return PublishBatchResult{}, fmt.Errorf("at least one table is required")
 	}
-	if len(in.EventIDs) == 0 {
-		return PublishBatchResult{}, fmt.Errorf("at least one event id is required")
+	if len(in.BatchIDs) == 0 {
+		return PublishBatchResult{}, fmt.Errorf("at least one batch id is required")
 	}
 	if in.CreatedBy == "" {
 		in.CreatedBy = "duckmesh-coordinator"
 	}
-	if len(in.StatsJSON) == 0 {
-		in.StatsJSON = []byte("{}")
-	}
 
-	eventIDs, err := parseInt64Slice(in.EventIDs, "event id")
+	var eventIDs []int64
+	for _, table := range in.Tables {
+		if table.TableID <= 0 {
+			return PublishBatchResult{}, fmt.Errorf("table id is required")
+		}
+		if len(table.EventIDs) == 0 {
+			return PublishBatchResult{}, fmt.Errorf("table %d: at least one event id is required", table.TableID)
+		}
+		if len(table.Files) == 0 {
+			return PublishBatchResult{}, fmt.Errorf("table %d: at least one file is required", table.TableID)
+		}
+		ids, err := parseInt64Slice(table.EventIDs, "event id")
+		if err != nil {
+			return PublishBatchResult{}, err
+		}
+		eventIDs = append(eventIDs, ids...)
+	}
+	batchIDs, err := parseInt64Slice(in.BatchIDs, "batch id")
 	if err != nil {
 		return PublishBatchResult{}, err
 	}
-	batchID, err := strconv.ParseInt(in.BatchID, 10, 64)
+	rejectedIDs, err := parseInt64Slice(in.RejectedEventIDs, "event id")
 	if err != nil {
-		return PublishBatchResult{}, fmt.Errorf("invalid batch id %q: %w", in.BatchID, err)
+		return PublishBatchResult{}, err
 	}
 
 	tx, err := r.db.BeginTx(ctx, nil)
@@ -89,62 +151,96 @@ func (r *Repository) PublishBatch(ctx context.Context, in PublishBatchInput) (Pu
 	defer func() { _ = tx.Rollback() }()
 
 	var parentSnapshotID *int64
+	var parentToken int64
 	if err := tx.QueryRowContext(ctx, `
-SELECT snapshot_id
+SELECT snapshot_id, max_visibility_token
 FROM snapshot
 WHERE tenant_id = $1
 ORDER BY snapshot_id DESC
-LIMIT 1`, in.TenantID).Scan(&parentSnapshotID); err != nil && err != sql.ErrNoRows {
+LIMIT 1`, in.TenantID).Scan(&parentSnapshotID, &parentToken); err != nil && err != sql.ErrNoRows {
 		return PublishBatchResult{}, fmt.Errorf("select parent snapshot: %w", err)
 	}
 
+	// Events that were nacked, are backing off or are claimed by another
+	// coordinator are not in this snapshot, so the watermark stays below them.
+	var oldestPending sql.NullInt64
+	if err := tx.QueryRowContext(ctx, `
+SELECT MIN(event_id)
+FROM ingest_event
+WHERE tenant_id = $1 AND state IN ('accepted', 'claimed') AND event_id <= $2 AND NOT (event_id = ANY($3::bigint[]))`, in.TenantID, in.MaxVisibilityToken, append(slices.Clone(eventIDs), rejectedIDs...)).Scan(&oldestPending); err != nil {
+		return PublishBatchResult{}, fmt.Errorf("select pending ingest events: %w", err)
+	}
+	watermark := in.MaxVisibilityToken
+	if oldestPending.Valid {
+		watermark = max(parentToken, oldestPending.Int64-1)
+	}
+
 	if _, err := tx.ExecContext(ctx, `
 INSERT INTO snapshot (snapshot_id, tenant_id, created_by, max_visibility_token, parent_snapshot_id)
 OVERRIDING SYSTEM VALUE
-VALUES ($1, $2, $3, $4, $5)`, in.SnapshotID, in.TenantID, in.CreatedBy, in.MaxVisibilityToken, parentSnapshotID); err != nil {
+VALUES ($1, $2, $3, $4, $5)`, in.SnapshotID, in.TenantID, in.CreatedBy, watermark, parentSnapshotID); err != nil {
 		return PublishBatchResult{}, fmt.Errorf("insert snapshot: %w", err)
 	}
 
-	var fileID int64
-	if err := tx.QueryRowContext(ctx, `
-INSERT INTO data_file (tenant_id, table_id, path, format, record_count, file_size_bytes, min_event_time, max_event_time, stats_json)
-VALUES ($1, $2, $3, 'parquet', $4, $5, $6, $7, $8::jsonb)
-RETURNING file_id`, in.TenantID, in.TableID, in.DataFilePath, in.RecordCount, in.FileSizeBytes, in.MinEventTime, in.MaxEventTime, string(in.StatsJSON)).Scan(&fileID); err != nil {
-		return PublishBatchResult{}, fmt.Errorf("insert data file: %w", err)
-	}
+	fileIDs := make([]int64, 0, len(in.Tables))
+	for _, table := range in.Tables {
+		for _, file := range table.Files {
+			content := file.Content
+			if content == "" {
+				content = catalog.DataFileContentData
+			}
+			stats := file.StatsJSON
+			if len(stats) == 0 {
+				stats = []byte("{}")
+			}
+			partitionValues, err := encodePartitionValues(file.PartitionValues)
+			if err != nil {
+				return PublishBatchResult{}, err
+			}
 
-	if _, err := tx.ExecContext(ctx, `
-INSERT INTO snapshot_table_watermark (snapshot_id, table_id, max_visibility_token)
-VALUES ($1, $2, $3)`, in.SnapshotID, in.TableID, in.MaxVisibilityToken); err != nil {
-		return PublishBatchResult{}, fmt.Errorf("insert snapshot table watermark: %w", err)
-	}
+			var fileID int64
+			if err := tx.QueryRowContext(ctx, `
+INSERT INTO data_file (tenant_id, table_id, path, format, content, record_count, file_size_bytes, min_event_time, max_event_time, stats_json, partition_values)
+VALUES ($1, $2, $3, 'parquet', $4, $5, $6, $7, $8, $9::jsonb, $10::jsonb)
+RETURNING file_id`, in.TenantID, table.TableID, file.Path, string(content), file.RecordCount, file.FileSizeBytes, file.MinEventTime, file.MaxEventTime, string(stats), partitionValues).Scan(&fileID); err != nil {
+				return PublishBatchResult{}, fmt.Errorf("insert %s file: %w", content, err)
+			}
 
-	if _, err := tx.ExecContext(ctx, `
+			if _, err := tx.ExecContext(ctx, `
 INSERT INTO snapshot_file (snapshot_id, table_id, file_id, change_type)
-VALUES ($1, $2, $3, 'add')`, in.SnapshotID, in.TableID, fileID); err != nil {
-		return PublishBatchResult{}, fmt.Errorf("insert snapshot file: %w", err)
+VALUES ($1, $2, $3, 'add')`, in.SnapshotID, table.TableID, fileID); err != nil {
+				return PublishBatchResult{}, fmt.Errorf("insert snapshot file: %w", err)
+			}
+			fileIDs = append(fileIDs, fileID)
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO snapshot_table_watermark (snapshot_id, table_id, max_visibility_token)
+VALUES ($1, $2, $3)`, in.SnapshotID, table.TableID, watermark); err != nil {
+			return PublishBatchResult{}, fmt.Errorf("insert snapshot table watermark: %w", err)
+		}
 	}
 
 	if _, err := tx.ExecContext(ctx, `
 UPDATE ingest_event AS e
 SET state = 'committed', lease_owner = NULL, lease_until = NULL
 FROM ingest_claim_item AS i
-WHERE i.batch_id = $1 AND i.event_id = e.event_id AND e.event_id = ANY($2::bigint[])`, batchID, eventIDs); err != nil {
+WHERE i.batch_id = ANY($1::bigint[]) AND i.event_id = e.event_id AND e.event_id = ANY($2::bigint[])`, batchIDs, eventIDs); err != nil {
 		return PublishBatchResult{}, fmt.Errorf("mark events committed: %w", err)
 	}
 
 	if _, err := tx.ExecContext(ctx, `
-UPDATE ingest_claim_batch
+UPDATE ingest_claim_batch AS b
 SET state = CASE
     WHEN EXISTS (
         SELECT 1
         FROM ingest_claim_item AS i
         JOIN ingest_event AS e ON e.event_id = i.event_id
-        WHERE i.batch_id = $1 AND e.state = 'claimed'
+        WHERE i.batch_id = b.batch_id AND e.state = 'claimed'
     ) THEN 'claimed'::duckmesh_ingest_state
     ELSE 'committed'::duckmesh_ingest_state
 END
-WHERE batch_id = $1`, batchID); err != nil {
+WHERE b.batch_id = ANY($1::bigint[])`, batchIDs); err != nil {
 		return PublishBatchResult{}, fmt.Errorf("update claim batch state: %w", err)
 	}
 
@@ -152,7 +248,114 @@ WHERE batch_id = $1`, batchID); err != nil {
 		return PublishBatchResult{}, fmt.Errorf("commit publish tx: %w", err)
 	}
 
-	return PublishBatchResult{SnapshotID: in.SnapshotID, FileID: fileID}, nil
+	return PublishBatchResult{SnapshotID: in.SnapshotID, MaxVisibilityToken: watermark, FileIDs: fileIDs}, nil
+}
+
+// PublishBulkLoad registers externally written files and publishes them in a
+// new snapshot within one transaction.
+func (r *Repository) PublishBulkLoad(ctx context.Context, in PublishBulkLoadInput) (PublishBulkLoadResult, error) {
+	if in.SnapshotID <= 0 {
+		return PublishBulkLoadResult{}, fmt.Errorf("snapshot id is required")
+	}
+	if in.TableID <= 0 {
+		return PublishBulkLoadResult{}, fmt.Errorf("table id is required")
+	}
+	if len(in.Files) == 0 {
+		return PublishBulkLoadResult{}, fmt.Errorf("at least one file is required")
+	}
+	if in.CreatedBy == "" {
+		in.CreatedBy = "duckmesh-bulkload"
+	}
+
+	paths := make([]string, 0, len(in.Files))
+	for _, file := range in.Files {
+		paths = append(paths, file.Path)
+	}
+
+	result := PublishBulkLoadResult{SnapshotID: in.SnapshotID}
+	err := r.WithTx(ctx, func(tx *TxRepository) error {
+		var registered bool
+		if err := tx.q.QueryRowContext(ctx, `
+SELECT EXISTS (
+    SELECT 1 FROM data_file WHERE tenant_id = $1 AND path = ANY($2::text[])
+)`, in.TenantID, paths).Scan(&registered); err != nil {
+			return fmt.Errorf("check registered paths: %w", err)
+		}
+		if registered {
+			return fmt.Errorf("bulk load path is already registered: %w", catalog.ErrConflict)
+		}
+
+		if err := tx.q.QueryRowContext(ctx, `SELECT nextval(pg_get_serial_sequence('ingest_event', 'event_id'))`).Scan(&result.VisibilityToken); err != nil {
+			return fmt.Errorf("allocate visibility token: %w", err)
+		}
+
+		var parentSnapshotID *int64
+		var parentToken int64
+		if err := tx.q.QueryRowContext(ctx, `
+SELECT snapshot_id, max_visibility_token
+FROM snapshot
+WHERE tenant_id = $1
+ORDER BY snapshot_id DESC
+LIMIT 1`, in.TenantID).Scan(&parentSnapshotID, &parentToken); err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("select parent snapshot: %w", err)
+		}
+
+		var oldestPending sql.NullInt64
+		if err := tx.q.QueryRowContext(ctx, `
+SELECT MIN(event_id)
+FROM ingest_event
+WHERE tenant_id = $1 AND state IN ('accepted', 'claimed') AND event_id < $2`, in.TenantID, result.VisibilityToken).Scan(&oldestPending); err != nil {
+			return fmt.Errorf("select pending ingest events: %w", err)
+		}
+		result.MaxVisibilityToken = result.VisibilityToken
+		if oldestPending.Valid {
+			result.MaxVisibilityToken = max(parentToken, oldestPending.Int64-1)
+		}
+
+		if _, err := tx.q.ExecContext(ctx, `
+INSERT INTO snapshot (snapshot_id, tenant_id, created_by, max_visibility_token, parent_snapshot_id)
+OVERRIDING SYSTEM VALUE
+VALUES ($1, $2, $3, $4, $5)`, in.SnapshotID, in.TenantID, in.CreatedBy, result.MaxVisibilityToken, parentSnapshotID); err != nil {
+			return fmt.Errorf("insert bulk load snapshot: %w", err)
+		}
+
+		for _, file := range in.Files {
+			registeredFile, err := tx.RegisterDataFile(ctx, catalog.RegisterDataFileInput{
+				TenantID:        in.TenantID,
+				TableID:         in.TableID,
+				Path:            file.Path,
+				Content:         file.Content,
+				RecordCount:     file.RecordCount,
+				FileSizeBytes:   file.FileSizeBytes,
+				MinEventTime:    file.MinEventTime,
+				MaxEventTime:    file.MaxEventTime,
+				StatsJSON:       file.StatsJSON,
+				PartitionValues: file.PartitionValues,
+			})
+			if err != nil {
+				return err
+			}
+			if err := tx.AddSnapshotFile(ctx, catalog.AddSnapshotFileInput{
+				SnapshotID: in.SnapshotID,
+				TableID:    in.TableID,
+				FileID:     registeredFile.FileID,
+				ChangeType: catalog.SnapshotChangeAdd,
+			}); err != nil {
+				return err
+			}
+			result.FileIDs = append(result.FileIDs, registeredFile.FileID)
+		}
+
+		return tx.UpsertSnapshotTableWatermark(ctx, catalog.UpsertSnapshotTableWatermarkInput{
+			SnapshotID:         in.SnapshotID,
+			TableID:            in.TableID,
+			MaxVisibilityToken: result.VisibilityToken,
+		})
+	})
+	if err != nil {
+		return PublishBulkLoadResult{}, err
+	}
+	return result, nil
 }
 
 func parseInt64Slice(values []string, field string) ([]int64, error) {
@@ -180,6 +383,10 @@ func (r *Repository) PublishCompaction(ctx context.Context, in PublishCompaction
 	if len(in.StatsJSON) == 0 {
 		in.StatsJSON = []byte("{}")
 	}
+	partitionValues, err := encodePartitionValues(in.PartitionValues)
+	if err != nil {
+		return PublishCompactionResult{}, err
+	}
 
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -206,9 +413,9 @@ VALUES ($1, $2, $3, $4, $5)`, in.SnapshotID, in.TenantID, in.CreatedBy, in.MaxVi
 
 	var newFileID int64
 	if err := tx.QueryRowContext(ctx, `
-INSERT INTO data_file (tenant_id, table_id, path, format, record_count, file_size_bytes, stats_json)
-VALUES ($1, $2, $3, 'parquet', $4, $5, $6::jsonb)
-RETURNING file_id`, in.TenantID, in.TableID, in.DataFilePath, in.RecordCount, in.FileSizeBytes, string(in.StatsJSON)).Scan(&newFileID); err != nil {
+INSERT INTO data_file (tenant_id, table_id, path, format, record_count, file_size_bytes, min_event_time, max_event_time, stats_json, partition_values)
+VALUES ($1, $2, $3, 'parquet', $4, $5, $6, $7, $8::jsonb, $9::jsonb)
+RETURNING file_id`, in.TenantID, in.TableID, in.DataFilePath, in.RecordCount, in.FileSizeBytes, in.MinEventTime, in.MaxEventTime, string(in.StatsJSON), partitionValues).Scan(&newFileID); err != nil {
 		return PublishCompactionResult{}, fmt.Errorf("insert compacted data file: %w", err)
 	}
 
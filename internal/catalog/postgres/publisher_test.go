@@ -0,0 +1,256 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/duckmesh/duckmesh/internal/catalog"
+)
+
+// textArrayConverter lets sqlmock accept the []string and []int64 arguments
+// pgx encodes as arrays.
+type textArrayConverter struct{}
+
+func (textArrayConverter) ConvertValue(v any) (driver.Value, error) {
+	switch values := v.(type) {
+	case []string:
+		return "{" + strings.Join(values, ",") + "}", nil
+	case []int64:
+		parts := make([]string, 0, len(values))
+		for _, value := range values {
+			parts = append(parts, strconv.FormatInt(value, 10))
+		}
+		return "{" + strings.Join(parts, ",") + "}", nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+func newBulkLoadSQLMock(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.ValueConverterOption(textArrayConverter{}))
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db, mock
+}
+
+func TestPublishBatchWritesAllTablesInOneSnapshot(t *testing.T) {
+	db, mock := newBulkLoadSQLMock(t)
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT snapshot_id, max_visibility_token`)).
+		WithArgs("tenant-1").
+		WillReturnRows(sqlmock.NewRows([]string{"snapshot_id", "max_visibility_token"}).AddRow(int64(44), int64(9)))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT MIN(event_id)`)).
+		WithArgs("tenant-1", int64(12), "{10,11,12}").
+		WillReturnRows(sqlmock.NewRows([]string{"min"}).AddRow(nil))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO snapshot (snapshot_id, tenant_id, created_by, max_visibility_token, parent_snapshot_id)`)).
+		WithArgs(int64(45), "tenant-1", "duckmesh-coordinator", int64(12), int64(44)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	for i, table := range []struct {
+		tableID   int64
+		path      string
+		fileID    int64
+		partition string
+	}{{7, "tenant-1/orders.parquet", 81, `{"date":"2026-02-19"}`}, {8, "tenant-1/order_items.parquet", 82, "{}"}} {
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO data_file`)).
+			WithArgs("tenant-1", table.tableID, table.path, "data", int64(i+1), int64(100), nil, nil, "{}", table.partition).
+			WillReturnRows(sqlmock.NewRows([]string{"file_id"}).AddRow(table.fileID))
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO snapshot_file`)).
+			WithArgs(int64(45), table.tableID, table.fileID).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO snapshot_table_watermark`)).
+			WithArgs(int64(45), table.tableID, int64(12)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE ingest_event AS e`)).
+		WithArgs("{300,301}", "{10,11,12}").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE ingest_claim_batch AS b`)).
+		WithArgs("{300,301}").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	result, err := repo.PublishBatch(context.Background(), PublishBatchInput{
+		SnapshotID:         45,
+		TenantID:           "tenant-1",
+		BatchIDs:           []string{"300", "301"},
+		MaxVisibilityToken: 12,
+		Tables: []PublishTable{
+			{TableID: 7, EventIDs: []string{"10"}, Files: []PublishFile{{Path: "tenant-1/orders.parquet", Content: catalog.DataFileContentData, RecordCount: 1, FileSizeBytes: 100, PartitionValues: map[string]string{"date": "2026-02-19"}}}},
+			{TableID: 8, EventIDs: []string{"11", "12"}, Files: []PublishFile{{Path: "tenant-1/order_items.parquet", RecordCount: 2, FileSizeBytes: 100}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("PublishBatch() error = %v", err)
+	}
+	if result.SnapshotID != 45 || result.MaxVisibilityToken != 12 || fmt.Sprint(result.FileIDs) != "[81 82]" {
+		t.Fatalf("result = %+v", result)
+	}
+	assertSQLMock(t, mock)
+}
+
+func TestPublishBatchHoldsWatermarkBelowPendingEvents(t *testing.T) {
+	db, mock := newBulkLoadSQLMock(t)
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT snapshot_id, max_visibility_token`)).
+		WithArgs("tenant-1").
+		WillReturnRows(sqlmock.NewRows([]string{"snapshot_id", "max_visibility_token"}).AddRow(int64(44), int64(9)))
+	// Event 11 was rejected by its schema and is acked with the snapshot;
+	// event 12 was nacked and is pending again.
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT MIN(event_id)`)).
+		WithArgs("tenant-1", int64(13), "{10,13,11}").
+		WillReturnRows(sqlmock.NewRows([]string{"min"}).AddRow(int64(12)))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO snapshot (snapshot_id, tenant_id, created_by, max_visibility_token, parent_snapshot_id)`)).
+		WithArgs(int64(45), "tenant-1", "duckmesh-coordinator", int64(11), int64(44)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO data_file`)).
+		WillReturnRows(sqlmock.NewRows([]string{"file_id"}).AddRow(int64(81)))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO snapshot_file`)).
+		WithArgs(int64(45), int64(7), int64(81)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO snapshot_table_watermark`)).
+		WithArgs(int64(45), int64(7), int64(11)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE ingest_event AS e`)).
+		WithArgs("{300}", "{10,13}").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE ingest_claim_batch AS b`)).
+		WithArgs("{300}").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	result, err := repo.PublishBatch(context.Background(), PublishBatchInput{
+		SnapshotID:         45,
+		TenantID:           "tenant-1",
+		BatchIDs:           []string{"300"},
+		MaxVisibilityToken: 13,
+		Tables:             []PublishTable{{TableID: 7, EventIDs: []string{"10", "13"}, Files: []PublishFile{{Path: "tenant-1/orders.parquet", RecordCount: 2}}}},
+		RejectedEventIDs:   []string{"11"},
+	})
+	if err != nil {
+		t.Fatalf("PublishBatch() error = %v", err)
+	}
+	if result.MaxVisibilityToken != 11 {
+		t.Fatalf("max visibility token = %d, want 11", result.MaxVisibilityToken)
+	}
+	assertSQLMock(t, mock)
+}
+
+func TestPublishBatchRollsBackAllTablesOnFailure(t *testing.T) {
+	db, mock := newBulkLoadSQLMock(t)
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT snapshot_id`)).
+		WithArgs("tenant-1").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT MIN(event_id)`)).
+		WillReturnRows(sqlmock.NewRows([]string{"min"}).AddRow(nil))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO snapshot (snapshot_id, tenant_id, created_by, max_visibility_token, parent_snapshot_id)`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO data_file`)).
+		WillReturnRows(sqlmock.NewRows([]string{"file_id"}).AddRow(int64(81)))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO snapshot_file`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO snapshot_table_watermark`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO data_file`)).
+		WillReturnError(errors.New("disk full"))
+	mock.ExpectRollback()
+
+	_, err := repo.PublishBatch(context.Background(), PublishBatchInput{
+		SnapshotID: 45,
+		TenantID:   "tenant-1",
+		BatchIDs:   []string{"300"},
+		Tables: []PublishTable{
+			{TableID: 7, EventIDs: []string{"10"}, Files: []PublishFile{{Path: "a.parquet"}}},
+			{TableID: 8, EventIDs: []string{"11"}, Files: []PublishFile{{Path: "b.parquet"}}},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("PublishBatch() error = %v", err)
+	}
+	assertSQLMock(t, mock)
+}
+
+func TestPublishBulkLoadHoldsSnapshotWatermarkWhileEventsPending(t *testing.T) {
+	db, mock := newBulkLoadSQLMock(t)
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT 1 FROM data_file WHERE tenant_id = $1 AND path = ANY($2::text[])`)).
+		WithArgs("tenant-1", "{tenant-1/a.parquet}").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT nextval(pg_get_serial_sequence('ingest_event', 'event_id'))`)).
+		WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(int64(900)))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT snapshot_id, max_visibility_token`)).
+		WithArgs("tenant-1").
+		WillReturnRows(sqlmock.NewRows([]string{"snapshot_id", "max_visibility_token"}).AddRow(int64(44), int64(120)))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT MIN(event_id)`)).
+		WithArgs("tenant-1", int64(900)).
+		WillReturnRows(sqlmock.NewRows([]string{"min"}).AddRow(int64(100)))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO snapshot (snapshot_id, tenant_id, created_by, max_visibility_token, parent_snapshot_id)`)).
+		WithArgs(int64(45), "tenant-1", "duckmesh-bulkload", int64(120), int64(44)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO data_file`)).
+		WithArgs("tenant-1", int64(7), "tenant-1/a.parquet", "parquet", "data", int64(3), int64(512), nil, nil, "{}", "{}").
+		WillReturnRows(sqlmock.NewRows([]string{"file_id", "created_at"}).AddRow(int64(81), time.Unix(1700000000, 0).UTC()))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO snapshot_file`)).
+		WithArgs(int64(45), int64(7), int64(81), "add").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO snapshot_table_watermark`)).
+		WithArgs(int64(45), int64(7), int64(900)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	result, err := repo.PublishBulkLoad(context.Background(), PublishBulkLoadInput{
+		SnapshotID: 45,
+		TenantID:   "tenant-1",
+		TableID:    7,
+		Files:      []PublishFile{{Path: "tenant-1/a.parquet", RecordCount: 3, FileSizeBytes: 512}},
+	})
+	if err != nil {
+		t.Fatalf("PublishBulkLoad() error = %v", err)
+	}
+	if result.VisibilityToken != 900 || result.MaxVisibilityToken != 120 || len(result.FileIDs) != 1 || result.FileIDs[0] != 81 {
+		t.Fatalf("result = %+v", result)
+	}
+	assertSQLMock(t, mock)
+}
+
+func TestPublishBulkLoadRejectsRegisteredPaths(t *testing.T) {
+	db, mock := newBulkLoadSQLMock(t)
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT 1 FROM data_file WHERE tenant_id = $1 AND path = ANY($2::text[])`)).
+		WithArgs("tenant-1", "{tenant-1/a.parquet}").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	_, err := repo.PublishBulkLoad(context.Background(), PublishBulkLoadInput{
+		SnapshotID: 45,
+		TenantID:   "tenant-1",
+		TableID:    7,
+		Files:      []PublishFile{{Path: "tenant-1/a.parquet"}},
+	})
+	if !errors.Is(err, catalog.ErrConflict) {
+		t.Fatalf("PublishBulkLoad() error = %v, want ErrConflict", err)
+	}
+	assertSQLMock(t, mock)
+}
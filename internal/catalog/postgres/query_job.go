@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/catalog"
+)
+
+const queryJobColumns = `job_id, tenant_id, state, query_text, format, plan_json, snapshot_id, max_visibility_token, cancel_requested,
+    owner, lease_until, result_path, result_rows, result_bytes, scanned_bytes, error_message, created_at, started_at, finished_at`
+
+func (r *Repository) CreateQueryJob(ctx context.Context, in catalog.CreateQueryJobInput) (catalog.QueryJob, error) {
+	query := `
+INSERT INTO query_job (tenant_id, query_text, format, plan_json, snapshot_id, max_visibility_token)
+VALUES ($1, $2, $3, $4::jsonb, $5, $6)
+RETURNING ` + queryJobColumns
+	job, err := scanQueryJob(r.db.QueryRowContext(ctx, query, in.TenantID, in.SQL, in.Format, string(in.PlanJSON), in.SnapshotID, in.MaxVisibilityToken))
+	if err != nil {
+		return catalog.QueryJob{}, fmt.Errorf("create query job: %w", err)
+	}
+	return job, nil
+}
+
+func (r *Repository) GetQueryJob(ctx context.Context, tenantID string, jobID int64) (catalog.QueryJob, error) {
+	query := `
+SELECT ` + queryJobColumns + `
+FROM query_job
+WHERE tenant_id = $1 AND job_id = $2`
+	return scanQueryJob(r.db.QueryRowContext(ctx, query, tenantID, jobID))
+}
+
+// ClaimQueryJob starts the oldest queued job for owner. Running jobs whose
+// lease expired, because their replica stopped, are failed first. It
+// returns catalog.ErrNotFound when no job is queued.
+func (r *Repository) ClaimQueryJob(ctx context.Context, owner string, leaseUntil time.Time) (catalog.QueryJob, error) {
+	expire := `
+UPDATE query_job
+SET state = CASE WHEN cancel_requested THEN 'cancelled'::duckmesh_query_job_state ELSE 'failed'::duckmesh_query_job_state END,
+    error_message = CASE WHEN cancel_requested THEN NULL ELSE 'query job lease expired' END,
+    lease_until = NULL,
+    finished_at = NOW()
+WHERE state = 'running' AND lease_until < NOW()`
+	if _, err := r.db.ExecContext(ctx, expire); err != nil {
+		return catalog.QueryJob{}, fmt.Errorf("expire query jobs: %w", err)
+	}
+
+	claim := `
+UPDATE query_job
+SET state = 'running', owner = $1, lease_until = $2, started_at = NOW()
+WHERE job_id = (
+    SELECT job_id
+    FROM query_job
+    WHERE state = 'queued'
+    ORDER BY job_id
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING ` + queryJobColumns
+	return scanQueryJob(r.db.QueryRowContext(ctx, claim, owner, leaseUntil.UTC()))
+}
+
+// RenewQueryJob extends the lease of a running job and reports whether its
+// cancellation was requested. It returns catalog.ErrNotFound when owner no
+// longer runs the job.
+func (r *Repository) RenewQueryJob(ctx context.Context, jobID int64, owner string, leaseUntil time.Time) (bool, error) {
+	query := `
+UPDATE query_job
+SET lease_until = $3
+WHERE job_id = $1 AND owner = $2 AND state = 'running'
+RETURNING cancel_requested`
+	var cancelRequested bool
+	if err := r.db.QueryRowContext(ctx, query, jobID, owner, leaseUntil.UTC()).Scan(&cancelRequested); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, catalog.ErrNotFound
+		}
+		return false, fmt.Errorf("renew query job: %w", err)
+	}
+	return cancelRequested, nil
+}
+
+func (r *Repository) FinishQueryJob(ctx context.Context, in catalog.FinishQueryJobInput) error {
+	query := `
+UPDATE query_job
+SET state = $3::duckmesh_query_job_state,
+    result_path = NULLIF($4, ''),
+    result_rows = $5,
+    result_bytes = $6,
+    scanned_bytes = $7,
+    error_message = NULLIF($8, ''),
+    lease_until = NULL,
+    finished_at = NOW()
+WHERE job_id = $1 AND owner = $2 AND state = 'running'`
+	result, err := r.db.ExecContext(ctx, query, in.JobID, in.Owner, string(in.State), in.ResultPath, in.ResultRows, in.ResultBytes, in.ScannedBytes, in.Error)
+	if err != nil {
+		return fmt.Errorf("finish query job: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("finish query job rows affected: %w", err)
+	}
+	if affected == 0 {
+		return catalog.ErrNotFound
+	}
+	return nil
+}
+
+// CancelQueryJob cancels a queued job and asks the owner of a running job
+// to stop it. It returns catalog.ErrConflict for finished jobs.
+func (r *Repository) CancelQueryJob(ctx context.Context, tenantID string, jobID int64) (catalog.QueryJob, error) {
+	query := `
+UPDATE query_job
+SET cancel_requested = TRUE,
+    state = CASE WHEN state = 'queued' THEN 'cancelled'::duckmesh_query_job_state ELSE state END,
+    finished_at = CASE WHEN state = 'queued' THEN NOW() ELSE finished_at END
+WHERE tenant_id = $1 AND job_id = $2 AND state IN ('queued', 'running')
+RETURNING ` + queryJobColumns
+	job, err := scanQueryJob(r.db.QueryRowContext(ctx, query, tenantID, jobID))
+	if errors.Is(err, catalog.ErrNotFound) {
+		if _, getErr := r.GetQueryJob(ctx, tenantID, jobID); getErr != nil {
+			return catalog.QueryJob{}, getErr
+		}
+		return catalog.QueryJob{}, catalog.ErrConflict
+	}
+	return job, err
+}
+
+// DeleteQueryJob removes a finished job. It returns catalog.ErrConflict for
+// queued and running jobs.
+func (r *Repository) DeleteQueryJob(ctx context.Context, tenantID string, jobID int64) error {
+	query := `
+DELETE FROM query_job
+WHERE tenant_id = $1 AND job_id = $2 AND state IN ('succeeded', 'failed', 'cancelled')`
+	result, err := r.db.ExecContext(ctx, query, tenantID, jobID)
+	if err != nil {
+		return fmt.Errorf("delete query job: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete query job rows affected: %w", err)
+	}
+	if affected == 0 {
+		if _, err := r.GetQueryJob(ctx, tenantID, jobID); err != nil {
+			return err
+		}
+		return catalog.ErrConflict
+	}
+	return nil
+}
+
+func scanQueryJob(row *sql.Row) (catalog.QueryJob, error) {
+	var job catalog.QueryJob
+	var state string
+	var owner, resultPath, errorMessage sql.NullString
+	var resultRows, resultBytes, scannedBytes sql.NullInt64
+	var leaseUntil, startedAt, finishedAt sql.NullTime
+	if err := row.Scan(
+		&job.JobID,
+		&job.TenantID,
+		&state,
+		&job.SQL,
+		&job.Format,
+		&job.PlanJSON,
+		&job.SnapshotID,
+		&job.MaxVisibilityToken,
+		&job.CancelRequested,
+		&owner,
+		&leaseUntil,
+		&resultPath,
+		&resultRows,
+		&resultBytes,
+		&scannedBytes,
+		&errorMessage,
+		&job.CreatedAt,
+		&startedAt,
+		&finishedAt,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return catalog.QueryJob{}, catalog.ErrNotFound
+		}
+		return catalog.QueryJob{}, fmt.Errorf("scan query job: %w", err)
+	}
+	job.State = catalog.QueryJobState(state)
+	job.Owner = owner.String
+	job.LeaseUntil = nullTimePtr(leaseUntil)
+	job.ResultPath = resultPath.String
+	job.ResultRows = resultRows.Int64
+	job.ResultBytes = resultBytes.Int64
+	job.ScannedBytes = scannedBytes.Int64
+	job.Error = errorMessage.String
+	job.StartedAt = nullTimePtr(startedAt)
+	job.FinishedAt = nullTimePtr(finishedAt)
+	return job, nil
+}
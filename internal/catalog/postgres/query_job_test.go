@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/duckmesh/duckmesh/internal/catalog"
+)
+
+var queryJobRowColumns = []string{
+	"job_id", "tenant_id", "state", "query_text", "format", "plan_json", "snapshot_id", "max_visibility_token", "cancel_requested",
+	"owner", "lease_until", "result_path", "result_rows", "result_bytes", "scanned_bytes", "error_message", "created_at", "started_at", "finished_at",
+}
+
+func TestClaimQueryJobExpiresStaleLeasesFirst(t *testing.T) {
+	db, mock := newSQLMock(t)
+	repo := NewRepository(db)
+	now := time.Date(2026, 2, 19, 10, 0, 0, 0, time.UTC)
+	leaseUntil := now.Add(30 * time.Second)
+
+	mock.ExpectExec(regexp.QuoteMeta(`WHERE state = 'running' AND lease_until < NOW()`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE SKIP LOCKED`)).
+		WithArgs("api-1", leaseUntil).
+		WillReturnRows(sqlmock.NewRows(queryJobRowColumns).AddRow(
+			int64(7), "tenant-1", "running", "SELECT 1", "parquet", []byte(`{"sql":"SELECT 1"}`), int64(12), int64(40), false,
+			"api-1", leaseUntil, nil, nil, nil, nil, nil, now, now, nil,
+		))
+
+	job, err := repo.ClaimQueryJob(context.Background(), "api-1", leaseUntil)
+	if err != nil {
+		t.Fatalf("ClaimQueryJob() error = %v", err)
+	}
+	if job.JobID != 7 || job.State != catalog.QueryJobRunning || job.Owner != "api-1" || job.LeaseUntil == nil || job.FinishedAt != nil {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+	assertSQLMock(t, mock)
+}
+
+func TestClaimQueryJobReturnsNotFoundWhenQueueIsEmpty(t *testing.T) {
+	db, mock := newSQLMock(t)
+	repo := NewRepository(db)
+	leaseUntil := time.Date(2026, 2, 19, 10, 0, 30, 0, time.UTC)
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE query_job`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE SKIP LOCKED`)).
+		WithArgs("api-1", leaseUntil).
+		WillReturnError(sql.ErrNoRows)
+
+	if _, err := repo.ClaimQueryJob(context.Background(), "api-1", leaseUntil); !errors.Is(err, catalog.ErrNotFound) {
+		t.Fatalf("ClaimQueryJob() error = %v, want ErrNotFound", err)
+	}
+	assertSQLMock(t, mock)
+}
+
+func TestCancelQueryJobRejectsFinishedJobs(t *testing.T) {
+	db, mock := newSQLMock(t)
+	repo := NewRepository(db)
+	now := time.Date(2026, 2, 19, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SET cancel_requested = TRUE`)).
+		WithArgs("tenant-1", int64(7)).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM query_job
+WHERE tenant_id = $1 AND job_id = $2`)).
+		WithArgs("tenant-1", int64(7)).
+		WillReturnRows(sqlmock.NewRows(queryJobRowColumns).AddRow(
+			int64(7), "tenant-1", "succeeded", "SELECT 1", "csv", []byte(`{}`), int64(12), int64(40), false,
+			"api-1", nil, "tenant-1/_query_jobs/7/result.csv", int64(1), int64(10), int64(100), nil, now, now, now,
+		))
+
+	if _, err := repo.CancelQueryJob(context.Background(), "tenant-1", 7); !errors.Is(err, catalog.ErrConflict) {
+		t.Fatalf("CancelQueryJob() error = %v, want ErrConflict", err)
+	}
+	assertSQLMock(t, mock)
+}
+
+func TestRenewQueryJobReportsCancellation(t *testing.T) {
+	db, mock := newSQLMock(t)
+	repo := NewRepository(db)
+	leaseUntil := time.Date(2026, 2, 19, 10, 0, 30, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`RETURNING cancel_requested`)).
+		WithArgs(int64(7), "api-1", leaseUntil).
+		WillReturnRows(sqlmock.NewRows([]string{"cancel_requested"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta(`RETURNING cancel_requested`)).
+		WithArgs(int64(8), "api-1", leaseUntil).
+		WillReturnError(sql.ErrNoRows)
+
+	cancelRequested, err := repo.RenewQueryJob(context.Background(), 7, "api-1", leaseUntil)
+	if err != nil || !cancelRequested {
+		t.Fatalf("RenewQueryJob() = %v, %v", cancelRequested, err)
+	}
+	if _, err := repo.RenewQueryJob(context.Background(), 8, "api-1", leaseUntil); !errors.Is(err, catalog.ErrNotFound) {
+		t.Fatalf("RenewQueryJob() error = %v, want ErrNotFound", err)
+	}
+	assertSQLMock(t, mock)
+}
@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -173,6 +174,30 @@ WHERE tenant_id = $1 AND table_name = $2`
 	return table, nil
 }
 
+func (r *Repository) GetTableByID(ctx context.Context, tableID int64) (catalog.TableDef, error) {
+	query := `
+SELECT table_id, tenant_id, table_name, primary_key_cols, partition_spec, schema_version, created_at
+FROM table_def
+WHERE table_id = $1`
+
+	var table catalog.TableDef
+	if err := r.db.QueryRowContext(ctx, query, tableID).Scan(
+		&table.TableID,
+		&table.TenantID,
+		&table.TableName,
+		&table.PrimaryKeyCols,
+		&table.PartitionSpec,
+		&table.SchemaVersion,
+		&table.CreatedAt,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return catalog.TableDef{}, catalog.ErrNotFound
+		}
+		return catalog.TableDef{}, fmt.Errorf("get table by id: %w", err)
+	}
+	return table, nil
+}
+
 func (r *Repository) ListTables(ctx context.Context, tenantID string) ([]catalog.TableDef, error) {
 	query := `
 SELECT table_id, tenant_id, table_name, primary_key_cols, partition_spec, schema_version, created_at
@@ -249,18 +274,23 @@ func (r *Repository) UpsertTableSchemaVersion(ctx context.Context, in catalog.Up
 	if compatibility == "" {
 		compatibility = "backward"
 	}
+	unknownFields := in.UnknownFields
+	if unknownFields == "" {
+		unknownFields = "allow"
+	}
 
 	query := `
-INSERT INTO table_schema_version (table_id, schema_version, schema_json, compatibility_mode)
-VALUES ($1, $2, $3::jsonb, $4)
+INSERT INTO table_schema_version (table_id, schema_version, schema_json, compatibility_mode, unknown_fields)
+VALUES ($1, $2, $3::jsonb, $4, $5)
 ON CONFLICT (table_id, schema_version)
 DO UPDATE SET
     schema_json = EXCLUDED.schema_json,
-    compatibility_mode = EXCLUDED.compatibility_mode
+    compatibility_mode = EXCLUDED.compatibility_mode,
+    unknown_fields = EXCLUDED.unknown_fields
 RETURNING created_at`
 
 	var createdAt time.Time
-	if err := r.db.QueryRowContext(ctx, query, in.TableID, in.SchemaVersion, string(schemaJSON), compatibility).Scan(&createdAt); err != nil {
+	if err := r.db.QueryRowContext(ctx, query, in.TableID, in.SchemaVersion, string(schemaJSON), compatibility, unknownFields).Scan(&createdAt); err != nil {
 		return catalog.TableSchemaVersion{}, fmt.Errorf("upsert table schema version: %w", err)
 	}
 	return catalog.TableSchemaVersion{
@@ -268,10 +298,35 @@ RETURNING created_at`
 		SchemaVersion:     in.SchemaVersion,
 		SchemaJSON:        schemaJSON,
 		CompatibilityMode: compatibility,
+		UnknownFields:     unknownFields,
 		CreatedAt:         createdAt,
 	}, nil
 }
 
+func (r *Repository) GetCurrentTableSchema(ctx context.Context, tableID int64) (catalog.TableSchemaVersion, error) {
+	query := `
+SELECT v.table_id, v.schema_version, v.schema_json, v.compatibility_mode, v.unknown_fields, v.created_at
+FROM table_def AS t
+JOIN table_schema_version AS v ON v.table_id = t.table_id AND v.schema_version = t.schema_version
+WHERE t.table_id = $1`
+
+	var version catalog.TableSchemaVersion
+	if err := r.db.QueryRowContext(ctx, query, tableID).Scan(
+		&version.TableID,
+		&version.SchemaVersion,
+		&version.SchemaJSON,
+		&version.CompatibilityMode,
+		&version.UnknownFields,
+		&version.CreatedAt,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return catalog.TableSchemaVersion{}, catalog.ErrNotFound
+		}
+		return catalog.TableSchemaVersion{}, fmt.Errorf("get current table schema: %w", err)
+	}
+	return version, nil
+}
+
 func (r *Repository) InsertIngestEvent(ctx context.Context, in catalog.InsertIngestEventInput) (catalog.InsertIngestEventResult, error) {
 	payload := in.PayloadJSON
 	if len(payload) == 0 {
@@ -387,7 +442,7 @@ LIMIT 1`
 
 func (r *Repository) ListSnapshotFiles(ctx context.Context, tenantID string, snapshotID int64) ([]catalog.SnapshotFileEntry, error) {
 	query := `
-SELECT sf.table_id, td.table_name, sf.file_id, df.path, df.file_size_bytes, df.record_count
+SELECT sf.table_id, td.table_name, td.primary_key_cols, sf.file_id, df.path, df.content, df.file_size_bytes, df.record_count, df.min_event_time, df.max_event_time, df.stats_json, df.partition_values
 FROM snapshot_file AS sf
 JOIN table_def AS td ON td.table_id = sf.table_id
 JOIN data_file AS df ON df.file_id = sf.file_id
@@ -413,16 +468,29 @@ ORDER BY td.table_name ASC, sf.file_id ASC`
 	files := make([]catalog.SnapshotFileEntry, 0)
 	for rows.Next() {
 		var file catalog.SnapshotFileEntry
+		var minEventTime, maxEventTime sql.NullTime
+		var partitionValues []byte
 		if err := rows.Scan(
 			&file.TableID,
 			&file.TableName,
+			&file.PrimaryKeyCols,
 			&file.FileID,
 			&file.Path,
+			&file.Content,
 			&file.FileSizeBytes,
 			&file.RecordCount,
+			&minEventTime,
+			&maxEventTime,
+			&file.StatsJSON,
+			&partitionValues,
 		); err != nil {
 			return nil, fmt.Errorf("scan snapshot file row: %w", err)
 		}
+		if file.PartitionValues, err = decodePartitionValues(partitionValues); err != nil {
+			return nil, err
+		}
+		file.MinEventTime = nullTimePtr(minEventTime)
+		file.MaxEventTime = nullTimePtr(maxEventTime)
 		files = append(files, file)
 	}
 	if err := rows.Err(); err != nil {
@@ -433,7 +501,7 @@ ORDER BY td.table_name ASC, sf.file_id ASC`
 
 func (r *Repository) ListSnapshotFilesForTable(ctx context.Context, tenantID string, snapshotID, tableID int64) ([]catalog.SnapshotFileEntry, error) {
 	query := `
-SELECT sf.table_id, td.table_name, sf.file_id, df.path, df.file_size_bytes, df.record_count
+SELECT sf.table_id, td.table_name, td.primary_key_cols, sf.file_id, df.path, df.content, df.file_size_bytes, df.record_count, df.min_event_time, df.max_event_time, df.stats_json, df.partition_values
 FROM snapshot_file AS sf
 JOIN table_def AS td ON td.table_id = sf.table_id
 JOIN data_file AS df ON df.file_id = sf.file_id
@@ -460,9 +528,16 @@ ORDER BY sf.file_id ASC`
 	files := make([]catalog.SnapshotFileEntry, 0)
 	for rows.Next() {
 		var file catalog.SnapshotFileEntry
-		if err := rows.Scan(&file.TableID, &file.TableName, &file.FileID, &file.Path, &file.FileSizeBytes, &file.RecordCount); err != nil {
+		var minEventTime, maxEventTime sql.NullTime
+		var partitionValues []byte
+		if err := rows.Scan(&file.TableID, &file.TableName, &file.PrimaryKeyCols, &file.FileID, &file.Path, &file.Content, &file.FileSizeBytes, &file.RecordCount, &minEventTime, &maxEventTime, &file.StatsJSON, &partitionValues); err != nil {
 			return nil, fmt.Errorf("scan snapshot table file row: %w", err)
 		}
+		if file.PartitionValues, err = decodePartitionValues(partitionValues); err != nil {
+			return nil, err
+		}
+		file.MinEventTime = nullTimePtr(minEventTime)
+		file.MaxEventTime = nullTimePtr(maxEventTime)
 		files = append(files, file)
 	}
 	if err := rows.Err(); err != nil {
@@ -482,6 +557,8 @@ type RecordGCRunInput struct {
 	DetailsJSON []byte
 }
 
+// ListGCFileCandidates returns files removed before the kept snapshots. Files
+// that a queued or running query job's snapshot still reads are skipped.
 func (r *Repository) ListGCFileCandidates(ctx context.Context, tenantID string, keepSnapshots int, olderThan time.Time) ([]GCFileCandidate, error) {
 	if keepSnapshots < 1 {
 		keepSnapshots = 1
@@ -517,6 +594,15 @@ WHERE lc.rn = 1
   AND lc.change_type = 'remove'
   AND lc.snapshot_id < $2
   AND df.created_at <= $3
+  AND NOT EXISTS (
+      SELECT 1
+      FROM query_job AS qj
+      JOIN snapshot_file AS sf_add ON sf_add.file_id = df.file_id AND sf_add.change_type = 'add'
+      WHERE qj.tenant_id = $1
+        AND qj.state IN ('queued', 'running')
+        AND qj.snapshot_id >= sf_add.snapshot_id
+        AND qj.snapshot_id < lc.snapshot_id
+  )
 ORDER BY df.file_id ASC`, tenantID, minKeepSnapshotID, olderThan.UTC())
 	if err != nil {
 		return nil, fmt.Errorf("list gc candidates: %w", err)
@@ -552,13 +638,15 @@ SELECT
     COALESCE(SUM(CASE WHEN state = 'accepted' THEN 1 ELSE 0 END), 0) AS accepted_events,
     COALESCE(SUM(CASE WHEN state = 'claimed' THEN 1 ELSE 0 END), 0) AS claimed_events,
     MIN(CASE WHEN state IN ('accepted', 'claimed') THEN ingested_at END) AS oldest_pending_ingested_at,
-    COALESCE(MAX(CASE WHEN state IN ('accepted', 'claimed') THEN event_id ELSE NULL END), 0) AS max_pending_token
+    COALESCE(MAX(CASE WHEN state IN ('accepted', 'claimed') THEN event_id ELSE NULL END), 0) AS max_pending_token,
+    COALESCE(SUM(CASE WHEN state = 'failed' AND discarded_at IS NULL THEN 1 ELSE 0 END), 0) AS failed_events
 FROM ingest_event
 WHERE tenant_id = $1`, tenantID).Scan(
 		&stats.AcceptedEvents,
 		&stats.ClaimedEvents,
 		&stats.OldestPendingIngestAt,
 		&stats.MaxPendingToken,
+		&stats.FailedEvents,
 	); err != nil {
 		return catalog.IngestLagStats{}, fmt.Errorf("query ingest lag counters: %w", err)
 	}
@@ -614,6 +702,10 @@ DO UPDATE SET max_visibility_token = GREATEST(
 }
 
 func (r *Repository) RegisterDataFile(ctx context.Context, in catalog.RegisterDataFileInput) (catalog.DataFile, error) {
+	return registerDataFile(ctx, r.db, in)
+}
+
+func registerDataFile(ctx context.Context, q dbTX, in catalog.RegisterDataFileInput) (catalog.DataFile, error) {
 	stats := in.StatsJSON
 	if len(stats) == 0 {
 		stats = []byte("{}")
@@ -622,10 +714,18 @@ func (r *Repository) RegisterDataFile(ctx context.Context, in catalog.RegisterDa
 	if format == "" {
 		format = "parquet"
 	}
+	content := in.Content
+	if content == "" {
+		content = catalog.DataFileContentData
+	}
+	partitionValues, err := encodePartitionValues(in.PartitionValues)
+	if err != nil {
+		return catalog.DataFile{}, err
+	}
 
 	query := `
-INSERT INTO data_file (tenant_id, table_id, path, format, record_count, file_size_bytes, min_event_time, max_event_time, stats_json)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9::jsonb)
+INSERT INTO data_file (tenant_id, table_id, path, format, content, record_count, file_size_bytes, min_event_time, max_event_time, stats_json, partition_values)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10::jsonb, $11::jsonb)
 RETURNING file_id, created_at`
 
 	var file catalog.DataFile
@@ -633,22 +733,26 @@ RETURNING file_id, created_at`
 	file.TableID = in.TableID
 	file.Path = in.Path
 	file.Format = format
+	file.Content = content
 	file.RecordCount = in.RecordCount
 	file.FileSizeBytes = in.FileSizeBytes
 	file.MinEventTime = in.MinEventTime
 	file.MaxEventTime = in.MaxEventTime
 	file.StatsJSON = stats
+	file.PartitionValues = in.PartitionValues
 
-	if err := r.db.QueryRowContext(ctx, query,
+	if err := q.QueryRowContext(ctx, query,
 		in.TenantID,
 		in.TableID,
 		in.Path,
 		format,
+		string(content),
 		in.RecordCount,
 		in.FileSizeBytes,
 		in.MinEventTime,
 		in.MaxEventTime,
 		string(stats),
+		partitionValues,
 	).Scan(&file.FileID, &file.CreatedAt); err != nil {
 		return catalog.DataFile{}, fmt.Errorf("register data file: %w", err)
 	}
@@ -703,6 +807,10 @@ DO UPDATE SET max_visibility_token = GREATEST(
 	return nil
 }
 
+func (r *TxRepository) RegisterDataFile(ctx context.Context, in catalog.RegisterDataFileInput) (catalog.DataFile, error) {
+	return registerDataFile(ctx, r.q, in)
+}
+
 func (r *TxRepository) AddSnapshotFile(ctx context.Context, in catalog.AddSnapshotFileInput) error {
 	query := `
 INSERT INTO snapshot_file (snapshot_id, table_id, file_id, change_type)
@@ -732,3 +840,33 @@ func scanSnapshot(row *sql.Row) (catalog.Snapshot, error) {
 	}
 	return snapshot, nil
 }
+
+func encodePartitionValues(values map[string]string) (string, error) {
+	if len(values) == 0 {
+		return "{}", nil
+	}
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("encode partition values: %w", err)
+	}
+	return string(encoded), nil
+}
+
+func nullTimePtr(value sql.NullTime) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	at := value.Time.UTC()
+	return &at
+}
+
+func decodePartitionValues(raw []byte) (map[string]string, error) {
+	values := map[string]string{}
+	if len(raw) == 0 {
+		return values, nil
+	}
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, fmt.Errorf("decode partition values: %w", err)
+	}
+	return values, nil
+}
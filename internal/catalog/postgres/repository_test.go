@@ -95,6 +95,36 @@ WHERE tenant_id = $1`)).
 	assertSQLMock(t, mock)
 }
 
+func TestGetCurrentTableSchema(t *testing.T) {
+	db, mock := newSQLMock(t)
+	repo := NewRepository(db)
+	now := time.Now().UTC()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+SELECT v.table_id, v.schema_version, v.schema_json, v.compatibility_mode, v.unknown_fields, v.created_at
+FROM table_def AS t
+JOIN table_schema_version AS v ON v.table_id = t.table_id AND v.schema_version = t.schema_version
+WHERE t.table_id = $1`)).
+		WithArgs(int64(22)).
+		WillReturnRows(sqlmock.NewRows([]string{"table_id", "schema_version", "schema_json", "compatibility_mode", "unknown_fields", "created_at"}).
+			AddRow(int64(22), 3, []byte(`{"id":"bigint"}`), "backward", "reject", now))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM table_def AS t`)).
+		WithArgs(int64(23)).
+		WillReturnError(sql.ErrNoRows)
+
+	version, err := repo.GetCurrentTableSchema(context.Background(), 22)
+	if err != nil {
+		t.Fatalf("GetCurrentTableSchema() error = %v", err)
+	}
+	if version.SchemaVersion != 3 || string(version.SchemaJSON) != `{"id":"bigint"}` || version.UnknownFields != "reject" {
+		t.Fatalf("version = %+v", version)
+	}
+	if _, err := repo.GetCurrentTableSchema(context.Background(), 23); !errors.Is(err, catalog.ErrNotFound) {
+		t.Fatalf("error = %v, want %v", err, catalog.ErrNotFound)
+	}
+	assertSQLMock(t, mock)
+}
+
 func TestDeleteTableByName(t *testing.T) {
 	db, mock := newSQLMock(t)
 	repo := NewRepository(db)
@@ -186,6 +216,38 @@ VALUES ($1, $2, $3::jsonb, NOW())`)).
 	assertSQLMock(t, mock)
 }
 
+func TestListGCFileCandidatesSkipsFilesPinnedByQueryJobs(t *testing.T) {
+	db, mock := newSQLMock(t)
+	repo := NewRepository(db)
+	olderThan := time.Date(2026, 2, 19, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM snapshot
+WHERE tenant_id = $1`)).
+		WithArgs("tenant-1", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"snapshot_id"}).AddRow(int64(12)))
+	mock.ExpectQuery(regexp.QuoteMeta(`
+  AND NOT EXISTS (
+      SELECT 1
+      FROM query_job AS qj
+      JOIN snapshot_file AS sf_add ON sf_add.file_id = df.file_id AND sf_add.change_type = 'add'
+      WHERE qj.tenant_id = $1
+        AND qj.state IN ('queued', 'running')
+        AND qj.snapshot_id >= sf_add.snapshot_id
+        AND qj.snapshot_id < lc.snapshot_id
+  )`)).
+		WithArgs("tenant-1", int64(12), olderThan).
+		WillReturnRows(sqlmock.NewRows([]string{"file_id", "path"}).AddRow(int64(3), "tenant-1/events/part-3.parquet"))
+
+	candidates, err := repo.ListGCFileCandidates(context.Background(), "tenant-1", 1, olderThan)
+	if err != nil {
+		t.Fatalf("ListGCFileCandidates() error = %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].FileID != 3 {
+		t.Fatalf("candidates = %+v", candidates)
+	}
+	assertSQLMock(t, mock)
+}
+
 func TestListSnapshots(t *testing.T) {
 	db, mock := newSQLMock(t)
 	repo := NewRepository(db)
@@ -231,12 +293,13 @@ SELECT
     COALESCE(SUM(CASE WHEN state = 'accepted' THEN 1 ELSE 0 END), 0) AS accepted_events,
     COALESCE(SUM(CASE WHEN state = 'claimed' THEN 1 ELSE 0 END), 0) AS claimed_events,
     MIN(CASE WHEN state IN ('accepted', 'claimed') THEN ingested_at END) AS oldest_pending_ingested_at,
-    COALESCE(MAX(CASE WHEN state IN ('accepted', 'claimed') THEN event_id ELSE NULL END), 0) AS max_pending_token
+    COALESCE(MAX(CASE WHEN state IN ('accepted', 'claimed') THEN event_id ELSE NULL END), 0) AS max_pending_token,
+    COALESCE(SUM(CASE WHEN state = 'failed' AND discarded_at IS NULL THEN 1 ELSE 0 END), 0) AS failed_events
 FROM ingest_event
 WHERE tenant_id = $1`)).
 		WithArgs("tenant-1").
-		WillReturnRows(sqlmock.NewRows([]string{"accepted_events", "claimed_events", "oldest_pending_ingested_at", "max_pending_token"}).
-			AddRow(int64(4), int64(1), oldest, int64(42)))
+		WillReturnRows(sqlmock.NewRows([]string{"accepted_events", "claimed_events", "oldest_pending_ingested_at", "max_pending_token", "failed_events"}).
+			AddRow(int64(4), int64(1), oldest, int64(42), int64(2)))
 
 	mock.ExpectQuery(regexp.QuoteMeta(`
 SELECT snapshot_id, max_visibility_token, created_at
@@ -252,8 +315,8 @@ LIMIT 1`)).
 	if err != nil {
 		t.Fatalf("GetIngestLagStats() error = %v", err)
 	}
-	if stats.AcceptedEvents != 4 || stats.ClaimedEvents != 1 {
-		t.Fatalf("counts = accepted:%d claimed:%d", stats.AcceptedEvents, stats.ClaimedEvents)
+	if stats.AcceptedEvents != 4 || stats.ClaimedEvents != 1 || stats.FailedEvents != 2 {
+		t.Fatalf("counts = accepted:%d claimed:%d failed:%d", stats.AcceptedEvents, stats.ClaimedEvents, stats.FailedEvents)
 	}
 	if stats.MaxPendingToken != 42 || stats.LatestVisibilityToken != 39 {
 		t.Fatalf("tokens = pending:%d latest:%d", stats.MaxPendingToken, stats.LatestVisibilityToken)
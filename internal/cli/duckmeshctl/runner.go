@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
 	"strings"
 	"time"
 )
@@ -38,7 +40,7 @@ func Run(ctx context.Context, args []string, defaults Options) int {
 	baseURL := fs.String("base-url", firstNonEmpty(defaults.BaseURL, "http://localhost:8080"), "DuckMesh API base URL")
 	apiKey := fs.String("api-key", defaults.APIKey, "API key for authenticated requests")
 	tenantID := fs.String("tenant-id", defaults.TenantID, "Tenant ID header (used when auth is disabled)")
-	timeout := fs.Duration("timeout", durationOr(defaults.Timeout, 10*time.Second), "HTTP timeout (e.g. 10s)")
+	timeout := fs.Duration("timeout", durationOr(defaults.Timeout, 10*time.Second), "HTTP timeout (e.g. 10s); bulk-upload is not limited")
 
 	if err := fs.Parse(args); err != nil {
 		return 2
@@ -54,8 +56,11 @@ func Run(ctx context.Context, args []string, defaults Options) int {
 	}
 
 	command := strings.TrimSpace(fs.Arg(0))
+	commandArgs := fs.Args()[1:]
 	method := ""
 	path := ""
+	var body io.Reader
+	contentType := ""
 	switch command {
 	case "health":
 		method, path = http.MethodGet, "/v1/health"
@@ -69,6 +74,68 @@ func Run(ctx context.Context, args []string, defaults Options) int {
 		method, path = http.MethodPost, "/v1/retention/run"
 	case "integrity-run":
 		method, path = http.MethodPost, "/v1/integrity/run"
+	case "failed":
+		if len(commandArgs) > 1 {
+			_, _ = fmt.Fprintln(stderr, "failed takes at most one table")
+			return 2
+		}
+		method, path = http.MethodGet, "/v1/ingest/failed"
+		if len(commandArgs) == 1 {
+			path += "?table=" + url.QueryEscape(commandArgs[0])
+		}
+	case "replay", "discard":
+		if len(commandArgs) == 0 {
+			_, _ = fmt.Fprintf(stderr, "%s requires at least one event id\n", command)
+			return 2
+		}
+		payload, err := json.Marshal(map[string]any{"event_ids": commandArgs})
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "encode %s request: %v\n", command, err)
+			return 1
+		}
+		method, path = http.MethodPost, "/v1/ingest/failed/"+command
+		body, contentType = bytes.NewReader(payload), "application/json"
+	case "bulk-load":
+		if len(commandArgs) < 2 {
+			_, _ = fmt.Fprintln(stderr, "bulk-load requires a table and at least one object path")
+			return 2
+		}
+		payload, err := bulkLoadPayload(commandArgs[1:])
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "encode bulk load request: %v\n", err)
+			return 1
+		}
+		method, path = http.MethodPost, "/v1/tables/"+url.PathEscape(commandArgs[0])+"/bulk-load"
+		body, contentType = bytes.NewReader(payload), "application/json"
+	case "query":
+		if len(commandArgs) < 1 {
+			_, _ = fmt.Fprintln(stderr, "query requires a SQL statement")
+			return 2
+		}
+		payload, err := queryPayload(commandArgs[0], commandArgs[1:])
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "encode query request: %v\n", err)
+			return 2
+		}
+		method, path = http.MethodPost, "/v1/query"
+		body, contentType = bytes.NewReader(payload), "application/json"
+	case "bulk-upload":
+		if len(commandArgs) != 2 {
+			_, _ = fmt.Fprintln(stderr, "bulk-upload requires a table and a local parquet file")
+			return 2
+		}
+		file, err := os.Open(commandArgs[1])
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "open parquet file: %v\n", err)
+			return 1
+		}
+		defer func() { _ = file.Close() }()
+		if defaults.HTTPClient == nil {
+			// Large uploads outlast -timeout; ctx still cancels them.
+			client = &http.Client{}
+		}
+		method, path = http.MethodPost, "/v1/tables/"+url.PathEscape(commandArgs[0])+"/bulk-load"
+		body, contentType = file, "application/vnd.apache.parquet"
 	default:
 		_, _ = fmt.Fprintf(stderr, "unknown command %q\n\n", command)
 		writeUsage(stderr)
@@ -76,7 +143,7 @@ func Run(ctx context.Context, args []string, defaults Options) int {
 	}
 
 	endpoint := strings.TrimRight(*baseURL, "/") + path
-	code, responseBody, err := doRequest(ctx, client, method, endpoint, *apiKey, *tenantID)
+	code, responseBody, err := doRequest(ctx, client, method, endpoint, *apiKey, *tenantID, body, contentType)
 	if err != nil {
 		_, _ = fmt.Fprintf(stderr, "request failed: %v\n", err)
 		return 1
@@ -97,12 +164,15 @@ func Run(ctx context.Context, args []string, defaults Options) int {
 	return 0
 }
 
-func doRequest(ctx context.Context, client *http.Client, method, url, apiKey, tenantID string) (int, []byte, error) {
-	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+func doRequest(ctx context.Context, client *http.Client, method, endpoint, apiKey, tenantID string, body io.Reader, contentType string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
 	if err != nil {
 		return 0, nil, err
 	}
 	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
 	if strings.TrimSpace(apiKey) != "" {
 		req.Header.Set("X-API-Key", strings.TrimSpace(apiKey))
 	}
@@ -116,11 +186,64 @@ func doRequest(ctx context.Context, client *http.Client, method, url, apiKey, te
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	body, err := io.ReadAll(resp.Body)
+	responseBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return 0, nil, err
 	}
-	return resp.StatusCode, body, nil
+	return resp.StatusCode, responseBody, nil
+}
+
+func bulkLoadPayload(objectPaths []string) ([]byte, error) {
+	files := make([]map[string]string, 0, len(objectPaths))
+	for _, objectPath := range objectPaths {
+		files = append(files, map[string]string{"path": objectPath})
+	}
+	return json.Marshal(map[string]any{"files": files})
+}
+
+// queryPayload builds a query request. Params of the form name=value are
+// named, all others positional. Values that are valid JSON are sent as JSON,
+// so typed values like {"type":"timestamp","value":"..."} work; anything
+// else is sent as a string.
+func queryPayload(sqlText string, args []string) ([]byte, error) {
+	named := map[string]json.RawMessage{}
+	positional := make([]json.RawMessage, 0, len(args))
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || !isParamName(name) {
+			positional = append(positional, paramJSON(arg))
+			continue
+		}
+		named[name] = paramJSON(value)
+	}
+
+	request := map[string]any{"sql": sqlText}
+	switch {
+	case len(named) > 0 && len(positional) > 0:
+		return nil, fmt.Errorf("named (name=value) and positional params cannot be mixed")
+	case len(named) > 0:
+		request["params"] = named
+	case len(positional) > 0:
+		request["params"] = positional
+	}
+	return json.Marshal(request)
+}
+
+func isParamName(value string) bool {
+	for i, r := range value {
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return value != ""
+}
+
+func paramJSON(value string) json.RawMessage {
+	if json.Valid([]byte(value)) {
+		return json.RawMessage(value)
+	}
+	encoded, _ := json.Marshal(value)
+	return encoded
 }
 
 func prettyJSON(raw []byte) (string, bool) {
@@ -139,7 +262,7 @@ func prettyJSON(raw []byte) (string, bool) {
 }
 
 func writeUsage(w io.Writer) {
-	_, _ = fmt.Fprintln(w, "usage: duckmeshctl [flags] <command>")
+	_, _ = fmt.Fprintln(w, "usage: duckmeshctl [flags] <command> [args]")
 	_, _ = fmt.Fprintln(w, "")
 	_, _ = fmt.Fprintln(w, "commands:")
 	_, _ = fmt.Fprintln(w, "  health           GET /v1/health")
@@ -148,6 +271,17 @@ func writeUsage(w io.Writer) {
 	_, _ = fmt.Fprintln(w, "  compaction-run   POST /v1/compaction/run")
 	_, _ = fmt.Fprintln(w, "  retention-run    POST /v1/retention/run")
 	_, _ = fmt.Fprintln(w, "  integrity-run    POST /v1/integrity/run")
+	_, _ = fmt.Fprintln(w, "  failed [table]   GET /v1/ingest/failed")
+	_, _ = fmt.Fprintln(w, "  replay <event-id>...")
+	_, _ = fmt.Fprintln(w, "                   POST /v1/ingest/failed/replay")
+	_, _ = fmt.Fprintln(w, "  discard <event-id>...")
+	_, _ = fmt.Fprintln(w, "                   POST /v1/ingest/failed/discard")
+	_, _ = fmt.Fprintln(w, "  bulk-load <table> <object-path>...")
+	_, _ = fmt.Fprintln(w, "                   POST /v1/tables/{table}/bulk-load with object store paths")
+	_, _ = fmt.Fprintln(w, "  query <sql> [name=value...|value...]")
+	_, _ = fmt.Fprintln(w, "                   POST /v1/query with named or positional params (JSON or text)")
+	_, _ = fmt.Fprintln(w, "  bulk-upload <table> <file.parquet>")
+	_, _ = fmt.Fprintln(w, "                   POST /v1/tables/{table}/bulk-load with a local parquet file")
 }
 
 func firstNonEmpty(a, b string) string {
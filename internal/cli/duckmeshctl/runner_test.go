@@ -3,8 +3,11 @@ package duckmeshctl
 import (
 	"bytes"
 	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -83,6 +86,130 @@ func TestRunIntegrityCommand(t *testing.T) {
 	}
 }
 
+func TestRunFailedEventCommands(t *testing.T) {
+	var gotMethod, gotURI, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotURI = r.URL.RequestURI()
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		_, _ = w.Write([]byte(`{"count":0}`))
+	}))
+	defer srv.Close()
+
+	if code := Run(context.Background(), []string{"-base-url", srv.URL, "failed", "click events"}, Options{}); code != 0 {
+		t.Fatalf("failed exit code = %d", code)
+	}
+	if gotMethod != http.MethodGet || gotURI != "/v1/ingest/failed?table=click+events" {
+		t.Fatalf("request = %s %s", gotMethod, gotURI)
+	}
+
+	if code := Run(context.Background(), []string{"-base-url", srv.URL, "replay", "41", "42"}, Options{}); code != 0 {
+		t.Fatalf("replay exit code = %d", code)
+	}
+	if gotMethod != http.MethodPost || gotURI != "/v1/ingest/failed/replay" || gotBody != `{"event_ids":["41","42"]}` {
+		t.Fatalf("request = %s %s %s", gotMethod, gotURI, gotBody)
+	}
+
+	if code := Run(context.Background(), []string{"-base-url", srv.URL, "discard", "43"}, Options{}); code != 0 {
+		t.Fatalf("discard exit code = %d", code)
+	}
+	if gotURI != "/v1/ingest/failed/discard" || gotBody != `{"event_ids":["43"]}` {
+		t.Fatalf("request = %s %s", gotURI, gotBody)
+	}
+
+	if code := Run(context.Background(), []string{"-base-url", srv.URL, "replay"}, Options{}); code != 2 {
+		t.Fatalf("replay without ids exit code = %d", code)
+	}
+}
+
+func TestRunBulkLoadCommand(t *testing.T) {
+	var gotPath, gotContentType, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotContentType = r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		_, _ = w.Write([]byte(`{"snapshot_id":7}`))
+	}))
+	defer srv.Close()
+
+	code := Run(context.Background(), []string{"-base-url", srv.URL, "bulk-load", "events", "t1/a.parquet", "t1/b.parquet"}, Options{})
+	if code != 0 {
+		t.Fatalf("exit code = %d", code)
+	}
+	if gotPath != "/v1/tables/events/bulk-load" || gotContentType != "application/json" {
+		t.Fatalf("request path=%q content-type=%q", gotPath, gotContentType)
+	}
+	if gotBody != `{"files":[{"path":"t1/a.parquet"},{"path":"t1/b.parquet"}]}` {
+		t.Fatalf("body = %s", gotBody)
+	}
+}
+
+func TestRunQueryCommandSendsParams(t *testing.T) {
+	var gotPath string
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		_, _ = w.Write([]byte(`{"columns":["c"],"rows":[[1]]}`))
+	}))
+	defer srv.Close()
+
+	code := Run(context.Background(), []string{"-base-url", srv.URL, "query", "SELECT * FROM events WHERE id = $id AND region = $region AND at > $since",
+		"id=42", "region=eu", `since={"type":"timestamp","value":"2026-02-19T00:00:00Z"}`}, Options{})
+	if code != 0 {
+		t.Fatalf("exit code = %d", code)
+	}
+	want := `{"params":{"id":42,"region":"eu","since":{"type":"timestamp","value":"2026-02-19T00:00:00Z"}},"sql":"SELECT * FROM events WHERE id = $id AND region = $region AND at \u003e $since"}`
+	if gotPath != "/v1/query" || bodies[0] != want {
+		t.Fatalf("path = %q, body = %s", gotPath, bodies[0])
+	}
+
+	if code := Run(context.Background(), []string{"-base-url", srv.URL, "query", "SELECT $1, $2", "eu west", "true"}, Options{}); code != 0 {
+		t.Fatalf("positional exit code = %d", code)
+	}
+	if bodies[1] != `{"params":["eu west",true],"sql":"SELECT $1, $2"}` {
+		t.Fatalf("positional body = %s", bodies[1])
+	}
+
+	if code := Run(context.Background(), []string{"-base-url", srv.URL, "query", "SELECT $1, $x", "1", "x=2"}, Options{}); code != 2 {
+		t.Fatalf("mixed params exit code = %d", code)
+	}
+}
+
+func TestRunBulkUploadCommand(t *testing.T) {
+	localPath := filepath.Join(t.TempDir(), "events.parquet")
+	if err := os.WriteFile(localPath, []byte("PAR1-data"), 0o600); err != nil {
+		t.Fatalf("write parquet file: %v", err)
+	}
+
+	var gotContentType, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotContentType = r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		time.Sleep(100 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"snapshot_id":7}`))
+	}))
+	defer srv.Close()
+
+	// The upload outlasts -timeout, which only applies to the other commands.
+	code := Run(context.Background(), []string{"-base-url", srv.URL, "-timeout", "20ms", "bulk-upload", "events", localPath}, Options{})
+	if code != 0 {
+		t.Fatalf("exit code = %d", code)
+	}
+	if gotContentType != "application/vnd.apache.parquet" || gotBody != "PAR1-data" {
+		t.Fatalf("content-type=%q body=%q", gotContentType, gotBody)
+	}
+
+	code = Run(context.Background(), []string{"-base-url", srv.URL, "bulk-upload", "events"}, Options{})
+	if code != 2 {
+		t.Fatalf("missing file exit code = %d", code)
+	}
+}
+
 func TestRunReturnsErrorOnHTTPFailure(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusForbidden)
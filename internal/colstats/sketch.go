@@ -0,0 +1,85 @@
+package colstats
+
+import (
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// sketchPrecision gives 256 one-byte HyperLogLog registers, about 6.5%
+// standard error.
+const (
+	sketchPrecision = 8
+	sketchRegisters = 1 << sketchPrecision
+)
+
+// sketch is a HyperLogLog distinct-value estimator. Hashing is deterministic
+// so sketches written by different processes can be merged.
+type sketch []uint8
+
+func newSketch() sketch {
+	return make(sketch, sketchRegisters)
+}
+
+func (s sketch) add(key string) {
+	hasher := fnv.New64a()
+	_, _ = hasher.Write([]byte(key))
+	hash := mix64(hasher.Sum64())
+
+	index := hash >> (64 - sketchPrecision)
+	rank := uint8(bits.LeadingZeros64(hash<<sketchPrecision|1<<(sketchPrecision-1)) + 1)
+	if rank > s[index] {
+		s[index] = rank
+	}
+}
+
+func (s sketch) merge(other sketch) {
+	for i := range s {
+		s[i] = max(s[i], other[i])
+	}
+}
+
+func (s sketch) estimate() int64 {
+	sum := 0.0
+	zeros := 0
+	for _, register := range s {
+		sum += math.Ldexp(1, -int(register))
+		if register == 0 {
+			zeros++
+		}
+	}
+	m := float64(sketchRegisters)
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(estimate))
+}
+
+func (s sketch) encode() string {
+	return base64.RawStdEncoding.EncodeToString(s)
+}
+
+func decodeSketch(encoded string) (sketch, error) {
+	raw, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode sketch: %w", err)
+	}
+	if len(raw) != sketchRegisters {
+		return nil, fmt.Errorf("sketch has %d registers, want %d", len(raw), sketchRegisters)
+	}
+	return sketch(raw), nil
+}
+
+// mix64 is the splitmix64 finalizer; FNV alone spreads short keys poorly
+// across the high bits used for register selection.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
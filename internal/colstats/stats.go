@@ -0,0 +1,286 @@
+package colstats
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/tableschema"
+)
+
+// maxBoundBytes caps recorded string bounds; longer values leave the column
+// without min/max.
+const maxBoundBytes = 256
+
+const timestampBoundLayout = "2006-01-02T15:04:05.000000Z"
+
+// Column holds the statistics of one column of a data file. Min and Max are
+// JSON numbers, booleans or strings (timestamps as RFC 3339 UTC with
+// microseconds, dates as YYYY-MM-DD) and are omitted for json columns.
+type Column struct {
+	Type          tableschema.Type `json:"type"`
+	Min           any              `json:"min,omitempty"`
+	Max           any              `json:"max,omitempty"`
+	NullCount     int64            `json:"null_count"`
+	DistinctCount int64            `json:"distinct_count"`
+	// Sketch is a base64 HyperLogLog of the non-null values, kept so the
+	// distinct estimates of several files can be merged.
+	Sketch string `json:"sketch,omitempty"`
+}
+
+// Stats is the data_file.stats_json document.
+type Stats struct {
+	EventCount int64             `json:"event_count"`
+	Columns    map[string]Column `json:"columns,omitempty"`
+}
+
+// Parse reads a stats_json document. Keys other than event_count and columns
+// are ignored.
+func Parse(raw []byte) (Stats, error) {
+	var stats Stats
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return stats, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&stats); err != nil {
+		return Stats{}, fmt.Errorf("decode stats: %w", err)
+	}
+	return stats, nil
+}
+
+// Collector accumulates column statistics while rows are encoded.
+type Collector struct {
+	fields  []tableschema.Field
+	columns []*columnState
+}
+
+type columnState struct {
+	min, max  any
+	unbounded bool
+	values    int64
+	nulls     int64
+	sketch    sketch
+}
+
+func NewCollector(schema tableschema.Schema) *Collector {
+	collector := &Collector{fields: schema.Fields}
+	for range schema.Fields {
+		collector.columns = append(collector.columns, &columnState{sketch: newSketch()})
+	}
+	return collector
+}
+
+// Add records one row. values holds the coerced value of every schema field,
+// in schema order, with nil for nulls.
+func (c *Collector) Add(values []any) {
+	for i, field := range c.fields {
+		state := c.columns[i]
+		var value any
+		if i < len(values) {
+			value = normalize(field.Type, values[i])
+		}
+		if value == nil {
+			state.nulls++
+			continue
+		}
+		state.values++
+		state.sketch.add(fmt.Sprint(value))
+		if field.Type == tableschema.TypeJSON || state.unbounded {
+			continue
+		}
+		if text, ok := value.(string); ok && len(text) > maxBoundBytes {
+			state.unbounded = true
+			state.min, state.max = nil, nil
+			continue
+		}
+		if order, ok := compareBounds(field.Type, value, state.min); state.min == nil || ok && order < 0 {
+			state.min = value
+		}
+		if order, ok := compareBounds(field.Type, value, state.max); state.max == nil || ok && order > 0 {
+			state.max = value
+		}
+	}
+}
+
+// Columns returns the statistics collected so far, keyed by field name.
+func (c *Collector) Columns() map[string]Column {
+	columns := make(map[string]Column, len(c.fields))
+	for i, field := range c.fields {
+		state := c.columns[i]
+		columns[field.Name] = Column{
+			Type:          field.Type,
+			Min:           state.min,
+			Max:           state.max,
+			NullCount:     state.nulls,
+			DistinctCount: min(state.sketch.estimate(), state.values),
+			Sketch:        state.sketch.encode(),
+		}
+	}
+	return columns
+}
+
+// Part is the row count and column statistics of one file.
+type Part struct {
+	Rows    int64
+	Columns map[string]Column
+}
+
+// Merge combines the column statistics of several files, given oldest first.
+// A column a file does not have counts as null for all of its rows, as when
+// files are read by column name. It returns nil when any file has no column
+// statistics.
+func Merge(parts []Part) map[string]Column {
+	names := map[string]bool{}
+	for _, part := range parts {
+		if part.Columns == nil {
+			return nil
+		}
+		for name := range part.Columns {
+			names[name] = true
+		}
+	}
+
+	merged := make(map[string]Column, len(names))
+	for name := range names {
+		var result Column
+		var rows int64
+		combined := newSketch()
+		sketched, bounded, typed := true, true, true
+		for _, part := range parts {
+			rows += part.Rows
+			column, ok := part.Columns[name]
+			if !ok {
+				result.NullCount += part.Rows
+				continue
+			}
+			if result.Type != "" && result.Type != column.Type {
+				typed = false
+			}
+			result.Type = column.Type
+			result.NullCount += column.NullCount
+			result.DistinctCount = max(result.DistinctCount, column.DistinctCount)
+
+			if column.Min == nil && column.NullCount < part.Rows {
+				bounded = false
+			}
+			if column.Min != nil && bounded {
+				if order, ok := compareBounds(column.Type, column.Min, result.Min); result.Min == nil || ok && order < 0 {
+					result.Min = column.Min
+				} else if !ok {
+					bounded = false
+				}
+				if order, ok := compareBounds(column.Type, column.Max, result.Max); result.Max == nil || ok && order > 0 {
+					result.Max = column.Max
+				} else if !ok {
+					bounded = false
+				}
+			}
+
+			if column.NullCount < part.Rows {
+				decoded, err := decodeSketch(column.Sketch)
+				if err != nil {
+					sketched = false
+					continue
+				}
+				combined.merge(decoded)
+			}
+		}
+		if !bounded || !typed {
+			result.Min, result.Max = nil, nil
+		}
+		if sketched {
+			result.DistinctCount = combined.estimate()
+			result.Sketch = combined.encode()
+		}
+		result.DistinctCount = min(result.DistinctCount, rows-result.NullCount)
+		merged[name] = result
+	}
+	return merged
+}
+
+func normalize(fieldType tableschema.Type, value any) any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case int32:
+		return int64(v)
+	case time.Time:
+		if fieldType == tableschema.TypeDate {
+			return v.UTC().Format(time.DateOnly)
+		}
+		return v.UTC().Format(timestampBoundLayout)
+	}
+	return value
+}
+
+// compareBounds orders two bounds of a column. Values may be Go values from a
+// Collector or json.Number values from Parse. ok is false when either value
+// does not fit the column type.
+func compareBounds(fieldType tableschema.Type, a, b any) (int, bool) {
+	switch fieldType {
+	case tableschema.TypeBigint, tableschema.TypeInteger:
+		x, okA := boundInt(a)
+		y, okB := boundInt(b)
+		if !okA || !okB {
+			return 0, false
+		}
+		return cmp.Compare(x, y), true
+	case tableschema.TypeDouble:
+		x, okA := boundFloat(a)
+		y, okB := boundFloat(b)
+		if !okA || !okB {
+			return 0, false
+		}
+		return cmp.Compare(x, y), true
+	case tableschema.TypeBoolean:
+		x, okA := a.(bool)
+		y, okB := b.(bool)
+		if !okA || !okB {
+			return 0, false
+		}
+		if x == y {
+			return 0, true
+		}
+		if !x {
+			return -1, true
+		}
+		return 1, true
+	case tableschema.TypeVarchar, tableschema.TypeTimestamp, tableschema.TypeDate:
+		x, okA := a.(string)
+		y, okB := b.(string)
+		if !okA || !okB {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	}
+	return 0, false
+}
+
+func boundInt(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case json.Number:
+		parsed, err := strconv.ParseInt(v.String(), 10, 64)
+		return parsed, err == nil
+	}
+	return 0, false
+}
+
+func boundFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		parsed, err := v.Float64()
+		return parsed, err == nil
+	}
+	return 0, false
+}
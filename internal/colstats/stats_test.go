@@ -0,0 +1,97 @@
+package colstats
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/tableschema"
+)
+
+func TestCollectorRecordsBoundsNullsAndDistinct(t *testing.T) {
+	schema := tableschema.Schema{Fields: []tableschema.Field{
+		{Name: "id", Type: tableschema.TypeBigint},
+		{Name: "name", Type: tableschema.TypeVarchar},
+		{Name: "seen", Type: tableschema.TypeTimestamp},
+		{Name: "doc", Type: tableschema.TypeJSON},
+	}}
+	collector := NewCollector(schema)
+	collector.Add([]any{int64(5), "bob", time.Date(2026, 2, 19, 9, 0, 0, 0, time.UTC), `{"a":1}`})
+	collector.Add([]any{int64(-2), "alice", nil, nil})
+	collector.Add([]any{int64(5), nil, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), `{"a":2}`})
+
+	columns := collector.Columns()
+	id := columns["id"]
+	if id.Min != int64(-2) || id.Max != int64(5) || id.NullCount != 0 || id.DistinctCount != 2 {
+		t.Fatalf("id stats = %+v", id)
+	}
+	name := columns["name"]
+	if name.Min != "alice" || name.Max != "bob" || name.NullCount != 1 || name.DistinctCount != 2 {
+		t.Fatalf("name stats = %+v", name)
+	}
+	if seen := columns["seen"]; seen.Min != "2025-01-01T00:00:00.000000Z" || seen.Max != "2026-02-19T09:00:00.000000Z" {
+		t.Fatalf("seen stats = %+v", seen)
+	}
+	if doc := columns["doc"]; doc.Min != nil || doc.Max != nil || doc.NullCount != 1 || doc.DistinctCount != 2 {
+		t.Fatalf("doc stats = %+v", doc)
+	}
+}
+
+func TestCollectorDropsLongStringBounds(t *testing.T) {
+	collector := NewCollector(tableschema.Schema{Fields: []tableschema.Field{{Name: "body", Type: tableschema.TypeVarchar}}})
+	collector.Add([]any{"short"})
+	collector.Add([]any{strings.Repeat("x", maxBoundBytes+1)})
+	collector.Add([]any{"a"})
+
+	body := collector.Columns()["body"]
+	if body.Min != nil || body.Max != nil || body.DistinctCount != 3 {
+		t.Fatalf("body stats = %+v", body)
+	}
+}
+
+func TestMergeRoundTripsThroughStatsJSON(t *testing.T) {
+	schema := tableschema.Schema{Fields: []tableschema.Field{
+		{Name: "id", Type: tableschema.TypeBigint},
+		{Name: "score", Type: tableschema.TypeDouble},
+	}}
+	first := NewCollector(schema)
+	for i := range 1000 {
+		first.Add([]any{int64(i), float64(i) / 2})
+	}
+	second := NewCollector(tableschema.Schema{Fields: schema.Fields[:1]})
+	for i := 500; i < 1500; i++ {
+		second.Add([]any{int64(i)})
+	}
+
+	parts := make([]Part, 0, 2)
+	for _, collector := range []*Collector{first, second} {
+		encoded, err := json.Marshal(Stats{EventCount: 1000, Columns: collector.Columns()})
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		stats, err := Parse(encoded)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		parts = append(parts, Part{Rows: stats.EventCount, Columns: stats.Columns})
+	}
+
+	merged := Merge(parts)
+	id := merged["id"]
+	if fmt.Sprintf("%v %v", id.Min, id.Max) != "0 1499" || id.NullCount != 0 {
+		t.Fatalf("merged id = %+v", id)
+	}
+	if id.DistinctCount < 1300 || id.DistinctCount > 1700 {
+		t.Fatalf("merged id distinct = %d, want about 1500", id.DistinctCount)
+	}
+	score := merged["score"]
+	if fmt.Sprintf("%v %v", score.Min, score.Max) != "0 499.5" || score.NullCount != 1000 {
+		t.Fatalf("merged score = %+v", score)
+	}
+
+	if Merge([]Part{parts[0], {Rows: 3}}) != nil {
+		t.Fatal("Merge() with a file without column stats should be nil")
+	}
+}
@@ -24,15 +24,27 @@ func TestLoadDefaultsForDevProfile(t *testing.T) {
 	if cfg.Auth.Required {
 		t.Fatal("Auth.Required should default to false in dev")
 	}
+	if cfg.Bus.Backend != BusBackendPostgres || cfg.ObjectStore.Backend != ObjectStoreBackendS3 {
+		t.Fatalf("backends = %q/%q", cfg.Bus.Backend, cfg.ObjectStore.Backend)
+	}
 	if cfg.ObjectStore.Endpoint != "localhost:9000" {
 		t.Fatalf("ObjectStore.Endpoint = %q", cfg.ObjectStore.Endpoint)
 	}
 	if cfg.Catalog.MaxOpenConns != 20 {
 		t.Fatalf("Catalog.MaxOpenConns = %d", cfg.Catalog.MaxOpenConns)
 	}
+	if !cfg.Catalog.NotifyEnabled || cfg.Catalog.NotifyFallbackPoll != 2*time.Second {
+		t.Fatalf("Catalog notify = %v/%s", cfg.Catalog.NotifyEnabled, cfg.Catalog.NotifyFallbackPoll)
+	}
 	if cfg.Coordinator.ClaimLimit != 500 {
 		t.Fatalf("Coordinator.ClaimLimit = %d", cfg.Coordinator.ClaimLimit)
 	}
+	if cfg.Coordinator.Workers < 1 || cfg.Bus.ClaimFairness != ClaimFairnessFIFO {
+		t.Fatalf("Coordinator.Workers = %d, Bus.ClaimFairness = %q", cfg.Coordinator.Workers, cfg.Bus.ClaimFairness)
+	}
+	if cfg.Ingest.StreamChunkRecords != 500 {
+		t.Fatalf("Ingest.StreamChunkRecords = %d", cfg.Ingest.StreamChunkRecords)
+	}
 	if cfg.Maintenance.CompactionMinInputFiles != 4 {
 		t.Fatalf("Maintenance.CompactionMinInputFiles = %d", cfg.Maintenance.CompactionMinInputFiles)
 	}
@@ -87,8 +99,17 @@ func TestLoadWithEnvOverrides(t *testing.T) {
 		"DUCKMESH_CATALOG_DSN":                            "postgres://example",
 		"DUCKMESH_CATALOG_MAX_OPEN_CONNS":                 "42",
 		"DUCKMESH_CATALOG_MAX_IDLE_CONNS":                 "17",
+		"DUCKMESH_CATALOG_NOTIFY_ENABLED":                 "false",
+		"DUCKMESH_CATALOG_NOTIFY_FALLBACK_POLL":           "750ms",
 		"DUCKMESH_SERVICE_NAME":                           "duckmesh-custom",
 		"DUCKMESH_HTTP_WRITE_TIMEOUT":                     "3s",
+		"DUCKMESH_BUS_BACKEND":                            "memory",
+		"DUCKMESH_BUS_MAX_ATTEMPTS":                       "8",
+		"DUCKMESH_BUS_RETRY_BASE_BACKOFF":                 "250ms",
+		"DUCKMESH_BUS_RETRY_MAX_BACKOFF":                  "30s",
+		"DUCKMESH_BUS_CLAIM_FAIRNESS":                     "Round_Robin",
+		"DUCKMESH_BUS_TENANT_WEIGHTS":                     "tenant-a=3, tenant-b=1",
+		"DUCKMESH_OBJECTSTORE_BACKEND":                    "Memory",
 		"DUCKMESH_OBJECTSTORE_ENDPOINT":                   "s3.example.com",
 		"DUCKMESH_OBJECTSTORE_BUCKET":                     "duckmesh-prod",
 		"DUCKMESH_OBJECTSTORE_REGION":                     "us-west-2",
@@ -97,11 +118,29 @@ func TestLoadWithEnvOverrides(t *testing.T) {
 		"DUCKMESH_OBJECTSTORE_USE_SSL":                    "true",
 		"DUCKMESH_OBJECTSTORE_PREFIX":                     "tenant-root",
 		"DUCKMESH_OBJECTSTORE_AUTO_CREATE_BUCKET":         "false",
+		"DUCKMESH_INGEST_STREAM_CHUNK_RECORDS":            "250",
+		"DUCKMESH_INGEST_STREAM_IDLE_TIMEOUT":             "12s",
+		"DUCKMESH_QUERY_ALLOWED_FUNCTIONS":                "query_table, ",
+		"DUCKMESH_QUERY_DENIED_FUNCTIONS":                 "generate_series",
+		"DUCKMESH_QUERY_TENANT_ALLOWED_FUNCTIONS":         "tenant-a=read_parquet|duckdb_tables, tenant-b=range",
+		"DUCKMESH_QUERY_TENANT_DENIED_FUNCTIONS":          "tenant-b=query_table",
+		"DUCKMESH_OBJECT_CACHE_DIR":                       "/var/cache/duckmesh",
+		"DUCKMESH_OBJECT_CACHE_MAX_BYTES":                 "1048576",
+		"DUCKMESH_QUERY_JOB_WORKERS":                      "0",
+		"DUCKMESH_QUERY_JOB_LEASE":                        "45s",
+		"DUCKMESH_QUERY_JOB_TIMEOUT":                      "5m",
 		"DUCKMESH_COORDINATOR_CONSUMER_ID":                "worker-1",
 		"DUCKMESH_COORDINATOR_CLAIM_LIMIT":                "123",
 		"DUCKMESH_COORDINATOR_LEASE_SECONDS":              "45",
 		"DUCKMESH_COORDINATOR_POLL_INTERVAL":              "900ms",
 		"DUCKMESH_COORDINATOR_CREATED_BY":                 "coordinator-a",
+		"DUCKMESH_COORDINATOR_EMBEDDED":                   "true",
+		"DUCKMESH_COORDINATOR_HEARTBEAT_INTERVAL":         "15s",
+		"DUCKMESH_COORDINATOR_SWEEP_INTERVAL":             "20s",
+		"DUCKMESH_COORDINATOR_TARGET_FILE_ROWS":           "2500",
+		"DUCKMESH_COORDINATOR_TARGET_FILE_BYTES":          "1048576",
+		"DUCKMESH_COORDINATOR_MAX_BATCH_DELAY":            "1500ms",
+		"DUCKMESH_COORDINATOR_WORKERS":                    "6",
 		"DUCKMESH_MAINTENANCE_COMPACTION_INTERVAL":        "11m",
 		"DUCKMESH_MAINTENANCE_COMPACTION_MIN_INPUT_FILES": "7",
 		"DUCKMESH_MAINTENANCE_RETENTION_INTERVAL":         "37m",
@@ -151,6 +190,24 @@ func TestLoadWithEnvOverrides(t *testing.T) {
 	if cfg.Catalog.MaxIdleConns != 17 {
 		t.Fatalf("Catalog.MaxIdleConns = %d", cfg.Catalog.MaxIdleConns)
 	}
+	if cfg.Catalog.NotifyEnabled {
+		t.Fatal("Catalog.NotifyEnabled = true, want false")
+	}
+	if cfg.Catalog.NotifyFallbackPoll != 750*time.Millisecond {
+		t.Fatalf("Catalog.NotifyFallbackPoll = %s", cfg.Catalog.NotifyFallbackPoll)
+	}
+	if cfg.Bus.Backend != BusBackendMemory {
+		t.Fatalf("Bus.Backend = %q", cfg.Bus.Backend)
+	}
+	if cfg.Bus.MaxAttempts != 8 || cfg.Bus.RetryBaseBackoff != 250*time.Millisecond || cfg.Bus.RetryMaxBackoff != 30*time.Second {
+		t.Fatalf("Bus retry = %d/%s/%s", cfg.Bus.MaxAttempts, cfg.Bus.RetryBaseBackoff, cfg.Bus.RetryMaxBackoff)
+	}
+	if cfg.Bus.ClaimFairness != ClaimFairnessRoundRobin || len(cfg.Bus.TenantWeights) != 2 || cfg.Bus.TenantWeights["tenant-a"] != 3 {
+		t.Fatalf("Bus fairness = %q/%v", cfg.Bus.ClaimFairness, cfg.Bus.TenantWeights)
+	}
+	if cfg.ObjectStore.Backend != ObjectStoreBackendMemory {
+		t.Fatalf("ObjectStore.Backend = %q", cfg.ObjectStore.Backend)
+	}
 	if cfg.ObjectStore.Endpoint != "s3.example.com" {
 		t.Fatalf("ObjectStore.Endpoint = %q", cfg.ObjectStore.Endpoint)
 	}
@@ -163,6 +220,24 @@ func TestLoadWithEnvOverrides(t *testing.T) {
 	if cfg.ObjectStore.AutoCreateBucket {
 		t.Fatal("ObjectStore.AutoCreateBucket = true, want false")
 	}
+	if cfg.Ingest.StreamChunkRecords != 250 {
+		t.Fatalf("Ingest.StreamChunkRecords = %d", cfg.Ingest.StreamChunkRecords)
+	}
+	if cfg.Ingest.StreamIdleTimeout != 12*time.Second {
+		t.Fatalf("Ingest.StreamIdleTimeout = %s", cfg.Ingest.StreamIdleTimeout)
+	}
+	if len(cfg.Query.AllowedFunctions) != 1 || cfg.Query.DeniedFunctions[0] != "generate_series" {
+		t.Fatalf("Query functions = %v/%v", cfg.Query.AllowedFunctions, cfg.Query.DeniedFunctions)
+	}
+	if len(cfg.Query.TenantAllowedFunctions["tenant-a"]) != 2 || cfg.Query.TenantAllowedFunctions["tenant-b"][0] != "range" || cfg.Query.TenantDeniedFunctions["tenant-b"][0] != "query_table" {
+		t.Fatalf("Query tenant functions = %v/%v", cfg.Query.TenantAllowedFunctions, cfg.Query.TenantDeniedFunctions)
+	}
+	if cfg.QueryJobs.Workers != 0 || cfg.QueryJobs.Lease != 45*time.Second || cfg.QueryJobs.Timeout != 5*time.Minute || cfg.QueryJobs.PollInterval != time.Second {
+		t.Fatalf("QueryJobs = %+v", cfg.QueryJobs)
+	}
+	if cfg.ObjectCache.Dir != "/var/cache/duckmesh" || cfg.ObjectCache.MaxBytes != 1<<20 {
+		t.Fatalf("ObjectCache = %+v", cfg.ObjectCache)
+	}
 	if cfg.Coordinator.ConsumerID != "worker-1" {
 		t.Fatalf("Coordinator.ConsumerID = %q", cfg.Coordinator.ConsumerID)
 	}
@@ -178,6 +253,24 @@ func TestLoadWithEnvOverrides(t *testing.T) {
 	if cfg.Coordinator.CreatedBy != "coordinator-a" {
 		t.Fatalf("Coordinator.CreatedBy = %q", cfg.Coordinator.CreatedBy)
 	}
+	if !cfg.Coordinator.Embedded {
+		t.Fatal("Coordinator.Embedded = false, want true")
+	}
+	if cfg.Coordinator.HeartbeatInterval != 15*time.Second {
+		t.Fatalf("Coordinator.HeartbeatInterval = %s", cfg.Coordinator.HeartbeatInterval)
+	}
+	if cfg.Coordinator.SweepInterval != 20*time.Second {
+		t.Fatalf("Coordinator.SweepInterval = %s", cfg.Coordinator.SweepInterval)
+	}
+	if cfg.Coordinator.TargetFileRows != 2500 || cfg.Coordinator.TargetFileBytes != 1<<20 {
+		t.Fatalf("Coordinator targets = %d rows, %d bytes", cfg.Coordinator.TargetFileRows, cfg.Coordinator.TargetFileBytes)
+	}
+	if cfg.Coordinator.MaxBatchDelay != 1500*time.Millisecond {
+		t.Fatalf("Coordinator.MaxBatchDelay = %s", cfg.Coordinator.MaxBatchDelay)
+	}
+	if cfg.Coordinator.Workers != 6 {
+		t.Fatalf("Coordinator.Workers = %d", cfg.Coordinator.Workers)
+	}
 	if cfg.Maintenance.CompactionInterval != 11*time.Minute {
 		t.Fatalf("Maintenance.CompactionInterval = %s", cfg.Maintenance.CompactionInterval)
 	}
@@ -227,12 +320,30 @@ func TestLoadErrorsOnInvalidValues(t *testing.T) {
 		{"DUCKMESH_PROFILE": "oops"},
 		{"DUCKMESH_HTTP_READ_TIMEOUT": "NaN"},
 		{"DUCKMESH_CATALOG_MAX_OPEN_CONNS": "oops"},
+		{"DUCKMESH_CATALOG_NOTIFY_ENABLED": "sometimes"},
 		{"DUCKMESH_COORDINATOR_CLAIM_LIMIT": "oops"},
 		{"DUCKMESH_MAINTENANCE_INTEGRITY_SNAPSHOT_LIMIT": "oops"},
 		{"DUCKMESH_MAINTENANCE_KEEP_SNAPSHOTS": "oops"},
 		{"DUCKMESH_AI_TEMPERATURE": "bad"},
 		{"DUCKMESH_AUTH_REQUIRED": "not-bool"},
 		{"DUCKMESH_LOG_LEVEL": "verbose"},
+		{"DUCKMESH_BUS_BACKEND": "kafka"},
+		{"DUCKMESH_BUS_MAX_ATTEMPTS": "-1"},
+		{"DUCKMESH_BUS_RETRY_BASE_BACKOFF": "soon"},
+		{"DUCKMESH_OBJECTSTORE_BACKEND": "gcs"},
+		{"DUCKMESH_COORDINATOR_EMBEDDED": "maybe"},
+		{"DUCKMESH_COORDINATOR_HEARTBEAT_INTERVAL": "30s"},
+		{"DUCKMESH_COORDINATOR_SWEEP_INTERVAL": "often"},
+		{"DUCKMESH_COORDINATOR_TARGET_FILE_ROWS": "-1"},
+		{"DUCKMESH_COORDINATOR_MAX_BATCH_DELAY": "-1s"},
+		{"DUCKMESH_COORDINATOR_WORKERS": "0"},
+		{"DUCKMESH_QUERY_JOB_WORKERS": "-1"},
+		{"DUCKMESH_OBJECT_CACHE_DIR": "/tmp/cache", "DUCKMESH_OBJECT_CACHE_MAX_BYTES": "0"},
+		{"DUCKMESH_QUERY_JOB_LEASE": "0s"},
+		{"DUCKMESH_BUS_CLAIM_FAIRNESS": "lottery"},
+		{"DUCKMESH_BUS_TENANT_WEIGHTS": "tenant-a"},
+		{"DUCKMESH_BUS_TENANT_WEIGHTS": "tenant-a=0"},
+		{"DUCKMESH_QUERY_TENANT_ALLOWED_FUNCTIONS": "read_parquet"},
 	}
 	for _, env := range tests {
 		_, err := Load("duckmesh-api", mapLookup(env))
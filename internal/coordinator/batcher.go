@@ -0,0 +1,284 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/bus"
+	"github.com/duckmesh/duckmesh/internal/observability"
+)
+
+// batchBuffer accumulates claimed events per (tenant, table) until the
+// batching policy flushes them. A tenant is always flushed as a whole so its
+// tables advance together. A claim keeps its lease heartbeat running until
+// every one of its events has been acked or nacked.
+type batchBuffer struct {
+	groups []*pendingGroup
+}
+
+type heldClaim struct {
+	batchID string
+	ctx     context.Context
+	stop    func()
+	open    int
+}
+
+type pendingPart struct {
+	claim  *heldClaim
+	events []bus.Envelope
+}
+
+type pendingGroup struct {
+	tenantID string
+	tableID  int64
+	parts    []pendingPart
+	rows     int
+	bytes    int64
+	deadline time.Time
+}
+
+func newBatchBuffer() *batchBuffer {
+	return &batchBuffer{}
+}
+
+func (b *batchBuffer) add(claim *heldClaim, events []bus.Envelope, deadline time.Time) {
+	for _, group := range groupEventsByTenantAndTable(events) {
+		pending := b.find(group.TenantID, group.TableID)
+		if pending == nil {
+			pending = &pendingGroup{tenantID: group.TenantID, tableID: group.TableID, deadline: deadline}
+			b.groups = append(b.groups, pending)
+		}
+		pending.parts = append(pending.parts, pendingPart{claim: claim, events: group.Events})
+		pending.rows += len(group.Events)
+		for _, event := range group.Events {
+			pending.bytes += int64(len(event.PayloadJSON))
+		}
+		claim.open += len(group.Events)
+	}
+}
+
+func (b *batchBuffer) find(tenantID string, tableID int64) *pendingGroup {
+	for _, group := range b.groups {
+		if group.tenantID == tenantID && group.tableID == tableID {
+			return group
+		}
+	}
+	return nil
+}
+
+// nextTenant removes and returns every group of the first tenant with a due
+// group, or nil.
+func (b *batchBuffer) nextTenant(due func(*pendingGroup) bool) []*pendingGroup {
+	tenantID := ""
+	found := false
+	for _, group := range b.groups {
+		if due(group) {
+			tenantID, found = group.tenantID, true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	taken := make([]*pendingGroup, 0)
+	kept := b.groups[:0]
+	for _, group := range b.groups {
+		if group.tenantID == tenantID {
+			taken = append(taken, group)
+			continue
+		}
+		kept = append(kept, group)
+	}
+	b.groups = kept
+	return taken
+}
+
+func (b *batchBuffer) nextDeadline() (time.Time, bool) {
+	var next time.Time
+	for _, group := range b.groups {
+		if next.IsZero() || group.deadline.Before(next) {
+			next = group.deadline
+		}
+	}
+	return next, !next.IsZero()
+}
+
+func (b *batchBuffer) empty() bool {
+	return len(b.groups) == 0
+}
+
+func (s *Service) due(group *pendingGroup, now time.Time) bool {
+	if s.Config.TargetFileRows > 0 && group.rows >= s.Config.TargetFileRows {
+		return true
+	}
+	if s.Config.TargetFileBytes > 0 && group.bytes >= s.Config.TargetFileBytes {
+		return true
+	}
+	return !now.Before(group.deadline)
+}
+
+// claim claims one batch into buffer. Its lease heartbeat derives from
+// leaseCtx so Run can keep leases alive while draining on shutdown.
+func (s *Service) claim(ctx context.Context, buffer *batchBuffer, leaseCtx context.Context) (bool, error) {
+	batch, err := s.Bus.ClaimBatch(ctx, s.Config.ConsumerID, s.Config.ClaimLimit, s.Config.LeaseSeconds)
+	if err != nil {
+		return false, fmt.Errorf("claim batch: %w", err)
+	}
+	if len(batch.Envelopes) == 0 {
+		return false, nil
+	}
+
+	claimedPerTenant := map[string]int{}
+	for _, envelope := range batch.Envelopes {
+		claimedPerTenant[envelope.TenantID]++
+	}
+	for tenantID, count := range claimedPerTenant {
+		observability.ObserveCoordinatorClaimed(tenantID, count)
+	}
+
+	claimCtx, stop := s.startHeartbeat(leaseCtx, batch.BatchID)
+	claim := &heldClaim{batchID: batch.BatchID, ctx: claimCtx, stop: stop}
+	buffer.add(claim, batch.Envelopes, s.Clock().Add(s.Config.MaxBatchDelay))
+	if claim.open == 0 {
+		stop()
+	}
+	return true, nil
+}
+
+// flush publishes every tenant with a due group, or all of them when force
+// is set. Groups are written in parallel and each tenant is published in one
+// snapshot; failed groups are nacked with the error as the reason so their
+// events are retried.
+func (s *Service) flush(ctx context.Context, buffer *batchBuffer, force bool) error {
+	now := s.Clock()
+	tenants := make([]*tenantFlush, 0)
+	defer func() {
+		for _, tenant := range tenants {
+			tenant.release()
+		}
+	}()
+	for {
+		pending := buffer.nextTenant(func(group *pendingGroup) bool {
+			return force || s.due(group, now)
+		})
+		if pending == nil {
+			break
+		}
+		tenants = append(tenants, s.collectTenant(ctx, pending))
+	}
+
+	s.prepareTenants(tenants)
+	var failures []error
+	for _, tenant := range tenants {
+		if err := s.commitTenant(ctx, tenant); err != nil {
+			failures = append(failures, err)
+		}
+	}
+	return errors.Join(failures...)
+}
+
+// tenantFlush is one tenant's share of a flush. groups, prepared and errs are
+// parallel.
+type tenantFlush struct {
+	ctx        context.Context
+	stop       func()
+	pending    []*pendingGroup
+	groups     []groupedEvents
+	snapshotID int64
+	prepared   []preparedGroup
+	errs       []error
+}
+
+func (s *Service) collectTenant(ctx context.Context, pending []*pendingGroup) *tenantFlush {
+	tenant := &tenantFlush{pending: pending}
+	live := make([]pendingPart, 0)
+	for _, item := range pending {
+		group := groupedEvents{TenantID: item.tenantID, TableID: item.tableID}
+		for _, part := range item.parts {
+			// Events of a claim whose lease was lost may already belong to
+			// another consumer; leave them to it.
+			if part.claim.ctx.Err() != nil {
+				continue
+			}
+			live = append(live, part)
+			eventIDs := make([]string, 0, len(part.events))
+			for _, event := range part.events {
+				eventIDs = append(eventIDs, event.EventID)
+				if token, err := strconv.ParseInt(event.EventID, 10, 64); err == nil {
+					group.MaxVisibilityToken = max(group.MaxVisibilityToken, token)
+				}
+			}
+			group.Events = append(group.Events, part.events...)
+			group.EventIDs = append(group.EventIDs, eventIDs...)
+			group.Claims = append(group.Claims, claimedEvents{BatchID: part.claim.batchID, EventIDs: eventIDs})
+		}
+		if len(group.Events) == 0 {
+			continue
+		}
+		tenant.groups = append(tenant.groups, group)
+	}
+	tenant.ctx, tenant.stop = claimsContext(ctx, live)
+	tenant.prepared = make([]preparedGroup, len(tenant.groups))
+	tenant.errs = make([]error, len(tenant.groups))
+	if len(tenant.groups) == 0 {
+		return tenant
+	}
+
+	snapshotID, err := s.Publisher.AllocateSnapshotID(tenant.ctx)
+	if err != nil {
+		for i := range tenant.errs {
+			tenant.errs[i] = fmt.Errorf("allocate snapshot id: %w", err)
+		}
+	}
+	tenant.snapshotID = snapshotID
+	return tenant
+}
+
+// release stops the heartbeat of every claim this flush settled last.
+func (t *tenantFlush) release() {
+	t.stop()
+	for _, group := range t.pending {
+		for _, part := range group.parts {
+			part.claim.open -= len(part.events)
+			if part.claim.open == 0 {
+				part.claim.stop()
+			}
+		}
+	}
+}
+
+// drain publishes whatever Run still holds when it stops. ctx must outlive
+// the cancelled run context; the drain is bounded by one lease.
+func (s *Service) drain(ctx context.Context, buffer *batchBuffer) {
+	if buffer.empty() {
+		return
+	}
+	drainCtx, cancel := context.WithTimeout(ctx, time.Duration(s.Config.LeaseSeconds)*time.Second)
+	defer cancel()
+	if err := s.flush(drainCtx, buffer, true); err != nil && s.Logger != nil {
+		s.Logger.ErrorContext(drainCtx, "coordinator failed to flush buffered events on shutdown", slog.Any("error", err))
+	}
+}
+
+// claimsContext is cancelled as soon as ctx or the lease of any claim is.
+func claimsContext(ctx context.Context, parts []pendingPart) (context.Context, func()) {
+	merged, cancel := context.WithCancelCause(ctx)
+	stops := make([]func() bool, 0, len(parts))
+	for _, part := range parts {
+		claimCtx := part.claim.ctx
+		stops = append(stops, context.AfterFunc(claimCtx, func() {
+			cancel(context.Cause(claimCtx))
+		}))
+	}
+	return merged, func() {
+		for _, stop := range stops {
+			stop()
+		}
+		cancel(nil)
+	}
+}
@@ -0,0 +1,226 @@
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/bus"
+	busmemory "github.com/duckmesh/duckmesh/internal/bus/memory"
+	memorystore "github.com/duckmesh/duckmesh/internal/storage/memory"
+)
+
+type batchingHarness struct {
+	ctx       context.Context
+	bus       *busmemory.IngestBus
+	publisher *stubPublisher
+	svc       *Service
+	buffer    *batchBuffer
+	now       time.Time
+	nextKey   int
+}
+
+func newBatchingHarness(t *testing.T, cfg Config) *batchingHarness {
+	t.Helper()
+	h := &batchingHarness{
+		ctx:       context.Background(),
+		bus:       busmemory.NewIngestBus(),
+		publisher: &stubPublisher{},
+		buffer:    newBatchBuffer(),
+		now:       time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	h.svc = &Service{
+		Bus:         h.bus,
+		Publisher:   h.publisher,
+		ObjectStore: memorystore.New(),
+		Config:      cfg,
+		Clock:       func() time.Time { return h.now },
+	}
+	h.svc.ensureDefaults()
+	return h
+}
+
+// cycle publishes one event per table id, claims them and flushes due groups.
+func (h *batchingHarness) cycle(t *testing.T, tableIDs ...string) {
+	t.Helper()
+	events := make([]bus.Envelope, 0, len(tableIDs))
+	for _, tableID := range tableIDs {
+		h.nextKey++
+		events = append(events, bus.Envelope{TenantID: "tenant", TableID: tableID, IdempotencyKey: fmt.Sprintf("k%d", h.nextKey), Op: "insert", PayloadJSON: []byte(`{"a":1}`)})
+	}
+	if len(events) > 0 {
+		if _, err := h.bus.Publish(h.ctx, events); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+		if _, err := h.svc.claim(h.ctx, h.buffer, h.ctx); err != nil {
+			t.Fatalf("claim() error = %v", err)
+		}
+	}
+	if err := h.svc.flush(h.ctx, h.buffer, false); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+}
+
+func TestBatchingAccumulatesClaimsUntilRowTarget(t *testing.T) {
+	h := newBatchingHarness(t, Config{TargetFileRows: 3, MaxBatchDelay: time.Minute})
+
+	h.cycle(t, "20")
+	h.cycle(t, "20")
+	if len(h.publisher.inputs) != 0 {
+		t.Fatalf("published before reaching the row target: %+v", h.publisher.inputs)
+	}
+	h.cycle(t, "20")
+
+	if len(h.publisher.inputs) != 1 {
+		t.Fatalf("publish inputs = %d, want 1", len(h.publisher.inputs))
+	}
+	input := h.publisher.inputs[0]
+	if len(input.BatchIDs) != 3 || len(input.Tables) != 1 || len(input.Tables[0].EventIDs) != 3 || input.Tables[0].Files[0].RecordCount != 3 {
+		t.Fatalf("publish input = %+v", input)
+	}
+	if input.MaxVisibilityToken != 3 {
+		t.Fatalf("max visibility token = %d, want 3", input.MaxVisibilityToken)
+	}
+	for _, eventID := range input.Tables[0].EventIDs {
+		if state, _ := h.bus.State(eventID); state != bus.StateCommitted {
+			t.Fatalf("event %s state = %q", eventID, state)
+		}
+	}
+	if !h.buffer.empty() {
+		t.Fatal("buffer not empty after flush")
+	}
+}
+
+func TestBatchingFlushesAtMaxDelay(t *testing.T) {
+	h := newBatchingHarness(t, Config{TargetFileRows: 1000, MaxBatchDelay: 2 * time.Second})
+
+	h.cycle(t, "20")
+	h.now = h.now.Add(1999 * time.Millisecond)
+	h.cycle(t)
+	if len(h.publisher.inputs) != 0 {
+		t.Fatal("published before the max delay elapsed")
+	}
+	if deadline, ok := h.buffer.nextDeadline(); !ok || deadline.Sub(h.now) != time.Millisecond {
+		t.Fatalf("next deadline = %v, %v", deadline, ok)
+	}
+
+	h.now = h.now.Add(time.Millisecond)
+	h.cycle(t)
+	if len(h.publisher.inputs) != 1 || h.publisher.inputs[0].Tables[0].Files[0].RecordCount != 1 {
+		t.Fatalf("publish inputs = %+v", h.publisher.inputs)
+	}
+}
+
+func TestBatchingFlushesWholeTenantWhenOneGroupIsDue(t *testing.T) {
+	h := newBatchingHarness(t, Config{TargetFileRows: 2, MaxBatchDelay: time.Minute})
+
+	// Tokens 1 and 3 go to table 20, token 2 to table 21.
+	h.cycle(t, "20", "21", "20")
+	if len(h.publisher.inputs) != 1 {
+		t.Fatalf("publish inputs = %+v", h.publisher.inputs)
+	}
+	input := h.publisher.inputs[0]
+	if len(input.Tables) != 2 || input.Tables[0].TableID != 20 || input.Tables[1].TableID != 21 {
+		t.Fatalf("published tables = %+v", input.Tables)
+	}
+	if input.MaxVisibilityToken != 3 {
+		t.Fatalf("watermark = %d, want 3", input.MaxVisibilityToken)
+	}
+	if !h.buffer.empty() {
+		t.Fatal("buffer not empty after flushing the tenant")
+	}
+}
+
+func TestBatchingKeepsLeaseAliveWhileHolding(t *testing.T) {
+	h := newBatchingHarness(t, Config{MaxBatchDelay: time.Minute, LeaseSeconds: 1, HeartbeatInterval: 10 * time.Millisecond})
+
+	h.cycle(t, "20")
+	claim := h.buffer.groups[0].parts[0].claim
+	time.Sleep(1200 * time.Millisecond)
+	if err := claim.ctx.Err(); err != nil {
+		t.Fatalf("held claim lost its lease: %v", context.Cause(claim.ctx))
+	}
+	if count, err := h.bus.RequeueExpired(h.ctx); err != nil || count != 0 {
+		t.Fatalf("RequeueExpired() = %d, %v", count, err)
+	}
+
+	if err := h.svc.flush(h.ctx, h.buffer, true); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+	if len(h.publisher.inputs) != 1 {
+		t.Fatalf("publish inputs = %d, want 1", len(h.publisher.inputs))
+	}
+	select {
+	case <-claim.ctx.Done():
+	default:
+		t.Fatal("heartbeat still running after the claim was fully acked")
+	}
+}
+
+func TestRunDrainsBufferedEventsOnShutdown(t *testing.T) {
+	ctx := context.Background()
+	ingestBus := busmemory.NewIngestBus()
+	if _, err := ingestBus.Publish(ctx, []bus.Envelope{{TenantID: "tenant", TableID: "20", IdempotencyKey: "k1", Op: "insert", PayloadJSON: []byte(`{"a":1}`)}}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	publisher := &stubPublisher{}
+	svc := &Service{
+		Bus:         ingestBus,
+		Publisher:   publisher,
+		ObjectStore: memorystore.New(),
+		Config:      Config{PollInterval: 10 * time.Millisecond, MaxBatchDelay: time.Hour, TargetFileRows: 100},
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if err := svc.Run(runCtx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(publisher.inputs) != 1 {
+		t.Fatalf("publish inputs = %d, want buffered events drained on shutdown", len(publisher.inputs))
+	}
+	if state, _ := ingestBus.State(publisher.inputs[0].Tables[0].EventIDs[0]); state != bus.StateCommitted {
+		t.Fatalf("state = %q, want committed", state)
+	}
+}
+
+func TestBatchingKeepsClaimOpenAfterNackingAnotherTenant(t *testing.T) {
+	h := newBatchingHarness(t, Config{TargetFileRows: 2, MaxBatchDelay: time.Minute, LeaseSeconds: 1, HeartbeatInterval: 10 * time.Millisecond})
+	h.svc.ObjectStore = &stubStore{failPrefix: "tenant-a/"}
+
+	published, err := h.bus.Publish(h.ctx, []bus.Envelope{
+		{TenantID: "tenant-a", TableID: "20", IdempotencyKey: "k1", Op: "insert", PayloadJSON: []byte(`{"a":1}`)},
+		{TenantID: "tenant-a", TableID: "20", IdempotencyKey: "k2", Op: "insert", PayloadJSON: []byte(`{"a":2}`)},
+		{TenantID: "tenant-b", TableID: "30", IdempotencyKey: "k3", Op: "insert", PayloadJSON: []byte(`{"a":3}`)},
+	})
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if _, err := h.svc.claim(h.ctx, h.buffer, h.ctx); err != nil {
+		t.Fatalf("claim() error = %v", err)
+	}
+	// tenant-a reached the row target and fails; tenant-b stays buffered.
+	if err := h.svc.flush(h.ctx, h.buffer, false); err == nil {
+		t.Fatal("flush() error = nil")
+	}
+	if state, _ := h.bus.State(published[0].EventID); state != bus.StateAccepted {
+		t.Fatalf("nacked event state = %q, want accepted", state)
+	}
+
+	claim := h.buffer.groups[0].parts[0].claim
+	time.Sleep(1200 * time.Millisecond)
+	if err := claim.ctx.Err(); err != nil {
+		t.Fatalf("claim lost its lease after a partial nack: %v", context.Cause(claim.ctx))
+	}
+
+	if err := h.svc.flush(h.ctx, h.buffer, true); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+	if len(h.publisher.inputs) != 1 || h.publisher.inputs[0].TenantID != "tenant-b" {
+		t.Fatalf("publish inputs = %+v", h.publisher.inputs)
+	}
+	if state, _ := h.bus.State(published[2].EventID); state != bus.StateCommitted {
+		t.Fatalf("buffered event state = %q, want committed", state)
+	}
+}
@@ -0,0 +1,77 @@
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// startHeartbeat extends the lease of batchID until the returned stop
+// function is called. If the lease cannot be extended the returned context
+// is cancelled, so the batch is abandoned instead of being published after
+// another consumer may have claimed it.
+func (s *Service) startHeartbeat(ctx context.Context, batchID string) (context.Context, func()) {
+	batchCtx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(s.Config.HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-batchCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := s.Bus.ExtendLease(batchCtx, batchID, s.Config.LeaseSeconds); err != nil {
+				if batchCtx.Err() != nil {
+					return
+				}
+				if s.Logger != nil {
+					s.Logger.WarnContext(ctx, "coordinator lost batch lease",
+						slog.String("batch_id", batchID),
+						slog.Any("error", err),
+					)
+				}
+				cancel(fmt.Errorf("extend lease for batch %s: %w", batchID, err))
+				return
+			}
+		}
+	}()
+	return batchCtx, func() {
+		cancel(nil)
+		<-done
+	}
+}
+
+func (s *Service) runSweeper(ctx context.Context) {
+	ticker := time.NewTicker(s.Config.SweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.sweepOnce(ctx)
+	}
+}
+
+// sweepOnce requeues claims whose lease expired, such as those held by a
+// coordinator that crashed mid-batch.
+func (s *Service) sweepOnce(ctx context.Context) {
+	count, err := s.Bus.RequeueExpired(ctx)
+	if s.Logger == nil {
+		return
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			s.Logger.ErrorContext(ctx, "coordinator requeue of expired claims failed", slog.Any("error", err))
+		}
+		return
+	}
+	if count > 0 {
+		s.Logger.InfoContext(ctx, "coordinator requeued expired claims", slog.Int("event_count", count))
+	}
+}
@@ -2,6 +2,7 @@ package coordinator
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"time"
@@ -9,6 +10,8 @@ import (
 	"github.com/parquet-go/parquet-go"
 
 	"github.com/duckmesh/duckmesh/internal/bus"
+	"github.com/duckmesh/duckmesh/internal/colstats"
+	"github.com/duckmesh/duckmesh/internal/tableschema"
 )
 
 type ParquetEncodeResult struct {
@@ -16,8 +19,25 @@ type ParquetEncodeResult struct {
 	RecordCount  int64
 	MinEventTime *time.Time
 	MaxEventTime *time.Time
+	// Columns holds per-column statistics of typed files.
+	Columns  map[string]colstats.Column
+	Rejected []RejectedEvent
 }
 
+type RejectedEvent struct {
+	Event  bus.Envelope
+	Reason string
+}
+
+const (
+	columnEventID        = tableschema.ReservedPrefix + "event_id"
+	columnTenantID       = tableschema.ReservedPrefix + "tenant_id"
+	columnTableID        = tableschema.ReservedPrefix + "table_id"
+	columnIdempotencyKey = tableschema.ReservedPrefix + "idempotency_key"
+	columnOp             = tableschema.ReservedPrefix + "op"
+	columnEventTime      = tableschema.ReservedPrefix + "event_time"
+)
+
 type parquetEvent struct {
 	EventID         int64  `parquet:"event_id"`
 	TenantID        string `parquet:"tenant_id"`
@@ -57,17 +77,7 @@ func EncodeEventsToParquet(events []bus.Envelope) (ParquetEncodeResult, error) {
 			EventTimeUnixMs: event.EventTimeUnixMs,
 		})
 
-		if event.EventTimeUnixMs > 0 {
-			eventTime := time.UnixMilli(event.EventTimeUnixMs).UTC()
-			if minTime == nil || eventTime.Before(*minTime) {
-				copy := eventTime
-				minTime = &copy
-			}
-			if maxTime == nil || eventTime.After(*maxTime) {
-				copy := eventTime
-				maxTime = &copy
-			}
-		}
+		minTime, maxTime = widenEventTimeRange(minTime, maxTime, event.EventTimeUnixMs)
 	}
 
 	buf := bytes.NewBuffer(nil)
@@ -86,3 +96,180 @@ func EncodeEventsToParquet(events []bus.Envelope) (ParquetEncodeResult, error) {
 		MaxEventTime: maxTime,
 	}, nil
 }
+
+// EncodeEventsWithSchema writes one typed column per declared field plus the
+// reserved event metadata columns. Events whose payload does not match the
+// schema are returned in Rejected instead of failing the whole file. Tables
+// without a declared schema keep the envelope layout.
+func EncodeEventsWithSchema(events []bus.Envelope, tableSchema tableschema.Schema) (ParquetEncodeResult, error) {
+	if tableSchema.IsEmpty() {
+		return EncodeEventsToParquet(events)
+	}
+	if len(events) == 0 {
+		return ParquetEncodeResult{}, fmt.Errorf("events are required")
+	}
+
+	schema := typedParquetSchema(tableSchema)
+	columns := map[string]parquet.LeafColumn{}
+	for _, path := range schema.Columns() {
+		leaf, _ := schema.Lookup(path...)
+		columns[path[0]] = leaf
+	}
+
+	result := ParquetEncodeResult{}
+	collector := colstats.NewCollector(tableSchema)
+	rows := make([]parquet.Row, 0, len(events))
+	for _, event := range events {
+		row, values, err := typedParquetRow(event, tableSchema, columns)
+		if err != nil {
+			result.Rejected = append(result.Rejected, RejectedEvent{Event: event, Reason: err.Error()})
+			continue
+		}
+		rows = append(rows, row)
+		collector.Add(values)
+		result.MinEventTime, result.MaxEventTime = widenEventTimeRange(result.MinEventTime, result.MaxEventTime, event.EventTimeUnixMs)
+	}
+	if len(rows) == 0 {
+		return result, nil
+	}
+
+	buf := bytes.NewBuffer(nil)
+	writer := parquet.NewWriter(buf, schema)
+	if _, err := writer.WriteRows(rows); err != nil {
+		return ParquetEncodeResult{}, fmt.Errorf("write parquet rows: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return ParquetEncodeResult{}, fmt.Errorf("close parquet writer: %w", err)
+	}
+	result.Data = buf.Bytes()
+	result.RecordCount = int64(len(rows))
+	result.Columns = collector.Columns()
+	return result, nil
+}
+
+func typedParquetSchema(tableSchema tableschema.Schema) *parquet.Schema {
+	group := parquet.Group{
+		columnEventID:        parquet.Leaf(parquet.Int64Type),
+		columnTenantID:       parquet.String(),
+		columnTableID:        parquet.Leaf(parquet.Int64Type),
+		columnIdempotencyKey: parquet.String(),
+		columnOp:             parquet.String(),
+		columnEventTime:      parquet.Optional(parquet.TimestampAdjusted(parquet.Microsecond, false)),
+	}
+	for _, field := range tableSchema.Fields {
+		group[field.Name] = parquet.Optional(parquetNodeForType(field.Type))
+	}
+	return parquet.NewSchema("event", group)
+}
+
+func parquetNodeForType(fieldType tableschema.Type) parquet.Node {
+	switch fieldType {
+	case tableschema.TypeBigint:
+		return parquet.Leaf(parquet.Int64Type)
+	case tableschema.TypeInteger:
+		return parquet.Leaf(parquet.Int32Type)
+	case tableschema.TypeDouble:
+		return parquet.Leaf(parquet.DoubleType)
+	case tableschema.TypeBoolean:
+		return parquet.Leaf(parquet.BooleanType)
+	case tableschema.TypeTimestamp:
+		return parquet.TimestampAdjusted(parquet.Microsecond, false)
+	case tableschema.TypeDate:
+		return parquet.Date()
+	case tableschema.TypeJSON:
+		return parquet.JSON()
+	default:
+		return parquet.String()
+	}
+}
+
+// typedParquetRow returns the row and the coerced value of every schema field.
+func typedParquetRow(event bus.Envelope, tableSchema tableschema.Schema, columns map[string]parquet.LeafColumn) (parquet.Row, []any, error) {
+	eventID, err := strconv.ParseInt(event.EventID, 10, 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid event id %q: %w", event.EventID, err)
+	}
+	tableID, err := strconv.ParseInt(event.TableID, 10, 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid table id %q: %w", event.TableID, err)
+	}
+
+	payload := map[string]any{}
+	decoder := json.NewDecoder(bytes.NewReader(event.PayloadJSON))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, nil, fmt.Errorf("payload is not a JSON object: %w", err)
+	}
+
+	row := make(parquet.Row, len(columns))
+	set := func(name string, value any) {
+		leaf := columns[name]
+		if value == nil {
+			row[leaf.ColumnIndex] = parquet.NullValue().Level(0, 0, leaf.ColumnIndex)
+			return
+		}
+		row[leaf.ColumnIndex] = parquetValue(value).Level(0, leaf.MaxDefinitionLevel, leaf.ColumnIndex)
+	}
+
+	set(columnEventID, eventID)
+	set(columnTenantID, event.TenantID)
+	set(columnTableID, tableID)
+	set(columnIdempotencyKey, event.IdempotencyKey)
+	set(columnOp, event.Op)
+	if event.EventTimeUnixMs > 0 {
+		set(columnEventTime, time.UnixMilli(event.EventTimeUnixMs).UTC())
+	} else {
+		set(columnEventTime, nil)
+	}
+
+	values := make([]any, 0, len(tableSchema.Fields))
+	for _, field := range tableSchema.Fields {
+		value, err := field.Coerce(payload[field.Name])
+		if err != nil {
+			return nil, nil, fmt.Errorf("field %q: %w", field.Name, err)
+		}
+		if value == nil && field.Required {
+			return nil, nil, fmt.Errorf("field %q is required", field.Name)
+		}
+		values = append(values, value)
+		if day, ok := value.(time.Time); ok && field.Type == tableschema.TypeDate {
+			value = int32(day.Unix() / 86400)
+		}
+		set(field.Name, value)
+	}
+	return row, values, nil
+}
+
+func parquetValue(value any) parquet.Value {
+	switch v := value.(type) {
+	case int64:
+		return parquet.Int64Value(v)
+	case int32:
+		return parquet.Int32Value(v)
+	case float64:
+		return parquet.DoubleValue(v)
+	case bool:
+		return parquet.BooleanValue(v)
+	case time.Time:
+		return parquet.Int64Value(v.UnixMicro())
+	case string:
+		return parquet.ByteArrayValue([]byte(v))
+	}
+	return parquet.ByteArrayValue([]byte(fmt.Sprint(value)))
+}
+
+func widenEventTimeRange(minTime, maxTime *time.Time, eventTimeUnixMs int64) (*time.Time, *time.Time) {
+	if eventTimeUnixMs <= 0 {
+		return minTime, maxTime
+	}
+	eventTime := time.UnixMilli(eventTimeUnixMs).UTC()
+	if minTime == nil || eventTime.Before(*minTime) {
+		copy := eventTime
+		minTime = &copy
+	}
+	if maxTime == nil || eventTime.After(*maxTime) {
+		copy := eventTime
+		maxTime = &copy
+	}
+	return minTime, maxTime
+}
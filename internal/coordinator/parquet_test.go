@@ -10,6 +10,7 @@ import (
 	"github.com/parquet-go/parquet-go"
 
 	"github.com/duckmesh/duckmesh/internal/bus"
+	"github.com/duckmesh/duckmesh/internal/tableschema"
 )
 
 func TestEncodeEventsToParquet(t *testing.T) {
@@ -59,3 +60,77 @@ func TestEncodeEventsToParquet(t *testing.T) {
 		t.Fatalf("unexpected event ids: %+v", rows)
 	}
 }
+
+func TestEncodeEventsWithSchemaWritesTypedColumns(t *testing.T) {
+	tableSchema, err := tableschema.Parse([]byte(`{"id":{"type":"bigint","required":true},"region":"varchar","amount":"double"}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	eventTime := time.Date(2026, time.February, 19, 10, 0, 0, 0, time.UTC)
+	events := []bus.Envelope{
+		{EventID: "1", TenantID: "tenant-a", TableID: "10", IdempotencyKey: "idem-1", Op: "insert", PayloadJSON: []byte(`{"id":7,"region":"eu","amount":1.5,"extra":true}`), EventTimeUnixMs: eventTime.UnixMilli()},
+		{EventID: "2", TenantID: "tenant-a", TableID: "10", IdempotencyKey: "idem-2", Op: "insert", PayloadJSON: []byte(`{"id":"seven"}`), EventTimeUnixMs: eventTime.UnixMilli()},
+		{EventID: "3", TenantID: "tenant-a", TableID: "10", IdempotencyKey: "idem-3", Op: "insert", PayloadJSON: []byte(`{"region":"us"}`), EventTimeUnixMs: eventTime.UnixMilli()},
+		{EventID: "4", TenantID: "tenant-a", TableID: "10", IdempotencyKey: "idem-4", Op: "insert", PayloadJSON: []byte(`{"id":8}`)},
+	}
+
+	result, err := EncodeEventsWithSchema(events, tableSchema)
+	if err != nil {
+		t.Fatalf("EncodeEventsWithSchema() error = %v", err)
+	}
+	if result.RecordCount != 2 {
+		t.Fatalf("RecordCount = %d", result.RecordCount)
+	}
+	if len(result.Rejected) != 2 || result.Rejected[0].Event.EventID != "2" || result.Rejected[1].Event.EventID != "3" {
+		t.Fatalf("Rejected = %+v", result.Rejected)
+	}
+	if id := result.Columns["id"]; id.Min != int64(7) || id.Max != int64(8) || id.NullCount != 0 || id.DistinctCount != 2 {
+		t.Fatalf("id stats = %+v", id)
+	}
+	if region := result.Columns["region"]; region.Min != "eu" || region.NullCount != 1 || region.DistinctCount != 1 {
+		t.Fatalf("region stats = %+v", region)
+	}
+
+	type typedRow struct {
+		EventID int64      `parquet:"__event_id"`
+		Op      string     `parquet:"__op"`
+		Time    *time.Time `parquet:"__event_time,optional,timestamp(microsecond)"`
+		ID      *int64     `parquet:"id,optional"`
+		Region  *string    `parquet:"region,optional"`
+		Amount  *float64   `parquet:"amount,optional"`
+	}
+	reader := parquet.NewGenericReader[typedRow](bytes.NewReader(result.Data))
+	defer func() { _ = reader.Close() }()
+	if _, ok := reader.Schema().Lookup("payload_json"); ok {
+		t.Fatal("typed layout should not contain payload_json")
+	}
+	rows := make([]typedRow, 2)
+	count, err := reader.Read(rows)
+	if err != nil && !errors.Is(err, io.EOF) {
+		t.Fatalf("reader.Read() error = %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("read rows = %d", count)
+	}
+	if rows[0].EventID != 1 || rows[0].ID == nil || *rows[0].ID != 7 || rows[0].Region == nil || *rows[0].Region != "eu" || rows[0].Amount == nil || *rows[0].Amount != 1.5 {
+		t.Fatalf("unexpected row[0] = %+v", rows[0])
+	}
+	if rows[1].EventID != 4 || rows[1].Region != nil || rows[1].Time != nil {
+		t.Fatalf("unexpected row[1] = %+v", rows[1])
+	}
+}
+
+func TestEncodeEventsWithEmptySchemaKeepsEnvelopeLayout(t *testing.T) {
+	events := []bus.Envelope{
+		{EventID: "1", TenantID: "tenant-a", TableID: "10", IdempotencyKey: "idem-1", Op: "insert", PayloadJSON: []byte(`{"a":1}`)},
+	}
+	result, err := EncodeEventsWithSchema(events, tableschema.Schema{})
+	if err != nil {
+		t.Fatalf("EncodeEventsWithSchema() error = %v", err)
+	}
+	reader := parquet.NewGenericReader[parquetEvent](bytes.NewReader(result.Data))
+	defer func() { _ = reader.Close() }()
+	if _, ok := reader.Schema().Lookup("payload_json"); !ok {
+		t.Fatal("expected envelope payload_json column")
+	}
+}
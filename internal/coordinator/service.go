@@ -1,26 +1,40 @@
 package coordinator
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"slices"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/duckmesh/duckmesh/internal/bus"
+	"github.com/duckmesh/duckmesh/internal/catalog"
 	catalogpostgres "github.com/duckmesh/duckmesh/internal/catalog/postgres"
+	"github.com/duckmesh/duckmesh/internal/colstats"
+	"github.com/duckmesh/duckmesh/internal/notify"
+	"github.com/duckmesh/duckmesh/internal/observability"
+	"github.com/duckmesh/duckmesh/internal/partition"
 	"github.com/duckmesh/duckmesh/internal/storage"
+	"github.com/duckmesh/duckmesh/internal/tableschema"
 )
 
 type Service struct {
 	Bus         bus.IngestBus
 	Publisher   Publisher
+	Tables      TableResolver
 	ObjectStore storage.ObjectStore
 	Config      Config
 	Logger      *slog.Logger
 	Clock       func() time.Time
+	// Notifications wakes Run on notify.ChannelIngest. When set, polling
+	// only happens every FallbackPollInterval.
+	Notifications notify.Subscriber
 }
 
 type Publisher interface {
@@ -28,53 +42,102 @@ type Publisher interface {
 	PublishBatch(ctx context.Context, in catalogpostgres.PublishBatchInput) (catalogpostgres.PublishBatchResult, error)
 }
 
+type TableResolver interface {
+	GetTableByID(ctx context.Context, tableID int64) (catalog.TableDef, error)
+	GetCurrentTableSchema(ctx context.Context, tableID int64) (catalog.TableSchemaVersion, error)
+}
+
 type Config struct {
 	ConsumerID   string
 	ClaimLimit   int
 	LeaseSeconds int
 	PollInterval time.Duration
 	CreatedBy    string
+	// HeartbeatInterval is how often the lease of an in-flight batch is
+	// extended. Defaults to a third of the lease.
+	HeartbeatInterval time.Duration
+	// SweepInterval is how often Run requeues claims whose lease expired.
+	SweepInterval time.Duration
+	// FallbackPollInterval replaces PollInterval while Notifications is set.
+	FallbackPollInterval time.Duration
+	// Run holds claimed events per (tenant, table) until TargetFileRows or
+	// TargetFileBytes of payload accumulate, but never longer than
+	// MaxBatchDelay after the first claim. Zero MaxBatchDelay publishes
+	// every claim as it arrives.
+	TargetFileRows  int
+	TargetFileBytes int64
+	MaxBatchDelay   time.Duration
+	// Workers is how many groups are encoded and uploaded at once.
+	// Defaults to one.
+	Workers int
 }
 
 func (s *Service) Run(ctx context.Context) error {
 	s.ensureDefaults()
 
-	ticker := time.NewTicker(s.Config.PollInterval)
-	defer ticker.Stop()
+	sweeperDone := make(chan struct{})
+	go func() {
+		defer close(sweeperDone)
+		s.runSweeper(ctx)
+	}()
+	defer func() { <-sweeperDone }()
+
+	var wakeups <-chan struct{}
+	idle := s.Config.PollInterval
+	if s.Notifications != nil {
+		var unsubscribe func()
+		wakeups, unsubscribe = s.Notifications.Subscribe(notify.ChannelIngest, "")
+		defer unsubscribe()
+		idle = s.Config.FallbackPollInterval
+	}
+	timer := time.NewTimer(idle)
+	defer timer.Stop()
+
+	// Leases of buffered claims outlive ctx so they can be drained on exit.
+	leaseCtx := context.WithoutCancel(ctx)
+	buffer := newBatchBuffer()
+	defer s.drain(leaseCtx, buffer)
 
 	for {
-		if err := s.ProcessOnce(ctx); err != nil {
+		claimed, err := s.claim(ctx, buffer, leaseCtx)
+		if flushErr := s.flush(ctx, buffer, false); flushErr != nil {
+			err = errors.Join(err, flushErr)
+		}
+		if err != nil {
 			if s.Logger != nil {
 				s.Logger.ErrorContext(ctx, "coordinator process cycle failed", slog.Any("error", err))
 			}
 		}
+		// A clean, non-empty cycle may have left a backlog behind whose
+		// notifications were already consumed, so claim again right away.
+		if claimed && err == nil && ctx.Err() == nil {
+			continue
+		}
 
+		wait := idle
+		if deadline, ok := buffer.nextDeadline(); ok {
+			wait = min(wait, max(deadline.Sub(s.Clock()), 0))
+		}
+		timer.Reset(wait)
 		select {
 		case <-ctx.Done():
 			return nil
-		case <-ticker.C:
+		case <-wakeups:
+		case <-timer.C:
 		}
 	}
 }
 
+// ProcessOnce claims one batch and publishes it right away, regardless of
+// the batching policy.
 func (s *Service) ProcessOnce(ctx context.Context) error {
 	s.ensureDefaults()
-	batch, err := s.Bus.ClaimBatch(ctx, s.Config.ConsumerID, s.Config.ClaimLimit, s.Config.LeaseSeconds)
-	if err != nil {
-		return fmt.Errorf("claim batch: %w", err)
+	buffer := newBatchBuffer()
+	claimed, err := s.claim(ctx, buffer, ctx)
+	if err != nil || !claimed {
+		return err
 	}
-	if len(batch.Envelopes) == 0 {
-		return nil
-	}
-
-	groups := groupEventsByTenantAndTable(batch.Envelopes)
-	for _, group := range groups {
-		if err := s.processGroup(ctx, batch.BatchID, group); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return s.flush(ctx, buffer, true)
 }
 
 func (s *Service) ensureDefaults() {
@@ -90,6 +153,21 @@ func (s *Service) ensureDefaults() {
 	if s.Config.PollInterval <= 0 {
 		s.Config.PollInterval = 300 * time.Millisecond
 	}
+	if s.Config.HeartbeatInterval <= 0 {
+		s.Config.HeartbeatInterval = time.Duration(s.Config.LeaseSeconds) * time.Second / 3
+	}
+	if s.Config.SweepInterval <= 0 {
+		s.Config.SweepInterval = 10 * time.Second
+	}
+	if s.Config.FallbackPollInterval <= 0 {
+		s.Config.FallbackPollInterval = 2 * time.Second
+	}
+	if s.Config.MaxBatchDelay < 0 {
+		s.Config.MaxBatchDelay = 0
+	}
+	if s.Config.Workers <= 0 {
+		s.Config.Workers = 1
+	}
 	if s.Config.ConsumerID == "" {
 		s.Config.ConsumerID = "duckmesh-coordinator"
 	}
@@ -98,69 +176,442 @@ func (s *Service) ensureDefaults() {
 	}
 }
 
-func (s *Service) processGroup(ctx context.Context, batchID string, group groupedEvents) error {
-	snapshotID, err := s.Publisher.AllocateSnapshotID(ctx)
-	if err != nil {
-		return fmt.Errorf("allocate snapshot id: %w", err)
+// preparedGroup is one table's share of a tenant snapshot: written to the
+// object store, not yet visible.
+type preparedGroup struct {
+	group      groupedEvents
+	files      []catalogpostgres.PublishFile
+	rejected   []RejectedEvent
+	rejectPath string
+}
+
+// prepareTenants writes the groups of every tenant to the object store on up
+// to Config.Workers goroutines.
+func (s *Service) prepareTenants(tenants []*tenantFlush) {
+	type task struct {
+		tenant *tenantFlush
+		index  int
+	}
+	tasks := make([]task, 0)
+	for _, tenant := range tenants {
+		for i := range tenant.groups {
+			if tenant.errs[i] == nil {
+				tasks = append(tasks, task{tenant: tenant, index: i})
+			}
+		}
+	}
+
+	queue := make(chan task)
+	var wg sync.WaitGroup
+	for range min(s.Config.Workers, len(tasks)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for next := range queue {
+				tenant := next.tenant
+				observability.AddCoordinatorBusyWorkers(1)
+				started := time.Now()
+				tenant.prepared[next.index], tenant.errs[next.index] = s.prepareGroup(tenant.ctx, tenant.snapshotID, tenant.groups[next.index])
+				observability.ObserveCoordinatorGroupWrite(time.Since(started))
+				observability.AddCoordinatorBusyWorkers(-1)
+			}
+		}()
+	}
+	for _, next := range tasks {
+		queue <- next
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// commitTenant publishes the prepared groups of one tenant in a single
+// snapshot, so readers never see one table advanced without the others, and
+// acks them. A group that could not be written fails on its own; a failed
+// publish fails them all. Failed groups are nacked.
+func (s *Service) commitTenant(ctx context.Context, tenant *tenantFlush) error {
+	if len(tenant.groups) == 0 {
+		return nil
 	}
 
-	encoded, err := EncodeEventsToParquet(group.Events)
+	// The watermark only covers tables that are published; the catalog
+	// further holds it below events of the tenant still in flight.
+	input := catalogpostgres.PublishBatchInput{
+		SnapshotID: tenant.snapshotID,
+		TenantID:   tenant.groups[0].TenantID,
+		CreatedBy:  s.Config.CreatedBy,
+	}
+	for i, item := range tenant.prepared {
+		if tenant.errs[i] != nil {
+			continue
+		}
+		if len(item.files) == 0 {
+			input.RejectedEventIDs = append(input.RejectedEventIDs, item.group.EventIDs...)
+			continue
+		}
+		for _, rejected := range item.rejected {
+			input.RejectedEventIDs = append(input.RejectedEventIDs, rejected.Event.EventID)
+		}
+		input.MaxVisibilityToken = max(input.MaxVisibilityToken, item.group.MaxVisibilityToken)
+		input.Tables = append(input.Tables, catalogpostgres.PublishTable{
+			TableID:  item.group.TableID,
+			EventIDs: acceptedEventIDs(item.group.EventIDs, item.rejected),
+			Files:    item.files,
+		})
+		for _, batchID := range item.group.batchIDs() {
+			if !slices.Contains(input.BatchIDs, batchID) {
+				input.BatchIDs = append(input.BatchIDs, batchID)
+			}
+		}
+	}
+	var watermark int64
+	if len(input.Tables) > 0 {
+		published, err := s.Publisher.PublishBatch(tenant.ctx, input)
+		if err != nil {
+			for i := range tenant.errs {
+				if tenant.errs[i] == nil {
+					tenant.errs[i] = fmt.Errorf("publish snapshot batch: %w", err)
+				}
+			}
+		}
+		watermark = published.MaxVisibilityToken
+	}
+
+	var failures []error
+	for i, group := range tenant.groups {
+		err := tenant.errs[i]
+		if err == nil {
+			for _, claim := range group.Claims {
+				if err = s.Bus.Ack(tenant.ctx, claim.BatchID, claim.EventIDs); err != nil {
+					err = fmt.Errorf("ack claimed events: %w", err)
+					break
+				}
+			}
+		}
+		if err == nil {
+			observability.ObserveCoordinatorGroup(group.TenantID, len(group.Events), true)
+			s.logPublished(tenant.ctx, tenant.snapshotID, watermark, tenant.prepared[i])
+			continue
+		}
+
+		observability.ObserveCoordinatorGroup(group.TenantID, len(group.Events), false)
+		if cause := context.Cause(tenant.ctx); cause != nil && ctx.Err() == nil {
+			err = fmt.Errorf("%w (%w)", err, cause)
+		}
+		failures = append(failures, fmt.Errorf("tenant %s table %d: %w", group.TenantID, group.TableID, err))
+		for _, claim := range group.Claims {
+			if nackErr := s.Bus.Nack(ctx, claim.BatchID, claim.EventIDs, err.Error()); nackErr != nil {
+				failures = append(failures, fmt.Errorf("nack tenant %s table %d: %w", group.TenantID, group.TableID, nackErr))
+			}
+		}
+	}
+	return errors.Join(failures...)
+}
+
+func (s *Service) prepareGroup(ctx context.Context, snapshotID int64, group groupedEvents) (preparedGroup, error) {
+	layout, err := s.resolveTableLayout(ctx, group.TableID)
 	if err != nil {
-		return fmt.Errorf("encode events to parquet: %w", err)
+		return preparedGroup{}, err
+	}
+
+	upserts, deletes, rejected := splitEventsForLayout(group.Events, layout)
+
+	partitions := partitionEvents(upserts, layout.Spec, s.Clock())
+	encodedData := make([]ParquetEncodeResult, len(partitions))
+	for i, part := range partitions {
+		encodedData[i], err = EncodeEventsWithSchema(part.events, layout.Schema)
+		if err != nil {
+			return preparedGroup{}, fmt.Errorf("encode events to parquet: %w", err)
+		}
+		rejected = append(rejected, encodedData[i].Rejected...)
+	}
+	var encodedDeletes ParquetEncodeResult
+	if len(deletes) > 0 {
+		encodedDeletes, err = EncodeEventsWithSchema(deletes, layout.Schema.Project(layout.PrimaryKey))
+		if err != nil {
+			return preparedGroup{}, fmt.Errorf("encode delete events to parquet: %w", err)
+		}
+		rejected = append(rejected, encodedDeletes.Rejected...)
 	}
 
 	sequence := int(snapshotID % 100000)
-	dataFilePath, err := storage.BuildDataFilePath(group.TenantID, "table-"+strconv.FormatInt(group.TableID, 10), s.Clock(), snapshotID, sequence)
-	if err != nil {
-		return fmt.Errorf("build data file path: %w", err)
+	tableDir := "table-" + strconv.FormatInt(group.TableID, 10)
+
+	item := preparedGroup{group: group, rejected: rejected}
+	if len(rejected) > 0 {
+		item.rejectPath, err = s.writeRejectedEvents(ctx, group.TenantID, tableDir, snapshotID, sequence, rejected)
+		if err != nil {
+			return preparedGroup{}, err
+		}
+	}
+
+	for i, part := range partitions {
+		if encodedData[i].RecordCount == 0 {
+			continue
+		}
+		segments := make([]storage.PartitionSegment, 0, len(part.values))
+		for _, value := range part.values {
+			segments = append(segments, storage.PartitionSegment{Name: value.Name, Value: value.Value})
+		}
+		dataFilePath, err := storage.BuildDataFilePath(group.TenantID, tableDir, segments, snapshotID, sequence)
+		if err != nil {
+			return preparedGroup{}, fmt.Errorf("build data file path: %w", err)
+		}
+		file, err := s.putEncodedFile(ctx, dataFilePath, catalog.DataFileContentData, encodedData[i])
+		if err != nil {
+			return preparedGroup{}, err
+		}
+		file.PartitionValues = partition.Map(part.values)
+		item.files = append(item.files, file)
+	}
+	if encodedDeletes.RecordCount > 0 {
+		deleteFilePath, err := storage.BuildDeleteFilePath(group.TenantID, tableDir, snapshotID, sequence)
+		if err != nil {
+			return preparedGroup{}, fmt.Errorf("build delete file path: %w", err)
+		}
+		file, err := s.putEncodedFile(ctx, deleteFilePath, catalog.DataFileContentDelete, encodedDeletes)
+		if err != nil {
+			return preparedGroup{}, err
+		}
+		item.files = append(item.files, file)
 	}
+	return item, nil
+}
 
-	putInfo, err := s.ObjectStore.Put(ctx, dataFilePath, bytesReader(encoded.Data), int64(len(encoded.Data)), storage.PutOptions{ContentType: "application/octet-stream"})
+func (s *Service) logPublished(ctx context.Context, snapshotID, watermark int64, item preparedGroup) {
+	if s.Logger == nil {
+		return
+	}
+	if item.rejectPath != "" {
+		s.Logger.WarnContext(ctx, "coordinator rejected events not matching table schema",
+			slog.String("tenant_id", item.group.TenantID),
+			slog.Int64("table_id", item.group.TableID),
+			slog.Int("rejected_count", len(item.rejected)),
+			slog.String("object_path", item.rejectPath),
+		)
+	}
+	for _, file := range item.files {
+		s.Logger.InfoContext(ctx, "coordinator published batch",
+			slog.String("tenant_id", item.group.TenantID),
+			slog.Int64("table_id", item.group.TableID),
+			slog.Int64("snapshot_id", snapshotID),
+			slog.Int64("max_visibility_token", watermark),
+			slog.String("content", string(file.Content)),
+			slog.Int64("event_count", file.RecordCount),
+			slog.String("object_path", file.Path),
+		)
+	}
+}
+
+func (s *Service) putEncodedFile(ctx context.Context, objectPath string, content catalog.DataFileContent, encoded ParquetEncodeResult) (catalogpostgres.PublishFile, error) {
+	putInfo, err := s.ObjectStore.Put(ctx, objectPath, bytesReader(encoded.Data), int64(len(encoded.Data)), storage.PutOptions{ContentType: "application/octet-stream"})
 	if err != nil {
-		return fmt.Errorf("put parquet object: %w", err)
+		return catalogpostgres.PublishFile{}, fmt.Errorf("put parquet object: %w", err)
 	}
 
-	statsJSON, err := json.Marshal(map[string]any{
-		"event_count": len(group.Events),
+	statsJSON, err := json.Marshal(colstats.Stats{
+		EventCount: encoded.RecordCount,
+		Columns:    encoded.Columns,
 	})
 	if err != nil {
-		return fmt.Errorf("marshal data file stats: %w", err)
+		return catalogpostgres.PublishFile{}, fmt.Errorf("marshal data file stats: %w", err)
 	}
 
-	if _, err := s.Publisher.PublishBatch(ctx, catalogpostgres.PublishBatchInput{
-		SnapshotID:         snapshotID,
-		TenantID:           group.TenantID,
-		TableID:            group.TableID,
-		BatchID:            batchID,
-		EventIDs:           group.EventIDs,
-		CreatedBy:          s.Config.CreatedBy,
-		MaxVisibilityToken: group.MaxVisibilityToken,
-		DataFilePath:       dataFilePath,
-		RecordCount:        encoded.RecordCount,
-		FileSizeBytes:      putInfo.Size,
-		MinEventTime:       encoded.MinEventTime,
-		MaxEventTime:       encoded.MaxEventTime,
-		StatsJSON:          statsJSON,
-	}); err != nil {
-		return fmt.Errorf("publish snapshot batch: %w", err)
+	return catalogpostgres.PublishFile{
+		Path:          objectPath,
+		Content:       content,
+		RecordCount:   encoded.RecordCount,
+		FileSizeBytes: putInfo.Size,
+		MinEventTime:  encoded.MinEventTime,
+		MaxEventTime:  encoded.MaxEventTime,
+		StatsJSON:     statsJSON,
+	}, nil
+}
+
+type tableLayout struct {
+	Schema     tableschema.Schema
+	PrimaryKey []string
+	Spec       partition.Spec
+}
+
+func (s *Service) resolveTableLayout(ctx context.Context, tableID int64) (tableLayout, error) {
+	if s.Tables == nil {
+		return tableLayout{Spec: partition.Default()}, nil
 	}
 
-	if err := s.Bus.Ack(ctx, batchID, group.EventIDs); err != nil {
-		return fmt.Errorf("ack claimed events: %w", err)
+	layout := tableLayout{Spec: partition.Default()}
+	table, err := s.Tables.GetTableByID(ctx, tableID)
+	if err != nil && !errors.Is(err, catalog.ErrNotFound) {
+		return tableLayout{}, fmt.Errorf("get table: %w", err)
+	}
+	if spec, err := partition.Parse(table.PartitionSpec); err != nil {
+		if s.Logger != nil {
+			s.Logger.WarnContext(ctx, "table partition spec is invalid, writing default layout",
+				slog.Int64("table_id", tableID),
+				slog.Any("error", err),
+			)
+		}
+	} else {
+		layout.Spec = spec
+	}
+	if len(table.PrimaryKeyCols) > 0 {
+		if err := json.Unmarshal(table.PrimaryKeyCols, &layout.PrimaryKey); err != nil && s.Logger != nil {
+			s.Logger.WarnContext(ctx, "table primary key is invalid, writing append-only",
+				slog.Int64("table_id", tableID),
+				slog.Any("error", err),
+			)
+		}
 	}
 
-	if s.Logger != nil {
-		s.Logger.InfoContext(ctx, "coordinator published batch",
-			slog.String("tenant_id", group.TenantID),
-			slog.Int64("table_id", group.TableID),
-			slog.Int64("snapshot_id", snapshotID),
-			slog.Int64("max_visibility_token", group.MaxVisibilityToken),
-			slog.Int("event_count", len(group.Events)),
-			slog.String("object_path", dataFilePath),
-		)
+	version, err := s.Tables.GetCurrentTableSchema(ctx, tableID)
+	if err != nil {
+		if errors.Is(err, catalog.ErrNotFound) {
+			return layout, nil
+		}
+		return tableLayout{}, fmt.Errorf("get table schema: %w", err)
+	}
+	parsed, err := tableschema.Parse(version.SchemaJSON)
+	if err != nil {
+		if s.Logger != nil {
+			s.Logger.WarnContext(ctx, "table schema is invalid, writing envelope layout",
+				slog.Int64("table_id", tableID),
+				slog.Int("schema_version", version.SchemaVersion),
+				slog.Any("error", err),
+			)
+		}
+		return layout, nil
 	}
+	if !parsed.IsEmpty() {
+		for _, column := range layout.PrimaryKey {
+			if _, ok := parsed.Field(column); !ok {
+				parsed.Fields = append(parsed.Fields, tableschema.Field{Name: column, Type: tableschema.TypeJSON})
+			}
+		}
+	}
+	layout.Schema = parsed
+	return layout, nil
+}
 
-	return nil
+type eventPartition struct {
+	values []partition.Value
+	events []bus.Envelope
+}
+
+// partitionEvents splits events by the partition spec in order of first
+// appearance. Events without an event time are placed by processing time.
+func partitionEvents(events []bus.Envelope, spec partition.Spec, now time.Time) []eventPartition {
+	partitions := make([]eventPartition, 0)
+	index := map[string]int{}
+	for _, event := range events {
+		eventTime := now
+		if event.EventTimeUnixMs > 0 {
+			eventTime = time.UnixMilli(event.EventTimeUnixMs)
+		}
+		values := spec.Values(event.PayloadJSON, eventTime)
+		key := partition.Key(values)
+		i, ok := index[key]
+		if !ok {
+			i = len(partitions)
+			index[key] = i
+			partitions = append(partitions, eventPartition{values: values})
+		}
+		partitions[i].events = append(partitions[i].events, event)
+	}
+	return partitions
+}
+
+// splitEventsForLayout separates upserts from deletes for tables with a primary
+// key and rejects events whose payload does not carry every key column. Tables
+// without a primary key stay append-only and keep deletes as regular rows.
+func splitEventsForLayout(events []bus.Envelope, layout tableLayout) ([]bus.Envelope, []bus.Envelope, []RejectedEvent) {
+	if len(layout.PrimaryKey) == 0 {
+		return events, nil, nil
+	}
+
+	upserts := make([]bus.Envelope, 0, len(events))
+	deletes := make([]bus.Envelope, 0)
+	rejected := make([]RejectedEvent, 0)
+	for _, event := range events {
+		if reason := missingPrimaryKey(event, layout.PrimaryKey); reason != "" {
+			rejected = append(rejected, RejectedEvent{Event: event, Reason: reason})
+			continue
+		}
+		if event.Op == "delete" {
+			deletes = append(deletes, event)
+			continue
+		}
+		upserts = append(upserts, event)
+	}
+	return upserts, deletes, rejected
+}
+
+func missingPrimaryKey(event bus.Envelope, primaryKey []string) string {
+	payload := map[string]any{}
+	if err := json.Unmarshal(event.PayloadJSON, &payload); err != nil {
+		return fmt.Sprintf("payload is not a JSON object: %v", err)
+	}
+	for _, column := range primaryKey {
+		if payload[column] == nil {
+			return fmt.Sprintf("primary key field %q is required", column)
+		}
+	}
+	return ""
+}
+
+type rejectedEventRecord struct {
+	EventID         string          `json:"event_id"`
+	IdempotencyKey  string          `json:"idempotency_key"`
+	Op              string          `json:"op"`
+	EventTimeUnixMs int64           `json:"event_time_unix_ms"`
+	Payload         json.RawMessage `json:"payload"`
+	Reason          string          `json:"reason"`
+}
+
+func (s *Service) writeRejectedEvents(ctx context.Context, tenantID, tableDir string, snapshotID int64, sequence int, rejected []RejectedEvent) (string, error) {
+	rejectPath, err := storage.BuildRejectFilePath(tenantID, tableDir, snapshotID, sequence)
+	if err != nil {
+		return "", fmt.Errorf("build reject file path: %w", err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	encoder := json.NewEncoder(buf)
+	for _, item := range rejected {
+		payload := json.RawMessage(item.Event.PayloadJSON)
+		if !json.Valid(payload) {
+			payload, _ = json.Marshal(string(item.Event.PayloadJSON))
+		}
+		if err := encoder.Encode(rejectedEventRecord{
+			EventID:         item.Event.EventID,
+			IdempotencyKey:  item.Event.IdempotencyKey,
+			Op:              item.Event.Op,
+			EventTimeUnixMs: item.Event.EventTimeUnixMs,
+			Payload:         payload,
+			Reason:          item.Reason,
+		}); err != nil {
+			return "", fmt.Errorf("encode rejected event: %w", err)
+		}
+	}
+
+	if _, err := s.ObjectStore.Put(ctx, rejectPath, bytesReader(buf.Bytes()), int64(buf.Len()), storage.PutOptions{ContentType: "application/x-ndjson"}); err != nil {
+		return "", fmt.Errorf("put rejected events object: %w", err)
+	}
+	return rejectPath, nil
+}
+
+func acceptedEventIDs(eventIDs []string, rejected []RejectedEvent) []string {
+	if len(rejected) == 0 {
+		return eventIDs
+	}
+	skip := make(map[string]struct{}, len(rejected))
+	for _, item := range rejected {
+		skip[item.Event.EventID] = struct{}{}
+	}
+	accepted := make([]string, 0, len(eventIDs)-len(rejected))
+	for _, eventID := range eventIDs {
+		if _, ok := skip[eventID]; !ok {
+			accepted = append(accepted, eventID)
+		}
+	}
+	return accepted
 }
 
 type groupedEvents struct {
@@ -169,6 +620,21 @@ type groupedEvents struct {
 	Events             []bus.Envelope
 	EventIDs           []string
 	MaxVisibilityToken int64
+	// Claims splits EventIDs by the claim batch they were claimed in.
+	Claims []claimedEvents
+}
+
+type claimedEvents struct {
+	BatchID  string
+	EventIDs []string
+}
+
+func (g groupedEvents) batchIDs() []string {
+	ids := make([]string, 0, len(g.Claims))
+	for _, claim := range g.Claims {
+		ids = append(ids, claim.BatchID)
+	}
+	return ids
 }
 
 func groupEventsByTenantAndTable(events []bus.Envelope) []groupedEvents {
@@ -2,14 +2,21 @@ package coordinator
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/duckmesh/duckmesh/internal/bus"
+	busmemory "github.com/duckmesh/duckmesh/internal/bus/memory"
+	"github.com/duckmesh/duckmesh/internal/catalog"
 	catalogpostgres "github.com/duckmesh/duckmesh/internal/catalog/postgres"
+	"github.com/duckmesh/duckmesh/internal/notify"
 	"github.com/duckmesh/duckmesh/internal/storage"
+	memorystore "github.com/duckmesh/duckmesh/internal/storage/memory"
 )
 
 func TestGroupEventsByTenantAndTable(t *testing.T) {
@@ -72,9 +79,517 @@ func TestProcessOncePublishesAndAcks(t *testing.T) {
 	}
 }
 
+func TestProcessOnceWithMemoryBusAndStore(t *testing.T) {
+	ctx := context.Background()
+	ingestBus := busmemory.NewIngestBus()
+	store := memorystore.New()
+	publisher := &stubPublisher{}
+
+	results, err := ingestBus.Publish(ctx, []bus.Envelope{
+		{TenantID: "tenant", TableID: "20", IdempotencyKey: "k1", Op: "insert", PayloadJSON: []byte(`{"a":1}`)},
+		{TenantID: "tenant", TableID: "20", IdempotencyKey: "k2", Op: "insert", PayloadJSON: []byte(`{"a":2}`)},
+	})
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	svc := &Service{
+		Bus:         ingestBus,
+		Publisher:   publisher,
+		ObjectStore: store,
+		Config:      Config{ConsumerID: "worker-test", ClaimLimit: 10, LeaseSeconds: 10, CreatedBy: "worker-test"},
+	}
+	if err := svc.ProcessOnce(ctx); err != nil {
+		t.Fatalf("ProcessOnce() error = %v", err)
+	}
+
+	if len(publisher.inputs) != 1 || publisher.inputs[0].MaxVisibilityToken != results[1].VisibilityToken {
+		t.Fatalf("publish inputs = %+v", publisher.inputs)
+	}
+	if keys := store.Keys(); len(keys) != 1 || keys[0] != publisher.inputs[0].Tables[0].Files[0].Path {
+		t.Fatalf("stored keys = %v", keys)
+	}
+	for _, result := range results {
+		if state, _ := ingestBus.State(result.EventID); state != bus.StateCommitted {
+			t.Fatalf("event %s state = %q", result.EventID, state)
+		}
+	}
+}
+
+func TestProcessOnceWritesRejectedEventsAndAcksAll(t *testing.T) {
+	busStub := &stubBus{
+		claimBatch: bus.Batch{
+			BatchID: "100",
+			Envelopes: []bus.Envelope{
+				{EventID: "10", TenantID: "tenant", TableID: "20", IdempotencyKey: "k1", Op: "insert", PayloadJSON: []byte(`{"id":1}`)},
+				{EventID: "11", TenantID: "tenant", TableID: "20", IdempotencyKey: "k2", Op: "insert", PayloadJSON: []byte(`{"id":"one"}`)},
+			},
+		},
+	}
+	publisher := &stubPublisher{}
+	store := &stubStore{}
+
+	svc := &Service{
+		Bus:         busStub,
+		Publisher:   publisher,
+		Tables:      stubTables{schemas: map[int64]string{20: `{"id":"bigint"}`}},
+		ObjectStore: store,
+		Clock: func() time.Time {
+			return time.Date(2026, time.February, 19, 12, 0, 0, 0, time.UTC)
+		},
+	}
+
+	if err := svc.ProcessOnce(context.Background()); err != nil {
+		t.Fatalf("ProcessOnce() error = %v", err)
+	}
+	if len(store.putKeys) != 2 || store.putKeys[0] != "tenant/table-20/rejects/reject-901-00901.ndjson" {
+		t.Fatalf("put keys = %v", store.putKeys)
+	}
+	if len(publisher.inputs) != 1 {
+		t.Fatalf("publish calls = %d", len(publisher.inputs))
+	}
+	if got := publisher.inputs[0].Tables[0].EventIDs; len(got) != 1 || got[0] != "10" {
+		t.Fatalf("published event ids = %v", got)
+	}
+	if publisher.inputs[0].MaxVisibilityToken != 11 || fmt.Sprint(publisher.inputs[0].RejectedEventIDs) != "[11]" {
+		t.Fatalf("publish input = %+v", publisher.inputs[0])
+	}
+	if len(busStub.acked) != 1 || len(busStub.acked[0]) != 2 {
+		t.Fatalf("acked = %v", busStub.acked)
+	}
+}
+
+func TestProcessOnceSkipsPublishWhenAllEventsRejected(t *testing.T) {
+	busStub := &stubBus{
+		claimBatch: bus.Batch{
+			BatchID: "100",
+			Envelopes: []bus.Envelope{
+				{EventID: "11", TenantID: "tenant", TableID: "20", IdempotencyKey: "k2", Op: "insert", PayloadJSON: []byte(`{"id":"one"}`)},
+			},
+		},
+	}
+	publisher := &stubPublisher{}
+	store := &stubStore{}
+
+	svc := &Service{
+		Bus:         busStub,
+		Publisher:   publisher,
+		Tables:      stubTables{schemas: map[int64]string{20: `{"id":"bigint"}`}},
+		ObjectStore: store,
+	}
+
+	if err := svc.ProcessOnce(context.Background()); err != nil {
+		t.Fatalf("ProcessOnce() error = %v", err)
+	}
+	if len(store.putKeys) != 1 {
+		t.Fatalf("put keys = %v", store.putKeys)
+	}
+	if len(publisher.inputs) != 0 {
+		t.Fatalf("publish calls = %d", len(publisher.inputs))
+	}
+	if len(busStub.acked) != 1 {
+		t.Fatalf("ack calls = %d", len(busStub.acked))
+	}
+}
+
+func TestProcessOnceWritesDeleteFilesForKeyedTables(t *testing.T) {
+	busStub := &stubBus{
+		claimBatch: bus.Batch{
+			BatchID: "100",
+			Envelopes: []bus.Envelope{
+				{EventID: "10", TenantID: "tenant", TableID: "20", IdempotencyKey: "k1", Op: "upsert", PayloadJSON: []byte(`{"id":1,"name":"a"}`)},
+				{EventID: "11", TenantID: "tenant", TableID: "20", IdempotencyKey: "k2", Op: "delete", PayloadJSON: []byte(`{"id":2}`)},
+				{EventID: "12", TenantID: "tenant", TableID: "20", IdempotencyKey: "k3", Op: "delete", PayloadJSON: []byte(`{"name":"b"}`)},
+			},
+		},
+	}
+	publisher := &stubPublisher{}
+	store := &stubStore{}
+
+	svc := &Service{
+		Bus:       busStub,
+		Publisher: publisher,
+		Tables: stubTables{
+			schemas:     map[int64]string{20: `{"id":"bigint","name":"varchar"}`},
+			primaryKeys: map[int64]string{20: `["id"]`},
+		},
+		ObjectStore: store,
+		Clock: func() time.Time {
+			return time.Date(2026, time.February, 19, 12, 0, 0, 0, time.UTC)
+		},
+	}
+
+	if err := svc.ProcessOnce(context.Background()); err != nil {
+		t.Fatalf("ProcessOnce() error = %v", err)
+	}
+	wantKeys := []string{
+		"tenant/table-20/rejects/reject-901-00901.ndjson",
+		"tenant/table-20/date=2026-02-19/hour=2026-02-19-12/part-901-00901.parquet",
+		"tenant/table-20/deletes/delete-901-00901.parquet",
+	}
+	if fmt.Sprint(store.putKeys) != fmt.Sprint(wantKeys) {
+		t.Fatalf("put keys = %v, want %v", store.putKeys, wantKeys)
+	}
+	if len(publisher.inputs) != 1 {
+		t.Fatalf("publish calls = %d", len(publisher.inputs))
+	}
+	files := publisher.inputs[0].Tables[0].Files
+	if len(files) != 2 || files[0].Content != catalog.DataFileContentData || files[1].Content != catalog.DataFileContentDelete {
+		t.Fatalf("published files = %+v", files)
+	}
+	if got := publisher.inputs[0].Tables[0].EventIDs; fmt.Sprint(got) != "[10 11]" {
+		t.Fatalf("published event ids = %v", got)
+	}
+}
+
+func TestProcessOnceSplitsGroupByPartitionSpec(t *testing.T) {
+	busStub := &stubBus{
+		claimBatch: bus.Batch{
+			BatchID: "100",
+			Envelopes: []bus.Envelope{
+				{EventID: "10", TenantID: "tenant", TableID: "20", IdempotencyKey: "k1", Op: "insert", EventTimeUnixMs: time.Date(2026, 2, 18, 23, 0, 0, 0, time.UTC).UnixMilli(), PayloadJSON: []byte(`{"region":"eu"}`)},
+				{EventID: "11", TenantID: "tenant", TableID: "20", IdempotencyKey: "k2", Op: "insert", PayloadJSON: []byte(`{"region":"us"}`)},
+				{EventID: "12", TenantID: "tenant", TableID: "20", IdempotencyKey: "k3", Op: "insert", EventTimeUnixMs: time.Date(2026, 2, 18, 8, 0, 0, 0, time.UTC).UnixMilli(), PayloadJSON: []byte(`{"region":"eu"}`)},
+				{EventID: "13", TenantID: "tenant", TableID: "20", IdempotencyKey: "k4", Op: "insert", PayloadJSON: []byte(`{}`)},
+			},
+		},
+	}
+	publisher := &stubPublisher{}
+	store := &stubStore{}
+
+	svc := &Service{
+		Bus:         busStub,
+		Publisher:   publisher,
+		Tables:      stubTables{partitionSpecs: map[int64]string{20: `{"fields":[{"column":"event_time","transform":"day"},{"column":"region"}]}`}},
+		ObjectStore: store,
+		Clock: func() time.Time {
+			return time.Date(2026, time.February, 19, 12, 0, 0, 0, time.UTC)
+		},
+	}
+
+	if err := svc.ProcessOnce(context.Background()); err != nil {
+		t.Fatalf("ProcessOnce() error = %v", err)
+	}
+	wantKeys := []string{
+		"tenant/table-20/date=2026-02-18/region=eu/part-901-00901.parquet",
+		"tenant/table-20/date=2026-02-19/region=us/part-901-00901.parquet",
+		"tenant/table-20/date=2026-02-19/region=__null__/part-901-00901.parquet",
+	}
+	if fmt.Sprint(store.putKeys) != fmt.Sprint(wantKeys) {
+		t.Fatalf("put keys = %v, want %v", store.putKeys, wantKeys)
+	}
+	files := publisher.inputs[0].Tables[0].Files
+	if len(files) != 3 || files[0].RecordCount != 2 || files[1].RecordCount != 1 {
+		t.Fatalf("published files = %+v", files)
+	}
+	if got := fmt.Sprint(files[0].PartitionValues); got != "map[date:2026-02-18 region:eu]" {
+		t.Fatalf("partition values = %s", got)
+	}
+}
+
+func TestProcessOnceNacksFailingGroupAndPublishesOthers(t *testing.T) {
+	busStub := &stubBus{
+		claimBatch: bus.Batch{
+			BatchID: "100",
+			Envelopes: []bus.Envelope{
+				{EventID: "10", TenantID: "tenant", TableID: "20", IdempotencyKey: "k1", Op: "insert", PayloadJSON: []byte(`{"a":1}`)},
+				{EventID: "11", TenantID: "tenant", TableID: "21", IdempotencyKey: "k2", Op: "insert", PayloadJSON: []byte(`{"a":2}`)},
+			},
+		},
+	}
+	publisher := &stubPublisher{}
+	svc := &Service{Bus: busStub, Publisher: publisher, ObjectStore: &stubStore{failPrefix: "tenant/table-20/"}}
+
+	err := svc.ProcessOnce(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "store unavailable") {
+		t.Fatalf("ProcessOnce() error = %v", err)
+	}
+	if len(busStub.nacked) != 1 || fmt.Sprint(busStub.nacked[0].eventIDs) != "[10]" {
+		t.Fatalf("nacked = %+v", busStub.nacked)
+	}
+	if !strings.Contains(busStub.nacked[0].reason, "store unavailable") {
+		t.Fatalf("nack reason = %q", busStub.nacked[0].reason)
+	}
+	if len(publisher.inputs) != 1 || len(publisher.inputs[0].Tables) != 1 || publisher.inputs[0].Tables[0].TableID != 21 {
+		t.Fatalf("publish inputs = %+v", publisher.inputs)
+	}
+	if len(busStub.acked) != 1 || fmt.Sprint(busStub.acked[0]) != "[11]" {
+		t.Fatalf("acked = %v", busStub.acked)
+	}
+}
+
+func TestProcessOnceKeepsWatermarkBelowFailingGroup(t *testing.T) {
+	busStub := &stubBus{
+		claimBatch: bus.Batch{
+			BatchID: "100",
+			Envelopes: []bus.Envelope{
+				{EventID: "10", TenantID: "tenant", TableID: "20", IdempotencyKey: "k1", Op: "insert", PayloadJSON: []byte(`{"a":1}`)},
+				{EventID: "11", TenantID: "tenant", TableID: "21", IdempotencyKey: "k2", Op: "insert", PayloadJSON: []byte(`{"a":2}`)},
+			},
+		},
+	}
+	publisher := &stubPublisher{}
+	svc := &Service{Bus: busStub, Publisher: publisher, ObjectStore: &stubStore{failPrefix: "tenant/table-21/"}}
+
+	if err := svc.ProcessOnce(context.Background()); err == nil {
+		t.Fatal("ProcessOnce() error = nil")
+	}
+	if len(publisher.inputs) != 1 || len(publisher.inputs[0].Tables) != 1 || publisher.inputs[0].Tables[0].TableID != 20 {
+		t.Fatalf("publish inputs = %+v", publisher.inputs)
+	}
+	if publisher.inputs[0].MaxVisibilityToken != 10 {
+		t.Fatalf("max visibility token = %d, want 10", publisher.inputs[0].MaxVisibilityToken)
+	}
+	if len(busStub.nacked) != 1 || fmt.Sprint(busStub.nacked[0].eventIDs) != "[11]" {
+		t.Fatalf("nacked = %+v", busStub.nacked)
+	}
+}
+
+func TestProcessOncePublishesTenantTablesInOneSnapshot(t *testing.T) {
+	busStub := &stubBus{
+		claimBatch: bus.Batch{
+			BatchID: "100",
+			Envelopes: []bus.Envelope{
+				{EventID: "10", TenantID: "tenant", TableID: "20", IdempotencyKey: "k1", Op: "insert", PayloadJSON: []byte(`{"a":1}`)},
+				{EventID: "11", TenantID: "tenant", TableID: "21", IdempotencyKey: "k2", Op: "insert", PayloadJSON: []byte(`{"a":2}`)},
+				{EventID: "12", TenantID: "other", TableID: "30", IdempotencyKey: "k3", Op: "insert", PayloadJSON: []byte(`{"a":3}`)},
+			},
+		},
+	}
+	publisher := &stubPublisher{}
+	svc := &Service{Bus: busStub, Publisher: publisher, ObjectStore: &stubStore{}}
+
+	if err := svc.ProcessOnce(context.Background()); err != nil {
+		t.Fatalf("ProcessOnce() error = %v", err)
+	}
+	if len(publisher.inputs) != 2 {
+		t.Fatalf("publish calls = %d, want one per tenant", len(publisher.inputs))
+	}
+	input := publisher.inputs[0]
+	if input.TenantID != "tenant" || len(input.Tables) != 2 || input.Tables[0].TableID != 20 || input.Tables[1].TableID != 21 {
+		t.Fatalf("publish input = %+v", input)
+	}
+	if fmt.Sprint(input.BatchIDs) != "[100]" || input.MaxVisibilityToken != 11 {
+		t.Fatalf("publish input = %+v", input)
+	}
+	if len(busStub.acked) != 3 {
+		t.Fatalf("acked = %v", busStub.acked)
+	}
+}
+
+func TestProcessOnceNacksAllTenantTablesWhenPublishFails(t *testing.T) {
+	busStub := &stubBus{
+		claimBatch: bus.Batch{
+			BatchID: "100",
+			Envelopes: []bus.Envelope{
+				{EventID: "10", TenantID: "tenant", TableID: "20", IdempotencyKey: "k1", Op: "insert", PayloadJSON: []byte(`{"a":1}`)},
+				{EventID: "11", TenantID: "tenant", TableID: "21", IdempotencyKey: "k2", Op: "insert", PayloadJSON: []byte(`{"a":2}`)},
+			},
+		},
+	}
+	publisher := &stubPublisher{failTableID: 20}
+	svc := &Service{Bus: busStub, Publisher: publisher, ObjectStore: &stubStore{}}
+
+	err := svc.ProcessOnce(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "catalog unavailable") {
+		t.Fatalf("ProcessOnce() error = %v", err)
+	}
+	if len(busStub.nacked) != 2 || fmt.Sprint(busStub.nacked[0].eventIDs) != "[10]" || fmt.Sprint(busStub.nacked[1].eventIDs) != "[11]" {
+		t.Fatalf("nacked = %+v", busStub.nacked)
+	}
+	if !strings.Contains(busStub.nacked[1].reason, "publish snapshot batch: catalog unavailable") {
+		t.Fatalf("nack reason = %q", busStub.nacked[1].reason)
+	}
+	if len(busStub.acked) != 0 {
+		t.Fatalf("acked = %v", busStub.acked)
+	}
+}
+
+func TestProcessOnceWritesGroupsInParallelAcrossWorkers(t *testing.T) {
+	envelopes := make([]bus.Envelope, 0, 4)
+	for i := range 4 {
+		envelopes = append(envelopes, bus.Envelope{EventID: fmt.Sprint(10 + i), TenantID: fmt.Sprintf("tenant-%d", i%2), TableID: fmt.Sprint(20 + i), IdempotencyKey: fmt.Sprintf("k%d", i), Op: "insert", PayloadJSON: []byte(`{"a":1}`)})
+	}
+	busStub := &stubBus{claimBatch: bus.Batch{BatchID: "100", Envelopes: envelopes}}
+	publisher := &stubPublisher{}
+	store := &stubStore{putDelay: 50 * time.Millisecond}
+	svc := &Service{Bus: busStub, Publisher: publisher, ObjectStore: store, Config: Config{Workers: 4}}
+
+	if err := svc.ProcessOnce(context.Background()); err != nil {
+		t.Fatalf("ProcessOnce() error = %v", err)
+	}
+	if store.maxActive != 4 {
+		t.Fatalf("max concurrent uploads = %d, want 4", store.maxActive)
+	}
+	if len(publisher.inputs) != 2 || publisher.inputs[0].TenantID != "tenant-0" || publisher.inputs[1].TenantID != "tenant-1" {
+		t.Fatalf("publish inputs = %+v", publisher.inputs)
+	}
+	if len(busStub.acked) != 4 {
+		t.Fatalf("acked = %v", busStub.acked)
+	}
+}
+
+func TestProcessOnceExtendsLeaseWhileBatchIsInFlight(t *testing.T) {
+	busStub := &stubBus{
+		claimBatch: bus.Batch{
+			BatchID:   "100",
+			Envelopes: []bus.Envelope{{EventID: "10", TenantID: "tenant", TableID: "20", IdempotencyKey: "k1", Op: "insert", PayloadJSON: []byte(`{"a":1}`)}},
+		},
+	}
+	svc := &Service{
+		Bus:         busStub,
+		Publisher:   &stubPublisher{},
+		ObjectStore: &stubStore{putDelay: 100 * time.Millisecond},
+		Config:      Config{HeartbeatInterval: 10 * time.Millisecond},
+	}
+
+	if err := svc.ProcessOnce(context.Background()); err != nil {
+		t.Fatalf("ProcessOnce() error = %v", err)
+	}
+	busStub.mu.Lock()
+	extended := busStub.extended
+	busStub.mu.Unlock()
+	if extended == 0 {
+		t.Fatal("lease was not extended during a slow upload")
+	}
+	if len(busStub.acked) != 1 {
+		t.Fatalf("acked = %v", busStub.acked)
+	}
+}
+
+func TestProcessOnceAbandonsBatchWhenLeaseIsLost(t *testing.T) {
+	busStub := &stubBus{
+		claimBatch: bus.Batch{
+			BatchID:   "100",
+			Envelopes: []bus.Envelope{{EventID: "10", TenantID: "tenant", TableID: "20", IdempotencyKey: "k1", Op: "insert", PayloadJSON: []byte(`{"a":1}`)}},
+		},
+		extendErr: errors.New("claim batch 100 not found or not claimable"),
+	}
+	publisher := &stubPublisher{}
+	svc := &Service{
+		Bus:         busStub,
+		Publisher:   publisher,
+		ObjectStore: &stubStore{putDelay: time.Second},
+		Config:      Config{HeartbeatInterval: 10 * time.Millisecond},
+	}
+
+	err := svc.ProcessOnce(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "not claimable") {
+		t.Fatalf("ProcessOnce() error = %v", err)
+	}
+	if len(publisher.inputs) != 0 || len(busStub.acked) != 0 {
+		t.Fatalf("abandoned batch was published: inputs=%d acked=%v", len(publisher.inputs), busStub.acked)
+	}
+	if len(busStub.nacked) != 1 || !strings.Contains(busStub.nacked[0].reason, "extend lease for batch 100") {
+		t.Fatalf("nacked = %+v", busStub.nacked)
+	}
+}
+
+func TestRunSweepsExpiredClaims(t *testing.T) {
+	busStub := &stubBus{}
+	svc := &Service{
+		Bus:         busStub,
+		Publisher:   &stubPublisher{},
+		ObjectStore: &stubStore{},
+		Config:      Config{PollInterval: 10 * time.Millisecond, SweepInterval: 10 * time.Millisecond},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := svc.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	busStub.mu.Lock()
+	defer busStub.mu.Unlock()
+	if busStub.requeued == 0 {
+		t.Fatal("RequeueExpired was never called")
+	}
+}
+
+func TestRunClaimsOnIngestNotificationInsteadOfPolling(t *testing.T) {
+	busStub := &stubBus{}
+	hub := notify.NewHub()
+	svc := &Service{
+		Bus:           busStub,
+		Publisher:     &stubPublisher{},
+		ObjectStore:   &stubStore{},
+		Notifications: hub,
+		Config:        Config{PollInterval: time.Millisecond, FallbackPollInterval: time.Hour},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- svc.Run(ctx) }()
+
+	waitForClaims := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			busStub.mu.Lock()
+			claims := busStub.claims
+			busStub.mu.Unlock()
+			if claims >= want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("claims = %d, want %d", claims, want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	waitForClaims(1)
+	time.Sleep(50 * time.Millisecond)
+	busStub.mu.Lock()
+	idleClaims := busStub.claims
+	busStub.mu.Unlock()
+	if idleClaims != 1 {
+		t.Fatalf("claims while idle = %d, want 1", idleClaims)
+	}
+
+	hub.Notify(notify.ChannelIngest, "tenant-1")
+	waitForClaims(2)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+}
+
+type stubTables struct {
+	schemas        map[int64]string
+	primaryKeys    map[int64]string
+	partitionSpecs map[int64]string
+}
+
+func (s stubTables) GetTableByID(_ context.Context, tableID int64) (catalog.TableDef, error) {
+	return catalog.TableDef{
+		TableID:        tableID,
+		PrimaryKeyCols: []byte(s.primaryKeys[tableID]),
+		PartitionSpec:  []byte(s.partitionSpecs[tableID]),
+	}, nil
+}
+
+func (s stubTables) GetCurrentTableSchema(_ context.Context, tableID int64) (catalog.TableSchemaVersion, error) {
+	schemaJSON, ok := s.schemas[tableID]
+	if !ok {
+		return catalog.TableSchemaVersion{}, catalog.ErrNotFound
+	}
+	return catalog.TableSchemaVersion{TableID: tableID, SchemaVersion: 1, SchemaJSON: []byte(schemaJSON)}, nil
+}
+
 type stubBus struct {
+	mu         sync.Mutex
 	claimBatch bus.Batch
 	acked      [][]string
+	nacked     []stubNack
+	extended   int
+	extendErr  error
+	requeued   int
+	claims     int
+}
+
+type stubNack struct {
+	eventIDs []string
+	reason   string
 }
 
 func (s *stubBus) Publish(context.Context, []bus.Envelope) ([]bus.PublishResult, error) {
@@ -82,29 +597,44 @@ func (s *stubBus) Publish(context.Context, []bus.Envelope) ([]bus.PublishResult,
 }
 
 func (s *stubBus) ClaimBatch(context.Context, string, int, int) (bus.Batch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.claims++
 	return s.claimBatch, nil
 }
 
 func (s *stubBus) Ack(_ context.Context, _ string, eventIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.acked = append(s.acked, append([]string(nil), eventIDs...))
 	return nil
 }
 
-func (s *stubBus) Nack(context.Context, string, []string, string) error {
+func (s *stubBus) Nack(_ context.Context, _ string, eventIDs []string, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nacked = append(s.nacked, stubNack{eventIDs: append([]string(nil), eventIDs...), reason: reason})
 	return nil
 }
 
 func (s *stubBus) ExtendLease(context.Context, string, int) error {
-	return nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.extended++
+	return s.extendErr
 }
 
 func (s *stubBus) RequeueExpired(context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requeued++
 	return 0, nil
 }
 
 type stubPublisher struct {
-	nextID int64
-	inputs []catalogpostgres.PublishBatchInput
+	nextID      int64
+	inputs      []catalogpostgres.PublishBatchInput
+	failTableID int64
 }
 
 func (s *stubPublisher) AllocateSnapshotID(context.Context) (int64, error) {
@@ -116,17 +646,49 @@ func (s *stubPublisher) AllocateSnapshotID(context.Context) (int64, error) {
 }
 
 func (s *stubPublisher) PublishBatch(_ context.Context, in catalogpostgres.PublishBatchInput) (catalogpostgres.PublishBatchResult, error) {
+	for _, table := range in.Tables {
+		if table.TableID == s.failTableID {
+			return catalogpostgres.PublishBatchResult{}, fmt.Errorf("catalog unavailable")
+		}
+	}
 	s.inputs = append(s.inputs, in)
-	return catalogpostgres.PublishBatchResult{SnapshotID: in.SnapshotID, FileID: 1}, nil
+	return catalogpostgres.PublishBatchResult{SnapshotID: in.SnapshotID, MaxVisibilityToken: in.MaxVisibilityToken, FileIDs: []int64{1}}, nil
 }
 
 type stubStore struct {
-	putKeys []string
+	mu         sync.Mutex
+	putKeys    []string
+	putDelay   time.Duration
+	failPrefix string
+	active     int
+	maxActive  int
 }
 
-func (s *stubStore) Put(_ context.Context, key string, body io.Reader, size int64, _ storage.PutOptions) (storage.ObjectInfo, error) {
+func (s *stubStore) Put(ctx context.Context, key string, body io.Reader, size int64, _ storage.PutOptions) (storage.ObjectInfo, error) {
+	s.mu.Lock()
+	s.active++
+	s.maxActive = max(s.maxActive, s.active)
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.active--
+		s.mu.Unlock()
+	}()
+
+	if s.putDelay > 0 {
+		select {
+		case <-time.After(s.putDelay):
+		case <-ctx.Done():
+			return storage.ObjectInfo{}, ctx.Err()
+		}
+	}
+	if s.failPrefix != "" && strings.HasPrefix(key, s.failPrefix) {
+		return storage.ObjectInfo{}, fmt.Errorf("store unavailable")
+	}
 	_, _ = io.Copy(io.Discard, body)
+	s.mu.Lock()
 	s.putKeys = append(s.putKeys, key)
+	s.mu.Unlock()
 	return storage.ObjectInfo{Key: key, Size: size, ETag: "etag"}, nil
 }
 
@@ -109,7 +109,7 @@ func (s *Service) ensureTable(ctx context.Context) error {
 	req := createTableRequest{
 		TableName:      s.cfg.TableName,
 		PrimaryKeyCols: []string{"event_id"},
-		PartitionSpec:  map[string]any{"date": "day"},
+		PartitionSpec:  map[string]any{"fields": []map[string]string{{"column": "event_time", "transform": "day"}}},
 		SchemaJSON: map[string]any{
 			"event_id":    "bigint",
 			"user_id":     "varchar",
@@ -21,13 +21,13 @@ func mergeParquetFiles(ctx context.Context, inputPaths []string, outputPath stri
 	defer func() { _ = db.Close() }()
 
 	var recordCount int64
-	countSQL := fmt.Sprintf(`SELECT COUNT(*) FROM read_parquet(%s)`, quoteStringArray(inputPaths))
+	countSQL := fmt.Sprintf(`SELECT COUNT(*) FROM read_parquet(%s, union_by_name = true)`, quoteStringArray(inputPaths))
 	if err := db.QueryRowContext(ctx, countSQL).Scan(&recordCount); err != nil {
 		return 0, fmt.Errorf("count merged rows: %w", err)
 	}
 
 	copySQL := fmt.Sprintf(
-		`COPY (SELECT * FROM read_parquet(%s)) TO %s (FORMAT PARQUET, COMPRESSION ZSTD)`,
+		`COPY (SELECT * FROM read_parquet(%s, union_by_name = true)) TO %s (FORMAT PARQUET, COMPRESSION ZSTD)`,
 		quoteStringArray(inputPaths),
 		quoteString(outputPath),
 	)
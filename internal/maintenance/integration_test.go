@@ -16,6 +16,7 @@ import (
 
 	"github.com/duckmesh/duckmesh/internal/bus"
 	buspostgres "github.com/duckmesh/duckmesh/internal/bus/postgres"
+	"github.com/duckmesh/duckmesh/internal/catalog"
 	catalogpostgres "github.com/duckmesh/duckmesh/internal/catalog/postgres"
 	"github.com/duckmesh/duckmesh/internal/coordinator"
 	"github.com/duckmesh/duckmesh/internal/migrations"
@@ -231,6 +232,108 @@ func TestRetentionRespectsKeepSnapshotsSafetyWindow(t *testing.T) {
 	}
 }
 
+func TestRetentionKeepsFilesPinnedByQueuedQueryJob(t *testing.T) {
+	adminDSN := strings.TrimSpace(os.Getenv("DUCKMESH_TEST_CATALOG_DSN"))
+	if adminDSN == "" {
+		t.Skip("DUCKMESH_TEST_CATALOG_DSN is not set")
+	}
+
+	testDSN, cleanup := createTemporaryDatabase(t, adminDSN, "maintenance")
+	defer cleanup()
+
+	db, err := sql.Open("pgx", testDSN)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 45*time.Second)
+	defer cancel()
+	if _, err := migrations.NewRunner().Up(ctx, db, 0); err != nil {
+		t.Fatalf("runner.Up() error = %v", err)
+	}
+
+	tenantID := "tenant-pinned"
+	tableName := "events"
+	seedTenantAndTable(t, db, tenantID, tableName)
+	tableID := fetchTableID(t, db, tenantID, tableName)
+
+	store := newTestStore(t, ctx, "maintenance-pinned")
+	repo := catalogpostgres.NewRepository(db)
+	ingestBus := buspostgres.NewIngestBus(db)
+	coord := &coordinator.Service{
+		Bus:         ingestBus,
+		Publisher:   repo,
+		ObjectStore: store,
+		Config: coordinator.Config{
+			ConsumerID:   "maintenance-pinned-coord",
+			ClaimLimit:   100,
+			LeaseSeconds: 30,
+			CreatedBy:    "maintenance-pinned-coord",
+		},
+	}
+
+	publishAndMaterializeEvents(t, ctx, ingestBus, coord, tenantID, tableID, 4, 200)
+	preCompactionSnapshot, err := repo.GetLatestSnapshot(ctx, tenantID)
+	if err != nil {
+		t.Fatalf("GetLatestSnapshot() before compaction error = %v", err)
+	}
+	pinnedFiles, err := repo.ListSnapshotFilesForTable(ctx, tenantID, preCompactionSnapshot.SnapshotID, tableID)
+	if err != nil {
+		t.Fatalf("ListSnapshotFilesForTable() before compaction error = %v", err)
+	}
+	job, err := repo.CreateQueryJob(ctx, catalog.CreateQueryJobInput{
+		TenantID:           tenantID,
+		SQL:                "SELECT count(*) FROM events",
+		Format:             "parquet",
+		PlanJSON:           []byte(`{}`),
+		SnapshotID:         preCompactionSnapshot.SnapshotID,
+		MaxVisibilityToken: preCompactionSnapshot.MaxVisibilityToken,
+	})
+	if err != nil {
+		t.Fatalf("CreateQueryJob() error = %v", err)
+	}
+
+	svc := &Service{
+		Catalog:     repo,
+		ObjectStore: store,
+		Config: Config{
+			CompactionMinInputFiles: 2,
+			KeepSnapshots:           1,
+			GCSafetyAge:             time.Nanosecond,
+			CreatedBy:               "maintenance-test",
+		},
+	}
+	if _, err := svc.RunCompactionOnce(ctx, tenantID); err != nil {
+		t.Fatalf("RunCompactionOnce() error = %v", err)
+	}
+	publishAndMaterializeEvents(t, ctx, ingestBus, coord, tenantID, tableID, 1, 300)
+
+	pinned, err := svc.RunRetentionOnce(ctx, tenantID)
+	if err != nil {
+		t.Fatalf("RunRetentionOnce() with queued job error = %v (summary=%+v)", err, pinned)
+	}
+	if pinned.FilesDeleted != 0 {
+		t.Fatalf("FilesDeleted with queued job = %d, want 0", pinned.FilesDeleted)
+	}
+	for _, file := range pinnedFiles {
+		if _, err := store.Stat(ctx, file.Path); err != nil {
+			t.Fatalf("pinned object %s was removed: %v", file.Path, err)
+		}
+	}
+
+	if _, err := repo.CancelQueryJob(ctx, tenantID, job.JobID); err != nil {
+		t.Fatalf("CancelQueryJob() error = %v", err)
+	}
+	released, err := svc.RunRetentionOnce(ctx, tenantID)
+	if err != nil {
+		t.Fatalf("RunRetentionOnce() after cancel error = %v (summary=%+v)", err, released)
+	}
+	if released.FilesDeleted != len(pinnedFiles) {
+		t.Fatalf("FilesDeleted after cancel = %d, want %d", released.FilesDeleted, len(pinnedFiles))
+	}
+}
+
 func TestIntegrityCheckDetectsMissingVisibleFile(t *testing.T) {
 	adminDSN := strings.TrimSpace(os.Getenv("DUCKMESH_TEST_CATALOG_DSN"))
 	if adminDSN == "" {
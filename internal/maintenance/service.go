@@ -14,7 +14,10 @@ import (
 
 	"github.com/duckmesh/duckmesh/internal/catalog"
 	catalogpostgres "github.com/duckmesh/duckmesh/internal/catalog/postgres"
+	"github.com/duckmesh/duckmesh/internal/colstats"
+	"github.com/duckmesh/duckmesh/internal/partition"
 	"github.com/duckmesh/duckmesh/internal/storage"
+	"github.com/duckmesh/duckmesh/internal/storage/cache"
 )
 
 type Catalog interface {
@@ -44,6 +47,7 @@ type Config struct {
 type Service struct {
 	Catalog     Catalog
 	ObjectStore storage.ObjectStore
+	Cache       *cache.Cache
 	Config      Config
 	Logger      *slog.Logger
 	Clock       func() time.Time
@@ -157,20 +161,28 @@ func (s *Service) RunCompactionOnce(ctx context.Context, tenantID string) (Compa
 				failures = append(failures, fmt.Sprintf("tenant %s table %s snapshot files: %v", tenant.TenantID, table.TableName, err))
 				continue
 			}
-			if len(files) < s.Config.CompactionMinInputFiles {
-				continue
+			// Files are only merged within a partition. An invalid spec still
+			// groups by the stored values; it only loses the path order.
+			spec, _ := partition.Parse(table.PartitionSpec)
+			compacted := false
+			for _, group := range groupFilesByPartition(files, spec) {
+				if len(group.files) < s.Config.CompactionMinInputFiles {
+					continue
+				}
+				tableSummary, err := s.compactTable(ctx, tenant.TenantID, table.TableID, table.TableName, snapshot.MaxVisibilityToken, group.values, group.files)
+				if err != nil {
+					summary.Failures++
+					failures = append(failures, fmt.Sprintf("tenant %s table %s partition %s compaction: %v", tenant.TenantID, table.TableName, partition.Key(group.values), err))
+					continue
+				}
+				compacted = true
+				summary.InputFilesCompacted += tableSummary.inputFiles
+				summary.BytesRewritten += tableSummary.bytesRewritten
+				summary.SnapshotsPublished++
 			}
-
-			tableSummary, err := s.compactTable(ctx, tenant.TenantID, table.TableID, table.TableName, snapshot.MaxVisibilityToken, files)
-			if err != nil {
-				summary.Failures++
-				failures = append(failures, fmt.Sprintf("tenant %s table %s compaction: %v", tenant.TenantID, table.TableName, err))
-				continue
+			if compacted {
+				summary.TablesCompacted++
 			}
-			summary.TablesCompacted++
-			summary.InputFilesCompacted += tableSummary.inputFiles
-			summary.BytesRewritten += tableSummary.bytesRewritten
-			summary.SnapshotsPublished++
 		}
 	}
 
@@ -347,12 +359,66 @@ func (s *Service) RunIntegrityCheckOnce(ctx context.Context, tenantID string) (I
 	return summary, nil
 }
 
+type partitionFiles struct {
+	values []partition.Value
+	files  []catalog.SnapshotFileEntry
+}
+
+func groupFilesByPartition(files []catalog.SnapshotFileEntry, spec partition.Spec) []partitionFiles {
+	groups := make([]partitionFiles, 0)
+	index := map[string]int{}
+	for _, file := range files {
+		values := spec.Order(file.PartitionValues)
+		key := partition.Key(values)
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, partitionFiles{values: values})
+		}
+		groups[i].files = append(groups[i].files, file)
+	}
+	return groups
+}
+
+// eventTimeRange spans the event times of the input files. It is unknown when
+// any input has no recorded range.
+func eventTimeRange(files []catalog.SnapshotFileEntry) (*time.Time, *time.Time) {
+	var minTime, maxTime *time.Time
+	for _, file := range files {
+		if file.MinEventTime == nil || file.MaxEventTime == nil {
+			return nil, nil
+		}
+		if minTime == nil || file.MinEventTime.Before(*minTime) {
+			minTime = file.MinEventTime
+		}
+		if maxTime == nil || file.MaxEventTime.After(*maxTime) {
+			maxTime = file.MaxEventTime
+		}
+	}
+	return minTime, maxTime
+}
+
+// mergeColumnStats combines the column statistics of compacted files, or
+// returns nil when any of them has none.
+func mergeColumnStats(files []catalog.SnapshotFileEntry) map[string]colstats.Column {
+	parts := make([]colstats.Part, 0, len(files))
+	for _, file := range files {
+		stats, err := colstats.Parse(file.StatsJSON)
+		if err != nil {
+			return nil
+		}
+		parts = append(parts, colstats.Part{Rows: file.RecordCount, Columns: stats.Columns})
+	}
+	return colstats.Merge(parts)
+}
+
 type compactedTableSummary struct {
 	inputFiles     int
 	bytesRewritten int64
 }
 
-func (s *Service) compactTable(ctx context.Context, tenantID string, tableID int64, tableName string, maxVisibilityToken int64, files []catalog.SnapshotFileEntry) (compactedTableSummary, error) {
+func (s *Service) compactTable(ctx context.Context, tenantID string, tableID int64, tableName string, maxVisibilityToken int64, values []partition.Value, files []catalog.SnapshotFileEntry) (compactedTableSummary, error) {
 	workDir, err := os.MkdirTemp("", "duckmesh-compact-")
 	if err != nil {
 		return compactedTableSummary{}, fmt.Errorf("create compaction temp dir: %w", err)
@@ -365,18 +431,9 @@ func (s *Service) compactTable(ctx context.Context, tenantID string, tableID int
 	var sourceRecords int64
 
 	for i, file := range files {
-		reader, err := s.ObjectStore.Get(ctx, file.Path)
-		if err != nil {
-			return compactedTableSummary{}, fmt.Errorf("download source object %s: %w", file.Path, err)
-		}
-
 		localPath := filepath.Join(workDir, fmt.Sprintf("input_%03d.parquet", i))
-		if err := writeLocalFile(localPath, reader); err != nil {
-			_ = reader.Close()
-			return compactedTableSummary{}, fmt.Errorf("write local source file %s: %w", localPath, err)
-		}
-		if err := reader.Close(); err != nil {
-			return compactedTableSummary{}, fmt.Errorf("close source object %s: %w", file.Path, err)
+		if err := s.downloadSource(ctx, file.Path, localPath); err != nil {
+			return compactedTableSummary{}, err
 		}
 		inputPaths = append(inputPaths, localPath)
 		removedFileIDs = append(removedFileIDs, file.FileID)
@@ -407,7 +464,11 @@ func (s *Service) compactTable(ctx context.Context, tenantID string, tableID int
 	if err != nil {
 		return compactedTableSummary{}, fmt.Errorf("allocate snapshot id: %w", err)
 	}
-	objectPath, err := storage.BuildDataFilePath(tenantID, tableName, s.Clock(), snapshotID, 0)
+	segments := make([]storage.PartitionSegment, 0, len(values))
+	for _, value := range values {
+		segments = append(segments, storage.PartitionSegment{Name: value.Name, Value: value.Value})
+	}
+	objectPath, err := storage.BuildDataFilePath(tenantID, tableName, segments, snapshotID, 0)
 	if err != nil {
 		return compactedTableSummary{}, fmt.Errorf("build compacted object path: %w", err)
 	}
@@ -417,16 +478,22 @@ func (s *Service) compactTable(ctx context.Context, tenantID string, tableID int
 		return compactedTableSummary{}, fmt.Errorf("upload compacted parquet: %w", err)
 	}
 
-	statsJSON, err := json.Marshal(map[string]any{
+	stats := map[string]any{
+		"event_count":       mergedRecords,
 		"source_file_count": len(files),
 		"source_records":    sourceRecords,
 		"merged_records":    mergedRecords,
 		"source_bytes":      sourceBytes,
 		"merged_bytes":      objectInfo.Size,
-	})
+	}
+	if columns := mergeColumnStats(files); columns != nil {
+		stats["columns"] = columns
+	}
+	statsJSON, err := json.Marshal(stats)
 	if err != nil {
 		return compactedTableSummary{}, fmt.Errorf("marshal compaction stats: %w", err)
 	}
+	minEventTime, maxEventTime := eventTimeRange(files)
 
 	if _, err := s.Catalog.PublishCompaction(ctx, catalogpostgres.PublishCompactionInput{
 		SnapshotID:         snapshotID,
@@ -437,7 +504,10 @@ func (s *Service) compactTable(ctx context.Context, tenantID string, tableID int
 		DataFilePath:       objectPath,
 		RecordCount:        mergedRecords,
 		FileSizeBytes:      objectInfo.Size,
+		MinEventTime:       minEventTime,
+		MaxEventTime:       maxEventTime,
 		StatsJSON:          statsJSON,
+		PartitionValues:    partition.Map(values),
 		RemovedFileIDs:     removedFileIDs,
 	}); err != nil {
 		_ = s.ObjectStore.Delete(ctx, objectPath)
@@ -510,6 +580,27 @@ func (s *Service) ensureDefaults() {
 	}
 }
 
+func (s *Service) downloadSource(ctx context.Context, objectPath, localPath string) error {
+	if s.Cache != nil {
+		if err := s.Cache.Link(ctx, objectPath, localPath); err != nil {
+			return fmt.Errorf("download source object %s: %w", objectPath, err)
+		}
+		return nil
+	}
+	reader, err := s.ObjectStore.Get(ctx, objectPath)
+	if err != nil {
+		return fmt.Errorf("download source object %s: %w", objectPath, err)
+	}
+	if err := writeLocalFile(localPath, reader); err != nil {
+		_ = reader.Close()
+		return fmt.Errorf("write local source file %s: %w", localPath, err)
+	}
+	if err := reader.Close(); err != nil {
+		return fmt.Errorf("close source object %s: %w", objectPath, err)
+	}
+	return nil
+}
+
 func writeLocalFile(path string, reader io.Reader) error {
 	file, err := os.Create(path)
 	if err != nil {
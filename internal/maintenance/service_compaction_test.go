@@ -0,0 +1,67 @@
+package maintenance
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/catalog"
+	"github.com/duckmesh/duckmesh/internal/partition"
+)
+
+func TestGroupFilesByPartitionKeepsPartitionsApart(t *testing.T) {
+	files := []catalog.SnapshotFileEntry{
+		{FileID: 1, PartitionValues: map[string]string{"hour": "2026-02-19-09", "date": "2026-02-19"}},
+		{FileID: 2, PartitionValues: map[string]string{"date": "2026-02-20", "hour": "2026-02-20-00"}},
+		{FileID: 3, PartitionValues: map[string]string{}},
+		{FileID: 4, PartitionValues: map[string]string{"date": "2026-02-19", "hour": "2026-02-19-09"}},
+	}
+
+	groups := groupFilesByPartition(files, partition.Default())
+	if len(groups) != 3 {
+		t.Fatalf("groups = %+v", groups)
+	}
+	if fmt.Sprint(groups[0].values) != "[{date 2026-02-19} {hour 2026-02-19-09}]" {
+		t.Fatalf("first group values = %v", groups[0].values)
+	}
+	if len(groups[0].files) != 2 || groups[0].files[1].FileID != 4 {
+		t.Fatalf("first group files = %+v", groups[0].files)
+	}
+	if len(groups[2].values) != 0 || groups[2].files[0].FileID != 3 {
+		t.Fatalf("unpartitioned group = %+v", groups[2])
+	}
+}
+
+func TestMergeColumnStatsRequiresStatsOnEveryFile(t *testing.T) {
+	files := []catalog.SnapshotFileEntry{
+		{RecordCount: 2, StatsJSON: []byte(`{"event_count":2,"columns":{"id":{"type":"bigint","min":1,"max":4,"null_count":0,"distinct_count":2}}}`)},
+		{RecordCount: 1, StatsJSON: []byte(`{"event_count":1,"columns":{"id":{"type":"bigint","min":-3,"max":-3,"null_count":0,"distinct_count":1}}}`)},
+	}
+	id := mergeColumnStats(files)["id"]
+	if fmt.Sprintf("%v %v", id.Min, id.Max) != "-3 4" || id.DistinctCount != 2 {
+		t.Fatalf("merged id = %+v", id)
+	}
+
+	files = append(files, catalog.SnapshotFileEntry{RecordCount: 5, StatsJSON: []byte(`{"event_count":5}`)})
+	if columns := mergeColumnStats(files); columns != nil {
+		t.Fatalf("merged columns = %+v, want none", columns)
+	}
+}
+
+func TestEventTimeRangeNeedsEveryInputRange(t *testing.T) {
+	early := time.Date(2026, 2, 19, 9, 0, 0, 0, time.UTC)
+	late := early.Add(3 * time.Hour)
+	files := []catalog.SnapshotFileEntry{
+		{MinEventTime: &late, MaxEventTime: &late},
+		{MinEventTime: &early, MaxEventTime: &early},
+	}
+	minTime, maxTime := eventTimeRange(files)
+	if minTime == nil || !minTime.Equal(early) || maxTime == nil || !maxTime.Equal(late) {
+		t.Fatalf("range = %v - %v", minTime, maxTime)
+	}
+
+	files = append(files, catalog.SnapshotFileEntry{})
+	if minTime, maxTime := eventTimeRange(files); minTime != nil || maxTime != nil {
+		t.Fatalf("range with an unknown input = %v - %v, want none", minTime, maxTime)
+	}
+}
@@ -0,0 +1 @@
+ALTER TABLE data_file DROP COLUMN IF EXISTS content;
@@ -0,0 +1,2 @@
+ALTER TABLE data_file
+    ADD COLUMN content TEXT NOT NULL DEFAULT 'data' CHECK (content IN ('data', 'delete'));
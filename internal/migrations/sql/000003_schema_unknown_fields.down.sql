@@ -0,0 +1 @@
+ALTER TABLE table_schema_version DROP COLUMN IF EXISTS unknown_fields;
@@ -0,0 +1,2 @@
+ALTER TABLE table_schema_version
+    ADD COLUMN unknown_fields TEXT NOT NULL DEFAULT 'allow' CHECK (unknown_fields IN ('allow', 'reject'));
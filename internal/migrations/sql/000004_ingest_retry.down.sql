@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_ingest_event_failed;
+ALTER TABLE ingest_event
+    DROP COLUMN IF EXISTS discarded_at,
+    DROP COLUMN IF EXISTS failed_at,
+    DROP COLUMN IF EXISTS last_error,
+    DROP COLUMN IF EXISTS attempts;
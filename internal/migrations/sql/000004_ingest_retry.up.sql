@@ -0,0 +1,9 @@
+ALTER TABLE ingest_event
+    ADD COLUMN attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN last_error TEXT,
+    ADD COLUMN failed_at TIMESTAMPTZ,
+    ADD COLUMN discarded_at TIMESTAMPTZ;
+
+CREATE INDEX idx_ingest_event_failed
+    ON ingest_event (tenant_id, event_id)
+    WHERE state = 'failed' AND discarded_at IS NULL;
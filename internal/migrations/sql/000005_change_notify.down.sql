@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS trg_snapshot_notify ON snapshot;
+DROP FUNCTION IF EXISTS duckmesh_notify_snapshot();
+DROP TRIGGER IF EXISTS trg_ingest_event_notify ON ingest_event;
+DROP FUNCTION IF EXISTS duckmesh_notify_ingest();
@@ -0,0 +1,23 @@
+CREATE FUNCTION duckmesh_notify_ingest() RETURNS trigger AS $$
+BEGIN
+    IF NEW.state = 'accepted' AND (TG_OP = 'INSERT' OR OLD.state <> 'accepted') THEN
+        PERFORM pg_notify('duckmesh_ingest', NEW.tenant_id);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_ingest_event_notify
+    AFTER INSERT OR UPDATE OF state ON ingest_event
+    FOR EACH ROW EXECUTE FUNCTION duckmesh_notify_ingest();
+
+CREATE FUNCTION duckmesh_notify_snapshot() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('duckmesh_snapshot', NEW.tenant_id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_snapshot_notify
+    AFTER INSERT ON snapshot
+    FOR EACH ROW EXECUTE FUNCTION duckmesh_notify_snapshot();
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_ingest_event_accepted_tenant;
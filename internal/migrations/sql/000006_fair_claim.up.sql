@@ -0,0 +1,3 @@
+CREATE INDEX idx_ingest_event_accepted_tenant
+    ON ingest_event (tenant_id, event_id)
+    WHERE state = 'accepted';
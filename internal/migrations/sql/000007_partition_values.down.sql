@@ -0,0 +1,2 @@
+ALTER TABLE data_file
+    DROP COLUMN IF EXISTS partition_values;
@@ -0,0 +1,2 @@
+ALTER TABLE data_file
+    ADD COLUMN partition_values JSONB NOT NULL DEFAULT '{}'::JSONB;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS query_job;
+DROP TYPE IF EXISTS duckmesh_query_job_state;
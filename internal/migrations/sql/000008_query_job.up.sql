@@ -0,0 +1,31 @@
+CREATE TYPE duckmesh_query_job_state AS ENUM ('queued', 'running', 'succeeded', 'failed', 'cancelled');
+
+CREATE TABLE query_job (
+    job_id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    tenant_id TEXT NOT NULL REFERENCES tenant(tenant_id) ON DELETE CASCADE,
+    state duckmesh_query_job_state NOT NULL DEFAULT 'queued',
+    query_text TEXT NOT NULL,
+    format TEXT NOT NULL,
+    plan_json JSONB NOT NULL,
+    snapshot_id BIGINT NOT NULL,
+    max_visibility_token BIGINT NOT NULL,
+    cancel_requested BOOLEAN NOT NULL DEFAULT FALSE,
+    owner TEXT,
+    lease_until TIMESTAMPTZ,
+    result_path TEXT,
+    result_rows BIGINT,
+    result_bytes BIGINT,
+    scanned_bytes BIGINT,
+    error_message TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_query_job_queued
+    ON query_job (job_id)
+    WHERE state = 'queued';
+
+CREATE INDEX idx_query_job_running_lease
+    ON query_job (lease_until)
+    WHERE state = 'running';
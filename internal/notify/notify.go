@@ -0,0 +1,84 @@
+package notify
+
+import "sync"
+
+// Channels carry the tenant id as payload. The Postgres catalog raises them
+// from triggers, so every ingest and publish path is covered.
+const (
+	ChannelIngest   = "duckmesh_ingest"
+	ChannelSnapshot = "duckmesh_snapshot"
+)
+
+// Notifier raises a wakeup on a channel for one key, usually a tenant id.
+type Notifier interface {
+	Notify(channel, key string)
+}
+
+// Subscriber delivers coalesced wakeups. An empty key receives every
+// notification on the channel. The returned func unsubscribes.
+type Subscriber interface {
+	Subscribe(channel, key string) (<-chan struct{}, func())
+}
+
+type subscription struct {
+	key string
+	ch  chan struct{}
+}
+
+// Hub fans notifications out to in-process subscribers. Wakeups never block
+// the sender: a subscriber that has not drained its previous wakeup simply
+// stays signalled.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[string]map[*subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: map[string]map[*subscription]struct{}{}}
+}
+
+func (h *Hub) Subscribe(channel, key string) (<-chan struct{}, func()) {
+	sub := &subscription{key: key, ch: make(chan struct{}, 1)}
+
+	h.mu.Lock()
+	if h.subs[channel] == nil {
+		h.subs[channel] = map[*subscription]struct{}{}
+	}
+	h.subs[channel][sub] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs[channel], sub)
+			h.mu.Unlock()
+		})
+	}
+}
+
+func (h *Hub) Notify(channel, key string) {
+	h.signal(channel, func(sub *subscription) bool {
+		return sub.key == "" || sub.key == key
+	})
+}
+
+// WakeAll signals every subscriber of channel regardless of key. Listeners
+// call it after reconnecting, when notifications may have been missed.
+func (h *Hub) WakeAll(channel string) {
+	h.signal(channel, func(*subscription) bool { return true })
+}
+
+func (h *Hub) signal(channel string, match func(*subscription) bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs[channel] {
+		if !match(sub) {
+			continue
+		}
+		select {
+		case sub.ch <- struct{}{}:
+		default:
+		}
+	}
+}
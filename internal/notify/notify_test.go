@@ -0,0 +1,62 @@
+package notify
+
+import "testing"
+
+func TestHubDeliversMatchingKeysAndCoalesces(t *testing.T) {
+	hub := NewHub()
+	tenantA, stopA := hub.Subscribe(ChannelSnapshot, "tenant-a")
+	defer stopA()
+	all, stopAll := hub.Subscribe(ChannelSnapshot, "")
+	defer stopAll()
+	ingest, stopIngest := hub.Subscribe(ChannelIngest, "")
+	defer stopIngest()
+
+	hub.Notify(ChannelSnapshot, "tenant-b")
+	hub.Notify(ChannelSnapshot, "tenant-b")
+
+	if signalled(tenantA) {
+		t.Fatal("tenant-a subscriber woke for tenant-b")
+	}
+	if !signalled(all) {
+		t.Fatal("wildcard subscriber did not wake")
+	}
+	if signalled(all) {
+		t.Fatal("repeated notifications were not coalesced")
+	}
+	if signalled(ingest) {
+		t.Fatal("ingest subscriber woke for snapshot channel")
+	}
+
+	hub.Notify(ChannelSnapshot, "tenant-a")
+	if !signalled(tenantA) {
+		t.Fatal("tenant-a subscriber did not wake")
+	}
+}
+
+func TestHubWakeAllIgnoresKeysAndUnsubscribeStopsDelivery(t *testing.T) {
+	hub := NewHub()
+	tenantA, stopA := hub.Subscribe(ChannelIngest, "tenant-a")
+	tenantB, stopB := hub.Subscribe(ChannelIngest, "tenant-b")
+	defer stopB()
+
+	hub.WakeAll(ChannelIngest)
+	if !signalled(tenantA) || !signalled(tenantB) {
+		t.Fatal("WakeAll did not reach every subscriber")
+	}
+
+	stopA()
+	stopA()
+	hub.Notify(ChannelIngest, "tenant-a")
+	if signalled(tenantA) {
+		t.Fatal("unsubscribed channel still received a wakeup")
+	}
+}
+
+func signalled(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,135 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/duckmesh/duckmesh/internal/migrations"
+	"github.com/duckmesh/duckmesh/internal/notify"
+)
+
+func TestListenerForwardsIngestAndSnapshotNotifications(t *testing.T) {
+	adminDSN := strings.TrimSpace(os.Getenv("DUCKMESH_TEST_CATALOG_DSN"))
+	if adminDSN == "" {
+		t.Skip("DUCKMESH_TEST_CATALOG_DSN is not set")
+	}
+
+	testDSN, cleanup := createTemporaryDatabase(t, adminDSN)
+	defer cleanup()
+
+	db, err := sql.Open("pgx", testDSN)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if _, err := migrations.NewRunner().Up(ctx, db, 0); err != nil {
+		t.Fatalf("runner.Up() error = %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO tenant (tenant_id, name, status) VALUES ('tenant-a', 'tenant-a', 'active')`); err != nil {
+		t.Fatalf("insert tenant error = %v", err)
+	}
+	var tableID int64
+	if err := db.QueryRowContext(ctx, `
+INSERT INTO table_def (tenant_id, table_name, primary_key_cols, partition_spec, schema_version)
+VALUES ('tenant-a', 'events', '[]'::jsonb, '{}'::jsonb, 1)
+RETURNING table_id`).Scan(&tableID); err != nil {
+		t.Fatalf("insert table_def error = %v", err)
+	}
+
+	hub := notify.NewHub()
+	ingest, stopIngest := hub.Subscribe(notify.ChannelIngest, "")
+	defer stopIngest()
+	snapshots, stopSnapshots := hub.Subscribe(notify.ChannelSnapshot, "tenant-a")
+	defer stopSnapshots()
+
+	listenCtx, stopListener := context.WithCancel(ctx)
+	listenerDone := make(chan error, 1)
+	go func() {
+		listener := &Listener{DSN: testDSN, Channels: []string{notify.ChannelIngest, notify.ChannelSnapshot}, Hub: hub}
+		listenerDone <- listener.Run(listenCtx)
+	}()
+	defer func() {
+		stopListener()
+		if err := <-listenerDone; err != nil {
+			t.Fatalf("Listener.Run() error = %v", err)
+		}
+	}()
+
+	// The initial wakeup signals that LISTEN is in place.
+	waitForWakeup(t, ingest, "listener connect")
+	waitForWakeup(t, snapshots, "listener connect")
+
+	if _, err := db.ExecContext(ctx, `
+INSERT INTO ingest_event (tenant_id, table_id, idempotency_key, op, payload_json)
+VALUES ('tenant-a', $1, 'k1', 'insert', '{}'::jsonb)`, tableID); err != nil {
+		t.Fatalf("insert ingest_event error = %v", err)
+	}
+	waitForWakeup(t, ingest, "ingest insert")
+
+	if _, err := db.ExecContext(ctx, `
+INSERT INTO snapshot (tenant_id, created_by, max_visibility_token)
+VALUES ('tenant-a', 'test', 1)`); err != nil {
+		t.Fatalf("insert snapshot error = %v", err)
+	}
+	waitForWakeup(t, snapshots, "snapshot insert")
+}
+
+func waitForWakeup(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no wakeup after %s", what)
+	}
+}
+
+func createTemporaryDatabase(t *testing.T, adminDSN string) (string, func()) {
+	t.Helper()
+
+	parsed, err := url.Parse(adminDSN)
+	if err != nil {
+		t.Fatalf("url.Parse(adminDSN) error = %v", err)
+	}
+	if strings.TrimPrefix(parsed.Path, "/") == "" {
+		t.Fatal("admin DSN must include a database name")
+	}
+
+	adminDB, err := sql.Open("pgx", adminDSN)
+	if err != nil {
+		t.Fatalf("sql.Open(adminDSN) error = %v", err)
+	}
+
+	name := fmt.Sprintf("duckmesh_it_notify_%d", time.Now().UnixNano())
+	if _, err := adminDB.Exec(`CREATE DATABASE ` + name); err != nil {
+		t.Fatalf("CREATE DATABASE failed: %v", err)
+	}
+
+	testURL := *parsed
+	testURL.Path = "/" + name
+	testDSN := testURL.String()
+
+	cleanup := func() {
+		defer func() { _ = adminDB.Close() }()
+		if _, err := adminDB.Exec(`SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = $1`, name); err != nil {
+			t.Fatalf("terminate test db sessions: %v", err)
+		}
+		if _, err := adminDB.Exec(`DROP DATABASE ` + name); err != nil {
+			t.Fatalf("DROP DATABASE failed: %v", err)
+		}
+	}
+	return testDSN, cleanup
+}
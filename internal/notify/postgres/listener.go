@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/duckmesh/duckmesh/internal/notify"
+)
+
+// Listener holds a dedicated catalog connection that LISTENs on the given
+// channels and forwards every notification to Hub.
+type Listener struct {
+	DSN            string
+	Channels       []string
+	Hub            *notify.Hub
+	ReconnectDelay time.Duration
+	Logger         *slog.Logger
+}
+
+// Run listens until ctx is cancelled, reconnecting after connection errors.
+// Each (re)connect wakes all subscribers so nothing waits on a notification
+// that was sent while the listener was down.
+func (l *Listener) Run(ctx context.Context) error {
+	if l.Hub == nil {
+		return fmt.Errorf("notification hub is required")
+	}
+	if len(l.Channels) == 0 {
+		return fmt.Errorf("at least one channel is required")
+	}
+	delay := l.ReconnectDelay
+	if delay <= 0 {
+		delay = time.Second
+	}
+
+	for {
+		err := l.listen(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if l.Logger != nil {
+			l.Logger.WarnContext(ctx, "catalog notification listener disconnected", slog.Any("error", err), slog.Duration("retry_in", delay))
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (l *Listener) listen(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, l.DSN)
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer func() { _ = conn.Close(context.Background()) }()
+
+	for _, channel := range l.Channels {
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return fmt.Errorf("listen %s: %w", channel, err)
+		}
+	}
+	for _, channel := range l.Channels {
+		l.Hub.WakeAll(channel)
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("wait for notification: %w", err)
+		}
+		l.Hub.Notify(notification.Channel, notification.Payload)
+	}
+}
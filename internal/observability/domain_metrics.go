@@ -63,6 +63,40 @@ var (
 			Help: "Total number of consistency timeout responses.",
 		},
 	)
+	coordinatorClaimedEventsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "duckmesh_coordinator_claimed_events_total",
+			Help: "Total number of ingest events claimed by the coordinator per tenant.",
+		},
+		[]string{"tenant_id"},
+	)
+	coordinatorGroupsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "duckmesh_coordinator_groups_total",
+			Help: "Total number of (tenant, table) groups the coordinator published or failed.",
+		},
+		[]string{"tenant_id", "result"},
+	)
+	coordinatorPublishedEventsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "duckmesh_coordinator_published_events_total",
+			Help: "Total number of ingest events committed to a snapshot per tenant.",
+		},
+		[]string{"tenant_id"},
+	)
+	coordinatorGroupWriteSeconds = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "duckmesh_coordinator_group_write_seconds",
+			Help:    "Time to encode and upload the files of one group.",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+	coordinatorBusyWorkers = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "duckmesh_coordinator_busy_workers",
+			Help: "Current number of coordinator workers encoding or uploading a group.",
+		},
+	)
 )
 
 func init() {
@@ -76,6 +110,11 @@ func init() {
 		latestVisibilityToken,
 		writeToVisibleLatencyMs,
 		consistencyTimeoutTotal,
+		coordinatorClaimedEventsTotal,
+		coordinatorGroupsTotal,
+		coordinatorPublishedEventsTotal,
+		coordinatorGroupWriteSeconds,
+		coordinatorBusyWorkers,
 	)
 }
 
@@ -109,3 +148,24 @@ func SetLagMetrics(pendingEvents int64, lagMs int64, latestToken int64) {
 	visibilityLagMs.Set(float64(lagMs))
 	latestVisibilityToken.Set(float64(latestToken))
 }
+
+func ObserveCoordinatorClaimed(tenantID string, events int) {
+	coordinatorClaimedEventsTotal.WithLabelValues(tenantID).Add(float64(events))
+}
+
+func ObserveCoordinatorGroup(tenantID string, events int, published bool) {
+	if !published {
+		coordinatorGroupsTotal.WithLabelValues(tenantID, "failed").Inc()
+		return
+	}
+	coordinatorGroupsTotal.WithLabelValues(tenantID, "published").Inc()
+	coordinatorPublishedEventsTotal.WithLabelValues(tenantID).Add(float64(events))
+}
+
+func ObserveCoordinatorGroupWrite(elapsed time.Duration) {
+	coordinatorGroupWriteSeconds.Observe(elapsed.Seconds())
+}
+
+func AddCoordinatorBusyWorkers(delta int) {
+	coordinatorBusyWorkers.Add(float64(delta))
+}
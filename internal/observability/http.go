@@ -71,6 +71,12 @@ func (r *statusRecorder) Write(body []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap lets http.ResponseController reach Flush and the deadlines of the
+// underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 func newTraceID() string {
 	buf := make([]byte, 16)
 	if _, err := rand.Read(buf); err != nil {
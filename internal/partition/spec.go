@@ -0,0 +1,235 @@
+package partition
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/tableschema"
+)
+
+type Transform string
+
+const (
+	TransformIdentity Transform = "identity"
+	TransformYear     Transform = "year"
+	TransformMonth    Transform = "month"
+	TransformDay      Transform = "day"
+	TransformHour     Transform = "hour"
+)
+
+// EventTimeColumn partitions by the event time of the ingest envelope, or by
+// processing time for events sent without one.
+const EventTimeColumn = "event_time"
+
+// NullValue is the partition value of events without a usable source value.
+const NullValue = "__null__"
+
+type Field struct {
+	Column    string    `json:"column"`
+	Transform Transform `json:"transform,omitempty"`
+	// Name is the path and catalog key of the field. It defaults to the
+	// column, or to the transform for event_time ("date" for day).
+	Name string `json:"name,omitempty"`
+}
+
+// Spec is a table_def.partition_spec document: the fields data files are
+// split by, in path order.
+type Spec struct {
+	Fields []Field `json:"fields"`
+}
+
+// Value is the partition value of one field.
+type Value struct {
+	Name  string
+	Value string
+}
+
+// Default lays files out by event-time day and hour.
+func Default() Spec {
+	return Spec{Fields: []Field{
+		{Column: EventTimeColumn, Transform: TransformDay, Name: "date"},
+		{Column: EventTimeColumn, Transform: TransformHour, Name: "hour"},
+	}}
+}
+
+// Parse reads a partition_spec document of the form
+// {"fields": [{"column": ..., "transform": ..., "name": ...}]}. An empty,
+// null or {} document yields Default.
+func Parse(raw []byte) (Spec, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) || bytes.Equal(trimmed, []byte("{}")) {
+		return Default(), nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.DisallowUnknownFields()
+	var spec Spec
+	if err := decoder.Decode(&spec); err != nil {
+		return Spec{}, fmt.Errorf("partition_spec must be {\"fields\": [{\"column\", \"transform\", \"name\"}]}: %w", err)
+	}
+	seen := map[string]bool{}
+	for i := range spec.Fields {
+		field := &spec.Fields[i]
+		field.Column = strings.TrimSpace(field.Column)
+		if field.Column == "" {
+			return Spec{}, fmt.Errorf("partition field %d: column is required", i)
+		}
+		if field.Transform == "" {
+			field.Transform = TransformIdentity
+		}
+		switch field.Transform {
+		case TransformIdentity:
+			if field.Column == EventTimeColumn {
+				return Spec{}, fmt.Errorf("partition field %d: %s needs a time transform", i, EventTimeColumn)
+			}
+		case TransformYear, TransformMonth, TransformDay, TransformHour:
+		default:
+			return Spec{}, fmt.Errorf("partition field %d: unknown transform %q", i, field.Transform)
+		}
+		if field.Name == "" {
+			field.Name = defaultName(*field)
+		}
+		if !validName(field.Name) {
+			return Spec{}, fmt.Errorf("partition field %d: invalid name %q", i, field.Name)
+		}
+		if seen[field.Name] {
+			return Spec{}, fmt.Errorf("partition field %d: duplicate name %q", i, field.Name)
+		}
+		seen[field.Name] = true
+	}
+	return spec, nil
+}
+
+func defaultName(field Field) string {
+	if field.Transform == TransformIdentity {
+		return field.Column
+	}
+	name := string(field.Transform)
+	if field.Transform == TransformDay {
+		name = "date"
+	}
+	if field.Column == EventTimeColumn {
+		return name
+	}
+	return field.Column + "_" + name
+}
+
+func validName(name string) bool {
+	if name == "" || len(name) > 64 {
+		return false
+	}
+	for _, r := range name {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Values computes the partition of an event from its JSON payload and event
+// time.
+func (s Spec) Values(payload []byte, eventTime time.Time) []Value {
+	var fields map[string]any
+	if len(s.Fields) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(payload))
+		decoder.UseNumber()
+		_ = decoder.Decode(&fields)
+	}
+
+	values := make([]Value, 0, len(s.Fields))
+	for _, field := range s.Fields {
+		value := NullValue
+		switch {
+		case field.Column == EventTimeColumn:
+			value = formatTime(eventTime, field.Transform)
+		case field.Transform == TransformIdentity:
+			value = formatIdentity(fields[field.Column])
+		default:
+			timestamp := tableschema.Field{Type: tableschema.TypeTimestamp}
+			if parsed, err := timestamp.Coerce(fields[field.Column]); err == nil && parsed != nil {
+				value = formatTime(parsed.(time.Time), field.Transform)
+			}
+		}
+		values = append(values, Value{Name: field.Name, Value: value})
+	}
+	return values
+}
+
+// Order returns catalog partition values in spec order; names the spec does
+// not know follow in name order.
+func (s Spec) Order(values map[string]string) []Value {
+	ordered := make([]Value, 0, len(values))
+	seen := map[string]bool{}
+	for _, field := range s.Fields {
+		if value, ok := values[field.Name]; ok {
+			ordered = append(ordered, Value{Name: field.Name, Value: value})
+			seen[field.Name] = true
+		}
+	}
+	rest := make([]string, 0)
+	for name := range values {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	for _, name := range rest {
+		ordered = append(ordered, Value{Name: name, Value: values[name]})
+	}
+	return ordered
+}
+
+// Key identifies a partition, for grouping files or events.
+func Key(values []Value) string {
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		parts = append(parts, value.Name+"="+value.Value)
+	}
+	return strings.Join(parts, "\x1f")
+}
+
+// Map returns values as stored in data_file.partition_values.
+func Map(values []Value) map[string]string {
+	out := make(map[string]string, len(values))
+	for _, value := range values {
+		out[value.Name] = value.Value
+	}
+	return out
+}
+
+func formatTime(ts time.Time, transform Transform) string {
+	ts = ts.UTC()
+	switch transform {
+	case TransformYear:
+		return ts.Format("2006")
+	case TransformMonth:
+		return ts.Format("2006-01")
+	case TransformHour:
+		return ts.Format("2006-01-02-15")
+	default:
+		return ts.Format("2006-01-02")
+	}
+}
+
+func formatIdentity(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return NullValue
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return NullValue
+	}
+	return string(encoded)
+}
@@ -0,0 +1,77 @@
+package partition
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseDefaultsAndNames(t *testing.T) {
+	for _, raw := range []string{"", "null", "{}"} {
+		spec, err := Parse([]byte(raw))
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", raw, err)
+		}
+		if fmt.Sprint(spec) != fmt.Sprint(Default()) {
+			t.Fatalf("Parse(%q) = %+v, want default", raw, spec)
+		}
+	}
+
+	spec, err := Parse([]byte(`{"fields":[{"column":"event_time","transform":"month"},{"column":"region"},{"column":"created_at","transform":"day"},{"column":"kind","name":"k"}]}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	names := make([]string, 0, len(spec.Fields))
+	for _, field := range spec.Fields {
+		names = append(names, field.Name)
+	}
+	if fmt.Sprint(names) != "[month region created_at_date k]" {
+		t.Fatalf("names = %v", names)
+	}
+	if spec.Fields[1].Transform != TransformIdentity {
+		t.Fatalf("default transform = %q", spec.Fields[1].Transform)
+	}
+}
+
+func TestParseRejectsInvalidSpecs(t *testing.T) {
+	cases := []string{
+		`{"date":"day"}`,
+		`{"fields":[{"transform":"day"}]}`,
+		`{"fields":[{"column":"event_time"}]}`,
+		`{"fields":[{"column":"region","transform":"bucket"}]}`,
+		`{"fields":[{"column":"region","name":"a/b"}]}`,
+		`{"fields":[{"column":"region"},{"column":"region"}]}`,
+	}
+	for _, raw := range cases {
+		if _, err := Parse([]byte(raw)); err == nil {
+			t.Fatalf("Parse(%s) expected error", raw)
+		}
+	}
+}
+
+func TestValuesFromPayloadAndEventTime(t *testing.T) {
+	spec, err := Parse([]byte(`{"fields":[{"column":"event_time","transform":"hour"},{"column":"region"},{"column":"shard"},{"column":"created_at","transform":"month"}]}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	eventTime := time.Date(2026, 2, 19, 9, 30, 0, 0, time.UTC)
+
+	values := spec.Values([]byte(`{"region":"eu","shard":7,"created_at":"2025-12-31T23:00:00Z"}`), eventTime)
+	if got := fmt.Sprint(Map(values)); got != "map[created_at_month:2025-12 hour:2026-02-19-09 region:eu shard:7]" {
+		t.Fatalf("Values() = %s", got)
+	}
+	values = spec.Values([]byte(`{"created_at":"yesterday"}`), eventTime)
+	if values[1].Value != NullValue || values[2].Value != NullValue || values[3].Value != NullValue {
+		t.Fatalf("Values() without sources = %+v", values)
+	}
+	if Key(values) == Key(spec.Values([]byte(`{"region":"eu"}`), eventTime)) {
+		t.Fatal("different partitions share a key")
+	}
+}
+
+func TestOrderFollowsSpec(t *testing.T) {
+	ordered := Default().Order(map[string]string{"zone": "z", "hour": "2026-02-19-09", "date": "2026-02-19"})
+	if fmt.Sprint(ordered) != "[{date 2026-02-19} {hour 2026-02-19-09} {zone z}]" {
+		t.Fatalf("Order() = %v", ordered)
+	}
+}
@@ -9,14 +9,18 @@ import (
 	"strings"
 	"time"
 
-	_ "github.com/marcboeker/go-duckdb/v2"
+	duckdb "github.com/marcboeker/go-duckdb/v2"
 
 	"github.com/duckmesh/duckmesh/internal/query"
 	"github.com/duckmesh/duckmesh/internal/storage"
+	"github.com/duckmesh/duckmesh/internal/storage/cache"
 )
 
 type Engine struct {
 	Store storage.ObjectStore
+	// Cache, when set, serves data files from local disk instead of
+	// downloading them for every query.
+	Cache *cache.Cache
 }
 
 func NewEngine(store storage.ObjectStore) *Engine {
@@ -24,123 +28,393 @@ func NewEngine(store storage.ObjectStore) *Engine {
 }
 
 func (e *Engine) Execute(ctx context.Context, request query.Request) (query.Result, error) {
-	if strings.TrimSpace(request.SQL) == "" {
-		return query.Result{}, fmt.Errorf("sql is required")
+	start := time.Now()
+	stream, err := e.Stream(ctx, request)
+	if err != nil {
+		return query.Result{}, err
 	}
-	if len(request.Files) == 0 {
-		return query.Result{}, fmt.Errorf("no files available for snapshot")
+	result, err := query.Collect(stream)
+	if err != nil {
+		return query.Result{}, err
 	}
-	if e.Store == nil {
-		return query.Result{}, fmt.Errorf("object store is required")
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// Stream downloads the request's files and starts the query. The returned
+// rows own the database and the downloaded files until they are closed.
+func (e *Engine) Stream(ctx context.Context, request query.Request) (stream query.Stream, err error) {
+	session, err := e.open(ctx, request)
+	if err != nil {
+		return query.Stream{}, err
+	}
+	defer func() {
+		if err != nil {
+			session.close()
+		}
+	}()
+
+	rows, err := session.db.QueryContext(ctx, session.sqlText, bindArgs(request.Params)...)
+	if err != nil {
+		return query.Stream{}, fmt.Errorf("execute query: %w", err)
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		_ = rows.Close()
+		return query.Stream{}, fmt.Errorf("query columns: %w", err)
+	}
+	columns := make([]query.Column, len(columnTypes))
+	for i, columnType := range columnTypes {
+		columns[i] = query.Column{Name: columnType.Name(), Type: columnType.DatabaseTypeName()}
 	}
 
+	return query.Stream{
+		Rows:         &resultRows{rows: rows, columns: columns, session: session},
+		ScannedFiles: len(request.Files),
+		ScannedBytes: session.scannedBytes,
+	}, nil
+}
+
+// Export runs the query with COPY into a file next to the downloaded data
+// and uploads that file to objectPath.
+func (e *Engine) Export(ctx context.Context, request query.Request, format query.ExportFormat, objectPath string) (query.ExportResult, error) {
 	start := time.Now()
-	workDir, err := os.MkdirTemp("", "duckmesh-query-")
+	var options, contentType string
+	switch format {
+	case query.ExportParquet:
+		options, contentType = "FORMAT parquet", "application/vnd.apache.parquet"
+	case query.ExportCSV:
+		options, contentType = "FORMAT csv, HEADER", "text/csv"
+	default:
+		return query.ExportResult{}, fmt.Errorf("unsupported export format %q", format)
+	}
+
+	session, err := e.open(ctx, request)
 	if err != nil {
-		return query.Result{}, fmt.Errorf("create query temp dir: %w", err)
+		return query.ExportResult{}, err
 	}
-	defer func() { _ = os.RemoveAll(workDir) }()
+	defer session.close()
 
-	groupedPaths := map[string][]string{}
-	var scannedBytes int64
+	localPath := filepath.Join(session.workDir, "result."+string(format))
+	copySQL := fmt.Sprintf("COPY (%s) TO %s (%s)", session.sqlText, quoteString(localPath), options)
+	copied, err := session.db.ExecContext(ctx, copySQL, bindArgs(request.Params)...)
+	if err != nil {
+		return query.ExportResult{}, fmt.Errorf("export query: %w", err)
+	}
+	rowCount, err := copied.RowsAffected()
+	if err != nil {
+		return query.ExportResult{}, fmt.Errorf("export query row count: %w", err)
+	}
 
-	for index, file := range request.Files {
-		reader, err := e.Store.Get(ctx, file.ObjectPath)
+	file, err := os.Open(localPath)
+	if err != nil {
+		return query.ExportResult{}, fmt.Errorf("open export file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	info, err := file.Stat()
+	if err != nil {
+		return query.ExportResult{}, fmt.Errorf("stat export file: %w", err)
+	}
+	if _, err := e.Store.Put(ctx, objectPath, file, info.Size(), storage.PutOptions{ContentType: contentType}); err != nil {
+		return query.ExportResult{}, fmt.Errorf("put export object %q: %w", objectPath, err)
+	}
+
+	return query.ExportResult{
+		Rows:         rowCount,
+		Bytes:        info.Size(),
+		ScannedFiles: len(request.Files),
+		ScannedBytes: session.scannedBytes,
+		Duration:     time.Since(start),
+	}, nil
+}
+
+// session is a database over a request's downloaded files.
+type session struct {
+	db           *sql.DB
+	workDir      string
+	scannedBytes int64
+	sqlText      string
+}
+
+func (s *session) close() {
+	if s.db != nil {
+		_ = s.db.Close()
+	}
+	_ = os.RemoveAll(s.workDir)
+}
+
+// open downloads the request's files, exposes them as views and locks the
+// database down to its work directory.
+func (e *Engine) open(ctx context.Context, request query.Request) (s *session, err error) {
+	if strings.TrimSpace(request.SQL) == "" {
+		return nil, fmt.Errorf("sql is required")
+	}
+	if e.Store == nil {
+		return nil, fmt.Errorf("object store is required")
+	}
+
+	workDir, err := os.MkdirTemp("", "duckmesh-query-")
+	if err != nil {
+		return nil, fmt.Errorf("create query temp dir: %w", err)
+	}
+	s = &session{workDir: workDir}
+	defer func() {
 		if err != nil {
-			return query.Result{}, fmt.Errorf("get object %q: %w", file.ObjectPath, err)
+			s.close()
 		}
+	}()
 
+	groupedPaths := map[string][]string{}
+	for index, file := range request.Files {
 		localPath := filepath.Join(workDir, fmt.Sprintf("%s_%d.parquet", sanitizeFileComponent(file.TableName), index))
-		if err := writeFile(localPath, reader); err != nil {
-			_ = reader.Close()
-			return query.Result{}, fmt.Errorf("write local parquet file %q: %w", localPath, err)
-		}
-		if err := reader.Close(); err != nil {
-			return query.Result{}, fmt.Errorf("close object %q: %w", file.ObjectPath, err)
+		if err := e.download(ctx, file.ObjectPath, localPath); err != nil {
+			return nil, err
 		}
 
 		groupedPaths[file.TableName] = append(groupedPaths[file.TableName], localPath)
-		scannedBytes += file.FileSizeBytes
+		s.scannedBytes += file.FileSizeBytes
 	}
 
-	db, err := sql.Open("duckdb", "")
+	s.db, err = sql.Open("duckdb", "")
 	if err != nil {
-		return query.Result{}, fmt.Errorf("open duckdb: %w", err)
+		return nil, fmt.Errorf("open duckdb: %w", err)
 	}
-	defer func() { _ = db.Close() }()
 
 	for tableName, localPaths := range groupedPaths {
-		viewSQL := fmt.Sprintf(`CREATE OR REPLACE VIEW %s AS SELECT * FROM read_parquet(%s)`, quoteIdent(tableName), quoteStringArray(localPaths))
-		if _, err := db.ExecContext(ctx, viewSQL); err != nil {
-			return query.Result{}, fmt.Errorf("create view for table %q: %w", tableName, err)
+		viewSQL, err := buildTableView(ctx, s.db, tableName, localPaths, request.PrimaryKeys[tableName])
+		if err != nil {
+			return nil, fmt.Errorf("plan view for table %q: %w", tableName, err)
+		}
+		if _, err := s.db.ExecContext(ctx, viewSQL); err != nil {
+			return nil, fmt.Errorf("create view for table %q: %w", tableName, err)
 		}
 	}
 
-	sqlText := stripTrailingSemicolons(request.SQL)
-	if sqlText == "" {
-		return query.Result{}, fmt.Errorf("sql is required")
+	if err := restrictToDirectory(ctx, s.db, workDir); err != nil {
+		return nil, err
+	}
+
+	s.sqlText = stripTrailingSemicolons(request.SQL)
+	if s.sqlText == "" {
+		return nil, fmt.Errorf("sql is required")
 	}
 	if request.RowLimit > 0 {
-		sqlText = fmt.Sprintf("SELECT * FROM (%s) AS q LIMIT %d", sqlText, request.RowLimit)
+		s.sqlText = fmt.Sprintf("SELECT * FROM (%s) AS q LIMIT %d", s.sqlText, request.RowLimit)
 	}
+	return s, nil
+}
+
+// resultRows scans one row at a time and removes the query's database and
+// files on Close.
+type resultRows struct {
+	rows    *sql.Rows
+	columns []query.Column
+	values  []any
+	err     error
+	session *session
+	closed  bool
+}
 
-	rows, err := db.QueryContext(ctx, sqlText)
+func (r *resultRows) Columns() []query.Column {
+	return r.columns
+}
+
+func (r *resultRows) Next() bool {
+	if r.err != nil || !r.rows.Next() {
+		return false
+	}
+	values := make([]any, len(r.columns))
+	scanTargets := make([]any, len(r.columns))
+	for i := range values {
+		scanTargets[i] = &values[i]
+	}
+	if err := r.rows.Scan(scanTargets...); err != nil {
+		r.err = fmt.Errorf("scan row: %w", err)
+		return false
+	}
+	r.values = normalizeValues(values)
+	return true
+}
+
+func (r *resultRows) Values() []any {
+	return r.values
+}
+
+func (r *resultRows) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	if err := r.rows.Err(); err != nil {
+		return fmt.Errorf("iterate rows: %w", err)
+	}
+	return nil
+}
+
+func (r *resultRows) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	err := r.rows.Close()
+	r.session.close()
+	return err
+}
+
+func buildTableView(ctx context.Context, db *sql.DB, tableName string, localPaths []string, primaryKey []string) (string, error) {
+	source := fmt.Sprintf(`read_parquet(%s, union_by_name = true)`, quoteStringArray(localPaths))
+	plain := fmt.Sprintf(`CREATE OR REPLACE VIEW %s AS SELECT * FROM %s`, quoteIdent(tableName), source)
+	if len(primaryKey) == 0 {
+		return plain, nil
+	}
+
+	columns, err := describeColumns(ctx, db, source)
 	if err != nil {
-		return query.Result{}, fmt.Errorf("execute query: %w", err)
+		return "", err
 	}
-	defer func() { _ = rows.Close() }()
+	typed := columns["__event_id"]
+	envelope := columns["payload_json"] && columns["event_id"]
+	if !typed && !envelope {
+		return plain, nil
+	}
+
+	keyExprs := make([]string, 0, len(primaryKey))
+	for _, column := range primaryKey {
+		typedExpr := ""
+		if typed && columns[column] {
+			typedExpr = fmt.Sprintf(`CAST(%s AS VARCHAR)`, quoteIdent(column))
+		}
+		envelopeExpr := ""
+		if envelope {
+			envelopeExpr = fmt.Sprintf(`json_extract_string(payload_json, %s)`, quoteString(`$."`+strings.ReplaceAll(column, `"`, `\"`)+`"`))
+		}
+		switch {
+		case typedExpr != "" && envelopeExpr != "":
+			keyExprs = append(keyExprs, fmt.Sprintf(`CASE WHEN "__event_id" IS NOT NULL THEN %s ELSE %s END`, typedExpr, envelopeExpr))
+		case typedExpr != "":
+			keyExprs = append(keyExprs, typedExpr)
+		case envelopeExpr != "":
+			keyExprs = append(keyExprs, envelopeExpr)
+		default:
+			return "", fmt.Errorf("primary key column %q is not present in data files", column)
+		}
+	}
+
+	orderExpr, opExpr := `"event_id"`, `"op"`
+	switch {
+	case typed && envelope:
+		orderExpr, opExpr = `coalesce("__event_id", "event_id")`, `coalesce("__op", "op")`
+	case typed:
+		orderExpr, opExpr = `"__event_id"`, `"__op"`
+	}
+
+	return fmt.Sprintf(
+		`CREATE OR REPLACE VIEW %s AS SELECT * EXCLUDE (__duckmesh_rank, __duckmesh_op) FROM (SELECT *, %s AS __duckmesh_op, row_number() OVER (PARTITION BY %s ORDER BY %s DESC) AS __duckmesh_rank FROM %s) WHERE __duckmesh_rank = 1 AND __duckmesh_op IS DISTINCT FROM 'delete'`,
+		quoteIdent(tableName), opExpr, strings.Join(keyExprs, ", "), orderExpr, source,
+	), nil
+}
+
+// restrictToDirectory limits file access to the downloaded files and locks
+// the configuration, so a statement that slips past the query policy can
+// still not read other files, reach the network or load extensions.
+func restrictToDirectory(ctx context.Context, db *sql.DB, dir string) error {
+	for _, statement := range []string{
+		fmt.Sprintf(`SET temp_directory = %s`, quoteString(dir)),
+		fmt.Sprintf(`SET allowed_directories = [%s]`, quoteString(dir)),
+		`SET enable_external_access = false`,
+		`SET autoinstall_known_extensions = false`,
+		`SET autoload_known_extensions = false`,
+		`SET lock_configuration = true`,
+	} {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("restrict query engine: %w", err)
+		}
+	}
+	return nil
+}
 
-	columns, err := rows.Columns()
+func describeColumns(ctx context.Context, db *sql.DB, source string) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, `SELECT column_name FROM (DESCRIBE SELECT * FROM `+source+`)`)
 	if err != nil {
-		return query.Result{}, fmt.Errorf("query columns: %w", err)
+		return nil, fmt.Errorf("describe parquet files: %w", err)
 	}
+	defer func() { _ = rows.Close() }()
 
-	resultRows := make([][]any, 0)
+	columns := map[string]bool{}
 	for rows.Next() {
-		values := make([]any, len(columns))
-		scanTargets := make([]any, len(columns))
-		for i := range values {
-			scanTargets[i] = &values[i]
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan column name: %w", err)
 		}
-		if err := rows.Scan(scanTargets...); err != nil {
-			return query.Result{}, fmt.Errorf("scan row: %w", err)
-		}
-		resultRows = append(resultRows, normalizeValues(values))
+		columns[name] = true
 	}
-	if err := rows.Err(); err != nil {
-		return query.Result{}, fmt.Errorf("iterate rows: %w", err)
+	return columns, rows.Err()
+}
+
+func bindArgs(params []query.Param) []any {
+	args := make([]any, 0, len(params))
+	for _, param := range params {
+		value := bindValue(param.Value)
+		if param.Name != "" {
+			value = sql.Named(param.Name, value)
+		}
+		args = append(args, value)
 	}
+	return args
+}
 
-	return query.Result{
-		Columns:      columns,
-		Rows:         resultRows,
-		ScannedFiles: len(request.Files),
-		ScannedBytes: scannedBytes,
-		Duration:     time.Since(start),
-	}, nil
+// bindValue converts values the driver does not bind itself. Decimals are
+// bound as text and cast by DuckDB where the placeholder is typed.
+func bindValue(value any) any {
+	switch typed := value.(type) {
+	case query.Decimal:
+		return string(typed)
+	case []any:
+		list := make([]any, len(typed))
+		for i, item := range typed {
+			list[i] = bindValue(item)
+		}
+		return list
+	}
+	return value
 }
 
 func normalizeValues(values []any) []any {
 	normalized := make([]any, len(values))
 	for i, value := range values {
-		switch typed := value.(type) {
-		case []byte:
-			normalized[i] = string(typed)
-		default:
-			normalized[i] = typed
-		}
+		normalized[i] = normalizeValue(value)
 	}
 	return normalized
 }
 
+// normalizeValue keeps results JSON friendly: decimals become exact strings.
+func normalizeValue(value any) any {
+	switch typed := value.(type) {
+	case []byte:
+		return string(typed)
+	case duckdb.Decimal:
+		return typed.String()
+	case []any:
+		list := make([]any, len(typed))
+		for i, item := range typed {
+			list[i] = normalizeValue(item)
+		}
+		return list
+	}
+	return value
+}
+
 func quoteIdent(value string) string {
 	return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
 }
 
+func quoteString(value string) string {
+	return `'` + strings.ReplaceAll(value, `'`, `''`) + `'`
+}
+
 func quoteStringArray(values []string) string {
 	quoted := make([]string, 0, len(values))
 	for _, value := range values {
-		quoted = append(quoted, `'`+strings.ReplaceAll(value, `'`, `''`)+`'`)
+		quoted = append(quoted, quoteString(value))
 	}
 	return "[" + strings.Join(quoted, ",") + "]"
 }
@@ -161,3 +435,21 @@ func stripTrailingSemicolons(sqlText string) string {
 	}
 	return trimmed
 }
+
+func (e *Engine) download(ctx context.Context, objectPath, localPath string) error {
+	if e.Cache != nil {
+		return e.Cache.Link(ctx, objectPath, localPath)
+	}
+	reader, err := e.Store.Get(ctx, objectPath)
+	if err != nil {
+		return fmt.Errorf("get object %q: %w", objectPath, err)
+	}
+	if err := writeFile(localPath, reader); err != nil {
+		_ = reader.Close()
+		return fmt.Errorf("write local parquet file %q: %w", localPath, err)
+	}
+	if err := reader.Close(); err != nil {
+		return fmt.Errorf("close object %q: %w", objectPath, err)
+	}
+	return nil
+}
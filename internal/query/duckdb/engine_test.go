@@ -3,7 +3,11 @@ package duckdb
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,6 +15,7 @@ import (
 
 	"github.com/duckmesh/duckmesh/internal/query"
 	"github.com/duckmesh/duckmesh/internal/storage"
+	"github.com/duckmesh/duckmesh/internal/storage/cache"
 )
 
 type row struct {
@@ -78,9 +83,63 @@ func TestExecuteSupportsTrailingSemicolonWithRowLimit(t *testing.T) {
 	}
 }
 
-func buildParquet(rows []row) ([]byte, error) {
+type envelopeRow struct {
+	EventID     int64  `parquet:"event_id"`
+	Op          string `parquet:"op"`
+	PayloadJSON string `parquet:"payload_json"`
+}
+
+func TestExecuteResolvesLatestRowPerPrimaryKey(t *testing.T) {
+	dataFile, err := buildParquet([]envelopeRow{
+		{EventID: 1, Op: "insert", PayloadJSON: `{"id":1,"value":"a"}`},
+		{EventID: 2, Op: "insert", PayloadJSON: `{"id":2,"value":"b"}`},
+		{EventID: 3, Op: "update", PayloadJSON: `{"id":1,"value":"a2"}`},
+		{EventID: 4, Op: "insert", PayloadJSON: `{"id":3,"value":"c"}`},
+	})
+	if err != nil {
+		t.Fatalf("buildParquet() error = %v", err)
+	}
+	deleteFile, err := buildParquet([]envelopeRow{{EventID: 5, Op: "delete", PayloadJSON: `{"id":2}`}})
+	if err != nil {
+		t.Fatalf("buildParquet() error = %v", err)
+	}
+	reinsertFile, err := buildParquet([]envelopeRow{{EventID: 6, Op: "insert", PayloadJSON: `{"id":3,"value":"c2"}`}})
+	if err != nil {
+		t.Fatalf("buildParquet() error = %v", err)
+	}
+
+	store := &memoryStore{objects: map[string][]byte{
+		"tenant/events/part-1.parquet":   dataFile,
+		"tenant/events/delete-1.parquet": deleteFile,
+		"tenant/events/part-2.parquet":   reinsertFile,
+	}}
+	engine := NewEngine(store)
+
+	result, err := engine.Execute(context.Background(), query.Request{
+		SQL: "SELECT json_extract_string(payload_json, '$.value') AS value FROM events ORDER BY value",
+		Files: []query.TableFile{
+			{TableName: "events", ObjectPath: "tenant/events/part-1.parquet"},
+			{TableName: "events", ObjectPath: "tenant/events/delete-1.parquet"},
+			{TableName: "events", ObjectPath: "tenant/events/part-2.parquet"},
+		},
+		PrimaryKeys: map[string][]string{"events": {"id"}},
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if len(result.Rows) != 2 || result.Rows[0][0] != "a2" || result.Rows[1][0] != "c2" {
+		t.Fatalf("rows = %#v", result.Rows)
+	}
+	for _, column := range result.Columns {
+		if strings.HasPrefix(column, "__duckmesh") {
+			t.Fatalf("internal column %q leaked into result", column)
+		}
+	}
+}
+
+func buildParquet[T any](rows []T) ([]byte, error) {
 	buf := bytes.NewBuffer(nil)
-	writer := parquet.NewGenericWriter[row](buf)
+	writer := parquet.NewGenericWriter[T](buf)
 	if _, err := writer.Write(rows); err != nil {
 		return nil, err
 	}
@@ -94,8 +153,16 @@ type memoryStore struct {
 	objects map[string][]byte
 }
 
-func (m *memoryStore) Put(context.Context, string, io.Reader, int64, storage.PutOptions) (storage.ObjectInfo, error) {
-	return storage.ObjectInfo{}, nil
+func (m *memoryStore) Put(_ context.Context, key string, body io.Reader, _ int64, _ storage.PutOptions) (storage.ObjectInfo, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	if m.objects == nil {
+		m.objects = map[string][]byte{}
+	}
+	m.objects[key] = data
+	return storage.ObjectInfo{Key: key, Size: int64(len(data))}, nil
 }
 
 func (m *memoryStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
@@ -111,3 +178,216 @@ func (m *memoryStore) Delete(context.Context, string) error {
 }
 
 var _ = time.Second
+
+func TestInspectUsesDuckDBParser(t *testing.T) {
+	engine := NewEngine(&memoryStore{})
+
+	statement, err := engine.Inspect(context.Background(), `
+WITH recent AS (SELECT * FROM events WHERE id > 1)
+SELECT r.id, upper(u.name), row_number() OVER ()
+FROM recent AS r
+JOIN Users AS u USING (id)
+CROSS JOIN range(3)
+WHERE r.value IN (SELECT value FROM main.allowed) AND u.id + 1 > 2`)
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	tables := make([]string, 0, len(statement.Tables))
+	for _, table := range statement.Tables {
+		tables = append(tables, strings.Trim(table.Schema+"."+table.Name, "."))
+	}
+	if !statement.ReadOnly || statement.Statements != 1 || statement.All || strings.Join(tables, ",") != "Users,main.allowed,events" {
+		t.Fatalf("statement = %+v", statement)
+	}
+	if strings.Join(statement.TableFunctions, ",") != "range" || strings.Join(statement.Functions, ",") != "row_number,upper" {
+		t.Fatalf("functions = %v, %v", statement.TableFunctions, statement.Functions)
+	}
+
+	statement, err = engine.Inspect(context.Background(), "SELECT * FROM query_table('events'); SELECT * FROM read_csv(getenv('HOME'))")
+	if err != nil || !statement.All || statement.Statements != 2 || strings.Join(statement.TableFunctions, ",") != "query_table,read_csv" || strings.Join(statement.Functions, ",") != "getenv" {
+		t.Fatalf("statement = %+v, err = %v", statement, err)
+	}
+
+	for _, sqlText := range []string{"COPY (SELECT 1) TO 'out.csv'", "ATTACH 'other.db'", "SET threads = 1", "INSTALL httpfs"} {
+		statement, err := engine.Inspect(context.Background(), sqlText)
+		if err != nil || statement.ReadOnly {
+			t.Fatalf("%s: statement = %+v, err = %v", sqlText, statement, err)
+		}
+	}
+
+	if _, err := engine.Inspect(context.Background(), "SELEC 1"); !errors.Is(err, query.ErrInvalidSQL) {
+		t.Fatalf("Inspect() error = %v, want ErrInvalidSQL", err)
+	}
+}
+
+func TestExecuteOnlyReachesDownloadedFiles(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "secret.csv")
+	if err := os.WriteFile(outside, []byte("a\n1\n"), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	parquetBytes, err := buildParquet([]row{{ID: 1, Value: "a"}})
+	if err != nil {
+		t.Fatalf("buildParquet() error = %v", err)
+	}
+	engine := NewEngine(&memoryStore{objects: map[string][]byte{"k1": parquetBytes}})
+	files := []query.TableFile{{TableName: "events", ObjectPath: "k1"}}
+
+	for _, sqlText := range []string{
+		"SELECT * FROM read_csv(" + quoteString(outside) + ")",
+		"SELECT * FROM " + quoteString(outside),
+		"SELECT count(*) FROM events, read_text(" + quoteString(outside) + ")",
+	} {
+		if _, err := engine.Execute(context.Background(), query.Request{SQL: sqlText, Files: files}); err == nil {
+			t.Fatalf("%s: Execute() should fail", sqlText)
+		}
+	}
+	if _, err := engine.Execute(context.Background(), query.Request{SQL: "SET enable_external_access = true", Files: files}); err == nil {
+		t.Fatal("configuration should be locked")
+	}
+	result, err := engine.Execute(context.Background(), query.Request{SQL: "SELECT count(*) FROM events", Files: files})
+	if err != nil || result.Rows[0][0] != int64(1) {
+		t.Fatalf("result = %+v, err = %v", result, err)
+	}
+}
+
+func TestExecuteReadsRepeatedFilesFromCache(t *testing.T) {
+	parquetBytes, err := buildParquet([]row{{ID: 1, Value: "a"}, {ID: 2, Value: "b"}})
+	if err != nil {
+		t.Fatalf("buildParquet() error = %v", err)
+	}
+	store := &memoryStore{objects: map[string][]byte{"k1": parquetBytes}}
+	objectCache, err := cache.New(store, t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatalf("cache.New() error = %v", err)
+	}
+	engine := NewEngine(store)
+	engine.Cache = objectCache
+	request := query.Request{SQL: "SELECT count(*) FROM events", Files: []query.TableFile{{TableName: "events", ObjectPath: "k1"}}}
+
+	if _, err := engine.Execute(context.Background(), request); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	delete(store.objects, "k1")
+	result, err := engine.Execute(context.Background(), request)
+	if err != nil || result.Rows[0][0] != int64(2) {
+		t.Fatalf("cached result = %+v, err = %v", result, err)
+	}
+	if objectCache.Size() != int64(len(parquetBytes)) {
+		t.Fatalf("cache Size() = %d", objectCache.Size())
+	}
+}
+
+func TestExecuteWithoutFilesRunsConstantQueries(t *testing.T) {
+	result, err := NewEngine(&memoryStore{}).Execute(context.Background(), query.Request{SQL: "SELECT 2 AS c"})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0][0] != int32(2) || result.ScannedFiles != 0 {
+		t.Fatalf("result = %+v", result)
+	}
+}
+
+func TestStreamYieldsRowsAndRemovesFilesOnClose(t *testing.T) {
+	parquetBytes, err := buildParquet([]row{{ID: 1, Value: "a"}, {ID: 2, Value: "b"}, {ID: 3, Value: "c"}})
+	if err != nil {
+		t.Fatalf("buildParquet() error = %v", err)
+	}
+	engine := NewEngine(&memoryStore{objects: map[string][]byte{"k1": parquetBytes}})
+
+	stream, err := engine.Stream(context.Background(), query.Request{
+		SQL:   "SELECT id, value, id * 1.5::DECIMAL(4,1) AS scaled FROM events ORDER BY id",
+		Files: []query.TableFile{{TableName: "events", ObjectPath: "k1", FileSizeBytes: int64(len(parquetBytes))}},
+	})
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	columns := stream.Rows.Columns()
+	if len(columns) != 3 || columns[0] != (query.Column{Name: "id", Type: "BIGINT"}) || columns[1].Type != "VARCHAR" || !strings.HasPrefix(columns[2].Type, "DECIMAL(") {
+		t.Fatalf("columns = %+v", columns)
+	}
+	var ids []any
+	for stream.Rows.Next() {
+		ids = append(ids, stream.Rows.Values()[0])
+	}
+	if err := stream.Rows.Err(); err != nil || len(ids) != 3 || ids[2] != int64(3) || stream.ScannedFiles != 1 {
+		t.Fatalf("ids = %v, err = %v", ids, err)
+	}
+
+	workDir := stream.Rows.(*resultRows).session.workDir
+	if err := stream.Rows.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := os.Stat(workDir); !os.IsNotExist(err) {
+		t.Fatalf("work dir should be removed, stat error = %v", err)
+	}
+}
+
+func TestExportUploadsResultFile(t *testing.T) {
+	parquetBytes, err := buildParquet([]row{{ID: 1, Value: "a"}, {ID: 2, Value: "b"}, {ID: 3, Value: "c"}})
+	if err != nil {
+		t.Fatalf("buildParquet() error = %v", err)
+	}
+	store := &memoryStore{objects: map[string][]byte{"k1": parquetBytes}}
+	request := query.Request{
+		SQL:    "SELECT id, value FROM events WHERE id >= $min ORDER BY id",
+		Files:  []query.TableFile{{TableName: "events", ObjectPath: "k1", FileSizeBytes: int64(len(parquetBytes))}},
+		Params: []query.Param{{Name: "min", Value: int64(2)}},
+	}
+
+	result, err := NewEngine(store).Export(context.Background(), request, query.ExportCSV, "tenant-1/_query_jobs/1/result.csv")
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if got := string(store.objects["tenant-1/_query_jobs/1/result.csv"]); got != "id,value\n2,b\n3,c\n" {
+		t.Fatalf("exported csv = %q", got)
+	}
+	if result.Rows != 2 || result.Bytes != int64(len(store.objects["tenant-1/_query_jobs/1/result.csv"])) || result.ScannedFiles != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+
+	if _, err := NewEngine(store).Export(context.Background(), request, query.ExportParquet, "tenant-1/_query_jobs/1/result.parquet"); err != nil {
+		t.Fatalf("Export(parquet) error = %v", err)
+	}
+	if data := store.objects["tenant-1/_query_jobs/1/result.parquet"]; len(data) < 4 || string(data[:4]) != "PAR1" {
+		t.Fatalf("exported parquet starts with %q", data)
+	}
+}
+
+func TestExecuteBindsParams(t *testing.T) {
+	engine := NewEngine(&memoryStore{})
+	at := time.Date(2026, 2, 19, 10, 30, 0, 0, time.UTC)
+
+	result, err := engine.Execute(context.Background(), query.Request{
+		SQL: `SELECT $id + 1 AS next, $at + INTERVAL 1 HOUR AS later, list_contains($regions, 'eu') AS eu,
+			$amount::DECIMAL(18,4) * 2 AS doubled, 12.50::DECIMAL(10,2) AS fixed`,
+		RowLimit: 10,
+		Params: []query.Param{
+			{Name: "id", Value: int64(41)},
+			{Name: "at", Value: at},
+			{Name: "regions", Value: []any{"us", "eu"}},
+			{Name: "amount", Value: query.Decimal("1.0625")},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	row := result.Rows[0]
+	if row[0] != int64(42) || !row[1].(time.Time).Equal(at.Add(time.Hour)) || row[2] != true || row[3] != "2.125" || row[4] != "12.5" {
+		t.Fatalf("row = %#v", row)
+	}
+
+	result, err = engine.Execute(context.Background(), query.Request{
+		SQL:    "SELECT $2 || $1 AS joined, $1 IS NULL AS missing",
+		Params: []query.Param{{Value: "b"}, {Value: "a"}},
+	})
+	if err != nil {
+		t.Fatalf("Execute() positional error = %v", err)
+	}
+	if result.Rows[0][0] != "ab" || result.Rows[0][1] != false {
+		t.Fatalf("positional row = %#v", result.Rows[0])
+	}
+
+	if _, err := engine.Execute(context.Background(), query.Request{SQL: "SELECT $1 + $2", Params: []query.Param{{Value: int64(1)}}}); err == nil {
+		t.Fatal("Execute() with a missing param should fail")
+	}
+}
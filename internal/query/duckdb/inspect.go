@@ -0,0 +1,152 @@
+package duckdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/duckmesh/duckmesh/internal/query"
+)
+
+// dynamicTableFunctions read tables named by their arguments.
+var dynamicTableFunctions = map[string]bool{"query": true, "query_table": true}
+
+// Inspect parses a statement with DuckDB's parser through
+// json_serialize_sql, which only serializes queries; anything else is
+// reported as not read-only.
+func (e *Engine) Inspect(ctx context.Context, sqlText string) (query.Statement, error) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		return query.Statement{}, fmt.Errorf("open duckdb: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	var serialized string
+	if err := db.QueryRowContext(ctx, `SELECT CAST(json_serialize_sql(?::VARCHAR) AS VARCHAR)`, sqlText).Scan(&serialized); err != nil {
+		return query.Statement{}, fmt.Errorf("serialize sql: %w", err)
+	}
+	return parseStatement([]byte(serialized))
+}
+
+func parseStatement(serialized []byte) (query.Statement, error) {
+	var document struct {
+		Error        bool   `json:"error"`
+		ErrorType    string `json:"error_type"`
+		ErrorMessage string `json:"error_message"`
+		Statements   []any  `json:"statements"`
+	}
+	if err := json.Unmarshal(serialized, &document); err != nil {
+		return query.Statement{}, fmt.Errorf("decode serialized sql: %w", err)
+	}
+	if document.Error {
+		switch document.ErrorType {
+		case "parser":
+			return query.Statement{}, fmt.Errorf("%w: %s", query.ErrInvalidSQL, document.ErrorMessage)
+		case "not implemented":
+			return query.Statement{ReadOnly: false}, nil
+		}
+		return query.Statement{}, fmt.Errorf("serialize sql: %s", document.ErrorMessage)
+	}
+
+	walker := statementWalker{tables: map[query.TableName]bool{}, tableFunctions: map[string]bool{}, functions: map[string]bool{}}
+	walker.walk(document.Statements, nil)
+
+	statement := query.Statement{Statements: len(document.Statements), ReadOnly: true, All: walker.all}
+	for table := range walker.tables {
+		statement.Tables = append(statement.Tables, table)
+	}
+	sort.Slice(statement.Tables, func(i, j int) bool {
+		a, b := statement.Tables[i], statement.Tables[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Schema != b.Schema {
+			return a.Schema < b.Schema
+		}
+		return a.Catalog < b.Catalog
+	})
+	statement.TableFunctions = sortedKeys(walker.tableFunctions)
+	statement.Functions = sortedKeys(walker.functions)
+	return statement, nil
+}
+
+type statementWalker struct {
+	tables         map[query.TableName]bool
+	tableFunctions map[string]bool
+	functions      map[string]bool
+	all            bool
+}
+
+// walk visits the serialized tree. ctes holds the lowercase names of the
+// CTEs in scope, which unqualified table references resolve to first.
+func (w *statementWalker) walk(node any, ctes map[string]bool) {
+	switch typed := node.(type) {
+	case map[string]any:
+		if cteMap, ok := typed["cte_map"].(map[string]any); ok {
+			if entries, ok := cteMap["map"].([]any); ok && len(entries) > 0 {
+				scoped := make(map[string]bool, len(ctes)+len(entries))
+				for name := range ctes {
+					scoped[name] = true
+				}
+				for _, entry := range entries {
+					if key, ok := entry.(map[string]any)["key"].(string); ok {
+						scoped[strings.ToLower(key)] = true
+					}
+				}
+				ctes = scoped
+			}
+		}
+
+		switch {
+		case typed["type"] == "BASE_TABLE":
+			table := query.TableName{Catalog: stringField(typed, "catalog_name"), Schema: stringField(typed, "schema_name"), Name: stringField(typed, "table_name")}
+			if table.Name != "" && !(table.Catalog == "" && table.Schema == "" && ctes[strings.ToLower(table.Name)]) {
+				w.tables[table] = true
+			}
+		case typed["type"] == "TABLE_FUNCTION":
+			if function, ok := typed["function"].(map[string]any); ok {
+				name := strings.ToLower(stringField(function, "function_name"))
+				w.tableFunctions[name] = true
+				w.all = w.all || dynamicTableFunctions[name]
+				// The arguments are walked, not the function itself.
+				for _, child := range function {
+					w.walk(child, ctes)
+				}
+			}
+			for key, child := range typed {
+				if key != "function" {
+					w.walk(child, ctes)
+				}
+			}
+			return
+		case typed["class"] == "FUNCTION" || typed["class"] == "WINDOW":
+			if name := stringField(typed, "function_name"); name != "" && typed["is_operator"] != true {
+				w.functions[strings.ToLower(name)] = true
+			}
+		}
+		for _, child := range typed {
+			w.walk(child, ctes)
+		}
+	case []any:
+		for _, child := range typed {
+			w.walk(child, ctes)
+		}
+	}
+}
+
+func stringField(node map[string]any, key string) string {
+	value, _ := node[key].(string)
+	return value
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
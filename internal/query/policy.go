@@ -0,0 +1,143 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Reasons a statement is rejected by a Policy.
+const (
+	ReasonNotReadOnly             = "NOT_READ_ONLY"
+	ReasonMultipleStatements      = "MULTIPLE_STATEMENTS"
+	ReasonTableNotAllowed         = "TABLE_NOT_ALLOWED"
+	ReasonTableFunctionNotAllowed = "TABLE_FUNCTION_NOT_ALLOWED"
+	ReasonFunctionNotAllowed      = "FUNCTION_NOT_ALLOWED"
+)
+
+// defaultTableFunctions are the table functions that only generate values.
+// Every other table function, including the read_*, glob, duckdb_*, pragma_*
+// and scanner functions, reaches files, the network or engine state.
+var defaultTableFunctions = map[string]bool{
+	"range":           true,
+	"generate_series": true,
+	"unnest":          true,
+	"json_each":       true,
+	"json_tree":       true,
+}
+
+// defaultDeniedFunctions are scalar functions that expose the host or the
+// engine configuration.
+var defaultDeniedFunctions = map[string]bool{
+	"getenv":          true,
+	"current_setting": true,
+	"getvariable":     true,
+}
+
+// Policy decides which statements a tenant may run. The zero value allows a
+// single read-only statement over the tenant's tables that calls no table
+// functions besides range, generate_series, unnest, json_each and json_tree
+// and none of getenv, current_setting and getvariable.
+type Policy struct {
+	// AllowedFunctions and DeniedFunctions override the built-in rules for
+	// every tenant. TenantAllowedFunctions and TenantDeniedFunctions
+	// override both for single tenants. Within one level, denial wins.
+	AllowedFunctions       []string
+	DeniedFunctions        []string
+	TenantAllowedFunctions map[string][]string
+	TenantDeniedFunctions  map[string][]string
+}
+
+// Violation is returned by Policy.Check. Name is the offending table or
+// function, if any.
+type Violation struct {
+	Reason string
+	Name   string
+}
+
+func (v *Violation) Error() string {
+	switch v.Reason {
+	case ReasonNotReadOnly:
+		return "only read-only queries are allowed"
+	case ReasonMultipleStatements:
+		return "only a single statement is allowed"
+	case ReasonTableNotAllowed:
+		return fmt.Sprintf("table %q is not a table of the tenant", v.Name)
+	case ReasonTableFunctionNotAllowed:
+		return fmt.Sprintf("table function %q is not allowed", v.Name)
+	case ReasonFunctionNotAllowed:
+		return fmt.Sprintf("function %q is not allowed", v.Name)
+	}
+	return v.Reason
+}
+
+// Check returns a *Violation when the tenant may not run the statement.
+func (p Policy) Check(tenantID string, statement Statement) error {
+	if !statement.ReadOnly {
+		return &Violation{Reason: ReasonNotReadOnly}
+	}
+	if statement.Statements != 1 {
+		return &Violation{Reason: ReasonMultipleStatements}
+	}
+	for _, name := range statement.TableFunctions {
+		if !p.functionAllowed(tenantID, name, defaultTableFunctions[name]) {
+			return &Violation{Reason: ReasonTableFunctionNotAllowed, Name: name}
+		}
+	}
+	for _, name := range statement.Functions {
+		if !p.functionAllowed(tenantID, name, !defaultDeniedFunctions[name]) {
+			return &Violation{Reason: ReasonFunctionNotAllowed, Name: name}
+		}
+	}
+	return nil
+}
+
+// CheckTables returns a *Violation when the statement reads a table other
+// than the given ones. Unqualified and main schema references are allowed.
+func CheckTables(statement Statement, tables []string) error {
+	known := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		known[strings.ToLower(table)] = true
+	}
+	for _, table := range statement.Tables {
+		qualified := table.Catalog != "" || (table.Schema != "" && !strings.EqualFold(table.Schema, "main"))
+		if qualified || !known[strings.ToLower(table.Name)] {
+			return &Violation{Reason: ReasonTableNotAllowed, Name: qualifiedName(table)}
+		}
+	}
+	return nil
+}
+
+func (p Policy) functionAllowed(tenantID, name string, builtIn bool) bool {
+	if containsFold(p.TenantDeniedFunctions[tenantID], name) {
+		return false
+	}
+	if containsFold(p.TenantAllowedFunctions[tenantID], name) {
+		return true
+	}
+	if containsFold(p.DeniedFunctions, name) {
+		return false
+	}
+	if containsFold(p.AllowedFunctions, name) {
+		return true
+	}
+	return builtIn
+}
+
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(strings.TrimSpace(candidate), value) {
+			return true
+		}
+	}
+	return false
+}
+
+func qualifiedName(table TableName) string {
+	parts := make([]string, 0, 3)
+	for _, part := range []string{table.Catalog, table.Schema, table.Name} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,65 @@
+package query
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPolicyCheckReportsReasons(t *testing.T) {
+	tables := []string{"events", "Users"}
+	tests := []struct {
+		name      string
+		statement Statement
+		want      string
+		wantName  string
+	}{
+		{name: "query", statement: Statement{Statements: 1, ReadOnly: true, Tables: []TableName{{Name: "EVENTS"}, {Schema: "main", Name: "users"}}, TableFunctions: []string{"range"}, Functions: []string{"upper"}}},
+		{name: "copy", statement: Statement{}, want: ReasonNotReadOnly},
+		{name: "two statements", statement: Statement{Statements: 2, ReadOnly: true}, want: ReasonMultipleStatements},
+		{name: "read_csv", statement: Statement{Statements: 1, ReadOnly: true, TableFunctions: []string{"read_csv"}}, want: ReasonTableFunctionNotAllowed, wantName: "read_csv"},
+		{name: "getenv", statement: Statement{Statements: 1, ReadOnly: true, Functions: []string{"getenv"}}, want: ReasonFunctionNotAllowed, wantName: "getenv"},
+		{name: "file path", statement: Statement{Statements: 1, ReadOnly: true, Tables: []TableName{{Name: "/etc/passwd"}}}, want: ReasonTableNotAllowed, wantName: "/etc/passwd"},
+		{name: "system table", statement: Statement{Statements: 1, ReadOnly: true, Tables: []TableName{{Schema: "information_schema", Name: "tables"}}}, want: ReasonTableNotAllowed, wantName: "information_schema.tables"},
+		{name: "other catalog", statement: Statement{Statements: 1, ReadOnly: true, Tables: []TableName{{Catalog: "other", Schema: "main", Name: "events"}}}, want: ReasonTableNotAllowed, wantName: "other.main.events"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Policy{}.Check("tenant-1", tc.statement)
+			if err == nil {
+				err = CheckTables(tc.statement, tables)
+			}
+			if tc.want == "" {
+				if err != nil {
+					t.Fatalf("Check() error = %v", err)
+				}
+				return
+			}
+			var violation *Violation
+			if !errors.As(err, &violation) || violation.Reason != tc.want || violation.Name != tc.wantName {
+				t.Fatalf("Check() error = %#v, want %s %q", err, tc.want, tc.wantName)
+			}
+		})
+	}
+}
+
+func TestPolicyTenantOverrides(t *testing.T) {
+	policy := Policy{
+		AllowedFunctions:       []string{"query_table"},
+		DeniedFunctions:        []string{"generate_series"},
+		TenantAllowedFunctions: map[string][]string{"trusted": {"Read_Parquet", "generate_series"}},
+		TenantDeniedFunctions:  map[string][]string{"locked": {"query_table", "upper"}},
+	}
+	check := func(tenantID string, tableFunctions, functions []string) bool {
+		return policy.Check(tenantID, Statement{Statements: 1, ReadOnly: true, TableFunctions: tableFunctions, Functions: functions}) == nil
+	}
+
+	if !check("any", []string{"query_table"}, nil) || check("any", []string{"generate_series"}, nil) || check("any", []string{"read_parquet"}, nil) {
+		t.Fatal("global rules were not applied")
+	}
+	if !check("trusted", []string{"read_parquet", "generate_series"}, nil) {
+		t.Fatal("tenant allowances should override the global rules")
+	}
+	if check("locked", []string{"query_table"}, nil) || check("locked", nil, []string{"upper"}) {
+		t.Fatal("tenant denials should override the global rules")
+	}
+}
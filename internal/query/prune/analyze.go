@@ -0,0 +1,684 @@
+// Package prune decides which data files a query can skip. It recognizes a
+// deliberately small subset of SQL: conjunctions of comparisons between a
+// column and a literal in the WHERE clause of a SELECT block. Anything it does
+// not understand leaves the affected tables unpruned.
+package prune
+
+import (
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Op is a comparison operator of a Predicate.
+type Op string
+
+const (
+	OpEqual        Op = "="
+	OpNotEqual     Op = "!="
+	OpLess         Op = "<"
+	OpLessEqual    Op = "<="
+	OpGreater      Op = ">"
+	OpGreaterEqual Op = ">="
+	OpIn           Op = "in"
+	OpBetween      Op = "between"
+)
+
+type LiteralKind int
+
+const (
+	LiteralNumber LiteralKind = iota
+	LiteralString
+	LiteralBool
+	LiteralTime
+)
+
+// Literal is a constant a column is compared with.
+type Literal struct {
+	Kind LiteralKind
+	// Text is the number or string of LiteralNumber and LiteralString.
+	Text string
+	Bool bool
+	Time time.Time
+	// Zoned marks times that depend on the session time zone or on when the
+	// query runs, such as now() - INTERVAL 1 HOUR. They are compared with
+	// zonedSlack on either side.
+	Zoned bool
+}
+
+// Predicate is a condition every row read from a table must satisfy.
+// Column is lower-case; Values holds one literal, the list of OpIn or the
+// low and high bounds of OpBetween.
+type Predicate struct {
+	Column string
+	Op     Op
+	Values []Literal
+}
+
+// Analysis is what Analyze learned about the tables of a statement.
+type Analysis struct {
+	filters map[string][][]Predicate
+	opaque  map[string]bool
+	failed  bool
+}
+
+// Filters returns one predicate conjunction per reference to table in the
+// statement; a row is read when it satisfies any of them. ok is false when
+// the statement may read the table in ways the analysis cannot see, in which
+// case nothing may be pruned.
+func (a Analysis) Filters(table string) ([][]Predicate, bool) {
+	table = strings.ToLower(table)
+	if a.failed || a.opaque[table] {
+		return nil, false
+	}
+	filters, ok := a.filters[table]
+	return filters, ok
+}
+
+// Analyze inspects a single SELECT statement. now resolves now() and
+// current_timestamp.
+func Analyze(sqlText string, now time.Time) Analysis {
+	tokens, ok := tokenize(sqlText)
+	for ok && len(tokens) > 0 && tokens[len(tokens)-1].symbol(";") {
+		tokens = tokens[:len(tokens)-1]
+	}
+	if !ok || len(tokens) == 0 {
+		return Analysis{failed: true}
+	}
+	for i, tok := range tokens {
+		if tok.symbol(";") {
+			return Analysis{failed: true}
+		}
+		// query() and query_table() read tables named in strings.
+		if (tok.keyword("query") || tok.keyword("query_table")) && i+1 < len(tokens) && tokens[i+1].symbol("(") {
+			return Analysis{failed: true}
+		}
+	}
+
+	a := &analyzer{
+		tokens:   tokens,
+		closing:  matchParens(tokens),
+		now:      now.UTC(),
+		resolved: make([]bool, len(tokens)),
+		analysis: Analysis{filters: map[string][][]Predicate{}, opaque: map[string]bool{}},
+	}
+	for _, name := range cteNames(tokens) {
+		a.analysis.opaque[name] = true
+	}
+	a.scope(0, len(tokens))
+	// A table name anywhere else, for example in SUMMARIZE or as an alias,
+	// is a use the analysis did not follow.
+	for i, tok := range tokens {
+		if tok.name() && !a.resolved[i] && !(i+1 < len(tokens) && tokens[i+1].symbol(".")) {
+			a.analysis.opaque[tok.text] = true
+		}
+	}
+	for name := range a.analysis.opaque {
+		if _, ok := a.analysis.filters[name]; !ok {
+			delete(a.analysis.opaque, name)
+		}
+	}
+	return a.analysis
+}
+
+type analyzer struct {
+	tokens   []token
+	closing  []int
+	now      time.Time
+	resolved []bool
+	analysis Analysis
+}
+
+type tableRef struct {
+	name  string
+	alias string
+	// renamed is set when the alias renames columns, as in t AS x(a, b).
+	renamed bool
+}
+
+// zonedSlack covers every UTC offset and the delay between analysis and
+// execution of clock-relative literals.
+const zonedSlack = 14 * time.Hour
+
+var setOperations = map[string]bool{"union": true, "except": true, "intersect": true}
+
+var clauseEnds = map[string]bool{
+	"group": true, "having": true, "order": true, "limit": true, "offset": true, "qualify": true,
+	"window": true, "fetch": true, "union": true, "except": true, "intersect": true,
+}
+
+var fromKeywords = map[string]bool{
+	"join": true, "left": true, "right": true, "full": true, "inner": true, "outer": true, "cross": true,
+	"natural": true, "lateral": true, "positional": true, "asof": true, "anti": true, "semi": true,
+	"on": true, "using": true, "where": true, "as": true, "pivot": true, "unpivot": true,
+	"tablesample": true, "sample": true, "select": true,
+}
+
+func matchParens(tokens []token) []int {
+	closing := make([]int, len(tokens))
+	stack := make([]int, 0)
+	for i, tok := range tokens {
+		closing[i] = -1
+		switch {
+		case tok.symbol("("):
+			stack = append(stack, i)
+		case tok.symbol(")"):
+			open := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			closing[open] = i
+		}
+	}
+	return closing
+}
+
+// cteNames returns the names defined by WITH clauses. The same name may refer
+// to the CTE or to the table depending on scope, so such tables are opaque.
+func cteNames(tokens []token) []string {
+	names := make([]string, 0)
+	for i, tok := range tokens {
+		if !tok.keyword("as") || i == 0 {
+			continue
+		}
+		j := i + 1
+		if j < len(tokens) && tokens[j].keyword("not") {
+			j++
+		}
+		if j < len(tokens) && tokens[j].keyword("materialized") {
+			j++
+		}
+		if j >= len(tokens) || !tokens[j].symbol("(") {
+			continue
+		}
+		k := i - 1
+		if tokens[k].symbol(")") {
+			for k >= 0 && !(tokens[k].symbol("(") && tokens[k].depth == tokens[i].depth) {
+				k--
+			}
+			k--
+		}
+		if k >= 0 && tokens[k].name() {
+			names = append(names, tokens[k].text)
+		}
+	}
+	return names
+}
+
+// scope analyzes tokens[start:end], all at the same depth, and recurses into
+// parenthesized groups.
+func (a *analyzer) scope(start, end int) {
+	if start >= end {
+		return
+	}
+	depth := a.tokens[start].depth
+	blockStart := start
+	for i := start; i < end; i++ {
+		tok := a.tokens[i]
+		if tok.symbol("(") {
+			a.scope(i+1, a.closing[i])
+			i = a.closing[i]
+			continue
+		}
+		if tok.depth == depth && tok.kind == tokenIdent && setOperations[tok.text] {
+			a.block(blockStart, i)
+			blockStart = i + 1
+		}
+	}
+	a.block(blockStart, end)
+}
+
+// block analyzes one SELECT block, possibly in FROM-first form.
+func (a *analyzer) block(start, end int) {
+	if start >= end {
+		return
+	}
+	from := a.findKeyword(start, end, "from")
+	if from < 0 {
+		return
+	}
+	fromEnd := end
+	where := a.findKeyword(from+1, end, "where")
+	if where >= 0 {
+		fromEnd = where
+	}
+	for i := from + 1; i < fromEnd; i++ {
+		if tok := a.tokens[i]; tok.depth == a.tokens[from].depth && tok.kind == tokenIdent && clauseEnds[tok.text] {
+			fromEnd = i
+			break
+		}
+	}
+	refs := a.fromRefs(from+1, fromEnd)
+
+	conjunctions := make([][]Predicate, len(refs))
+	if where >= 0 {
+		whereEnd := end
+		for i := where + 1; i < end; i++ {
+			if tok := a.tokens[i]; tok.depth == a.tokens[where].depth && tok.kind == tokenIdent && clauseEnds[tok.text] {
+				whereEnd = i
+				break
+			}
+		}
+		for _, conjunct := range a.conjuncts(where+1, whereEnd) {
+			qualifier, predicate, ok := a.predicate(conjunct)
+			if !ok {
+				continue
+			}
+			if target := resolveRef(refs, qualifier); target >= 0 && !refs[target].renamed {
+				conjunctions[target] = append(conjunctions[target], predicate)
+			}
+		}
+	}
+	for i, ref := range refs {
+		a.analysis.filters[ref.name] = append(a.analysis.filters[ref.name], conjunctions[i])
+	}
+}
+
+func (a *analyzer) findKeyword(start, end int, keyword string) int {
+	if start >= end {
+		return -1
+	}
+	for i := start; i < end; i++ {
+		if a.tokens[i].depth == a.tokens[start].depth && a.tokens[i].keyword(keyword) {
+			return i
+		}
+	}
+	return -1
+}
+
+// fromRefs collects the plain table references of a FROM clause and marks
+// their tokens as resolved. Dotted names count as references without
+// predicates.
+func (a *analyzer) fromRefs(start, end int) []tableRef {
+	refs := make([]tableRef, 0)
+	expectTable := true
+	for i := start; i < end; i++ {
+		tok := a.tokens[i]
+		switch {
+		case tok.symbol("("):
+			i = a.closing[i]
+			expectTable = false
+		case tok.symbol(","), tok.keyword("join"):
+			expectTable = true
+		case expectTable && tok.name() && !(tok.kind == tokenIdent && fromKeywords[tok.text]):
+			expectTable = false
+			if i+1 < end && a.tokens[i+1].symbol("(") {
+				continue
+			}
+			ref := tableRef{name: tok.text}
+			a.resolved[i] = true
+			for i+2 < end && a.tokens[i+1].symbol(".") && a.tokens[i+2].name() {
+				ref.name = a.tokens[i+2].text
+				ref.renamed = true
+				a.resolved[i+2] = true
+				i += 2
+			}
+			if i+1 < end && a.tokens[i+1].keyword("as") {
+				i++
+			}
+			if i+1 < end && a.tokens[i+1].name() && !(a.tokens[i+1].kind == tokenIdent && fromKeywords[a.tokens[i+1].text]) {
+				ref.alias = a.tokens[i+1].text
+				a.resolved[i+1] = true
+				i++
+				if i+1 < end && a.tokens[i+1].symbol("(") {
+					ref.renamed = true
+				}
+			}
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+func resolveRef(refs []tableRef, qualifier string) int {
+	if qualifier == "" {
+		if len(refs) == 1 {
+			return 0
+		}
+		return -1
+	}
+	for i, ref := range refs {
+		if ref.alias == qualifier || ref.alias == "" && ref.name == qualifier {
+			return i
+		}
+	}
+	return -1
+}
+
+// conjuncts splits a WHERE clause on its top-level ANDs. A top-level OR makes
+// the whole clause unusable.
+func (a *analyzer) conjuncts(start, end int) [][]token {
+	if start >= end {
+		return nil
+	}
+	depth := a.tokens[start].depth
+	out := make([][]token, 0)
+	partStart := start
+	inBetween := false
+	for i := start; i < end; i++ {
+		tok := a.tokens[i]
+		if tok.depth != depth {
+			continue
+		}
+		switch {
+		case tok.keyword("or"):
+			return nil
+		case tok.keyword("between"):
+			inBetween = true
+		case tok.keyword("and") && inBetween:
+			inBetween = false
+		case tok.keyword("and"):
+			out = append(out, a.tokens[partStart:i])
+			partStart = i + 1
+		}
+	}
+	return append(out, a.tokens[partStart:end])
+}
+
+var comparisonOps = map[string]Op{
+	"=": OpEqual, "==": OpEqual, "!=": OpNotEqual, "<>": OpNotEqual,
+	"<": OpLess, "<=": OpLessEqual, ">": OpGreater, ">=": OpGreaterEqual,
+}
+
+var flippedOps = map[Op]Op{
+	OpEqual: OpEqual, OpNotEqual: OpNotEqual,
+	OpLess: OpGreater, OpLessEqual: OpGreaterEqual, OpGreater: OpLess, OpGreaterEqual: OpLessEqual,
+}
+
+// predicate recognizes col op literal, literal op col, col BETWEEN literal AND
+// literal and col IN (literal, ...).
+func (a *analyzer) predicate(tokens []token) (string, Predicate, bool) {
+	if len(tokens) < 3 {
+		return "", Predicate{}, false
+	}
+	if qualifier, column, next, ok := a.columnRef(tokens); ok && next < len(tokens) {
+		rest := tokens[next+1:]
+		switch {
+		case tokens[next].kind == tokenSymbol && comparisonOps[tokens[next].text] != "":
+			if literal, ok := a.literal(rest); ok {
+				return qualifier, Predicate{Column: column, Op: comparisonOps[tokens[next].text], Values: []Literal{literal}}, true
+			}
+		case tokens[next].keyword("between"):
+			for i, tok := range rest {
+				if !tok.keyword("and") || tok.depth != tokens[next].depth {
+					continue
+				}
+				low, okLow := a.literal(rest[:i])
+				high, okHigh := a.literal(rest[i+1:])
+				if !okLow || !okHigh {
+					break
+				}
+				return qualifier, Predicate{Column: column, Op: OpBetween, Values: []Literal{low, high}}, true
+			}
+		case tokens[next].keyword("in"):
+			if values, ok := a.literalList(rest); ok {
+				return qualifier, Predicate{Column: column, Op: OpIn, Values: values}, true
+			}
+		}
+		return "", Predicate{}, false
+	}
+
+	depth := tokens[0].depth
+	for i, tok := range tokens {
+		op, isOp := comparisonOps[tok.text]
+		if tok.depth != depth || tok.kind != tokenSymbol || !isOp {
+			continue
+		}
+		literal, ok := a.literal(tokens[:i])
+		if !ok {
+			break
+		}
+		qualifier, column, next, ok := a.columnRef(tokens[i+1:])
+		if !ok || i+1+next != len(tokens) {
+			break
+		}
+		return qualifier, Predicate{Column: column, Op: flippedOps[op], Values: []Literal{literal}}, true
+	}
+	return "", Predicate{}, false
+}
+
+// columnRef reads col or qualifier.col and returns the index after it.
+func (a *analyzer) columnRef(tokens []token) (string, string, int, bool) {
+	if len(tokens) == 0 || !tokens[0].name() || tokens[0].kind == tokenIdent && isLiteralKeyword(tokens[0].text) {
+		return "", "", 0, false
+	}
+	if len(tokens) > 1 && (tokens[1].symbol("(") || tokens[1].kind == tokenString) {
+		return "", "", 0, false
+	}
+	if len(tokens) > 2 && tokens[1].symbol(".") && tokens[2].name() {
+		if len(tokens) > 3 && (tokens[3].symbol(".") || tokens[3].symbol("(")) {
+			return "", "", 0, false
+		}
+		return tokens[0].text, tokens[2].text, 3, true
+	}
+	return "", tokens[0].text, 1, true
+}
+
+func isLiteralKeyword(text string) bool {
+	switch text {
+	case "true", "false", "null", "interval", "current_timestamp", "current_date", "not":
+		return true
+	}
+	return false
+}
+
+func (a *analyzer) literalList(tokens []token) ([]Literal, bool) {
+	if len(tokens) < 3 || !tokens[0].symbol("(") || !tokens[len(tokens)-1].symbol(")") {
+		return nil, false
+	}
+	inner := tokens[1 : len(tokens)-1]
+	values := make([]Literal, 0)
+	start := 0
+	for i := 0; i <= len(inner); i++ {
+		if i < len(inner) && !(inner[i].symbol(",") && inner[i].depth == tokens[0].depth+1) {
+			continue
+		}
+		value, ok := a.literal(inner[start:i])
+		if !ok {
+			return nil, false
+		}
+		values = append(values, value)
+		start = i + 1
+	}
+	return values, true
+}
+
+// literal parses a constant that makes up all of tokens.
+func (a *analyzer) literal(tokens []token) (Literal, bool) {
+	if len(tokens) == 0 {
+		return Literal{}, false
+	}
+	first := tokens[0]
+	switch {
+	case first.kind == tokenNumber && len(tokens) == 1:
+		return numberLiteral(first.text)
+	case (first.symbol("-") || first.symbol("+")) && len(tokens) == 2 && tokens[1].kind == tokenNumber:
+		return numberLiteral(strings.TrimPrefix(first.text, "+") + tokens[1].text)
+	case first.kind == tokenString && len(tokens) == 1:
+		return Literal{Kind: LiteralString, Text: first.text}, true
+	case first.kind == tokenString && len(tokens) == 3 && tokens[1].symbol("::") && tokens[2].kind == tokenIdent:
+		return castLiteral(Literal{Kind: LiteralString, Text: first.text}, tokens[2].text)
+	case first.kind == tokenNumber && len(tokens) == 3 && tokens[1].symbol("::") && tokens[2].kind == tokenIdent:
+		if literal, ok := numberLiteral(first.text); ok {
+			return castLiteral(literal, tokens[2].text)
+		}
+	case first.kind == tokenIdent && len(tokens) == 2 && tokens[1].kind == tokenString:
+		return castLiteral(Literal{Kind: LiteralString, Text: tokens[1].text}, first.text)
+	case first.keyword("true") && len(tokens) == 1:
+		return Literal{Kind: LiteralBool, Bool: true}, true
+	case first.keyword("false") && len(tokens) == 1:
+		return Literal{Kind: LiteralBool}, true
+	case first.keyword("cast") && len(tokens) > 4 && tokens[1].symbol("(") && a.closingIn(tokens, 1) == len(tokens)-1:
+		inner := tokens[2 : len(tokens)-1]
+		for i, tok := range inner {
+			if tok.keyword("as") && tok.depth == tokens[1].depth+1 && i+2 == len(inner) && inner[i+1].kind == tokenIdent {
+				if value, ok := a.literal(inner[:i]); ok {
+					return castLiteral(value, inner[i+1].text)
+				}
+			}
+		}
+	default:
+		return a.clockLiteral(tokens)
+	}
+	return Literal{}, false
+}
+
+func (a *analyzer) closingIn(tokens []token, open int) int {
+	for i := open + 1; i < len(tokens); i++ {
+		if tokens[i].symbol(")") && tokens[i].depth == tokens[open].depth {
+			return i
+		}
+	}
+	return -1
+}
+
+// clockLiteral parses now(), current_timestamp or current_date, optionally
+// plus or minus an interval.
+func (a *analyzer) clockLiteral(tokens []token) (Literal, bool) {
+	next := 0
+	value := Literal{Kind: LiteralTime, Time: a.now, Zoned: true}
+	switch {
+	case len(tokens) >= 3 && (tokens[0].keyword("now") || tokens[0].keyword("get_current_timestamp")) && tokens[1].symbol("(") && tokens[2].symbol(")"):
+		next = 3
+	case tokens[0].keyword("current_timestamp") || tokens[0].keyword("current_date"):
+		next = 1
+		if tokens[0].keyword("current_date") {
+			value.Time = value.Time.Truncate(24 * time.Hour)
+		}
+		if len(tokens) >= 3 && tokens[1].symbol("(") && tokens[2].symbol(")") {
+			next = 3
+		}
+	default:
+		return Literal{}, false
+	}
+	if next == len(tokens) {
+		return value, true
+	}
+	if !tokens[next].symbol("-") && !tokens[next].symbol("+") {
+		return Literal{}, false
+	}
+	offset, ok := intervalLiteral(tokens[next+1:])
+	if !ok {
+		return Literal{}, false
+	}
+	if tokens[next].symbol("-") {
+		offset = -offset
+	}
+	value.Time = value.Time.Add(offset)
+	return value, true
+}
+
+const maxInterval = 100 * 365 * 24 * time.Hour
+
+var intervalUnits = map[string]time.Duration{
+	"microsecond": time.Microsecond, "millisecond": time.Millisecond, "second": time.Second,
+	"minute": time.Minute, "hour": time.Hour, "day": 24 * time.Hour, "week": 7 * 24 * time.Hour,
+}
+
+// intervalLiteral parses INTERVAL n unit, INTERVAL 'n unit' and INTERVAL 'n'
+// unit. Months and years are not fixed durations and are not supported.
+func intervalLiteral(tokens []token) (time.Duration, bool) {
+	if len(tokens) < 2 || !tokens[0].keyword("interval") {
+		return 0, false
+	}
+	var amount, unit string
+	switch {
+	case len(tokens) == 2 && tokens[1].kind == tokenString:
+		fields := strings.Fields(tokens[1].text)
+		if len(fields) != 2 {
+			return 0, false
+		}
+		amount, unit = fields[0], strings.ToLower(fields[1])
+	case len(tokens) == 3 && (tokens[1].kind == tokenNumber || tokens[1].kind == tokenString) && tokens[2].kind == tokenIdent:
+		amount, unit = strings.TrimSpace(tokens[1].text), tokens[2].text
+	default:
+		return 0, false
+	}
+	step, ok := intervalUnits[strings.TrimSuffix(unit, "s")]
+	if !ok {
+		return 0, false
+	}
+	count, ok := new(big.Rat).SetString(amount)
+	if !ok {
+		return 0, false
+	}
+	duration, _ := new(big.Rat).Mul(count, new(big.Rat).SetInt64(int64(step))).Float64()
+	if math.Abs(duration) > float64(maxInterval) {
+		return 0, false
+	}
+	return time.Duration(duration), true
+}
+
+func numberLiteral(text string) (Literal, bool) {
+	if _, ok := new(big.Rat).SetString(text); !ok {
+		return Literal{}, false
+	}
+	return Literal{Kind: LiteralNumber, Text: text}, true
+}
+
+// castLiteral applies a cast to a literal. Casts to types the pruner does not
+// compare fail.
+func castLiteral(value Literal, typeName string) (Literal, bool) {
+	switch typeName {
+	case "timestamp", "datetime", "date", "timestamp_us", "timestamp_ms", "timestamp_s", "timestamptz":
+		if value.Kind != LiteralString {
+			return Literal{}, false
+		}
+		parsed, zoned, ok := parseTime(value.Text)
+		if !ok {
+			return Literal{}, false
+		}
+		if typeName == "date" {
+			parsed = time.Date(parsed.Year(), parsed.Month(), parsed.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		return Literal{Kind: LiteralTime, Time: parsed, Zoned: zoned || typeName == "timestamptz"}, true
+	case "varchar", "text", "string":
+		if value.Kind != LiteralString {
+			return Literal{}, false
+		}
+		return value, true
+	case "bigint", "int8", "long", "integer", "int4", "int", "double", "float8", "decimal", "numeric":
+		if value.Kind != LiteralNumber && value.Kind != LiteralString {
+			return Literal{}, false
+		}
+		return numberLiteral(strings.TrimSpace(value.Text))
+	case "boolean", "bool":
+		if value.Kind == LiteralBool {
+			return value, true
+		}
+	}
+	return Literal{}, false
+}
+
+var timeLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05.999999999",
+}
+
+var zonedTimeLayouts = []string{
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05Z07",
+	"2006-01-02 15:04:05.999999999Z07",
+	time.RFC3339Nano,
+}
+
+// parseTime reads a timestamp string; zoned is true when it carries an offset.
+func parseTime(text string) (time.Time, bool, bool) {
+	text = strings.TrimSpace(text)
+	for _, layout := range timeLayouts {
+		if parsed, err := time.Parse(layout, text); err == nil {
+			return parsed, false, true
+		}
+	}
+	for _, layout := range zonedTimeLayouts {
+		if parsed, err := time.Parse(layout, text); err == nil {
+			return parsed.UTC(), true, true
+		}
+	}
+	return time.Time{}, false, false
+}
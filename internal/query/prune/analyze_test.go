@@ -0,0 +1,115 @@
+package prune
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAnalyzeExtractsConjunctionsPerReference(t *testing.T) {
+	now := time.Date(2026, 2, 19, 12, 0, 0, 0, time.UTC)
+	analysis := Analyze(`
+SELECT e.region, count(*)
+FROM events AS e
+JOIN users u ON u.id = e.user_id
+WHERE e.__event_time >= now() - INTERVAL 1 HOUR
+  AND e.region IN ('eu', 'us')
+  AND u.age BETWEEN 18 AND 30
+  AND 100 > e.amount
+GROUP BY 1`, now)
+
+	events, ok := analysis.Filters("EVENTS")
+	if !ok || len(events) != 1 || len(events[0]) != 3 {
+		t.Fatalf("events filters = %+v, ok = %v", events, ok)
+	}
+	eventTime := events[0][0]
+	if eventTime.Column != "__event_time" || eventTime.Op != OpGreaterEqual || !eventTime.Values[0].Zoned || !eventTime.Values[0].Time.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("event time predicate = %+v", eventTime)
+	}
+	if region := events[0][1]; region.Op != OpIn || fmt.Sprintf("%s %s", region.Values[0].Text, region.Values[1].Text) != "eu us" {
+		t.Fatalf("region predicate = %+v", region)
+	}
+	if amount := events[0][2]; amount.Column != "amount" || amount.Op != OpLess || amount.Values[0].Text != "100" {
+		t.Fatalf("amount predicate = %+v", amount)
+	}
+
+	users, ok := analysis.Filters("users")
+	if !ok || len(users) != 1 || len(users[0]) != 1 || users[0][0].Op != OpBetween {
+		t.Fatalf("users filters = %+v, ok = %v", users, ok)
+	}
+	if _, ok := analysis.Filters("orders"); ok {
+		t.Fatal("orders is not referenced")
+	}
+}
+
+func TestAnalyzeIgnoresWhatItCannotProve(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		sql        string
+		wantOK     bool
+		wantEmpty  bool
+		wantFilter int
+	}{
+		{name: "or", sql: "SELECT * FROM events WHERE id = 1 OR id = 2", wantOK: true, wantEmpty: true, wantFilter: 1},
+		{name: "unqualified join", sql: "SELECT * FROM events, users WHERE id = 1", wantOK: true, wantEmpty: true, wantFilter: 1},
+		{name: "column rename", sql: "SELECT * FROM events AS e(a, b) WHERE a = 1", wantOK: true, wantEmpty: true, wantFilter: 1},
+		{name: "union reads twice", sql: "SELECT * FROM events WHERE id = 1 UNION ALL SELECT * FROM events", wantOK: true, wantFilter: 2},
+		{name: "cte shadows table", sql: "WITH events AS (SELECT * FROM events) SELECT * FROM events WHERE id = 1"},
+		{name: "summarize", sql: "SELECT * FROM events WHERE id = 1 UNION ALL SELECT * FROM (SUMMARIZE events)"},
+		{name: "query function", sql: "SELECT * FROM events WHERE id = 1 UNION ALL SELECT * FROM query('SELECT * FROM events')"},
+		{name: "two statements", sql: "SELECT * FROM events WHERE id = 1; SELECT 1"},
+		{name: "unterminated string", sql: "SELECT * FROM events WHERE region = 'eu"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			filters, ok := Analyze(tc.sql, now).Filters("events")
+			if ok != tc.wantOK {
+				t.Fatalf("ok = %v, filters = %+v", ok, filters)
+			}
+			if !ok {
+				return
+			}
+			if len(filters) != tc.wantFilter {
+				t.Fatalf("filters = %+v, want %d references", filters, tc.wantFilter)
+			}
+			if tc.wantEmpty && len(filters[0]) != 0 {
+				t.Fatalf("filters = %+v, want no predicates", filters)
+			}
+		})
+	}
+}
+
+func TestAnalyzeParsesLiterals(t *testing.T) {
+	now := time.Date(2026, 2, 19, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		literal string
+		want    Literal
+	}{
+		{literal: "-42.5", want: Literal{Kind: LiteralNumber, Text: "-42.5"}},
+		{literal: "'it''s'", want: Literal{Kind: LiteralString, Text: "it's"}},
+		{literal: "TRUE", want: Literal{Kind: LiteralBool, Bool: true}},
+		{literal: "TIMESTAMP '2026-02-19 10:00:00'", want: Literal{Kind: LiteralTime, Time: time.Date(2026, 2, 19, 10, 0, 0, 0, time.UTC)}},
+		{literal: "'2026-02-19T10:00:00+02:00'::TIMESTAMP", want: Literal{Kind: LiteralTime, Time: time.Date(2026, 2, 19, 8, 0, 0, 0, time.UTC), Zoned: true}},
+		{literal: "CAST('2026-02-19' AS DATE)", want: Literal{Kind: LiteralTime, Time: time.Date(2026, 2, 19, 0, 0, 0, 0, time.UTC)}},
+		{literal: "current_timestamp - INTERVAL '90 minutes'", want: Literal{Kind: LiteralTime, Time: now.Add(-90 * time.Minute), Zoned: true}},
+		{literal: "current_date", want: Literal{Kind: LiteralTime, Time: time.Date(2026, 2, 19, 0, 0, 0, 0, time.UTC), Zoned: true}},
+	}
+	for _, tc := range tests {
+		filters, ok := Analyze("SELECT * FROM t WHERE c = "+tc.literal, now).Filters("t")
+		if !ok || len(filters) != 1 || len(filters[0]) != 1 {
+			t.Fatalf("%s: filters = %+v, ok = %v", tc.literal, filters, ok)
+		}
+		got := filters[0][0].Values[0]
+		if got.Kind != tc.want.Kind || got.Text != tc.want.Text || got.Bool != tc.want.Bool || !got.Time.Equal(tc.want.Time) || got.Zoned != tc.want.Zoned {
+			t.Fatalf("%s: literal = %+v, want %+v", tc.literal, got, tc.want)
+		}
+	}
+
+	for _, expression := range []string{"c = other", "c = $1", "c = now() - INTERVAL 1 MONTH", "lower(c) = 'x'", "c::VARCHAR = 'x'"} {
+		filters, _ := Analyze("SELECT * FROM t WHERE "+expression, now).Filters("t")
+		if len(filters) != 1 || len(filters[0]) != 0 {
+			t.Fatalf("%s: filters = %+v, want none", expression, filters)
+		}
+	}
+}
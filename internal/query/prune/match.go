@@ -0,0 +1,264 @@
+package prune
+
+import (
+	"encoding/json"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/colstats"
+	"github.com/duckmesh/duckmesh/internal/tableschema"
+)
+
+// EventTimeColumn is compared with the event time range of a file.
+const EventTimeColumn = tableschema.ReservedPrefix + "event_time"
+
+// File is what the catalog knows about the values of one data file.
+type File struct {
+	MinEventTime *time.Time
+	MaxEventTime *time.Time
+	// Columns are the column statistics of the file, if it has any.
+	Columns map[string]colstats.Column
+	// Identities maps columns of identity partition fields to the value
+	// every row of the file has.
+	Identities map[string]string
+}
+
+// CanMatch reports whether some row of the file may satisfy every predicate.
+// Predicates on columns the file has no usable bounds for are assumed to
+// match.
+func (f File) CanMatch(predicates []Predicate) bool {
+	for _, predicate := range predicates {
+		if !f.canMatch(predicate) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f File) canMatch(predicate Predicate) bool {
+	if predicate.Column == EventTimeColumn {
+		if f.MinEventTime == nil || f.MaxEventTime == nil {
+			return true
+		}
+		return evaluate(predicate, domainTime, f.MinEventTime.UTC(), f.MaxEventTime.UTC(), false)
+	}
+	if column, ok := lookupColumn(f.Columns, predicate.Column); ok && !matchColumn(predicate, column) {
+		return false
+	}
+	if value, ok := lookupIdentity(f.Identities, predicate.Column); ok && !matchIdentity(predicate, value) {
+		return false
+	}
+	return true
+}
+
+func matchColumn(predicate Predicate, column colstats.Column) bool {
+	if column.Min == nil || column.Max == nil {
+		return true
+	}
+	switch column.Type {
+	case tableschema.TypeBigint, tableschema.TypeInteger, tableschema.TypeDouble:
+		low, okLow := ratBound(column.Min)
+		high, okHigh := ratBound(column.Max)
+		return !okLow || !okHigh || evaluate(predicate, domainNumber, low, high, false)
+	case tableschema.TypeVarchar:
+		low, okLow := column.Min.(string)
+		high, okHigh := column.Max.(string)
+		return !okLow || !okHigh || evaluate(predicate, domainString, low, high, false)
+	case tableschema.TypeBoolean:
+		low, okLow := column.Min.(bool)
+		high, okHigh := column.Max.(bool)
+		return !okLow || !okHigh || evaluate(predicate, domainBool, low, high, false)
+	case tableschema.TypeTimestamp, tableschema.TypeDate:
+		low, okLow := timeBound(column.Min)
+		high, okHigh := timeBound(column.Max)
+		return !okLow || !okHigh || evaluate(predicate, domainTime, low, high, column.Type == tableschema.TypeDate)
+	}
+	return true
+}
+
+// matchIdentity compares a partition value both as a string and, when it
+// parses, as a number, since the column type is not known here.
+func matchIdentity(predicate Predicate, value string) bool {
+	if evaluate(predicate, domainString, value, value, false) {
+		return true
+	}
+	if number, ok := new(big.Rat).SetString(value); ok && evaluate(predicate, domainNumber, number, number, false) {
+		return true
+	}
+	if flag, err := strconv.ParseBool(value); err == nil && evaluate(predicate, domainBool, flag, flag, false) {
+		return true
+	}
+	return false
+}
+
+type domain int
+
+const (
+	domainNumber domain = iota
+	domainString
+	domainBool
+	domainTime
+)
+
+// evaluate reports whether a column with values in [low, high] may satisfy
+// the predicate. Literals that do not convert to the domain match.
+func evaluate(predicate Predicate, d domain, low, high any, dateColumn bool) bool {
+	ranges := make([][2]any, 0, len(predicate.Values))
+	for _, value := range predicate.Values {
+		from, to, ok := convertLiteral(d, value, dateColumn)
+		if !ok {
+			return true
+		}
+		ranges = append(ranges, [2]any{from, to})
+	}
+	if len(ranges) == 0 {
+		return true
+	}
+
+	switch predicate.Op {
+	case OpEqual:
+		return overlaps(low, high, ranges[0])
+	case OpIn:
+		for _, r := range ranges {
+			if overlaps(low, high, r) {
+				return true
+			}
+		}
+		return false
+	case OpNotEqual:
+		r := ranges[0]
+		return !(compare(low, high) == 0 && compare(r[0], r[1]) == 0 && compare(low, r[0]) == 0)
+	case OpLess:
+		return compare(low, ranges[0][1]) < 0
+	case OpLessEqual:
+		return compare(low, ranges[0][1]) <= 0
+	case OpGreater:
+		return compare(high, ranges[0][0]) > 0
+	case OpGreaterEqual:
+		return compare(high, ranges[0][0]) >= 0
+	case OpBetween:
+		return len(ranges) == 2 && compare(high, ranges[0][0]) >= 0 && compare(low, ranges[1][1]) <= 0
+	}
+	return true
+}
+
+func overlaps(low, high any, r [2]any) bool {
+	return compare(high, r[0]) >= 0 && compare(low, r[1]) <= 0
+}
+
+// convertLiteral returns the range of values a literal may stand for in a
+// domain: a single value, or a window around zoned times.
+func convertLiteral(d domain, value Literal, dateColumn bool) (any, any, bool) {
+	switch d {
+	case domainNumber:
+		if value.Kind != LiteralNumber && value.Kind != LiteralString {
+			return nil, nil, false
+		}
+		number, ok := new(big.Rat).SetString(strings.TrimSpace(value.Text))
+		return number, number, ok
+	case domainString:
+		if value.Kind != LiteralString {
+			return nil, nil, false
+		}
+		return value.Text, value.Text, true
+	case domainBool:
+		if value.Kind != LiteralBool {
+			return nil, nil, false
+		}
+		return value.Bool, value.Bool, true
+	case domainTime:
+		at, zoned := value.Time, value.Zoned
+		switch value.Kind {
+		case LiteralTime:
+		case LiteralString:
+			parsed, parsedZoned, ok := parseTime(value.Text)
+			if !ok {
+				return nil, nil, false
+			}
+			// A string compared with a DATE column is cast to DATE.
+			if dateColumn {
+				parsed = parsed.Truncate(24 * time.Hour)
+			}
+			at, zoned = parsed, parsedZoned
+		default:
+			return nil, nil, false
+		}
+		if zoned {
+			return at.Add(-zonedSlack), at.Add(zonedSlack), true
+		}
+		return at, at, true
+	}
+	return nil, nil, false
+}
+
+func compare(a, b any) int {
+	switch x := a.(type) {
+	case *big.Rat:
+		return x.Cmp(b.(*big.Rat))
+	case string:
+		return strings.Compare(x, b.(string))
+	case bool:
+		y := b.(bool)
+		switch {
+		case x == y:
+			return 0
+		case !x:
+			return -1
+		}
+		return 1
+	case time.Time:
+		return x.Compare(b.(time.Time))
+	}
+	return 0
+}
+
+func ratBound(value any) (*big.Rat, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		return new(big.Rat).SetString(v.String())
+	case int64:
+		return new(big.Rat).SetInt64(v), true
+	case float64:
+		rat := new(big.Rat)
+		if rat.SetFloat64(v) == nil {
+			return nil, false
+		}
+		return rat, true
+	}
+	return nil, false
+}
+
+func timeBound(value any) (time.Time, bool) {
+	text, ok := value.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	if parsed, err := time.Parse(time.RFC3339Nano, text); err == nil {
+		return parsed, true
+	}
+	parsed, err := time.Parse(time.DateOnly, text)
+	return parsed, err == nil
+}
+
+func lookupColumn(columns map[string]colstats.Column, name string) (colstats.Column, bool) {
+	if column, ok := columns[name]; ok {
+		return column, true
+	}
+	for key, column := range columns {
+		if strings.EqualFold(key, name) {
+			return column, true
+		}
+	}
+	return colstats.Column{}, false
+}
+
+func lookupIdentity(identities map[string]string, name string) (string, bool) {
+	for key, value := range identities {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return "", false
+}
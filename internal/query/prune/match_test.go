@@ -0,0 +1,79 @@
+package prune
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/colstats"
+	"github.com/duckmesh/duckmesh/internal/tableschema"
+)
+
+func TestCanMatchUsesEventTimeRange(t *testing.T) {
+	now := time.Date(2026, 2, 19, 12, 0, 0, 0, time.UTC)
+	filters, _ := Analyze("SELECT count(*) FROM events WHERE __event_time >= TIMESTAMP '2026-02-19 11:00:00'", now).Filters("events")
+	recent := filters[0]
+
+	old := fileWithEventTimes(now.AddDate(-1, 0, 0), now.AddDate(-1, 0, 1))
+	if old.CanMatch(recent) {
+		t.Fatal("a file from last year should be pruned")
+	}
+	if !fileWithEventTimes(now.Add(-2*time.Hour), now.Add(-30*time.Minute)).CanMatch(recent) {
+		t.Fatal("a file spanning the last hour should match")
+	}
+	if !(File{}).CanMatch(recent) {
+		t.Fatal("a file without an event time range should match")
+	}
+
+	clock, _ := Analyze("SELECT count(*) FROM events WHERE __event_time >= now() - INTERVAL 1 HOUR", now).Filters("events")
+	if !fileWithEventTimes(now.Add(-10*time.Hour), now.Add(-9*time.Hour)).CanMatch(clock[0]) {
+		t.Fatal("clock-relative bounds should keep files within the time zone slack")
+	}
+	if old.CanMatch(clock[0]) {
+		t.Fatal("clock-relative bounds should still prune last year")
+	}
+}
+
+func TestCanMatchUsesColumnStatsAndIdentities(t *testing.T) {
+	file := File{
+		Columns: map[string]colstats.Column{
+			"id":     {Type: tableschema.TypeBigint, Min: json.Number("9007199254740993"), Max: json.Number("9007199254741000")},
+			"region": {Type: tableschema.TypeVarchar, Min: "eu", Max: "us"},
+			"day":    {Type: tableschema.TypeDate, Min: "2026-02-19", Max: "2026-02-19"},
+			"note":   {Type: tableschema.TypeVarchar, NullCount: 3},
+		},
+		Identities: map[string]string{"tenant": "acme"},
+	}
+	now := time.Now()
+	tests := []struct {
+		where string
+		want  bool
+	}{
+		{where: "id > 9007199254740992", want: true},
+		{where: "id < 9007199254740993", want: false},
+		{where: "id IN (1, 2, 9007199254740999)", want: true},
+		{where: "id BETWEEN 1 AND 100", want: false},
+		{where: "region = 'ap'", want: false},
+		{where: "region >= 'eu' AND region < 'eu'", want: false},
+		{where: "day >= '2026-02-19 10:00:00'", want: true},
+		{where: "day > DATE '2026-02-19'", want: false},
+		{where: "note = 'x'", want: true},
+		{where: "tenant = 'globex'", want: false},
+		{where: "tenant != 'globex'", want: true},
+		{where: "id = 'not a number'", want: true},
+		{where: "unknown = 1", want: true},
+	}
+	for _, tc := range tests {
+		filters, ok := Analyze("SELECT * FROM t WHERE "+tc.where, now).Filters("t")
+		if !ok || len(filters) != 1 || len(filters[0]) == 0 {
+			t.Fatalf("%s: filters = %+v", tc.where, filters)
+		}
+		if got := file.CanMatch(filters[0]); got != tc.want {
+			t.Fatalf("%s: CanMatch() = %v, want %v", tc.where, got, tc.want)
+		}
+	}
+}
+
+func fileWithEventTimes(minTime, maxTime time.Time) File {
+	return File{MinEventTime: &minTime, MaxEventTime: &maxTime}
+}
@@ -0,0 +1,164 @@
+package prune
+
+import (
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenSymbol
+	tokenParam
+)
+
+type token struct {
+	kind tokenKind
+	// text is lower-cased for identifiers and unescaped for strings.
+	text  string
+	depth int
+}
+
+func (t token) is(kind tokenKind, text string) bool {
+	return t.kind == kind && t.text == text
+}
+
+func (t token) keyword(text string) bool {
+	return t.is(tokenIdent, text)
+}
+
+func (t token) symbol(text string) bool {
+	return t.is(tokenSymbol, text)
+}
+
+func (t token) name() bool {
+	return t.kind == tokenIdent || t.kind == tokenQuotedIdent
+}
+
+var twoCharSymbols = map[string]bool{
+	"::": true, "<=": true, ">=": true, "<>": true, "!=": true, "==": true, "||": true, "->": true,
+}
+
+// tokenize splits SQL into tokens and records the parenthesis depth of each.
+// It reports false for unterminated strings, identifiers or comments and for
+// unbalanced parentheses.
+func tokenize(sqlText string) ([]token, bool) {
+	tokens := make([]token, 0)
+	depth := 0
+	for i := 0; i < len(sqlText); {
+		c := sqlText[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case c == '-' && strings.HasPrefix(sqlText[i:], "--"):
+			end := strings.IndexByte(sqlText[i:], '\n')
+			if end < 0 {
+				i = len(sqlText)
+			} else {
+				i += end + 1
+			}
+		case c == '/' && strings.HasPrefix(sqlText[i:], "/*"):
+			end := strings.Index(sqlText[i+2:], "*/")
+			if end < 0 {
+				return nil, false
+			}
+			i += end + 4
+		case c == '\'' || c == '"':
+			text, next, ok := readQuoted(sqlText, i)
+			if !ok {
+				return nil, false
+			}
+			kind := tokenString
+			if c == '"' {
+				kind = tokenQuotedIdent
+				text = strings.ToLower(text)
+			}
+			tokens = append(tokens, token{kind: kind, text: text, depth: depth})
+			i = next
+		case isDigit(c) || c == '.' && i+1 < len(sqlText) && isDigit(sqlText[i+1]):
+			start := i
+			for i < len(sqlText) && (isDigit(sqlText[i]) || sqlText[i] == '.' || sqlText[i] == '_') {
+				i++
+			}
+			if i < len(sqlText) && (sqlText[i] == 'e' || sqlText[i] == 'E') {
+				j := i + 1
+				if j < len(sqlText) && (sqlText[j] == '+' || sqlText[j] == '-') {
+					j++
+				}
+				if j < len(sqlText) && isDigit(sqlText[j]) {
+					i = j
+					for i < len(sqlText) && isDigit(sqlText[i]) {
+						i++
+					}
+				}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: strings.ReplaceAll(sqlText[start:i], "_", ""), depth: depth})
+		case isIdentStart(c):
+			start := i
+			for i < len(sqlText) && isIdentPart(sqlText[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: strings.ToLower(sqlText[start:i]), depth: depth})
+		case c == '$' || c == '?':
+			start := i
+			i++
+			for i < len(sqlText) && isIdentPart(sqlText[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenParam, text: sqlText[start:i], depth: depth})
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenSymbol, text: "(", depth: depth})
+			depth++
+			i++
+		case c == ')':
+			depth--
+			if depth < 0 {
+				return nil, false
+			}
+			tokens = append(tokens, token{kind: tokenSymbol, text: ")", depth: depth})
+			i++
+		default:
+			if i+1 < len(sqlText) && twoCharSymbols[sqlText[i:i+2]] {
+				tokens = append(tokens, token{kind: tokenSymbol, text: sqlText[i : i+2], depth: depth})
+				i += 2
+				continue
+			}
+			tokens = append(tokens, token{kind: tokenSymbol, text: string(c), depth: depth})
+			i++
+		}
+	}
+	return tokens, depth == 0
+}
+
+func readQuoted(sqlText string, start int) (string, int, bool) {
+	quote := sqlText[start]
+	var text strings.Builder
+	for i := start + 1; i < len(sqlText); i++ {
+		if sqlText[i] != quote {
+			text.WriteByte(sqlText[i])
+			continue
+		}
+		if i+1 < len(sqlText) && sqlText[i+1] == quote {
+			text.WriteByte(quote)
+			i++
+			continue
+		}
+		return text.String(), i + 1, true
+	}
+	return "", 0, false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
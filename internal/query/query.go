@@ -2,9 +2,13 @@ package query
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrInvalidSQL is wrapped by engines when a statement does not parse.
+var ErrInvalidSQL = errors.New("invalid sql")
+
 type TableFile struct {
 	TableName     string
 	ObjectPath    string
@@ -15,8 +19,27 @@ type Request struct {
 	SQL      string
 	RowLimit int
 	Files    []TableFile
+	// PrimaryKeys maps table names to their primary key columns. Keyed tables
+	// are exposed with merge-on-read semantics: the latest event per key wins
+	// and deleted keys are hidden.
+	PrimaryKeys map[string][]string
+	// Params are bound to the placeholders of SQL through a prepared
+	// statement. Named and positional params are not mixed.
+	Params []Param
+}
+
+// Param is bound to the $name placeholder when Name is set and to the next
+// positional placeholder ($1 or ?) otherwise. Values are nil, bool, int64,
+// *big.Int, float64, string, Decimal, time.Time, or []any lists of those.
+type Param struct {
+	Name  string
+	Value any
 }
 
+// Decimal is an exact decimal number. It is bound as text, so statements
+// compare it with a DECIMAL column or cast it, as in $amount::DECIMAL(18,2).
+type Decimal string
+
 type Result struct {
 	Columns      []string
 	Rows         [][]any
@@ -25,6 +48,104 @@ type Result struct {
 	Duration     time.Duration
 }
 
+// Column describes a result column. Type is the DuckDB type name, for
+// example BIGINT, DECIMAL(18,2) or VARCHAR[].
+type Column struct {
+	Name string
+	Type string
+}
+
+// Rows iterates over a result without buffering it. Values returns a new
+// slice per row, normalized like Result.Rows. Rows must be closed.
+type Rows interface {
+	Columns() []Column
+	Next() bool
+	Values() []any
+	Err() error
+	Close() error
+}
+
+// Stream is a result delivered row by row.
+type Stream struct {
+	Rows         Rows
+	ScannedFiles int
+	ScannedBytes int64
+}
+
+// Collect reads and closes the rows of a stream.
+func Collect(stream Stream) (Result, error) {
+	columns := stream.Rows.Columns()
+	result := Result{
+		Columns:      make([]string, len(columns)),
+		Rows:         make([][]any, 0),
+		ScannedFiles: stream.ScannedFiles,
+		ScannedBytes: stream.ScannedBytes,
+	}
+	for i, column := range columns {
+		result.Columns[i] = column.Name
+	}
+	for stream.Rows.Next() {
+		result.Rows = append(result.Rows, stream.Rows.Values())
+	}
+	if err := stream.Rows.Err(); err != nil {
+		_ = stream.Rows.Close()
+		return Result{}, err
+	}
+	return result, stream.Rows.Close()
+}
+
+// ExportFormat is a file format a result can be exported as.
+type ExportFormat string
+
+const (
+	ExportParquet ExportFormat = "parquet"
+	ExportCSV     ExportFormat = "csv"
+)
+
+type ExportResult struct {
+	Rows         int64
+	Bytes        int64
+	ScannedFiles int
+	ScannedBytes int64
+	Duration     time.Duration
+}
+
+// Exporter writes the full result of a statement to an object instead of
+// returning it.
+type Exporter interface {
+	Export(ctx context.Context, request Request, format ExportFormat, objectPath string) (ExportResult, error)
+}
+
+// Statement is what parsing reports about SQL without running it.
+type Statement struct {
+	// Statements is the number of statements in the text.
+	Statements int
+	// ReadOnly is false when some statement is not a query, for example
+	// COPY, ATTACH, SET or INSTALL.
+	ReadOnly bool
+	// Tables are the tables read by name. References to CTEs are left out.
+	Tables []TableName
+	// TableFunctions and Functions are the lowercase names of the table
+	// functions and the scalar, aggregate and window functions called.
+	TableFunctions []string
+	Functions      []string
+	// All is set when the statement may read tables it does not name, for
+	// example through query_table().
+	All bool
+}
+
+type TableName struct {
+	Catalog string
+	Schema  string
+	Name    string
+}
+
 type Engine interface {
 	Execute(ctx context.Context, request Request) (Result, error)
+	// Stream runs a statement like Execute but hands out rows as they are
+	// produced, so large results are never held in memory.
+	Stream(ctx context.Context, request Request) (Stream, error)
+	// Inspect parses a statement without running it, so it can be checked
+	// against the query policy and only the tables it reads are fetched.
+	Inspect(ctx context.Context, sqlText string) (Statement, error)
 }
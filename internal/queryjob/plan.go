@@ -0,0 +1,170 @@
+package queryjob
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/query"
+)
+
+// plan is the stored form of a query.Request. Param values carry their
+// type, so they bind the same way when the job runs on another replica.
+type plan struct {
+	SQL         string              `json:"sql"`
+	RowLimit    int                 `json:"row_limit,omitempty"`
+	Files       []planFile          `json:"files"`
+	PrimaryKeys map[string][]string `json:"primary_keys,omitempty"`
+	Params      []planParam         `json:"params,omitempty"`
+}
+
+type planFile struct {
+	TableName     string `json:"table_name"`
+	ObjectPath    string `json:"object_path"`
+	FileSizeBytes int64  `json:"file_size_bytes"`
+}
+
+type planParam struct {
+	Name  string    `json:"name,omitempty"`
+	Value planValue `json:"value"`
+}
+
+type planValue struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value,omitempty"`
+	Items []planValue     `json:"items,omitempty"`
+}
+
+func encodePlan(request query.Request) ([]byte, error) {
+	stored := plan{
+		SQL:         request.SQL,
+		RowLimit:    request.RowLimit,
+		Files:       make([]planFile, 0, len(request.Files)),
+		PrimaryKeys: request.PrimaryKeys,
+	}
+	for _, file := range request.Files {
+		stored.Files = append(stored.Files, planFile(file))
+	}
+	for _, param := range request.Params {
+		value, err := encodeValue(param.Value)
+		if err != nil {
+			return nil, fmt.Errorf("encode param %q: %w", param.Name, err)
+		}
+		stored.Params = append(stored.Params, planParam{Name: param.Name, Value: value})
+	}
+	return json.Marshal(stored)
+}
+
+func decodePlan(data []byte) (query.Request, error) {
+	var stored plan
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return query.Request{}, fmt.Errorf("decode query job plan: %w", err)
+	}
+	request := query.Request{
+		SQL:         stored.SQL,
+		RowLimit:    stored.RowLimit,
+		Files:       make([]query.TableFile, 0, len(stored.Files)),
+		PrimaryKeys: stored.PrimaryKeys,
+	}
+	for _, file := range stored.Files {
+		request.Files = append(request.Files, query.TableFile(file))
+	}
+	for _, param := range stored.Params {
+		value, err := decodeValue(param.Value)
+		if err != nil {
+			return query.Request{}, fmt.Errorf("decode param %q: %w", param.Name, err)
+		}
+		request.Params = append(request.Params, query.Param{Name: param.Name, Value: value})
+	}
+	return request, nil
+}
+
+func encodeValue(value any) (planValue, error) {
+	var typeName string
+	var raw any
+	switch typed := value.(type) {
+	case nil:
+		return planValue{Type: "null"}, nil
+	case bool:
+		typeName, raw = "bool", typed
+	case int64:
+		typeName, raw = "int64", typed
+	case *big.Int:
+		typeName, raw = "bigint", typed.String()
+	case float64:
+		typeName, raw = "float64", typed
+	case string:
+		typeName, raw = "string", typed
+	case query.Decimal:
+		typeName, raw = "decimal", string(typed)
+	case time.Time:
+		typeName, raw = "time", typed.Format(time.RFC3339Nano)
+	case []any:
+		items := make([]planValue, len(typed))
+		for i, item := range typed {
+			encoded, err := encodeValue(item)
+			if err != nil {
+				return planValue{}, err
+			}
+			items[i] = encoded
+		}
+		return planValue{Type: "list", Items: items}, nil
+	default:
+		return planValue{}, fmt.Errorf("unsupported value type %T", value)
+	}
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return planValue{}, err
+	}
+	return planValue{Type: typeName, Value: encoded}, nil
+}
+
+func decodeValue(value planValue) (any, error) {
+	switch value.Type {
+	case "null":
+		return nil, nil
+	case "list":
+		items := make([]any, len(value.Items))
+		for i, item := range value.Items {
+			decoded, err := decodeValue(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = decoded
+		}
+		return items, nil
+	case "bool":
+		var decoded bool
+		err := json.Unmarshal(value.Value, &decoded)
+		return decoded, err
+	case "int64":
+		var decoded int64
+		err := json.Unmarshal(value.Value, &decoded)
+		return decoded, err
+	case "float64":
+		var decoded float64
+		err := json.Unmarshal(value.Value, &decoded)
+		return decoded, err
+	}
+
+	var text string
+	if err := json.Unmarshal(value.Value, &text); err != nil {
+		return nil, err
+	}
+	switch value.Type {
+	case "string":
+		return text, nil
+	case "decimal":
+		return query.Decimal(text), nil
+	case "bigint":
+		decoded, ok := new(big.Int).SetString(text, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %q", text)
+		}
+		return decoded, nil
+	case "time":
+		return time.Parse(time.RFC3339Nano, text)
+	}
+	return nil, fmt.Errorf("unknown value type %q", value.Type)
+}
@@ -0,0 +1,303 @@
+package queryjob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/catalog"
+	"github.com/duckmesh/duckmesh/internal/query"
+	"github.com/duckmesh/duckmesh/internal/storage"
+)
+
+type Catalog interface {
+	CreateQueryJob(ctx context.Context, in catalog.CreateQueryJobInput) (catalog.QueryJob, error)
+	GetQueryJob(ctx context.Context, tenantID string, jobID int64) (catalog.QueryJob, error)
+	ClaimQueryJob(ctx context.Context, owner string, leaseUntil time.Time) (catalog.QueryJob, error)
+	RenewQueryJob(ctx context.Context, jobID int64, owner string, leaseUntil time.Time) (bool, error)
+	FinishQueryJob(ctx context.Context, in catalog.FinishQueryJobInput) error
+	CancelQueryJob(ctx context.Context, tenantID string, jobID int64) (catalog.QueryJob, error)
+	DeleteQueryJob(ctx context.Context, tenantID string, jobID int64) error
+}
+
+type Config struct {
+	// Owner identifies this replica in job leases.
+	Owner        string
+	Workers      int
+	PollInterval time.Duration
+	Lease        time.Duration
+	Timeout      time.Duration
+}
+
+// Service runs query jobs in the background and exports their results to
+// the object store. Jobs live in the catalog, so any replica can accept,
+// run and serve them.
+type Service struct {
+	Catalog     Catalog
+	Exporter    query.Exporter
+	ObjectStore storage.ObjectStore
+	Config      Config
+	Logger      *slog.Logger
+	Clock       func() time.Time
+
+	wakeOnce sync.Once
+	wake     chan struct{}
+}
+
+type SubmitRequest struct {
+	TenantID           string
+	Format             query.ExportFormat
+	Request            query.Request
+	SnapshotID         int64
+	MaxVisibilityToken int64
+}
+
+// Submit queues a planned query. Its files are already resolved, so the job
+// reads the snapshot it was submitted against.
+func (s *Service) Submit(ctx context.Context, request SubmitRequest) (catalog.QueryJob, error) {
+	if request.Format != query.ExportParquet && request.Format != query.ExportCSV {
+		return catalog.QueryJob{}, fmt.Errorf("unsupported export format %q", request.Format)
+	}
+	planJSON, err := encodePlan(request.Request)
+	if err != nil {
+		return catalog.QueryJob{}, err
+	}
+	job, err := s.Catalog.CreateQueryJob(ctx, catalog.CreateQueryJobInput{
+		TenantID:           request.TenantID,
+		SQL:                request.Request.SQL,
+		Format:             string(request.Format),
+		PlanJSON:           planJSON,
+		SnapshotID:         request.SnapshotID,
+		MaxVisibilityToken: request.MaxVisibilityToken,
+	})
+	if err != nil {
+		return catalog.QueryJob{}, err
+	}
+	select {
+	case s.wakeups() <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+func (s *Service) Get(ctx context.Context, tenantID string, jobID int64) (catalog.QueryJob, error) {
+	return s.Catalog.GetQueryJob(ctx, tenantID, jobID)
+}
+
+// Cancel stops a queued or running job. It returns catalog.ErrConflict when
+// the job already finished.
+func (s *Service) Cancel(ctx context.Context, tenantID string, jobID int64) (catalog.QueryJob, error) {
+	return s.Catalog.CancelQueryJob(ctx, tenantID, jobID)
+}
+
+// Delete removes a finished job and its result object.
+func (s *Service) Delete(ctx context.Context, tenantID string, jobID int64) error {
+	job, err := s.Catalog.GetQueryJob(ctx, tenantID, jobID)
+	if err != nil {
+		return err
+	}
+	if !job.State.Finished() {
+		return catalog.ErrConflict
+	}
+	if job.ResultPath != "" {
+		if err := s.ObjectStore.Delete(ctx, job.ResultPath); err != nil && !errors.Is(err, storage.ErrObjectNotFound) {
+			return fmt.Errorf("delete query job result: %w", err)
+		}
+	}
+	return s.Catalog.DeleteQueryJob(ctx, tenantID, jobID)
+}
+
+// OpenResult returns a reader for the result of a succeeded job. It returns
+// catalog.ErrConflict when the job has no result.
+func (s *Service) OpenResult(ctx context.Context, tenantID string, jobID int64) (catalog.QueryJob, io.ReadCloser, error) {
+	job, err := s.Catalog.GetQueryJob(ctx, tenantID, jobID)
+	if err != nil {
+		return catalog.QueryJob{}, nil, err
+	}
+	if job.State != catalog.QueryJobSucceeded || job.ResultPath == "" {
+		return job, nil, catalog.ErrConflict
+	}
+	reader, err := s.ObjectStore.Get(ctx, job.ResultPath)
+	if err != nil {
+		return job, nil, fmt.Errorf("get query job result: %w", err)
+	}
+	return job, reader, nil
+}
+
+// Run starts Config.Workers workers that claim and run queued jobs until ctx
+// is done.
+func (s *Service) Run(ctx context.Context) error {
+	s.ensureDefaults()
+	if s.Catalog == nil || s.Exporter == nil {
+		return fmt.Errorf("catalog and exporter are required")
+	}
+
+	var wg sync.WaitGroup
+	for range s.Config.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(ctx)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func (s *Service) work(ctx context.Context) {
+	for {
+		ran, err := s.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil && s.Logger != nil {
+			s.Logger.ErrorContext(ctx, "query job cycle failed", slog.Any("error", err))
+		}
+		if ran && err == nil {
+			continue
+		}
+		timer := time.NewTimer(s.Config.PollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wakeups():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// RunOnce claims one queued job and runs it to completion. It reports false
+// when no job was queued.
+func (s *Service) RunOnce(ctx context.Context) (bool, error) {
+	s.ensureDefaults()
+	job, err := s.Catalog.ClaimQueryJob(ctx, s.Config.Owner, s.Clock().Add(s.Config.Lease))
+	if errors.Is(err, catalog.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("claim query job: %w", err)
+	}
+	return true, s.runJob(ctx, job)
+}
+
+func (s *Service) runJob(ctx context.Context, job catalog.QueryJob) error {
+	jobCtx, cancel := context.WithTimeout(ctx, s.Config.Timeout)
+	defer cancel()
+
+	// The lease is renewed while the export runs. A cancellation request or
+	// a lost lease stops the export through jobCtx.
+	var cancelled, lost bool
+	renewDone := make(chan struct{})
+	stopRenew := make(chan struct{})
+	go func() {
+		defer close(renewDone)
+		ticker := time.NewTicker(s.Config.Lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopRenew:
+				return
+			case <-jobCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			cancelRequested, err := s.Catalog.RenewQueryJob(jobCtx, job.JobID, s.Config.Owner, s.Clock().Add(s.Config.Lease))
+			lost = errors.Is(err, catalog.ErrNotFound)
+			cancelled = err == nil && cancelRequested
+			if lost || cancelled {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	finish := catalog.FinishQueryJobInput{JobID: job.JobID, Owner: s.Config.Owner}
+	result, resultPath, err := s.export(jobCtx, job)
+	close(stopRenew)
+	<-renewDone
+
+	switch {
+	case lost:
+		return fmt.Errorf("query job %d lease was lost", job.JobID)
+	case err == nil:
+		finish.State = catalog.QueryJobSucceeded
+		finish.ResultPath = resultPath
+		finish.ResultRows = result.Rows
+		finish.ResultBytes = result.Bytes
+		finish.ScannedBytes = result.ScannedBytes
+	case cancelled:
+		finish.State = catalog.QueryJobCancelled
+	case errors.Is(jobCtx.Err(), context.DeadlineExceeded):
+		finish.State = catalog.QueryJobFailed
+		finish.Error = fmt.Sprintf("query job timed out after %s", s.Config.Timeout)
+	case ctx.Err() != nil:
+		finish.State = catalog.QueryJobFailed
+		finish.Error = "query job was interrupted by shutdown"
+	default:
+		finish.State = catalog.QueryJobFailed
+		finish.Error = err.Error()
+	}
+
+	finishCtx, cancelFinish := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancelFinish()
+	if err := s.Catalog.FinishQueryJob(finishCtx, finish); err != nil {
+		return fmt.Errorf("finish query job %d: %w", job.JobID, err)
+	}
+	if s.Logger != nil {
+		s.Logger.InfoContext(ctx, "query job finished",
+			slog.Int64("job_id", job.JobID),
+			slog.String("tenant_id", job.TenantID),
+			slog.String("state", string(finish.State)),
+			slog.Int64("rows", finish.ResultRows),
+			slog.Duration("duration", result.Duration),
+		)
+	}
+	return nil
+}
+
+func (s *Service) export(ctx context.Context, job catalog.QueryJob) (query.ExportResult, string, error) {
+	request, err := decodePlan(job.PlanJSON)
+	if err != nil {
+		return query.ExportResult{}, "", err
+	}
+	resultPath, err := storage.BuildQueryJobResultPath(job.TenantID, job.JobID, job.Format)
+	if err != nil {
+		return query.ExportResult{}, "", err
+	}
+	result, err := s.Exporter.Export(ctx, request, query.ExportFormat(job.Format), resultPath)
+	if err != nil {
+		return query.ExportResult{}, "", err
+	}
+	return result, resultPath, nil
+}
+
+func (s *Service) wakeups() chan struct{} {
+	s.wakeOnce.Do(func() { s.wake = make(chan struct{}, 1) })
+	return s.wake
+}
+
+func (s *Service) ensureDefaults() {
+	if s.Clock == nil {
+		s.Clock = time.Now
+	}
+	if s.Config.Owner == "" {
+		hostname, _ := os.Hostname()
+		s.Config.Owner = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	if s.Config.Workers <= 0 {
+		s.Config.Workers = 1
+	}
+	if s.Config.PollInterval <= 0 {
+		s.Config.PollInterval = time.Second
+	}
+	if s.Config.Lease <= 0 {
+		s.Config.Lease = 30 * time.Second
+	}
+	if s.Config.Timeout <= 0 {
+		s.Config.Timeout = 30 * time.Minute
+	}
+}
@@ -0,0 +1,162 @@
+package queryjob
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/catalog"
+	"github.com/duckmesh/duckmesh/internal/query"
+)
+
+func TestPlanRoundTripKeepsParamTypes(t *testing.T) {
+	at := time.Date(2026, 2, 19, 10, 30, 0, 123456789, time.UTC)
+	request := query.Request{
+		SQL:         "SELECT * FROM orders WHERE id > $1 AND region IN $2",
+		RowLimit:    10,
+		Files:       []query.TableFile{{TableName: "orders", ObjectPath: "tenant-1/orders/part-1-00000.parquet", FileSizeBytes: 42}},
+		PrimaryKeys: map[string][]string{"orders": {"id"}},
+		Params: []query.Param{
+			{Value: int64(7)},
+			{Value: []any{"eu", nil, true}},
+			{Name: "amount", Value: query.Decimal("12.50")},
+			{Name: "big", Value: new(big.Int).Lsh(big.NewInt(1), 70)},
+			{Name: "at", Value: at},
+			{Name: "ratio", Value: 0.25},
+		},
+	}
+
+	encoded, err := encodePlan(request)
+	if err != nil {
+		t.Fatalf("encodePlan() error = %v", err)
+	}
+	decoded, err := decodePlan(encoded)
+	if err != nil {
+		t.Fatalf("decodePlan() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded, request) {
+		t.Fatalf("decoded plan = %+v, want %+v", decoded, request)
+	}
+}
+
+func TestRunOnceExportsResultAndFinishesJob(t *testing.T) {
+	planJSON, err := encodePlan(query.Request{SQL: "SELECT 1"})
+	if err != nil {
+		t.Fatalf("encodePlan() error = %v", err)
+	}
+	repo := &fakeCatalog{queued: []catalog.QueryJob{{JobID: 9, TenantID: "tenant-1", Format: "csv", PlanJSON: planJSON}}}
+	exporter := &fakeExporter{result: query.ExportResult{Rows: 3, Bytes: 30, ScannedBytes: 100}}
+	service := &Service{Catalog: repo, Exporter: exporter, Config: Config{Owner: "api-1"}}
+
+	ran, err := service.RunOnce(context.Background())
+	if err != nil || !ran {
+		t.Fatalf("RunOnce() = %v, %v", ran, err)
+	}
+	if exporter.path != "tenant-1/_query_jobs/9/result.csv" || exporter.format != query.ExportCSV || exporter.request.SQL != "SELECT 1" {
+		t.Fatalf("export = %q %q %+v", exporter.path, exporter.format, exporter.request)
+	}
+	want := catalog.FinishQueryJobInput{
+		JobID:        9,
+		Owner:        "api-1",
+		State:        catalog.QueryJobSucceeded,
+		ResultPath:   "tenant-1/_query_jobs/9/result.csv",
+		ResultRows:   3,
+		ResultBytes:  30,
+		ScannedBytes: 100,
+	}
+	if len(repo.finished) != 1 || repo.finished[0] != want {
+		t.Fatalf("finished = %+v", repo.finished)
+	}
+
+	if ran, err := service.RunOnce(context.Background()); err != nil || ran {
+		t.Fatalf("RunOnce() on an empty queue = %v, %v", ran, err)
+	}
+}
+
+func TestRunOnceStopsCancelledJob(t *testing.T) {
+	planJSON, err := encodePlan(query.Request{SQL: "SELECT 1"})
+	if err != nil {
+		t.Fatalf("encodePlan() error = %v", err)
+	}
+	repo := &fakeCatalog{
+		queued:          []catalog.QueryJob{{JobID: 9, TenantID: "tenant-1", Format: "parquet", PlanJSON: planJSON}},
+		cancelRequested: true,
+	}
+	exporter := &fakeExporter{block: true}
+	service := &Service{Catalog: repo, Exporter: exporter, Config: Config{Owner: "api-1", Lease: 30 * time.Millisecond}}
+
+	if _, err := service.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	if len(repo.finished) != 1 || repo.finished[0].State != catalog.QueryJobCancelled || repo.finished[0].ResultPath != "" {
+		t.Fatalf("finished = %+v", repo.finished)
+	}
+}
+
+type fakeCatalog struct {
+	mu              sync.Mutex
+	queued          []catalog.QueryJob
+	cancelRequested bool
+	finished        []catalog.FinishQueryJobInput
+}
+
+func (f *fakeCatalog) CreateQueryJob(context.Context, catalog.CreateQueryJobInput) (catalog.QueryJob, error) {
+	return catalog.QueryJob{}, nil
+}
+
+func (f *fakeCatalog) GetQueryJob(context.Context, string, int64) (catalog.QueryJob, error) {
+	return catalog.QueryJob{}, catalog.ErrNotFound
+}
+
+func (f *fakeCatalog) ClaimQueryJob(context.Context, string, time.Time) (catalog.QueryJob, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.queued) == 0 {
+		return catalog.QueryJob{}, catalog.ErrNotFound
+	}
+	job := f.queued[0]
+	f.queued = f.queued[1:]
+	job.State = catalog.QueryJobRunning
+	return job, nil
+}
+
+func (f *fakeCatalog) RenewQueryJob(context.Context, int64, string, time.Time) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.cancelRequested, nil
+}
+
+func (f *fakeCatalog) FinishQueryJob(_ context.Context, in catalog.FinishQueryJobInput) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.finished = append(f.finished, in)
+	return nil
+}
+
+func (f *fakeCatalog) CancelQueryJob(context.Context, string, int64) (catalog.QueryJob, error) {
+	return catalog.QueryJob{}, nil
+}
+
+func (f *fakeCatalog) DeleteQueryJob(context.Context, string, int64) error {
+	return nil
+}
+
+type fakeExporter struct {
+	block   bool
+	result  query.ExportResult
+	request query.Request
+	format  query.ExportFormat
+	path    string
+}
+
+func (f *fakeExporter) Export(ctx context.Context, request query.Request, format query.ExportFormat, objectPath string) (query.ExportResult, error) {
+	f.request, f.format, f.path = request, format, objectPath
+	if f.block {
+		<-ctx.Done()
+		return query.ExportResult{}, ctx.Err()
+	}
+	return f.result, nil
+}
@@ -0,0 +1,268 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/storage"
+)
+
+// Cache keeps local copies of objects in a directory. Copies are named by a
+// hash of the object key and ETag, so a rewritten object is never served
+// from a stale copy. The least recently used copies are removed once the
+// directory grows past its size limit. Copies survive restarts.
+//
+// The directory must not be shared between processes.
+type Cache struct {
+	store    storage.ObjectStore
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// order holds *entry values, most recently used first.
+	order   *list.List
+	size    int64
+	loading map[string]*download
+}
+
+type entry struct {
+	name string
+	size int64
+	// refs counts callers using the copy; it is not evicted meanwhile.
+	refs int
+}
+
+type download struct {
+	done chan struct{}
+	err  error
+}
+
+// New opens the cache in dir, indexing copies left by a previous process.
+func New(store storage.ObjectStore, dir string, maxBytes int64) (*Cache, error) {
+	if store == nil {
+		return nil, fmt.Errorf("object store is required")
+	}
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("cache size must be positive")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create cache dir: %w", err)
+	}
+	c := &Cache{
+		store:    store,
+		dir:      dir,
+		maxBytes: maxBytes,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+		loading:  map[string]*download{},
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Link makes the object available at dst, by hard link when dst is on the
+// same file system as the cache and by copy otherwise. Only the first of
+// concurrent callers for an object downloads it.
+func (c *Cache) Link(ctx context.Context, key, dst string) error {
+	info, err := c.store.Stat(ctx, key)
+	if err != nil {
+		return fmt.Errorf("stat object %q: %w", key, err)
+	}
+	name := entryName(key, info)
+	if err := c.acquire(ctx, key, name); err != nil {
+		return err
+	}
+	defer c.release(name)
+
+	src := filepath.Join(c.dir, name)
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	return copyFile(src, dst)
+}
+
+// Size returns the bytes held by cached copies.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+func (c *Cache) acquire(ctx context.Context, key, name string) error {
+	for {
+		c.mu.Lock()
+		if element, ok := c.entries[name]; ok {
+			element.Value.(*entry).refs++
+			c.order.MoveToFront(element)
+			c.mu.Unlock()
+			cacheHitsTotal.Inc()
+			now := time.Now()
+			_ = os.Chtimes(filepath.Join(c.dir, name), now, now)
+			return nil
+		}
+		if pending, ok := c.loading[name]; ok {
+			c.mu.Unlock()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-pending.done:
+			}
+			// A download abandoned by its caller is retried by the waiters.
+			if pending.err != nil && !errors.Is(pending.err, context.Canceled) && !errors.Is(pending.err, context.DeadlineExceeded) {
+				return pending.err
+			}
+			continue
+		}
+		pending := &download{done: make(chan struct{})}
+		c.loading[name] = pending
+		c.mu.Unlock()
+
+		cacheMissesTotal.Inc()
+		size, err := c.fetch(ctx, key, name)
+
+		c.mu.Lock()
+		delete(c.loading, name)
+		if err == nil {
+			c.entries[name] = c.order.PushFront(&entry{name: name, size: size, refs: 1})
+			c.size += size
+			c.evictLocked()
+		}
+		pending.err = err
+		close(pending.done)
+		c.mu.Unlock()
+		return err
+	}
+}
+
+func (c *Cache) release(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[name]; ok {
+		element.Value.(*entry).refs--
+	}
+	c.evictLocked()
+}
+
+func (c *Cache) fetch(ctx context.Context, key, name string) (int64, error) {
+	reader, err := c.store.Get(ctx, key)
+	if err != nil {
+		return 0, fmt.Errorf("get object %q: %w", key, err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	file, err := os.CreateTemp(c.dir, name+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("create cache file: %w", err)
+	}
+	size, err := io.Copy(file, reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), filepath.Join(c.dir, name))
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return 0, fmt.Errorf("write cache file for %q: %w", key, err)
+	}
+	return size, nil
+}
+
+// evictLocked removes least recently used copies that are not in use until
+// the cache fits in maxBytes.
+func (c *Cache) evictLocked() {
+	for element := c.order.Back(); element != nil && c.size > c.maxBytes; {
+		previous := element.Prev()
+		cached := element.Value.(*entry)
+		if cached.refs == 0 {
+			_ = os.Remove(filepath.Join(c.dir, cached.name))
+			c.order.Remove(element)
+			delete(c.entries, cached.name)
+			c.size -= cached.size
+			cacheEvictionsTotal.Inc()
+		}
+		element = previous
+	}
+	cacheBytes.Set(float64(c.size))
+}
+
+// load indexes the copies in the cache dir by modification time, which Link
+// refreshes on every hit, and removes unfinished downloads.
+func (c *Cache) load() error {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("read cache dir: %w", err)
+	}
+	type cachedFile struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+	files := make([]cachedFile, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(dirEntry.Name(), ".tmp") {
+			_ = os.Remove(filepath.Join(c.dir, dirEntry.Name()))
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cachedFile{name: dirEntry.Name(), size: info.Size(), modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, file := range files {
+		c.entries[file.name] = c.order.PushFront(&entry{name: file.name, size: file.size})
+		c.size += file.size
+	}
+	c.evictLocked()
+	return nil
+}
+
+// entryName hashes the object key with its ETag. Stores that report no ETag
+// fall back to size and modification time.
+func entryName(key string, info storage.ObjectInfo) string {
+	version := info.ETag
+	if version == "" {
+		version = fmt.Sprintf("%d-%d", info.Size, info.LastModified.UnixNano())
+	}
+	sum := sha256.Sum256([]byte(key + "\x00" + version))
+	return hex.EncodeToString(sum[:])
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
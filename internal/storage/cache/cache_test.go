@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/duckmesh/duckmesh/internal/storage"
+	"github.com/duckmesh/duckmesh/internal/storage/memory"
+)
+
+func TestLinkDownloadsOnceAndServesHits(t *testing.T) {
+	store := newCountingStore(t, map[string]string{"tenant-1/orders/a.parquet": "aaaa"})
+	c, err := New(store, t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	work := t.TempDir()
+	for i, name := range []string{"first.parquet", "second.parquet"} {
+		dst := filepath.Join(work, name)
+		if err := c.Link(context.Background(), "tenant-1/orders/a.parquet", dst); err != nil {
+			t.Fatalf("Link() #%d error = %v", i, err)
+		}
+		assertFile(t, dst, "aaaa")
+	}
+	if got := store.gets.Load(); got != 1 {
+		t.Fatalf("downloads = %d, want 1", got)
+	}
+
+	// Removing a query's work dir must leave the cached copy intact.
+	if err := os.RemoveAll(work); err != nil {
+		t.Fatalf("RemoveAll() error = %v", err)
+	}
+	dst := filepath.Join(t.TempDir(), "third.parquet")
+	if err := c.Link(context.Background(), "tenant-1/orders/a.parquet", dst); err != nil {
+		t.Fatalf("Link() error = %v", err)
+	}
+	assertFile(t, dst, "aaaa")
+
+	// A rewritten object gets a new ETag and is downloaded again.
+	store.put(t, "tenant-1/orders/a.parquet", "bbbb")
+	dst = filepath.Join(t.TempDir(), "fourth.parquet")
+	if err := c.Link(context.Background(), "tenant-1/orders/a.parquet", dst); err != nil {
+		t.Fatalf("Link() error = %v", err)
+	}
+	assertFile(t, dst, "bbbb")
+	if got := store.gets.Load(); got != 2 {
+		t.Fatalf("downloads = %d, want 2", got)
+	}
+}
+
+func TestLinkDeduplicatesConcurrentDownloads(t *testing.T) {
+	store := newCountingStore(t, map[string]string{"k": "payload"})
+	store.release = make(chan struct{})
+	c, err := New(store, t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	work := t.TempDir()
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = c.Link(context.Background(), "k", filepath.Join(work, strings.Repeat("x", i+1)))
+		}(i)
+	}
+	close(store.release)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("Link() #%d error = %v", i, err)
+		}
+		assertFile(t, filepath.Join(work, strings.Repeat("x", i+1)), "payload")
+	}
+	if got := store.gets.Load(); got != 1 {
+		t.Fatalf("downloads = %d, want 1", got)
+	}
+}
+
+func TestLinkEvictsLeastRecentlyUsed(t *testing.T) {
+	store := newCountingStore(t, map[string]string{"a": "1111", "b": "2222", "c": "3333"})
+	dir := t.TempDir()
+	c, err := New(store, dir, 8)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	work := t.TempDir()
+	link := func(key, name string) {
+		t.Helper()
+		if err := c.Link(context.Background(), key, filepath.Join(work, name)); err != nil {
+			t.Fatalf("Link(%q) error = %v", key, err)
+		}
+	}
+
+	link("a", "a1")
+	link("b", "b1")
+	link("a", "a2")
+	link("c", "c1")
+	if c.Size() != 8 {
+		t.Fatalf("Size() = %d, want 8", c.Size())
+	}
+	link("a", "a3")
+	if got := store.gets.Load(); got != 3 {
+		t.Fatalf("downloads = %d, want 3", got)
+	}
+	link("b", "b2")
+	if got := store.gets.Load(); got != 4 {
+		t.Fatalf("downloads after evicted key = %d, want 4", got)
+	}
+
+	// A new cache over the same dir keeps the files that were not evicted.
+	reopened, err := New(store, dir, 8)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if reopened.Size() != 8 {
+		t.Fatalf("reopened Size() = %d, want 8", reopened.Size())
+	}
+	if err := reopened.Link(context.Background(), "b", filepath.Join(work, "b3")); err != nil {
+		t.Fatalf("Link() error = %v", err)
+	}
+	if got := store.gets.Load(); got != 4 {
+		t.Fatalf("downloads after reopen = %d, want 4", got)
+	}
+}
+
+func TestNewRemovesUnfinishedDownloads(t *testing.T) {
+	dir := t.TempDir()
+	partial := filepath.Join(dir, "abc.123.tmp")
+	if err := os.WriteFile(partial, []byte("partial"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	c, err := New(memory.New(), dir, 1<<20)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if c.Size() != 0 {
+		t.Fatalf("Size() = %d, want 0", c.Size())
+	}
+	if _, err := os.Stat(partial); !os.IsNotExist(err) {
+		t.Fatalf("partial download still exists: %v", err)
+	}
+}
+
+type countingStore struct {
+	*memory.Store
+	gets    atomic.Int64
+	release chan struct{}
+}
+
+func newCountingStore(t *testing.T, objects map[string]string) *countingStore {
+	t.Helper()
+	store := &countingStore{Store: memory.New()}
+	for key, body := range objects {
+		store.put(t, key, body)
+	}
+	return store
+}
+
+func (s *countingStore) put(t *testing.T, key, body string) {
+	t.Helper()
+	if _, err := s.Put(context.Background(), key, strings.NewReader(body), int64(len(body)), storage.PutOptions{}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+}
+
+func (s *countingStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.gets.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+	return s.Store.Get(ctx, key)
+}
+
+func assertFile(t *testing.T, path, want string) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile(%q) error = %v", path, err)
+	}
+	if string(got) != want {
+		t.Fatalf("%s = %q, want %q", path, got, want)
+	}
+}
@@ -0,0 +1,34 @@
+package cache
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	cacheHitsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "duckmesh_object_cache_hits_total",
+			Help: "Total number of object reads served from the local cache.",
+		},
+	)
+	cacheMissesTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "duckmesh_object_cache_misses_total",
+			Help: "Total number of objects downloaded into the local cache.",
+		},
+	)
+	cacheEvictionsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "duckmesh_object_cache_evictions_total",
+			Help: "Total number of cached objects evicted to stay within the size limit.",
+		},
+	)
+	cacheBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "duckmesh_object_cache_bytes",
+			Help: "Current bytes held by the local object cache.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(cacheHitsTotal, cacheMissesTotal, cacheEvictionsTotal, cacheBytes)
+}
@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duckmesh/duckmesh/internal/storage"
+)
+
+// Store keeps objects in process memory. It applies the same key rules as
+// the S3 store so paths that work here also work against a bucket.
+type Store struct {
+	mu      sync.RWMutex
+	clock   func() time.Time
+	objects map[string]object
+}
+
+type object struct {
+	data []byte
+	info storage.ObjectInfo
+}
+
+func New() *Store {
+	return &Store{clock: time.Now, objects: map[string]object{}}
+}
+
+func (s *Store) Put(_ context.Context, key string, body io.Reader, size int64, _ storage.PutOptions) (storage.ObjectInfo, error) {
+	normalized, err := normalizeKey(key)
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return storage.ObjectInfo{}, fmt.Errorf("put object %q: %w", normalized, err)
+	}
+	if size >= 0 && int64(len(data)) != size {
+		return storage.ObjectInfo{}, fmt.Errorf("put object %q: read %d bytes, expected %d", normalized, len(data), size)
+	}
+
+	sum := md5.Sum(data)
+	info := storage.ObjectInfo{
+		Key:          normalized,
+		Size:         int64(len(data)),
+		ETag:         hex.EncodeToString(sum[:]),
+		LastModified: s.clock().UTC(),
+	}
+	s.mu.Lock()
+	s.objects[normalized] = object{data: data, info: info}
+	s.mu.Unlock()
+	return info, nil
+}
+
+func (s *Store) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	normalized, err := normalizeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	stored, ok := s.objects[normalized]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, storage.ErrObjectNotFound
+	}
+	return io.NopCloser(bytes.NewReader(stored.data)), nil
+}
+
+func (s *Store) Stat(_ context.Context, key string) (storage.ObjectInfo, error) {
+	normalized, err := normalizeKey(key)
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	s.mu.RLock()
+	stored, ok := s.objects[normalized]
+	s.mu.RUnlock()
+	if !ok {
+		return storage.ObjectInfo{}, storage.ErrObjectNotFound
+	}
+	return stored.info, nil
+}
+
+func (s *Store) Delete(_ context.Context, key string) error {
+	normalized, err := normalizeKey(key)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.objects, normalized)
+	s.mu.Unlock()
+	return nil
+}
+
+// Keys lists stored object keys in lexical order.
+func (s *Store) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func normalizeKey(key string) (string, error) {
+	key = strings.TrimSpace(strings.TrimPrefix(key, "/"))
+	if key == "" {
+		return "", fmt.Errorf("object key is required")
+	}
+	cleaned := path.Clean(key)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") || strings.Contains(cleaned, "/../") {
+		return "", fmt.Errorf("invalid object key: %q", key)
+	}
+	return cleaned, nil
+}
+
+var _ storage.ObjectStore = (*Store)(nil)
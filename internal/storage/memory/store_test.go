@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/duckmesh/duckmesh/internal/storage"
+)
+
+func TestStorePutGetStatDelete(t *testing.T) {
+	ctx := context.Background()
+	store := New()
+
+	info, err := store.Put(ctx, "/tenant-1/table-7/data.parquet", strings.NewReader("PAR1"), 4, storage.PutOptions{})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if info.Key != "tenant-1/table-7/data.parquet" || info.Size != 4 || info.ETag == "" {
+		t.Fatalf("info = %+v", info)
+	}
+
+	reader, err := store.Get(ctx, "tenant-1/table-7/data.parquet")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	body, _ := io.ReadAll(reader)
+	if string(body) != "PAR1" {
+		t.Fatalf("body = %q", body)
+	}
+	if stat, err := store.Stat(ctx, "tenant-1/table-7/data.parquet"); err != nil || stat.Size != 4 {
+		t.Fatalf("Stat() = %+v, %v", stat, err)
+	}
+	if keys := store.Keys(); len(keys) != 1 {
+		t.Fatalf("keys = %v", keys)
+	}
+
+	if err := store.Delete(ctx, "tenant-1/table-7/data.parquet"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := store.Get(ctx, "tenant-1/table-7/data.parquet"); !errors.Is(err, storage.ErrObjectNotFound) {
+		t.Fatalf("Get() after delete error = %v", err)
+	}
+	if _, err := store.Stat(ctx, "missing"); !errors.Is(err, storage.ErrObjectNotFound) {
+		t.Fatalf("Stat() missing error = %v", err)
+	}
+}
+
+func TestStoreRejectsInvalidKeysAndShortBodies(t *testing.T) {
+	ctx := context.Background()
+	store := New()
+	for _, key := range []string{"", "../escape", "a/../../b"} {
+		if _, err := store.Put(ctx, key, strings.NewReader("x"), 1, storage.PutOptions{}); err == nil {
+			t.Fatalf("Put(%q) error = nil", key)
+		}
+	}
+	if _, err := store.Put(ctx, "a", strings.NewReader("x"), 5, storage.PutOptions{}); err == nil {
+		t.Fatal("Put() with short body error = nil")
+	}
+}
@@ -4,12 +4,18 @@ import (
 	"fmt"
 	"path"
 	"regexp"
-	"time"
+	"strings"
 )
 
 var pathComponentPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]{0,127}$`)
 
-func BuildDataFilePath(tenantID, tableName string, eventTime time.Time, snapshotID int64, sequence int) (string, error) {
+// PartitionSegment is one name=value directory of a data file path.
+type PartitionSegment struct {
+	Name  string
+	Value string
+}
+
+func BuildDataFilePath(tenantID, tableName string, partition []PartitionSegment, snapshotID int64, sequence int) (string, error) {
 	if err := validatePathComponent(tenantID, "tenant id"); err != nil {
 		return "", err
 	}
@@ -20,17 +26,36 @@ func BuildDataFilePath(tenantID, tableName string, eventTime time.Time, snapshot
 		return "", fmt.Errorf("sequence must be >= 0")
 	}
 
-	ts := eventTime.UTC()
+	elements := []string{tenantID, tableName}
+	for _, segment := range partition {
+		if err := validatePathComponent(segment.Name, "partition name"); err != nil {
+			return "", err
+		}
+		elements = append(elements, segment.Name+"="+escapePartitionValue(segment.Value))
+	}
+	elements = append(elements, fmt.Sprintf("part-%d-%05d.parquet", snapshotID, sequence))
+	return path.Join(elements...), nil
+}
+
+func BuildDeleteFilePath(tenantID, tableName string, snapshotID int64, sequence int) (string, error) {
+	if err := validatePathComponent(tenantID, "tenant id"); err != nil {
+		return "", err
+	}
+	if err := validatePathComponent(tableName, "table name"); err != nil {
+		return "", err
+	}
+	if sequence < 0 {
+		return "", fmt.Errorf("sequence must be >= 0")
+	}
 	return path.Join(
 		tenantID,
 		tableName,
-		fmt.Sprintf("date=%04d-%02d-%02d", ts.Year(), ts.Month(), ts.Day()),
-		fmt.Sprintf("hour=%02d", ts.Hour()),
-		fmt.Sprintf("part-%d-%05d.parquet", snapshotID, sequence),
+		"deletes",
+		fmt.Sprintf("delete-%d-%05d.parquet", snapshotID, sequence),
 	), nil
 }
 
-func BuildDeleteFilePath(tenantID, tableName string, snapshotID int64, sequence int) (string, error) {
+func BuildRejectFilePath(tenantID, tableName string, snapshotID int64, sequence int) (string, error) {
 	if err := validatePathComponent(tenantID, "tenant id"); err != nil {
 		return "", err
 	}
@@ -43,11 +68,63 @@ func BuildDeleteFilePath(tenantID, tableName string, snapshotID int64, sequence
 	return path.Join(
 		tenantID,
 		tableName,
-		"deletes",
-		fmt.Sprintf("delete-%d-%05d.parquet", snapshotID, sequence),
+		"rejects",
+		fmt.Sprintf("reject-%d-%05d.ndjson", snapshotID, sequence),
+	), nil
+}
+
+func BuildBulkLoadFilePath(tenantID, tableName string, snapshotID int64, sequence int) (string, error) {
+	if err := validatePathComponent(tenantID, "tenant id"); err != nil {
+		return "", err
+	}
+	if err := validatePathComponent(tableName, "table name"); err != nil {
+		return "", err
+	}
+	if sequence < 0 {
+		return "", fmt.Errorf("sequence must be >= 0")
+	}
+	return path.Join(
+		tenantID,
+		tableName,
+		"bulk",
+		fmt.Sprintf("bulk-%d-%05d.parquet", snapshotID, sequence),
 	), nil
 }
 
+// BuildQueryJobResultPath returns where the result of an async query is
+// exported. The leading underscore keeps it apart from table directories.
+func BuildQueryJobResultPath(tenantID string, jobID int64, extension string) (string, error) {
+	if err := validatePathComponent(tenantID, "tenant id"); err != nil {
+		return "", err
+	}
+	if err := validatePathComponent(extension, "extension"); err != nil {
+		return "", err
+	}
+	if jobID <= 0 {
+		return "", fmt.Errorf("job id must be > 0")
+	}
+	return path.Join(tenantID, "_query_jobs", fmt.Sprintf("%d", jobID), "result."+extension), nil
+}
+
+// escapePartitionValue percent-encodes every byte outside [A-Za-z0-9._-] so
+// any value is a single safe path element.
+func escapePartitionValue(value string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c == '.' && (value == "." || value == "..") {
+			fmt.Fprintf(&escaped, "%%%02X", c)
+			continue
+		}
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-' {
+			escaped.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&escaped, "%%%02X", c)
+	}
+	return escaped.String()
+}
+
 func validatePathComponent(value, field string) error {
 	if !pathComponentPattern.MatchString(value) {
 		return fmt.Errorf("invalid %s: %q", field, value)
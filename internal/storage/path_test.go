@@ -1,20 +1,25 @@
 package storage
 
-import (
-	"testing"
-	"time"
-)
+import "testing"
 
 func TestBuildDataFilePath(t *testing.T) {
-	ts := time.Date(2026, time.February, 19, 4, 5, 0, 0, time.FixedZone("x", -5*3600))
-	key, err := BuildDataFilePath("tenant-1", "events", ts, 55, 3)
+	key, err := BuildDataFilePath("tenant-1", "events", []PartitionSegment{{Name: "date", Value: "2026-02-19"}, {Name: "region", Value: "eu west/1"}}, 55, 3)
 	if err != nil {
 		t.Fatalf("BuildDataFilePath() error = %v", err)
 	}
-	want := "tenant-1/events/date=2026-02-19/hour=09/part-55-00003.parquet"
+	want := "tenant-1/events/date=2026-02-19/region=eu%20west%2F1/part-55-00003.parquet"
 	if key != want {
 		t.Fatalf("BuildDataFilePath() = %q, want %q", key, want)
 	}
+
+	key, err = BuildDataFilePath("tenant-1", "events", []PartitionSegment{{Name: "kind", Value: ".."}}, 55, 3)
+	if err != nil || key != "tenant-1/events/kind=%2E%2E/part-55-00003.parquet" {
+		t.Fatalf("BuildDataFilePath(..) = %q, %v", key, err)
+	}
+	key, err = BuildDataFilePath("tenant-1", "events", nil, 55, 3)
+	if err != nil || key != "tenant-1/events/part-55-00003.parquet" {
+		t.Fatalf("unpartitioned BuildDataFilePath() = %q, %v", key, err)
+	}
 }
 
 func TestBuildDeleteFilePath(t *testing.T) {
@@ -28,9 +33,48 @@ func TestBuildDeleteFilePath(t *testing.T) {
 	}
 }
 
+func TestBuildRejectFilePath(t *testing.T) {
+	key, err := BuildRejectFilePath("tenant-1", "events", 55, 4)
+	if err != nil {
+		t.Fatalf("BuildRejectFilePath() error = %v", err)
+	}
+	want := "tenant-1/events/rejects/reject-55-00004.ndjson"
+	if key != want {
+		t.Fatalf("BuildRejectFilePath() = %q, want %q", key, want)
+	}
+}
+
+func TestBuildBulkLoadFilePath(t *testing.T) {
+	key, err := BuildBulkLoadFilePath("tenant-1", "events", 55, 0)
+	if err != nil {
+		t.Fatalf("BuildBulkLoadFilePath() error = %v", err)
+	}
+	want := "tenant-1/events/bulk/bulk-55-00000.parquet"
+	if key != want {
+		t.Fatalf("BuildBulkLoadFilePath() = %q, want %q", key, want)
+	}
+}
+
+func TestBuildQueryJobResultPath(t *testing.T) {
+	key, err := BuildQueryJobResultPath("tenant-1", 42, "csv")
+	if err != nil {
+		t.Fatalf("BuildQueryJobResultPath() error = %v", err)
+	}
+	want := "tenant-1/_query_jobs/42/result.csv"
+	if key != want {
+		t.Fatalf("BuildQueryJobResultPath() = %q, want %q", key, want)
+	}
+	if _, err := BuildQueryJobResultPath("tenant-1", 0, "csv"); err == nil {
+		t.Fatal("expected invalid job id error")
+	}
+}
+
 func TestBuildPathRejectsInvalidComponent(t *testing.T) {
-	_, err := BuildDataFilePath("../oops", "events", time.Now(), 1, 1)
+	_, err := BuildDataFilePath("../oops", "events", nil, 1, 1)
 	if err == nil {
 		t.Fatal("expected invalid component error")
 	}
+	if _, err := BuildDataFilePath("tenant-1", "events", []PartitionSegment{{Name: "../x", Value: "1"}}, 1, 1); err == nil {
+		t.Fatal("expected invalid partition name error")
+	}
 }
@@ -0,0 +1,397 @@
+package tableschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Type string
+
+const (
+	TypeBigint    Type = "bigint"
+	TypeInteger   Type = "integer"
+	TypeDouble    Type = "double"
+	TypeBoolean   Type = "boolean"
+	TypeVarchar   Type = "varchar"
+	TypeTimestamp Type = "timestamp"
+	TypeDate      Type = "date"
+	TypeJSON      Type = "json"
+)
+
+// ReservedPrefix marks column names owned by DuckMesh (event metadata and
+// merge-on-read bookkeeping). Declared fields may not use it.
+const ReservedPrefix = "__"
+
+var typeAliases = map[string]Type{
+	"bigint":    TypeBigint,
+	"int8":      TypeBigint,
+	"int64":     TypeBigint,
+	"long":      TypeBigint,
+	"integer":   TypeInteger,
+	"int":       TypeInteger,
+	"int4":      TypeInteger,
+	"int32":     TypeInteger,
+	"double":    TypeDouble,
+	"float":     TypeDouble,
+	"float8":    TypeDouble,
+	"float64":   TypeDouble,
+	"real":      TypeDouble,
+	"boolean":   TypeBoolean,
+	"bool":      TypeBoolean,
+	"varchar":   TypeVarchar,
+	"string":    TypeVarchar,
+	"text":      TypeVarchar,
+	"timestamp": TypeTimestamp,
+	"datetime":  TypeTimestamp,
+	"date":      TypeDate,
+	"json":      TypeJSON,
+}
+
+// Unknown field policies stored with a schema version. With UnknownFieldsAllow
+// undeclared payload fields are accepted and dropped from typed data files.
+const (
+	UnknownFieldsAllow  = "allow"
+	UnknownFieldsReject = "reject"
+)
+
+type Field struct {
+	Name     string `json:"name"`
+	Type     Type   `json:"type"`
+	Required bool   `json:"required,omitempty"`
+}
+
+type Schema struct {
+	Fields []Field
+}
+
+type fieldSpec struct {
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+}
+
+// Parse reads a table_schema_version.schema_json document. Each key is a field
+// name mapped either to a type name ("bigint") or to {"type": ..., "required": ...}.
+// An empty, null or {} document yields an empty schema.
+func Parse(raw []byte) (Schema, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return Schema{}, nil
+	}
+
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &document); err != nil {
+		return Schema{}, fmt.Errorf("schema_json must be a JSON object: %w", err)
+	}
+
+	fields := make([]Field, 0, len(document))
+	for name, rawSpec := range document {
+		field, err := parseField(name, rawSpec)
+		if err != nil {
+			return Schema{}, err
+		}
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	return Schema{Fields: fields}, nil
+}
+
+func parseField(name string, rawSpec json.RawMessage) (Field, error) {
+	if strings.TrimSpace(name) == "" {
+		return Field{}, fmt.Errorf("schema field name is required")
+	}
+	if strings.HasPrefix(name, ReservedPrefix) {
+		return Field{}, fmt.Errorf("schema field %q uses reserved prefix %q", name, ReservedPrefix)
+	}
+
+	var spec fieldSpec
+	var typeName string
+	if err := json.Unmarshal(rawSpec, &typeName); err == nil {
+		spec.Type = typeName
+	} else if err := json.Unmarshal(rawSpec, &spec); err != nil {
+		return Field{}, fmt.Errorf("schema field %q must be a type name or an object with a type", name)
+	}
+
+	fieldType, err := ParseType(spec.Type)
+	if err != nil {
+		return Field{}, fmt.Errorf("schema field %q: %w", name, err)
+	}
+	return Field{Name: name, Type: fieldType, Required: spec.Required}, nil
+}
+
+func ParseType(value string) (Type, error) {
+	fieldType, ok := typeAliases[strings.ToLower(strings.TrimSpace(value))]
+	if !ok {
+		return "", fmt.Errorf("unsupported type %q", value)
+	}
+	return fieldType, nil
+}
+
+func ParseUnknownFieldPolicy(value string) (string, error) {
+	switch policy := strings.ToLower(strings.TrimSpace(value)); policy {
+	case "":
+		return UnknownFieldsAllow, nil
+	case UnknownFieldsAllow, UnknownFieldsReject:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown_fields must be %q or %q", UnknownFieldsAllow, UnknownFieldsReject)
+}
+
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Validate checks a decoded payload against the declared fields and returns
+// one violation per offending field, declared fields first. Required fields
+// are only enforced when checkRequired is set; deletes carry keys only.
+func (s Schema) Validate(payload map[string]any, checkRequired, rejectUnknown bool) []Violation {
+	var violations []Violation
+	for _, field := range s.Fields {
+		value, present := payload[field.Name]
+		if value == nil {
+			if checkRequired && field.Required {
+				message := "required field is missing"
+				if present {
+					message = "required field is null"
+				}
+				violations = append(violations, Violation{Field: field.Name, Message: message})
+			}
+			continue
+		}
+		if _, err := field.Coerce(value); err != nil {
+			violations = append(violations, Violation{Field: field.Name, Message: err.Error()})
+		}
+	}
+	if !rejectUnknown {
+		return violations
+	}
+
+	unknown := make([]string, 0)
+	for name := range payload {
+		if _, ok := s.Field(name); !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		violations = append(violations, Violation{Field: name, Message: "field is not declared in the table schema"})
+	}
+	return violations
+}
+
+func (s Schema) IsEmpty() bool {
+	return len(s.Fields) == 0
+}
+
+func (s Schema) Field(name string) (Field, bool) {
+	for _, field := range s.Fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+// Project returns the declared fields named in names, in schema order.
+func (s Schema) Project(names []string) Schema {
+	wanted := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		wanted[name] = struct{}{}
+	}
+	projected := Schema{}
+	for _, field := range s.Fields {
+		if _, ok := wanted[field.Name]; ok {
+			projected.Fields = append(projected.Fields, field)
+		}
+	}
+	return projected
+}
+
+// Coerce converts a value decoded from a JSON payload (numbers as json.Number)
+// into the Go representation of the field type: int64, int32, float64, bool,
+// string or time.Time. A nil value is returned unchanged.
+func (f Field) Coerce(value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch f.Type {
+	case TypeBigint:
+		return coerceInt(value, math.MinInt64, math.MaxInt64)
+	case TypeInteger:
+		parsed, err := coerceInt(value, math.MinInt32, math.MaxInt32)
+		if err != nil {
+			return nil, err
+		}
+		return int32(parsed), nil
+	case TypeDouble:
+		switch v := value.(type) {
+		case json.Number:
+			parsed, err := v.Float64()
+			if err != nil {
+				return nil, fmt.Errorf("expected double, got %q", v.String())
+			}
+			return parsed, nil
+		case float64:
+			return v, nil
+		}
+		return nil, fmt.Errorf("expected double, got %s", describe(value))
+	case TypeBoolean:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("expected boolean, got %s", describe(value))
+	case TypeVarchar:
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("expected string, got %s", describe(value))
+	case TypeTimestamp:
+		switch v := value.(type) {
+		case string:
+			return parseTimestamp(v)
+		case json.Number:
+			millis, err := v.Int64()
+			if err != nil {
+				return nil, fmt.Errorf("expected timestamp as epoch milliseconds, got %q", v.String())
+			}
+			return time.UnixMilli(millis).UTC(), nil
+		}
+		return nil, fmt.Errorf("expected timestamp, got %s", describe(value))
+	case TypeDate:
+		if v, ok := value.(string); ok {
+			return parseDate(v)
+		}
+		return nil, fmt.Errorf("expected date, got %s", describe(value))
+	case TypeJSON:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("encode json value: %w", err)
+		}
+		return string(encoded), nil
+	}
+	return nil, fmt.Errorf("unsupported type %q", f.Type)
+}
+
+// ParseText converts a text value (for example a CSV cell) into the JSON
+// payload value for the field type, so that Coerce accepts it later.
+func (f Field) ParseText(raw string) (any, error) {
+	trimmed := strings.TrimSpace(raw)
+	switch f.Type {
+	case TypeBigint, TypeInteger, TypeDouble:
+		value, err := decodeJSONText(trimmed)
+		number, ok := value.(json.Number)
+		if err != nil || !ok {
+			return nil, fmt.Errorf("expected %s, got %q", f.Type, raw)
+		}
+		if _, err := f.Coerce(number); err != nil {
+			return nil, err
+		}
+		return number, nil
+	case TypeBoolean:
+		parsed, err := strconv.ParseBool(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("expected boolean, got %q", raw)
+		}
+		return parsed, nil
+	case TypeVarchar:
+		return raw, nil
+	case TypeTimestamp:
+		parsed, err := parseTimestamp(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		return parsed.Format(time.RFC3339Nano), nil
+	case TypeDate:
+		parsed, err := parseDate(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		return parsed.Format("2006-01-02"), nil
+	case TypeJSON:
+		value, err := decodeJSONText(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("expected JSON value, got %q", raw)
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("unsupported type %q", f.Type)
+}
+
+func decodeJSONText(raw string) (any, error) {
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("trailing data after JSON value")
+	}
+	return value, nil
+}
+
+func coerceInt(value any, minValue, maxValue int64) (int64, error) {
+	var parsed int64
+	switch v := value.(type) {
+	case json.Number:
+		if asInt, err := v.Int64(); err == nil {
+			parsed = asInt
+			break
+		}
+		asFloat, err := v.Float64()
+		if err != nil || asFloat != math.Trunc(asFloat) || math.Abs(asFloat) > 1<<53 {
+			return 0, fmt.Errorf("expected integer, got %q", v.String())
+		}
+		parsed = int64(asFloat)
+	case float64:
+		if v != math.Trunc(v) || math.Abs(v) > 1<<53 {
+			return 0, fmt.Errorf("expected integer, got %v", v)
+		}
+		parsed = int64(v)
+	default:
+		return 0, fmt.Errorf("expected integer, got %s", describe(value))
+	}
+	if parsed < minValue || parsed > maxValue {
+		return 0, fmt.Errorf("integer %d is out of range", parsed)
+	}
+	return parsed, nil
+}
+
+func parseTimestamp(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05.999999999", "2006-01-02"} {
+		if parsed, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+			return parsed.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected RFC3339 timestamp, got %q", value)
+}
+
+func parseDate(value string) (time.Time, error) {
+	parsed, err := time.Parse("2006-01-02", strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected YYYY-MM-DD date, got %q", value)
+	}
+	return parsed, nil
+}
+
+func describe(value any) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number, float64:
+		return "number"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,187 @@
+package tableschema
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseFlatAndObjectFields(t *testing.T) {
+	schema, err := Parse([]byte(`{"value":"double","id":{"type":"BIGINT","required":true},"name":"string"}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(schema.Fields) != 3 {
+		t.Fatalf("len(Fields) = %d", len(schema.Fields))
+	}
+	if schema.Fields[0].Name != "id" || schema.Fields[0].Type != TypeBigint || !schema.Fields[0].Required {
+		t.Fatalf("Fields[0] = %+v", schema.Fields[0])
+	}
+	if field, ok := schema.Field("name"); !ok || field.Type != TypeVarchar {
+		t.Fatalf("Field(name) = %+v, %v", field, ok)
+	}
+}
+
+func TestParseEmptyDocuments(t *testing.T) {
+	for _, raw := range []string{"", "null", "{}"} {
+		schema, err := Parse([]byte(raw))
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", raw, err)
+		}
+		if !schema.IsEmpty() {
+			t.Fatalf("Parse(%q) should be empty", raw)
+		}
+	}
+}
+
+func TestParseRejectsInvalidFields(t *testing.T) {
+	cases := []string{
+		`{"id":"uuidv9"}`,
+		`{"__op":"varchar"}`,
+		`{"id":42}`,
+		`["id"]`,
+	}
+	for _, raw := range cases {
+		if _, err := Parse([]byte(raw)); err == nil {
+			t.Fatalf("Parse(%s) expected error", raw)
+		}
+	}
+}
+
+func TestFieldCoerce(t *testing.T) {
+	cases := []struct {
+		field Field
+		input any
+		want  any
+	}{
+		{Field{Type: TypeBigint}, json.Number("42"), int64(42)},
+		{Field{Type: TypeBigint}, json.Number("42.0"), int64(42)},
+		{Field{Type: TypeInteger}, json.Number("7"), int32(7)},
+		{Field{Type: TypeDouble}, json.Number("1.5"), 1.5},
+		{Field{Type: TypeBoolean}, true, true},
+		{Field{Type: TypeVarchar}, "eu", "eu"},
+		{Field{Type: TypeTimestamp}, "2026-02-19T10:00:00Z", time.Date(2026, time.February, 19, 10, 0, 0, 0, time.UTC)},
+		{Field{Type: TypeDate}, "2026-02-19", time.Date(2026, time.February, 19, 0, 0, 0, 0, time.UTC)},
+		{Field{Type: TypeJSON}, map[string]any{"a": json.Number("1")}, `{"a":1}`},
+		{Field{Type: TypeBigint}, nil, nil},
+	}
+	for _, tc := range cases {
+		got, err := tc.field.Coerce(tc.input)
+		if err != nil {
+			t.Fatalf("Coerce(%v as %s) error = %v", tc.input, tc.field.Type, err)
+		}
+		if gotTime, ok := got.(time.Time); ok {
+			if !gotTime.Equal(tc.want.(time.Time)) {
+				t.Fatalf("Coerce(%v as %s) = %v, want %v", tc.input, tc.field.Type, got, tc.want)
+			}
+			continue
+		}
+		if got != tc.want {
+			t.Fatalf("Coerce(%v as %s) = %#v, want %#v", tc.input, tc.field.Type, got, tc.want)
+		}
+	}
+}
+
+func TestFieldCoerceRejectsMismatchedTypes(t *testing.T) {
+	cases := []struct {
+		field Field
+		input any
+	}{
+		{Field{Type: TypeBigint}, "42"},
+		{Field{Type: TypeBigint}, json.Number("1.5")},
+		{Field{Type: TypeInteger}, json.Number("3000000000")},
+		{Field{Type: TypeDouble}, "1.5"},
+		{Field{Type: TypeBoolean}, "true"},
+		{Field{Type: TypeVarchar}, json.Number("1")},
+		{Field{Type: TypeTimestamp}, "yesterday"},
+		{Field{Type: TypeDate}, "19/02/2026"},
+	}
+	for _, tc := range cases {
+		if _, err := tc.field.Coerce(tc.input); err == nil {
+			t.Fatalf("Coerce(%v as %s) expected error", tc.input, tc.field.Type)
+		}
+	}
+}
+
+func TestFieldParseText(t *testing.T) {
+	cases := []struct {
+		field Field
+		raw   string
+		want  any
+	}{
+		{Field{Type: TypeBigint}, " 42 ", json.Number("42")},
+		{Field{Type: TypeDouble}, "1.5", json.Number("1.5")},
+		{Field{Type: TypeBoolean}, "true", true},
+		{Field{Type: TypeVarchar}, " keep spaces ", " keep spaces "},
+		{Field{Type: TypeTimestamp}, "2026-01-02 03:04:05", "2026-01-02T03:04:05Z"},
+		{Field{Type: TypeDate}, "2026-01-02", "2026-01-02"},
+		{Field{Type: TypeJSON}, `"text"`, "text"},
+	}
+	for _, tc := range cases {
+		got, err := tc.field.ParseText(tc.raw)
+		if err != nil {
+			t.Fatalf("ParseText(%s, %q) error = %v", tc.field.Type, tc.raw, err)
+		}
+		if got != tc.want {
+			t.Fatalf("ParseText(%s, %q) = %#v, want %#v", tc.field.Type, tc.raw, got, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		field Field
+		raw   string
+	}{
+		{Field{Type: TypeBigint}, "1.5"},
+		{Field{Type: TypeInteger}, "NaN"},
+		{Field{Type: TypeBoolean}, "yes"},
+		{Field{Type: TypeJSON}, `{"a":`},
+	} {
+		if _, err := tc.field.ParseText(tc.raw); err == nil {
+			t.Fatalf("ParseText(%s, %q) expected error", tc.field.Type, tc.raw)
+		}
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := Parse([]byte(`{"id":{"type":"bigint","required":true},"name":"varchar","seen_at":"timestamp"}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if violations := schema.Validate(map[string]any{"id": 1.0, "name": "a", "extra": true}, true, false); len(violations) != 0 {
+		t.Fatalf("violations = %+v", violations)
+	}
+
+	violations := schema.Validate(map[string]any{"name": 5.0, "seen_at": "yesterday", "extra": true, "another": nil}, true, true)
+	want := []Violation{
+		{Field: "id", Message: "required field is missing"},
+		{Field: "name", Message: "expected string, got number"},
+		{Field: "seen_at", Message: `expected RFC3339 timestamp, got "yesterday"`},
+		{Field: "another", Message: "field is not declared in the table schema"},
+		{Field: "extra", Message: "field is not declared in the table schema"},
+	}
+	if len(violations) != len(want) {
+		t.Fatalf("violations = %+v", violations)
+	}
+	for i := range want {
+		if violations[i] != want[i] {
+			t.Fatalf("violations[%d] = %+v, want %+v", i, violations[i], want[i])
+		}
+	}
+
+	if violations := schema.Validate(map[string]any{"id": nil}, false, false); len(violations) != 0 {
+		t.Fatalf("delete violations = %+v", violations)
+	}
+}
+
+func TestParseUnknownFieldPolicy(t *testing.T) {
+	for raw, want := range map[string]string{"": UnknownFieldsAllow, "allow": UnknownFieldsAllow, " REJECT ": UnknownFieldsReject} {
+		got, err := ParseUnknownFieldPolicy(raw)
+		if err != nil || got != want {
+			t.Fatalf("ParseUnknownFieldPolicy(%q) = %q, %v", raw, got, err)
+		}
+	}
+	if _, err := ParseUnknownFieldPolicy("drop"); err == nil {
+		t.Fatal("expected error for unsupported policy")
+	}
+}
@@ -1,6 +1,7 @@
 import type {
   APIError,
   ConnectionSettings,
+  QueryParams,
   QueryResponse,
   TranslateResponse,
   UISchemaResponse,
@@ -43,11 +44,15 @@ export async function fetchUISchema(settings: ConnectionSettings): Promise<UISch
   })
 }
 
-export async function runQuery(settings: ConnectionSettings, sql: string): Promise<QueryResponse> {
+export async function runQuery(
+  settings: ConnectionSettings,
+  sql: string,
+  params?: QueryParams,
+): Promise<QueryResponse> {
   return request<QueryResponse>(settings, '/v1/query', {
     method: 'POST',
     headers: jsonHeaders,
-    body: JSON.stringify({ sql, row_limit: 2000 }),
+    body: JSON.stringify({ sql, params, row_limit: 2000 }),
   })
 }
 
@@ -18,6 +18,10 @@ export type UISchemaResponse = {
   max_visibility_token?: number
 }
 
+// Named params as an object ($name) or positional params as an array ($1).
+// Timestamps, dates and decimals use { type, value } objects.
+export type QueryParams = Record<string, unknown> | unknown[]
+
 export type QueryResponse = {
   columns: string[]
   rows: unknown[][]
@@ -28,6 +32,8 @@ export type QueryResponse = {
     duration_ms?: number
     scanned_files?: number
     scanned_bytes?: number
+    pruned_files?: number
+    pruned_bytes?: number
   }
 }
 
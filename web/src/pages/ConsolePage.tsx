@@ -4,7 +4,7 @@ import { z } from 'zod'
 import { zodResolver } from '@hookform/resolvers/zod'
 import { useForm } from 'react-hook-form'
 import { fetchUISchema, runQuery, translateQuery } from '../lib/api'
-import type { ConnectionSettings, QueryResponse } from '../lib/types'
+import type { ConnectionSettings, QueryParams, QueryResponse } from '../lib/types'
 import { QueryEditor } from '../components/QueryEditor'
 import { ResultTable } from '../components/ResultTable'
 
@@ -25,6 +25,7 @@ const starterSQL = `SELECT table_name, COUNT(*) AS files\nFROM (\n  SELECT table
 
 export function ConsolePage({ settings, onSettingsChange }: Props) {
   const [sql, setSql] = useState(starterSQL)
+  const [paramsText, setParamsText] = useState('')
   const [result, setResult] = useState<QueryResponse | null>(null)
 
   const schemaQuery = useQuery({
@@ -33,7 +34,7 @@ export function ConsolePage({ settings, onSettingsChange }: Props) {
   })
 
   const executeMutation = useMutation({
-    mutationFn: (nextSQL: string) => runQuery(settings, nextSQL),
+    mutationFn: (nextSQL: string) => runQuery(settings, nextSQL, parseParams(paramsText)),
     onSuccess: (response) => setResult(response),
   })
 
@@ -108,6 +109,13 @@ export function ConsolePage({ settings, onSettingsChange }: Props) {
 
           <QueryEditor sql={sql} onChange={setSql} tables={schemaTables} />
 
+          <textarea
+            className="mt-3 min-h-16 w-full rounded-lg border border-slate-200 bg-white px-3 py-2 font-mono text-xs"
+            onChange={(event) => setParamsText(event.target.value)}
+            placeholder='Params as JSON, e.g. {"id": 42, "since": {"type": "timestamp", "value": "2026-02-19T00:00:00Z"}} or [42]'
+            value={paramsText}
+          />
+
           <div className="mt-4">
             <ResultTable columns={result?.columns ?? []} rows={result?.rows ?? []} />
           </div>
@@ -162,3 +170,19 @@ export function ConsolePage({ settings, onSettingsChange }: Props) {
     </div>
   )
 }
+
+function parseParams(text: string): QueryParams | undefined {
+  if (!text.trim()) {
+    return undefined
+  }
+  let parsed: unknown
+  try {
+    parsed = JSON.parse(text)
+  } catch {
+    throw new Error('Params must be valid JSON')
+  }
+  if (parsed === null || typeof parsed !== 'object') {
+    throw new Error('Params must be a JSON object or array')
+  }
+  return parsed as QueryParams
+}